@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the agent configuration: defaults, overridden by the YAML file given with
+// -config, overridden by the flags given on the command line. Settings are applied again
+// when the file is reloaded on SIGHUP; changes to anything else need a restart.
+type Config struct {
+	ConfigFile string `yaml:"-"`
+
+	Name        string   `yaml:"name"`
+	Port        string   `yaml:"port"`
+	Tags        []string `yaml:"tags"`
+	Description string   `yaml:"description"`
+
+	CgroupRoot   string   `yaml:"cgroup_root"`
+	DockerSocket string   `yaml:"docker_socket"`
+	ProcRoot     string   `yaml:"proc_root"`
+	PluginDir    string   `yaml:"plugin_dir"`
+	LogPositions string   `yaml:"log_positions"`
+	LogRoots     []string `yaml:"log_roots"`
+
+	Server ServerConfig `yaml:"server"`
+	Queue  QueueConfig  `yaml:"queue"`
+
+	Settings domain.AgentSettings `yaml:"settings"`
+}
+
+// ServerConfig configures the connection to the monitoring server
+type ServerConfig struct {
+	URL               string        `yaml:"url"`
+	AgentID           string        `yaml:"agent_id"`
+	IDFile            string        `yaml:"id_file"`
+	Protocol          string        `yaml:"protocol"`
+	Advertise         string        `yaml:"advertise"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	ConfigInterval    time.Duration `yaml:"config_interval"`
+	PushBatch         int           `yaml:"push_batch"`
+	PushEncoding      string        `yaml:"push_encoding"`
+	PushCompression   string        `yaml:"push_compression"`
+}
+
+// QueueConfig configures the on-disk queue of samples not yet delivered
+type QueueConfig struct {
+	Dir      string        `yaml:"dir"`
+	MaxBytes int64         `yaml:"max_bytes"`
+	MaxAge   time.Duration `yaml:"max_age"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		Port:         "9090",
+		CgroupRoot:   "/sys/fs/cgroup",
+		DockerSocket: "/var/run/docker.sock",
+		ProcRoot:     "/proc",
+		LogPositions: "./data/log-positions.json",
+		Server: ServerConfig{
+			IDFile:            "./data/agent-id",
+			Protocol:          protocolAuto,
+			HeartbeatInterval: 30 * time.Second,
+			ConfigInterval:    60 * time.Second,
+			PushBatch:         100,
+			PushEncoding:      encodingJSON,
+			PushCompression:   compression.Gzip,
+		},
+		Queue: QueueConfig{
+			Dir:      "./data/agent-queue",
+			MaxBytes: 64 << 20,
+			MaxAge:   24 * time.Hour,
+		},
+		Settings: domain.DefaultAgentSettings(),
+	}
+}
+
+// newFlagSet defines the command line flags, bound to the fields of cfg
+func newFlagSet(cfg *Config, errorHandling flag.ErrorHandling) *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], errorHandling)
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "YAML config file, reloaded on SIGHUP (flags given on the command line take precedence)")
+	fs.StringVar(&cfg.Port, "port", cfg.Port, "Agent server port")
+	fs.StringVar(&cfg.Name, "name", cfg.Name, "Agent name (required)")
+	fs.Var((*listFlag)(&cfg.Tags), "tags", "Comma-separated tags sent at registration")
+	fs.StringVar(&cfg.Description, "description", cfg.Description, "Description sent at registration")
+	fs.StringVar(&cfg.CgroupRoot, "cgroup-root", cfg.CgroupRoot, "Cgroup filesystem root for container metrics (empty to disable)")
+	fs.StringVar(&cfg.DockerSocket, "docker-socket", cfg.DockerSocket, "Docker socket used to resolve container names")
+	fs.StringVar(&cfg.ProcRoot, "proc-root", cfg.ProcRoot, "Procfs root for pressure, meminfo and vmstat metrics (empty to disable)")
+	fs.StringVar(&cfg.PluginDir, "plugin-dir", cfg.PluginDir, "Directory of executable plugins reported under the custom section")
+	fs.StringVar(&cfg.LogPositions, "log-positions", cfg.LogPositions, "File keeping how far the tailed logs were shipped")
+	fs.Var((*listFlag)(&cfg.LogRoots), "log-roots", "Comma-separated directories under which the server-managed config may name log files")
+	fs.DurationVar((*time.Duration)(&cfg.Settings.PluginInterval), "plugin-interval", time.Duration(cfg.Settings.PluginInterval), "How often to run plugins")
+	fs.DurationVar((*time.Duration)(&cfg.Settings.PluginTimeout), "plugin-timeout", time.Duration(cfg.Settings.PluginTimeout), "Maximum run time of a single plugin")
+	fs.Var((*listFlag)(&cfg.Settings.Services), "services", "Comma-separated systemd units to monitor (e.g. nginx.service,postgresql.service)")
+	fs.StringVar(&cfg.Server.URL, "server", cfg.Server.URL, "Monitoring server URL to push metrics to (e.g. http://monitor:8080, empty to disable)")
+	fs.StringVar(&cfg.Server.AgentID, "agent-id", cfg.Server.AgentID, "Agent ID assigned by the server at registration (default: read from -id-file, or register)")
+	fs.StringVar(&cfg.Server.IDFile, "id-file", cfg.Server.IDFile, "File keeping the agent ID assigned at registration")
+	fs.StringVar(&cfg.Server.Protocol, "protocol", cfg.Server.Protocol, "Protocol to talk to the server: auto, grpc or http")
+	fs.StringVar(&cfg.Server.Advertise, "advertise", cfg.Server.Advertise, "Address the server reaches this agent's HTTP endpoints on (default: :port, on the agent's IP as seen by the server)")
+	fs.DurationVar(&cfg.Server.HeartbeatInterval, "heartbeat-interval", cfg.Server.HeartbeatInterval, "How often to send heartbeats")
+	fs.DurationVar(&cfg.Server.ConfigInterval, "config-interval", cfg.Server.ConfigInterval, "How often to fetch the server-managed config")
+	fs.DurationVar((*time.Duration)(&cfg.Settings.CollectInterval), "push-interval", time.Duration(cfg.Settings.CollectInterval), "How often to collect and push metrics")
+	fs.IntVar(&cfg.Server.PushBatch, "push-batch", cfg.Server.PushBatch, "Maximum number of queued samples sent per request")
+	fs.StringVar(&cfg.Server.PushEncoding, "push-encoding", cfg.Server.PushEncoding, "Encoding of pushed samples: json or protobuf")
+	fs.StringVar(&cfg.Server.PushCompression, "push-compression", cfg.Server.PushCompression, "Compression of push requests: gzip, zstd or none")
+	fs.StringVar(&cfg.Queue.Dir, "queue-dir", cfg.Queue.Dir, "Directory of the on-disk queue holding samples not yet delivered")
+	fs.Int64Var(&cfg.Queue.MaxBytes, "queue-max-bytes", cfg.Queue.MaxBytes, "Maximum size of the queue; the oldest samples are dropped beyond it")
+	fs.DurationVar(&cfg.Queue.MaxAge, "queue-max-age", cfg.Queue.MaxAge, "Queued samples older than this are dropped instead of replayed")
+	return fs
+}
+
+// loadConfig builds the configuration from the command line arguments and the config file
+// they name. The flags are parsed again over the file's values so that they take precedence.
+func loadConfig(args []string) (*Config, error) {
+	cfg := defaultConfig()
+	if err := newFlagSet(&cfg, flag.ExitOnError).Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.ConfigFile != "" {
+		data, err := os.ReadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+
+		fromFile := defaultConfig()
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&fromFile); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", cfg.ConfigFile, err)
+		}
+
+		fs := newFlagSet(&fromFile, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		cfg = fromFile
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Name == "" {
+		return errors.New("agent name is required (use -name flag or name in the config file)")
+	}
+	if err := c.Settings.Validate(); err != nil {
+		return err
+	}
+	for _, root := range c.LogRoots {
+		if !filepath.IsAbs(root) || strings.ContainsAny(root, "*?[") {
+			return fmt.Errorf("log root %q must be an absolute directory", root)
+		}
+	}
+	if c.Server.URL == "" {
+		return nil
+	}
+
+	switch c.Server.Protocol {
+	case protocolAuto, protocolGRPC, protocolHTTP:
+	default:
+		return fmt.Errorf("unknown protocol %q (use auto, grpc or http)", c.Server.Protocol)
+	}
+	if c.Server.PushBatch <= 0 {
+		return errors.New("push batch size must be positive")
+	}
+	if c.Server.PushEncoding != encodingJSON && c.Server.PushEncoding != encodingProtobuf {
+		return fmt.Errorf("unknown push encoding %q (use json or protobuf)", c.Server.PushEncoding)
+	}
+	if _, err := compression.NewWriter(c.pushCoding(), io.Discard); err != nil {
+		return fmt.Errorf("unknown push compression %q (use gzip, zstd or none)", c.Server.PushCompression)
+	}
+	if c.Server.HeartbeatInterval <= 0 || c.Server.ConfigInterval <= 0 {
+		return errors.New("heartbeat and config intervals must be positive")
+	}
+	return nil
+}
+
+// pushCoding returns the content coding of push requests, "" for none
+func (c *Config) pushCoding() string {
+	if c.Server.PushCompression == "none" {
+		return ""
+	}
+	return c.Server.PushCompression
+}
+
+// restartRequired reports whether next differs from c outside the settings
+func (c *Config) restartRequired(next *Config) bool {
+	a, b := *c, *next
+	a.Settings, b.Settings = domain.AgentSettings{}, domain.AgentSettings{}
+	return !reflect.DeepEqual(a, b)
+}
+
+// listFlag is a comma-separated list flag
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = splitList(value)
+	return nil
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// containerIDPattern matches the 64 hex character IDs used by docker, containerd, cri-o and podman
+var containerIDPattern = regexp.MustCompile(`(?:^|[-/])(?:docker-|cri-containerd-|crio-|libpod-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+// ContainerCollector reads per-container resource usage from the cgroup filesystem.
+// The root directory is configurable so the collector can run against a fake tree.
+type ContainerCollector struct {
+	root         string
+	dockerSocket string
+
+	mu       sync.Mutex
+	previous map[string]cpuSample
+}
+
+type cpuSample struct {
+	usage uint64
+	at    time.Time
+}
+
+// containerInfo is the metadata a container runtime knows about a container
+type containerInfo struct {
+	Name  string
+	Image string
+}
+
+func NewContainerCollector(root, dockerSocket string) *ContainerCollector {
+	return &ContainerCollector{
+		root:         root,
+		dockerSocket: dockerSocket,
+		previous:     make(map[string]cpuSample),
+	}
+}
+
+// Collect returns metrics for every container found under the cgroup root
+func (c *ContainerCollector) Collect() ([]domain.ContainerMetrics, error) {
+	if _, err := os.Stat(c.root); err != nil {
+		return nil, err
+	}
+
+	var containers []domain.ContainerMetrics
+	var err error
+	if c.isUnified() {
+		containers, err = c.collectV2()
+	} else {
+		containers, err = c.collectV1()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := c.runtimeInfo()
+	now := time.Now()
+
+	c.mu.Lock()
+	seen := make(map[string]bool, len(containers))
+	for i := range containers {
+		ct := &containers[i]
+		seen[ct.ID] = true
+
+		if prev, ok := c.previous[ct.ID]; ok && ct.CPU.UsageNanos >= prev.usage {
+			elapsed := now.Sub(prev.at).Nanoseconds()
+			if elapsed > 0 {
+				ct.CPU.UsagePercent = float64(ct.CPU.UsageNanos-prev.usage) / float64(elapsed) * 100
+			}
+		}
+		c.previous[ct.ID] = cpuSample{usage: ct.CPU.UsageNanos, at: now}
+
+		if ct.Memory.Limit > 0 {
+			ct.Memory.UsedPercent = float64(ct.Memory.Usage) / float64(ct.Memory.Limit) * 100
+		}
+
+		if meta, ok := info[ct.ID]; ok {
+			ct.Name = meta.Name
+			ct.Image = meta.Image
+		}
+	}
+	for id := range c.previous {
+		if !seen[id] {
+			delete(c.previous, id)
+		}
+	}
+	c.mu.Unlock()
+
+	return containers, nil
+}
+
+// isUnified reports whether the root is a cgroup v2 unified hierarchy
+func (c *ContainerCollector) isUnified() bool {
+	_, err := os.Stat(filepath.Join(c.root, "cgroup.controllers"))
+	return err == nil
+}
+
+// findContainers walks dir and returns the cgroup directories that belong to containers, keyed by container ID
+func findContainers(dir string) map[string]string {
+	found := make(map[string]string)
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == dir {
+			return nil
+		}
+		m := containerIDPattern.FindStringSubmatch(d.Name())
+		if m == nil {
+			return nil
+		}
+		// The first match wins; nested cgroups inside a container are part of it
+		if _, ok := found[m[1]]; !ok {
+			found[m[1]] = path
+		}
+		return filepath.SkipDir
+	})
+	return found
+}
+
+func (c *ContainerCollector) collectV2() ([]domain.ContainerMetrics, error) {
+	var containers []domain.ContainerMetrics
+	for id, dir := range findContainers(c.root) {
+		rel, _ := filepath.Rel(c.root, dir)
+		ct := domain.ContainerMetrics{
+			ID:            id,
+			Runtime:       runtimeFromPath(rel),
+			CgroupPath:    "/" + rel,
+			CgroupVersion: 2,
+		}
+
+		cpuStat := readKeyValues(filepath.Join(dir, "cpu.stat"))
+		ct.CPU.UsageNanos = cpuStat["usage_usec"] * 1000
+		ct.CPU.Periods = cpuStat["nr_periods"]
+		ct.CPU.ThrottledPeriods = cpuStat["nr_throttled"]
+		ct.CPU.ThrottledNanos = cpuStat["throttled_usec"] * 1000
+
+		ct.Memory.Usage = readUint(filepath.Join(dir, "memory.current"))
+		ct.Memory.Limit = readUint(filepath.Join(dir, "memory.max"))
+		ct.Memory.OOMKills = readKeyValues(filepath.Join(dir, "memory.events"))["oom_kill"]
+
+		ct.BlockIO = readIOStatV2(filepath.Join(dir, "io.stat"))
+
+		ct.PIDs.Current = readUint(filepath.Join(dir, "pids.current"))
+		ct.PIDs.Limit = readUint(filepath.Join(dir, "pids.max"))
+
+		containers = append(containers, ct)
+	}
+	return containers, nil
+}
+
+func (c *ContainerCollector) collectV1() ([]domain.ContainerMetrics, error) {
+	memRoot := filepath.Join(c.root, "memory")
+	if _, err := os.Stat(memRoot); err != nil {
+		return nil, err
+	}
+
+	controller := func(names ...string) string {
+		for _, name := range names {
+			dir := filepath.Join(c.root, name)
+			if _, err := os.Stat(dir); err == nil {
+				return dir
+			}
+		}
+		return ""
+	}
+	cpuRoot := controller("cpu,cpuacct", "cpuacct,cpu", "cpu")
+	cpuacctRoot := controller("cpu,cpuacct", "cpuacct,cpu", "cpuacct")
+	blkioRoot := controller("blkio")
+	pidsRoot := controller("pids")
+
+	var containers []domain.ContainerMetrics
+	for id, dir := range findContainers(memRoot) {
+		rel, _ := filepath.Rel(memRoot, dir)
+		ct := domain.ContainerMetrics{
+			ID:            id,
+			Runtime:       runtimeFromPath(rel),
+			CgroupPath:    "/" + rel,
+			CgroupVersion: 1,
+		}
+
+		if cpuacctRoot != "" {
+			ct.CPU.UsageNanos = readUint(filepath.Join(cpuacctRoot, rel, "cpuacct.usage"))
+		}
+		if cpuRoot != "" {
+			cpuStat := readKeyValues(filepath.Join(cpuRoot, rel, "cpu.stat"))
+			ct.CPU.Periods = cpuStat["nr_periods"]
+			ct.CPU.ThrottledPeriods = cpuStat["nr_throttled"]
+			ct.CPU.ThrottledNanos = cpuStat["throttled_time"]
+		}
+
+		ct.Memory.Usage = readUint(filepath.Join(dir, "memory.usage_in_bytes"))
+		// v1 reports "unlimited" as a page-aligned int64 max
+		if limit := readUint(filepath.Join(dir, "memory.limit_in_bytes")); limit < 1<<62 {
+			ct.Memory.Limit = limit
+		}
+		ct.Memory.OOMKills = readKeyValues(filepath.Join(dir, "memory.oom_control"))["oom_kill"]
+
+		if blkioRoot != "" {
+			bytesStat := readBlkioV1(filepath.Join(blkioRoot, rel, "blkio.throttle.io_service_bytes"))
+			opsStat := readBlkioV1(filepath.Join(blkioRoot, rel, "blkio.throttle.io_serviced"))
+			ct.BlockIO = domain.ContainerBlkIOMetrics{
+				ReadBytes:  bytesStat["Read"],
+				WriteBytes: bytesStat["Write"],
+				ReadOps:    opsStat["Read"],
+				WriteOps:   opsStat["Write"],
+			}
+		}
+
+		if pidsRoot != "" {
+			ct.PIDs.Current = readUint(filepath.Join(pidsRoot, rel, "pids.current"))
+			ct.PIDs.Limit = readUint(filepath.Join(pidsRoot, rel, "pids.max"))
+		}
+
+		containers = append(containers, ct)
+	}
+	return containers, nil
+}
+
+// runtimeInfo asks the docker daemon for container names and images.
+// A missing socket is not an error, containers are then reported by ID only.
+func (c *ContainerCollector) runtimeInfo() map[string]containerInfo {
+	info := make(map[string]containerInfo)
+	if c.dockerSocket == "" {
+		return info
+	}
+	if _, err := os.Stat(c.dockerSocket); err != nil {
+		return info
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", c.dockerSocket)
+			},
+		},
+	}
+	resp, err := client.Get("http://docker/containers/json")
+	if err != nil {
+		return info
+	}
+	defer resp.Body.Close()
+
+	var list []struct {
+		ID    string   `json:"Id"`
+		Names []string `json:"Names"`
+		Image string   `json:"Image"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return info
+	}
+	for _, ct := range list {
+		var name string
+		if len(ct.Names) > 0 {
+			name = strings.TrimPrefix(ct.Names[0], "/")
+		}
+		info[ct.ID] = containerInfo{Name: name, Image: ct.Image}
+	}
+	return info
+}
+
+func runtimeFromPath(path string) string {
+	switch {
+	case strings.Contains(path, "docker"):
+		return "docker"
+	case strings.Contains(path, "containerd"):
+		return "containerd"
+	case strings.Contains(path, "crio"):
+		return "crio"
+	case strings.Contains(path, "libpod"):
+		return "podman"
+	}
+	return ""
+}
+
+// readUint reads a single integer file; "max" and missing files read as 0
+func readUint(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	v, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return v
+}
+
+// readKeyValues parses "key value" lines such as cpu.stat and memory.events
+func readKeyValues(path string) map[string]uint64 {
+	values := make(map[string]uint64)
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[fields[0]] = v
+		}
+	}
+	return values
+}
+
+// readIOStatV2 sums io.stat lines of the form "8:0 rbytes=1 wbytes=2 rios=3 wios=4 ..."
+func readIOStatV2(path string) domain.ContainerBlkIOMetrics {
+	var stats domain.ContainerBlkIOMetrics
+	f, err := os.Open(path)
+	if err != nil {
+		return stats
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for _, field := range fields[min(1, len(fields)):] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "rbytes":
+				stats.ReadBytes += v
+			case "wbytes":
+				stats.WriteBytes += v
+			case "rios":
+				stats.ReadOps += v
+			case "wios":
+				stats.WriteOps += v
+			}
+		}
+	}
+	return stats
+}
+
+// readBlkioV1 sums blkio lines of the form "8:0 Read 1234" across devices, keyed by operation
+func readBlkioV1(path string) map[string]uint64 {
+	values := make(map[string]uint64)
+	f, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[2], 10, 64); err == nil {
+			values[fields[1]] += v
+		}
+	}
+	return values
+}
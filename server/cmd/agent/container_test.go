@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Containers of the fake cgroup trees in testdata
+const (
+	dockerID     = "3f4e9c1a7b2d8e6f0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f6071"
+	containerdID = "9a8b7c6d5e4f30211f2e3d4c5b6a79880f1e2d3c4b5a69788796a5b4c3d2e1f0"
+	crioID       = "c0ffee0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+
+	podSlice = "/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod6f1d2c3b_4a5e_4f60_8a7b_9c0d1e2f3a4b.slice"
+)
+
+func TestContainerCollector(t *testing.T) {
+	tests := []struct {
+		root string
+		want []domain.ContainerMetrics
+	}{
+		{
+			root: "testdata/cgroup-v2",
+			want: []domain.ContainerMetrics{
+				{
+					ID:            dockerID,
+					Runtime:       "docker",
+					CgroupPath:    "/system.slice/docker-" + dockerID + ".scope",
+					CgroupVersion: 2,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 5e9, Periods: 100, ThrottledPeriods: 5, ThrottledNanos: 2e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 100 << 20, Limit: 200 << 20, UsedPercent: 50, OOMKills: 1},
+					BlockIO:       domain.ContainerBlkIOMetrics{ReadBytes: 1500, WriteBytes: 2000, ReadOps: 15, WriteOps: 20},
+					PIDs:          domain.ContainerPIDMetrics{Current: 12, Limit: 100},
+				},
+				{
+					ID:            containerdID,
+					Runtime:       "containerd",
+					CgroupPath:    podSlice + "/cri-containerd-" + containerdID + ".scope",
+					CgroupVersion: 2,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 250e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 50 << 20}, // memory.max is "max"
+					PIDs:          domain.ContainerPIDMetrics{Current: 3},      // pids.max is "max"
+				},
+				{
+					ID:            crioID,
+					Runtime:       "crio",
+					CgroupPath:    podSlice + "/crio-" + crioID + ".scope",
+					CgroupVersion: 2,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 1e6, Periods: 10, ThrottledPeriods: 10, ThrottledNanos: 900e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 8 << 20, Limit: 16 << 20, UsedPercent: 50, OOMKills: 4},
+					BlockIO:       domain.ContainerBlkIOMetrics{ReadBytes: 4096, WriteBytes: 8192, ReadOps: 1, WriteOps: 2},
+					PIDs:          domain.ContainerPIDMetrics{Current: 1, Limit: 32},
+				},
+			},
+		},
+		{
+			root: "testdata/cgroup-v1",
+			want: []domain.ContainerMetrics{
+				{
+					ID:            dockerID,
+					Runtime:       "docker",
+					CgroupPath:    "/docker/" + dockerID,
+					CgroupVersion: 1,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 5e9, Periods: 100, ThrottledPeriods: 5, ThrottledNanos: 2e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 100 << 20, Limit: 200 << 20, UsedPercent: 50, OOMKills: 2},
+					BlockIO:       domain.ContainerBlkIOMetrics{ReadBytes: 1500, WriteBytes: 2000, ReadOps: 15, WriteOps: 20},
+					PIDs:          domain.ContainerPIDMetrics{Current: 12, Limit: 100},
+				},
+				{
+					ID:            containerdID,
+					Runtime:       "containerd",
+					CgroupPath:    podSlice + "/cri-containerd-" + containerdID + ".scope",
+					CgroupVersion: 1,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 250e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 50 << 20}, // unlimited
+					PIDs:          domain.ContainerPIDMetrics{Current: 3},
+				},
+				{
+					ID:            crioID,
+					Runtime:       "crio",
+					CgroupPath:    podSlice + "/crio-" + crioID + ".scope",
+					CgroupVersion: 1,
+					CPU:           domain.ContainerCPUMetrics{UsageNanos: 1e6, Periods: 10, ThrottledPeriods: 10, ThrottledNanos: 900e6},
+					Memory:        domain.ContainerMemMetrics{Usage: 8 << 20, Limit: 16 << 20, UsedPercent: 50, OOMKills: 4},
+					BlockIO:       domain.ContainerBlkIOMetrics{ReadBytes: 4096, WriteBytes: 8192, ReadOps: 1, WriteOps: 2},
+					PIDs:          domain.ContainerPIDMetrics{Current: 1, Limit: 32},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.root), func(t *testing.T) {
+			containers, err := NewContainerCollector(tt.root, "").Collect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]domain.ContainerMetrics, len(containers))
+			for _, ct := range containers {
+				got[ct.ID] = ct
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Collect() found %d containers, want %d", len(got), len(tt.want))
+			}
+			for _, want := range tt.want {
+				if ct := got[want.ID]; !reflect.DeepEqual(ct, want) {
+					t.Errorf("container %s:\n got %+v\nwant %+v", want.Runtime, ct, want)
+				}
+			}
+		})
+	}
+}
+
+func TestContainerCollectorCPUPercent(t *testing.T) {
+	root := t.TempDir()
+	if err := os.CopyFS(root, os.DirFS("testdata/cgroup-v2")); err != nil {
+		t.Fatal(err)
+	}
+	collector := NewContainerCollector(root, "")
+
+	first := time.Now()
+	containers, err := collector.Collect()
+	afterFirst := time.Now()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ct := range containers {
+		if ct.CPU.UsagePercent != 0 {
+			t.Fatalf("first Collect() reports %g%% CPU for %s, want 0", ct.CPU.UsagePercent, ct.Runtime)
+		}
+	}
+
+	// 50ms of CPU time over at least 100ms
+	cpuStat := filepath.Join(root, "system.slice", "docker-"+dockerID+".scope", "cpu.stat")
+	if err := os.WriteFile(cpuStat, []byte("usage_usec 5050000\nnr_periods 100\nnr_throttled 5\nthrottled_usec 2000\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	second := time.Now()
+	containers, err = collector.Collect()
+	afterSecond := time.Now()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used := 50 * time.Millisecond
+	low := float64(used) / float64(afterSecond.Sub(first)) * 100
+	high := float64(used) / float64(second.Sub(afterFirst)) * 100
+	for _, ct := range containers {
+		switch ct.ID {
+		case dockerID:
+			if ct.CPU.UsagePercent < low || ct.CPU.UsagePercent > high {
+				t.Errorf("docker CPU = %g%%, want between %g%% and %g%%", ct.CPU.UsagePercent, low, high)
+			}
+		default:
+			if ct.CPU.UsagePercent != 0 {
+				t.Errorf("%s CPU = %g%%, want 0 for an unchanged usage", ct.Runtime, ct.CPU.UsagePercent)
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Sample encodings for pushed metrics
+const (
+	encodingJSON     = "json"
+	encodingProtobuf = "protobuf"
+)
+
+// acceptsProtobuf reports whether an Accept header lists the protobuf media type
+func acceptsProtobuf(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err == nil && mediaType == protostruct.ContentType && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeBody writes a response body, compressed with the best coding the client accepts
+func writeBody(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept, Accept-Encoding")
+
+	if coding := compression.Negotiate(r.Header.Get("Accept-Encoding")); coding != "" {
+		compressed, err := compression.Compress(coding, body)
+		if err != nil {
+			log.Printf("Failed to compress response: %v", err)
+		} else {
+			w.Header().Set("Content-Encoding", coding)
+			body = compressed
+		}
+	}
+	w.Write(body)
+}
+
+// encodeSample encodes a queued sample in the push encoding
+func encodeSample(encoding string, sample *domain.AgentMetrics) ([]byte, error) {
+	if encoding == encodingProtobuf {
+		return protostruct.Marshal(sample)
+	}
+	return json.Marshal(sample)
+}
+
+// sampleEncoding detects how a queued sample was encoded; JSON objects start with '{'
+// while an encoded AgentMetrics starts with the tag of one of its fields
+func sampleEncoding(payload []byte) string {
+	if len(payload) > 0 && payload[0] == '{' {
+		return encodingJSON
+	}
+	return encodingProtobuf
+}
+
+// decodeSample decodes a queued sample in whichever encoding it was recorded
+func decodeSample(payload []byte) (*domain.AgentMetrics, error) {
+	var sample domain.AgentMetrics
+	var err error
+	if sampleEncoding(payload) == encodingJSON {
+		err = json.Unmarshal(payload, &sample)
+	} else {
+		err = protostruct.Unmarshal(payload, &sample)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sample, nil
+}
+
+// encodeBatch joins queued samples into one request body in the push encoding, re-encoding
+// samples queued in another encoding before the agent was restarted with a different one
+func encodeBatch(encoding string, records []WALRecord) []byte {
+	var body []byte
+	if encoding == encodingJSON {
+		body = append(body, '[')
+	}
+
+	written := 0
+	for _, record := range records {
+		payload := record.Payload
+		if sampleEncoding(payload) != encoding {
+			sample, err := decodeSample(payload)
+			if err == nil {
+				payload, err = encodeSample(encoding, sample)
+			}
+			if err != nil {
+				log.Printf("Dropping queued sample recorded at %s: %v", record.At.Format(time.RFC3339), err)
+				continue
+			}
+		}
+
+		if encoding == encodingJSON {
+			if written > 0 {
+				body = append(body, ',')
+			}
+			body = append(body, payload...)
+		} else {
+			// AgentMetricsBatch: every sample is an occurrence of field 1
+			body = protowire.AppendTag(body, 1, protowire.BytesType)
+			body = protowire.AppendBytes(body, payload)
+		}
+		written++
+	}
+
+	if encoding == encodingJSON {
+		body = append(body, ']')
+	}
+	return body
+}
+
+// contentType returns the media type of a push encoding
+func contentType(encoding string) string {
+	if encoding == encodingProtobuf {
+		return protostruct.ContentType
+	}
+	return "application/json"
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/agentpb"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/probe"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// dialGRPC creates a connection to the server's gRPC agent service; it connects lazily
+// and reconnects on its own after the server goes away
+func dialGRPC(addr string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(agentpb.Codec{})),
+	)
+}
+
+// agentContext adds the metadata identifying the agent on streams
+func agentContext(ctx context.Context, agentID string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, agentpb.MetadataAgentID, agentID)
+}
+
+// grpcSender delivers batches over a long-lived StreamMetrics stream, reopened after errors
+type grpcSender struct {
+	client  *agentpb.Client
+	addr    string
+	agentID string
+
+	stream grpc.BidiStreamingClient[domain.AgentMetrics, agentpb.MetricsAck]
+	cancel context.CancelFunc
+}
+
+func newGRPCSender(client *agentpb.Client, addr, agentID string) *grpcSender {
+	return &grpcSender{
+		client:  client,
+		addr:    addr,
+		agentID: agentID,
+	}
+}
+
+func (s *grpcSender) String() string { return "grpc://" + s.addr }
+
+// send streams the samples of a batch and waits for the acknowledgement of each of them.
+// Samples the server already stored are acknowledged as duplicates, so a batch interrupted
+// by a broken stream is safely resent whole.
+func (s *grpcSender) send(records []WALRecord) (bool, error) {
+	if s.stream == nil {
+		ctx, cancel := context.WithCancel(agentContext(context.Background(), s.agentID))
+		stream, err := s.client.StreamMetrics(ctx)
+		if err != nil {
+			cancel()
+			return true, err
+		}
+		s.stream, s.cancel = stream, cancel
+	}
+
+	sent := 0
+	for _, record := range records {
+		sample, err := decodeSample(record.Payload)
+		if err != nil {
+			log.Printf("Dropping queued sample recorded at %s: %v", record.At.Format(time.RFC3339), err)
+			continue
+		}
+		sample.AgentID = s.agentID
+		if err := s.stream.Send(sample); err != nil {
+			s.close()
+			return true, err
+		}
+		sent++
+	}
+
+	for i := 0; i < sent; i++ {
+		if _, err := s.stream.Recv(); err != nil {
+			s.close()
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// close ends the current stream; the next batch opens a new one
+func (s *grpcSender) close() {
+	if s.stream != nil {
+		s.cancel()
+		s.stream, s.cancel = nil, nil
+	}
+}
+
+// serveCommands keeps the command channel open, reopening it after errors, until ctx is done
+func (u *Uplink) serveCommands(ctx context.Context, client *agentpb.Client, agentID string) {
+	defer u.wg.Done()
+
+	open := false
+	for {
+		err := u.commandChannel(ctx, client, agentID, func() {
+			if !open {
+				log.Printf("Command channel open")
+				open = true
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		if open {
+			log.Printf("Command channel closed, reconnecting: %v", err)
+			open = false
+		}
+
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// commandChannel runs commands received on one Commands stream and returns when it breaks
+func (u *Uplink) commandChannel(ctx context.Context, client *agentpb.Client, agentID string, opened func()) error {
+	stream, err := client.Commands(agentContext(ctx, agentID))
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Header(); err != nil {
+		return err
+	}
+	opened()
+
+	// Commands run concurrently so a slow probe does not hold up the others; replies
+	// are matched to their command by ID and only their sends are serialized
+	var sendMu sync.Mutex
+	for {
+		command, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		go func() {
+			result := u.execute(command)
+			sendMu.Lock()
+			defer sendMu.Unlock()
+			if err := stream.Send(&result); err != nil {
+				log.Printf("Failed to reply to command %s: %v", command.ID, err)
+			}
+		}()
+	}
+}
+
+// execute runs a server command
+func (u *Uplink) execute(command *domain.AgentCommand) domain.AgentCommandResult {
+	result := domain.AgentCommandResult{CommandID: command.ID}
+
+	switch command.Name {
+	case domain.AgentCommandPing:
+		result.Output = "pong"
+	case domain.AgentCommandCollect:
+		u.pusher.Trigger()
+		result.Output = "sample collection triggered"
+	case domain.AgentCommandInfo:
+		info, err := json.Marshal(u.agent.Info())
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Output = string(info)
+	case domain.AgentCommandReloadConfig:
+		u.ReloadConfig()
+		result.Output = "config reload triggered"
+	case domain.AgentCommandProbe:
+		var spec domain.CheckSpec
+		if err := json.Unmarshal([]byte(command.Args["check"]), &spec); err != nil {
+			result.Error = fmt.Sprintf("invalid check: %v", err)
+			break
+		}
+		probeResult, err := json.Marshal(probe.Run(context.Background(), spec))
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Output = string(probeResult)
+	case domain.AgentCommandTraceroute:
+		req, err := tracerouteRequest(command.Args)
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		route, err := probe.Traceroute(context.Background(), req)
+		if err != nil {
+			result.Error = err.Error()
+			break
+		}
+		output, err := json.Marshal(route)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Output = string(output)
+	default:
+		result.Error = fmt.Sprintf("unknown command %q", command.Name)
+	}
+
+	log.Printf("Command %s (%s) from server", command.Name, command.ID)
+	result.Success = result.Error == ""
+	result.CompletedAt = time.Now()
+	return result
+}
+
+// tracerouteRequest reads a traceroute request from command arguments
+func tracerouteRequest(args map[string]string) (domain.TracerouteRequest, error) {
+	req := domain.TracerouteRequest{Target: args["target"]}
+	for name, value := range map[string]*int{"max_hops": &req.MaxHops, "probes": &req.Probes, "timeout_ms": &req.TimeoutMs} {
+		if args[name] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(args[name])
+		if err != nil || n <= 0 {
+			return req, fmt.Errorf("invalid %s %q", name, args[name])
+		}
+		*value = n
+	}
+	return req, validator.Validate(&req)
+}
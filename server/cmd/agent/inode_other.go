@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// fileInode returns 0 where inodes are not available; rotation is then only noticed as
+// truncation
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
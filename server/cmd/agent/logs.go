@@ -0,0 +1,681 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+const (
+	// maxPendingLogLines bounds the lines read but not yet shipped; the files and the journal
+	// are read no further until the server takes some
+	maxPendingLogLines = 10000
+	// logScanInterval is how often the file patterns are expanded and the files read
+	logScanInterval = time.Second
+	// journalRetry is how long to wait before running journalctl again after it exits
+	journalRetry = 10 * time.Second
+)
+
+// journalLevels names the syslog priorities of journal entries
+var journalLevels = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// logPositions is how far each file and the journal were shipped, saved in the positions
+// file so a restarted agent resumes where it stopped
+type logPositions struct {
+	Files   map[string]filePosition `json:"files"`
+	Journal string                  `json:"journal,omitempty"` // cursor of the last entry shipped
+}
+
+// filePosition is the offset in a file after the last line shipped; the inode tells whether
+// the file at the path is still the same
+type filePosition struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// pendingLine is a line read but not yet shipped, with the position reached once it is
+type pendingLine struct {
+	line     domain.LogLine
+	file     string // path of the file, "" for the journal
+	position filePosition
+}
+
+// tailedFile is an open file being followed
+type tailedFile struct {
+	path    string
+	file    *os.File
+	inode   uint64
+	offset  int64  // of the next byte to read
+	partial []byte // start of a line not yet ended
+	// skipping drops the rest of a line cut at max_line_bytes
+	skipping bool
+}
+
+// LogCollector tails log files and the systemd journal and queues their lines for shipping.
+// Rotated files are read to their end before the new file is followed, truncated files are
+// read again from their start, and the positions of the lines shipped are checkpointed.
+type LogCollector struct {
+	positionsFile string
+	// roots are the directories under which a server-managed config may name log files
+	roots []string
+
+	mu        sync.Mutex
+	settings  domain.LogSettings
+	pending   []pendingLine
+	positions logPositions
+	// journalCursor is the cursor of the last journal entry read
+	journalCursor string
+
+	room           chan struct{} // signalled when shipped lines free room in pending
+	filesChanged   chan struct{}
+	journalChanged chan struct{}
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
+}
+
+func NewLogCollector(settings domain.LogSettings, positionsFile string, roots []string) *LogCollector {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &LogCollector{
+		positionsFile:  positionsFile,
+		roots:          roots,
+		settings:       settings,
+		positions:      logPositions{Files: make(map[string]filePosition)},
+		room:           make(chan struct{}, 1),
+		filesChanged:   make(chan struct{}, 1),
+		journalChanged: make(chan struct{}, 1),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+
+	data, err := os.ReadFile(positionsFile)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &l.positions); err != nil {
+			log.Printf("Ignoring unreadable log positions file %s: %v", positionsFile, err)
+		}
+		if l.positions.Files == nil {
+			l.positions.Files = make(map[string]filePosition)
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		log.Printf("Failed to read log positions file %s: %v", positionsFile, err)
+	}
+	l.journalCursor = l.positions.Journal
+	return l
+}
+
+// Configure changes the files and journal units tailed
+func (l *LogCollector) Configure(settings domain.LogSettings) {
+	l.mu.Lock()
+	old := l.settings
+	l.settings = settings
+	l.mu.Unlock()
+
+	if !reflect.DeepEqual(old.Files, settings.Files) {
+		select {
+		case l.filesChanged <- struct{}{}:
+		default:
+		}
+	}
+	if old.Journal != settings.Journal || !reflect.DeepEqual(old.JournalUnits, settings.JournalUnits) || old.MaxLineBytes != settings.MaxLineBytes {
+		select {
+		case l.journalChanged <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// CheckServerFiles checks the file patterns of a server-managed config: besides the patterns
+// of the local config, the server may only name files under the log roots
+func (l *LogCollector) CheckServerFiles(patterns, local []string) error {
+	for _, pattern := range patterns {
+		if !slices.Contains(local, pattern) && !underRoot(pattern, l.roots) {
+			return fmt.Errorf("logs.files: %q is not under a log root of the agent", pattern)
+		}
+	}
+	return nil
+}
+
+// underRoot reports whether the paths matching pattern all lie under one of roots
+func underRoot(pattern string, roots []string) bool {
+	pattern = filepath.Clean(pattern)
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if !strings.HasSuffix(root, string(filepath.Separator)) {
+			root += string(filepath.Separator)
+		}
+		if strings.HasPrefix(pattern, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *LogCollector) current() domain.LogSettings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings
+}
+
+// Start begins tailing
+func (l *LogCollector) Start() {
+	settings := l.current()
+	log.Printf("Log collector started (%d file patterns, journal: %t)", len(settings.Files), settings.Journal)
+	l.wg.Add(2)
+	go l.tailLoop()
+	go l.journalLoop()
+}
+
+// Stop stops tailing; lines not yet shipped are read again after a restart
+func (l *LogCollector) Stop() {
+	l.cancel()
+	l.wg.Wait()
+}
+
+// Pending returns up to max of the lines not yet shipped, oldest first
+func (l *LogCollector) Pending(max int) []domain.LogLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if max > len(l.pending) {
+		max = len(l.pending)
+	}
+	lines := make([]domain.LogLine, max)
+	for i := range lines {
+		lines[i] = l.pending[i].line
+	}
+	return lines
+}
+
+// Commit records the first n pending lines as shipped and saves the positions reached
+func (l *LogCollector) Commit(n int) {
+	l.mu.Lock()
+	if n > len(l.pending) {
+		n = len(l.pending)
+	}
+	for _, p := range l.pending[:n] {
+		if p.file != "" {
+			l.positions.Files[p.file] = p.position
+		} else {
+			l.positions.Journal = p.line.Cursor
+		}
+	}
+	l.pending = append([]pendingLine(nil), l.pending[n:]...)
+	data, err := json.Marshal(l.positions)
+	l.mu.Unlock()
+
+	select {
+	case l.room <- struct{}{}:
+	default:
+	}
+	if err == nil {
+		err = l.savePositions(data)
+	}
+	if err != nil {
+		log.Printf("Failed to save log positions: %v", err)
+	}
+}
+
+func (l *LogCollector) savePositions(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(l.positionsFile), 0755); err != nil {
+		return err
+	}
+	tmp := l.positionsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.positionsFile)
+}
+
+// full reports whether pending holds as many lines as it may
+func (l *LogCollector) full() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pending) >= maxPendingLogLines
+}
+
+func (l *LogCollector) queue(lines []pendingLine) {
+	if len(lines) == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.pending = append(l.pending, lines...)
+	l.mu.Unlock()
+}
+
+// tailLoop follows the files matching the patterns. Files found by the first scan, or the
+// first after the patterns changed, are read from their end; files appearing later from
+// their start.
+func (l *LogCollector) tailLoop() {
+	defer l.wg.Done()
+
+	t := &fileTailer{
+		files:  make(map[string]*tailedFile),
+		failed: make(map[string]bool),
+	}
+	defer t.close()
+
+	ticker := time.NewTicker(logScanInterval)
+	defer ticker.Stop()
+
+	existing := true
+	for {
+		l.scanFiles(t, existing)
+		existing = false
+
+		select {
+		case <-ticker.C:
+		case <-l.filesChanged:
+			existing = true
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// fileTailer is the state of tailLoop
+type fileTailer struct {
+	files map[string]*tailedFile
+	// draining are files rotated away or no longer matched, read to their end before they
+	// are closed
+	draining []*tailedFile
+	failed   map[string]bool // paths that could not be opened, logged once
+}
+
+func (t *fileTailer) close() {
+	for _, tf := range t.files {
+		tf.file.Close()
+	}
+	for _, tf := range t.draining {
+		tf.file.Close()
+	}
+}
+
+// scanFiles expands the patterns, notices rotated, truncated and removed files, and reads
+// what was appended
+func (l *LogCollector) scanFiles(t *fileTailer, existing bool) {
+	settings := l.current()
+
+	paths := make(map[string]bool)
+	for _, pattern := range settings.Files {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			paths[path] = true
+		}
+	}
+
+	// Files no longer at their path, replaced by a rotation or no longer matched, are set
+	// aside; a file only renamed to another matched path is followed there
+	moved := make(map[uint64]*tailedFile)
+	for path, tf := range t.files {
+		if info, err := os.Stat(path); err == nil && paths[path] && (tf.inode == 0 || fileInode(info) == tf.inode) {
+			continue
+		}
+		delete(t.files, path)
+		l.mu.Lock()
+		delete(l.positions.Files, path)
+		l.mu.Unlock()
+		if tf.inode != 0 {
+			moved[tf.inode] = tf
+		} else {
+			t.draining = append(t.draining, tf)
+		}
+	}
+
+	for path := range paths {
+		if t.files[path] != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		inode := fileInode(info)
+		if tf := moved[inode]; tf != nil && inode != 0 {
+			delete(moved, inode)
+			tf.path = path
+			t.files[path] = tf
+			continue
+		}
+
+		// A path seen before and replaced by a rotation is read from its start
+		tf, err := l.openFile(path, info, inode, existing && !tailedAt(moved, path))
+		if err != nil {
+			if !t.failed[path] {
+				log.Printf("Failed to open log file %s: %v", path, err)
+				t.failed[path] = true
+			}
+			continue
+		}
+		delete(t.failed, path)
+		t.files[path] = tf
+	}
+	for _, tf := range moved {
+		t.draining = append(t.draining, tf)
+	}
+
+	// Rotated files are finished before their replacements are read
+	draining := t.draining[:0]
+	for _, tf := range t.draining {
+		if l.readFile(tf, settings.MaxLineBytes) {
+			tf.file.Close()
+		} else {
+			draining = append(draining, tf)
+		}
+	}
+	t.draining = draining
+	if len(t.draining) > 0 {
+		return
+	}
+
+	for path, tf := range t.files {
+		if info, err := tf.file.Stat(); err == nil && info.Size() < tf.offset {
+			log.Printf("Log file %s was truncated, reading it from the start", path)
+			if _, err := tf.file.Seek(0, io.SeekStart); err != nil {
+				continue
+			}
+			tf.offset, tf.partial, tf.skipping = 0, nil, false
+		}
+		l.readFile(tf, settings.MaxLineBytes)
+	}
+}
+
+// tailedAt reports whether one of the files set aside was followed at path
+func tailedAt(moved map[uint64]*tailedFile, path string) bool {
+	for _, tf := range moved {
+		if tf.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// openFile opens a file at its saved position, or at its end when fromEnd is set and it has
+// none. A file replaced while the agent was stopped is read from its start.
+func (l *LogCollector) openFile(path string, info os.FileInfo, inode uint64, fromEnd bool) (*tailedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	saved, ok := l.positions.Files[path]
+	l.mu.Unlock()
+
+	var offset int64
+	switch {
+	case ok && saved.Inode == inode && saved.Offset <= info.Size():
+		offset = saved.Offset
+	case ok:
+	case fromEnd:
+		offset = info.Size()
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &tailedFile{path: path, file: file, inode: inode, offset: offset}, nil
+}
+
+// readFile reads the lines appended to a file until pending is full, reporting whether it
+// reached the end of the file
+func (l *LogCollector) readFile(tf *tailedFile, maxLineBytes int) bool {
+	buf := make([]byte, 32<<10)
+	for !l.full() {
+		n, err := tf.file.Read(buf)
+		if n > 0 {
+			l.queue(tf.consume(buf[:n], maxLineBytes))
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Failed to read log file %s: %v", tf.path, err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// consume splits data read from the file into lines, keeping the start of an unfinished
+// line for the next read and cutting lines longer than maxLineBytes
+func (tf *tailedFile) consume(data []byte, maxLineBytes int) []pendingLine {
+	var lines []pendingLine
+	emit := func(text []byte) {
+		text = bytes.TrimRight(text, "\r")
+		if len(text) > maxLineBytes {
+			text = text[:maxLineBytes]
+		}
+		if len(text) == 0 {
+			return
+		}
+		position := filePosition{Inode: tf.inode, Offset: tf.offset}
+		lines = append(lines, pendingLine{
+			line: domain.LogLine{
+				Source:    tf.path,
+				Message:   strings.ToValidUTF8(string(text), "\uFFFD"),
+				Timestamp: time.Now(),
+				Cursor:    fmt.Sprintf("%d:%d", position.Inode, position.Offset),
+			},
+			file:     tf.path,
+			position: position,
+		})
+	}
+
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			tf.offset += int64(len(data))
+			if !tf.skipping {
+				tf.partial = append(tf.partial, data...)
+				if len(tf.partial) >= maxLineBytes {
+					emit(tf.partial)
+					tf.partial, tf.skipping = tf.partial[:0], true
+				}
+			}
+			break
+		}
+
+		tf.offset += int64(i + 1)
+		if tf.skipping {
+			tf.skipping = false
+		} else {
+			emit(append(tf.partial, data[:i]...))
+		}
+		tf.partial = tf.partial[:0]
+		data = data[i+1:]
+	}
+	return lines
+}
+
+// journalLoop runs journalctl while the journal is tailed, again after it exits, and anew
+// with the new units when they change
+func (l *LogCollector) journalLoop() {
+	defer l.wg.Done()
+
+	missing := false // journalctl not found, logged once
+	for {
+		settings := l.current()
+		if !settings.Journal {
+			select {
+			case <-l.journalChanged:
+				continue
+			case <-l.ctx.Done():
+				return
+			}
+		}
+
+		ctx, cancel := context.WithCancel(l.ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- l.readJournal(ctx, settings.JournalUnits, settings.MaxLineBytes)
+		}()
+
+		var err error
+		select {
+		case err = <-done:
+			cancel()
+		case <-l.journalChanged:
+			cancel()
+			<-done
+			continue
+		case <-l.ctx.Done():
+			cancel()
+			<-done
+			return
+		}
+
+		if errors.Is(err, exec.ErrNotFound) {
+			if !missing {
+				log.Printf("journalctl not found, the journal is not tailed")
+				missing = true
+			}
+		} else if err != nil {
+			log.Printf("Journal tailing stopped, restarting in %s: %v", journalRetry, err)
+		}
+
+		select {
+		case <-time.After(journalRetry):
+		case <-l.journalChanged:
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// readJournal follows the journal from the last entry read, or from now, until ctx is done
+// or journalctl exits
+func (l *LogCollector) readJournal(ctx context.Context, units []string, maxLineBytes int) error {
+	l.mu.Lock()
+	cursor := l.journalCursor
+	l.mu.Unlock()
+
+	args := []string{"--follow", "--output=json", "--quiet"}
+	if cursor != "" {
+		args = append(args, "--after-cursor="+cursor)
+	} else {
+		args = append(args, "--lines=0")
+	}
+	for _, unit := range units {
+		args = append(args, "--unit="+unit)
+	}
+
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(stdout, 64<<10)
+	for {
+		data, readErr := reader.ReadBytes('\n')
+		if line, ok := parseJournalEntry(data, maxLineBytes); ok {
+			if !l.waitForRoom(ctx) {
+				break
+			}
+			l.mu.Lock()
+			l.pending = append(l.pending, pendingLine{line: line})
+			l.journalCursor = line.Cursor
+			l.mu.Unlock()
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	err = cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if message := strings.TrimSpace(stderr.String()); message != "" {
+		return fmt.Errorf("journalctl exited: %v: %s", err, message)
+	}
+	return fmt.Errorf("journalctl exited: %v", err)
+}
+
+// waitForRoom blocks while pending is full, returning false when ctx is done first
+func (l *LogCollector) waitForRoom(ctx context.Context) bool {
+	for l.full() {
+		select {
+		case <-l.room:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// parseJournalEntry reads an entry printed by journalctl --output=json
+func parseJournalEntry(data []byte, maxLineBytes int) (domain.LogLine, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return domain.LogLine{}, false
+	}
+
+	line := domain.LogLine{
+		Source:  domain.LogSourceJournal,
+		Message: journalField(fields["MESSAGE"]),
+		Unit:    journalField(fields["_SYSTEMD_UNIT"]),
+		Cursor:  journalField(fields["__CURSOR"]),
+	}
+	if line.Cursor == "" {
+		return domain.LogLine{}, false
+	}
+	if line.Unit == "" {
+		line.Unit = journalField(fields["SYSLOG_IDENTIFIER"])
+	}
+	if len(line.Message) > maxLineBytes {
+		line.Message = line.Message[:maxLineBytes]
+	}
+	line.Message = strings.ToValidUTF8(line.Message, "\uFFFD")
+
+	line.Timestamp = time.Now()
+	if usec, err := strconv.ParseInt(journalField(fields["__REALTIME_TIMESTAMP"]), 10, 64); err == nil {
+		line.Timestamp = time.UnixMicro(usec)
+	}
+	if priority, err := strconv.Atoi(journalField(fields["PRIORITY"])); err == nil && priority >= 0 && priority < len(journalLevels) {
+		line.Level = journalLevels[priority]
+	}
+	return line, true
+}
+
+// journalField decodes a field of a journal entry: a string, or an array of bytes for
+// values that are not valid UTF-8
+func journalField(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var values []int
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return ""
+	}
+	data := make([]byte, len(values))
+	for i, v := range values {
+		data[i] = byte(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestCheckServerFiles(t *testing.T) {
+	logs := NewLogCollector(domain.LogSettings{}, t.TempDir()+"/positions.json", []string{"/var/log", "/srv/app/logs/"})
+	local := []string{"/opt/app/app.log"}
+
+	tests := []struct {
+		pattern string
+		ok      bool
+	}{
+		{"/var/log/syslog", true},
+		{"/var/log/nginx/*.log", true},
+		{"/srv/app/logs/*", true},
+		{"/opt/app/app.log", true}, // in the local config
+		{"/opt/app/other.log", false},
+		{"/etc/shadow", false},
+		{"/root/.ssh/*", false},
+		{"/var/log/../../etc/shadow", false},
+		{"/var/log/*/../../etc/shadow", false},
+		{"/var/log", false},
+		{"/var/logs/app.log", false},
+		{"/var/log*/x", false},
+	}
+	for _, tt := range tests {
+		err := logs.CheckServerFiles([]string{tt.pattern}, local)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckServerFiles(%q) = %v, want ok %v", tt.pattern, err, tt.ok)
+		}
+	}
+}
+
+func TestServerSettingsOutsideLogRoots(t *testing.T) {
+	settings := domain.DefaultAgentSettings()
+	settings.Logs.Files = []string{"/var/log/app.log"}
+	logs := NewLogCollector(settings.Logs, t.TempDir()+"/positions.json", []string{"/var/log"})
+	agent := NewAgentServer("test", "0", settings, nil, nil, nil, nil, nil, logs)
+
+	if err := agent.SetServerSettings("v1", json.RawMessage(`{"logs":{"files":["/etc/shadow"]}}`)); err == nil {
+		t.Fatal("SetServerSettings() accepted a file outside the log roots")
+	}
+	if files := agent.Settings().Logs.Files; len(files) != 1 || files[0] != "/var/log/app.log" {
+		t.Fatalf("Logs.Files = %v after a rejected config", files)
+	}
+
+	if err := agent.SetServerSettings("v2", json.RawMessage(`{"logs":{"files":["/var/log/nginx/*.log"]}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if files := agent.Settings().Logs.Files; len(files) != 1 || files[0] != "/var/log/nginx/*.log" {
+		t.Fatalf("Logs.Files = %v, want the server's", files)
+	}
+	// The file settings are not overwritten by the server's list
+	if err := agent.SetFileSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings.Logs.Files[0] != "/var/log/app.log" {
+		t.Fatalf("file settings changed to %v", settings.Logs.Files)
+	}
+}
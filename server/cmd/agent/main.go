@@ -1,18 +1,24 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/openmetrics"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/host"
@@ -25,219 +31,164 @@ import (
 const version = "1.0.0"
 
 type AgentServer struct {
-	name     string
-	hostname string
-	port     string
+	name       string
+	hostname   string
+	port       string
+	containers *ContainerCollector
+	services   *ServiceCollector
+	proc       *ProcCollector
+	plugins    *PluginRunner
+	pinger     *PingRunner
+	logs       *LogCollector
+
+	// settings are the file settings overlaid with the server-managed config
+	mu             sync.RWMutex
+	fileSettings   domain.AgentSettings
+	serverSettings json.RawMessage
+	serverVersion  string
+	settings       domain.AgentSettings
 }
 
-func NewAgentServer(name, port string) *AgentServer {
+func NewAgentServer(name, port string, settings domain.AgentSettings, containers *ContainerCollector, services *ServiceCollector, proc *ProcCollector, plugins *PluginRunner, pinger *PingRunner, logs *LogCollector) *AgentServer {
 	hostname, _ := os.Hostname()
-	return &AgentServer{
-		name:     name,
-		hostname: hostname,
-		port:     port,
+	a := &AgentServer{
+		name:         name,
+		hostname:     hostname,
+		port:         port,
+		containers:   containers,
+		services:     services,
+		proc:         proc,
+		plugins:      plugins,
+		pinger:       pinger,
+		logs:         logs,
+		fileSettings: settings,
 	}
+	a.applySettings(settings)
+	return a
 }
 
-// CollectMetrics collects system metrics
-func (a *AgentServer) CollectMetrics() (*domain.SystemMetrics, error) {
-	// Get CPU metrics
-	cpuPercent, _ := cpu.Percent(time.Second, false)
-	cpuPerCore, _ := cpu.Percent(time.Second, true)
-
-	cpuInfo, _ := cpu.Info()
-	var cpuModel string
-	var cpuFreq float64
-	if len(cpuInfo) > 0 {
-		cpuModel = cpuInfo[0].ModelName
-		cpuFreq = cpuInfo[0].Mhz
-	}
-
-	cpuCores, _ := cpu.Counts(false)
-	cpuThreads, _ := cpu.Counts(true)
-
-	cpuTimes, _ := cpu.Times(false)
-	var cpuUser, cpuSystem, cpuIdle float64
-	if len(cpuTimes) > 0 {
-		total := cpuTimes[0].User + cpuTimes[0].System + cpuTimes[0].Idle + cpuTimes[0].Iowait
-		if total > 0 {
-			cpuUser = (cpuTimes[0].User / total) * 100
-			cpuSystem = (cpuTimes[0].System / total) * 100
-			cpuIdle = (cpuTimes[0].Idle / total) * 100
-		}
-	}
+// Settings returns the settings in effect
+func (a *AgentServer) Settings() domain.AgentSettings {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.settings
+}
 
-	// Get Memory metrics
-	vmem, _ := mem.VirtualMemory()
-	swap, _ := mem.SwapMemory()
+// ConfigVersion returns the version of the server-managed config in effect, "" if none was applied
+func (a *AgentServer) ConfigVersion() string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.serverVersion
+}
 
-	// Get Disk metrics
-	partitions, _ := disk.Partitions(false)
-	var diskMetrics []domain.DiskMetrics
-	for _, partition := range partitions {
-		usage, err := disk.Usage(partition.Mountpoint)
-		if err != nil {
-			continue
-		}
-		diskMetrics = append(diskMetrics, domain.DiskMetrics{
-			Device:      partition.Device,
-			MountPoint:  partition.Mountpoint,
-			FsType:      partition.Fstype,
-			Total:       usage.Total,
-			Used:        usage.Used,
-			Free:        usage.Free,
-			UsedPercent: usage.UsedPercent,
-			InodesTotal: usage.InodesTotal,
-			InodesUsed:  usage.InodesUsed,
-			InodesFree:  usage.InodesFree,
-		})
+// SetFileSettings applies the settings of a reloaded config file, keeping the server overlay
+func (a *AgentServer) SetFileSettings(settings domain.AgentSettings) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	effective, err := a.overlay(settings, a.serverSettings)
+	if err != nil {
+		return err
 	}
+	a.fileSettings = settings
+	a.applySettings(effective)
+	return nil
+}
 
-	// Get Network metrics
-	netStats, _ := net.IOCounters(false)
-	var networkMetrics domain.NetworkMetrics
-	if len(netStats) > 0 {
-		networkMetrics.BytesSent = netStats[0].BytesSent
-		networkMetrics.BytesRecv = netStats[0].BytesRecv
-		networkMetrics.PacketsSent = netStats[0].PacketsSent
-		networkMetrics.PacketsRecv = netStats[0].PacketsRecv
-		networkMetrics.ErrorsIn = netStats[0].Errin
-		networkMetrics.ErrorsOut = netStats[0].Errout
-		networkMetrics.DropIn = netStats[0].Dropin
-		networkMetrics.DropOut = netStats[0].Dropout
-	}
-
-	// Get Network interfaces
-	interfaces, _ := net.Interfaces()
-	var netInterfaces []domain.NetworkInterface
-	for _, iface := range interfaces {
-		ifaceStats, _ := net.IOCounters(true)
-		var ifaceMetric domain.NetworkInterface
-		ifaceMetric.Name = iface.Name
-		ifaceMetric.MTU = iface.MTU
+// SetServerSettings applies a server-managed config over the file settings
+func (a *AgentServer) SetServerSettings(version string, settings json.RawMessage) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-		var addrs []string
-		for _, addr := range iface.Addrs {
-			addrs = append(addrs, addr.Addr)
-		}
-		ifaceMetric.Addrs = addrs
-
-		for _, stat := range ifaceStats {
-			if stat.Name == iface.Name {
-				ifaceMetric.BytesSent = stat.BytesSent
-				ifaceMetric.BytesRecv = stat.BytesRecv
-				ifaceMetric.PacketsSent = stat.PacketsSent
-				ifaceMetric.PacketsRecv = stat.PacketsRecv
-				break
-			}
-		}
-		netInterfaces = append(netInterfaces, ifaceMetric)
+	effective, err := a.overlay(a.fileSettings, settings)
+	if err != nil {
+		return err
 	}
-	networkMetrics.Interfaces = netInterfaces
+	a.serverSettings, a.serverVersion = settings, version
+	a.applySettings(effective)
+	return nil
+}
 
-	// Get connection stats
-	connections, _ := net.Connections("all")
-	connStats := domain.ConnectionStats{Total: len(connections)}
-	for _, conn := range connections {
-		switch conn.Status {
-		case "ESTABLISHED":
-			connStats.Established++
-		case "LISTEN":
-			connStats.Listen++
-		case "TIME_WAIT":
-			connStats.TimeWait++
-		case "CLOSE_WAIT":
-			connStats.CloseWait++
+// overlay sets a server-managed config over the file settings. Log files are only tailed
+// for the server under the log roots, so that it cannot read any file of the host.
+func (a *AgentServer) overlay(base domain.AgentSettings, overlay json.RawMessage) (domain.AgentSettings, error) {
+	settings, err := overlaySettings(base, overlay)
+	if err != nil {
+		return base, err
+	}
+	if a.logs != nil {
+		if err := a.logs.CheckServerFiles(settings.Logs.Files, base.Logs.Files); err != nil {
+			return base, fmt.Errorf("invalid server config: %w", err)
 		}
 	}
-	networkMetrics.Connections = connStats
+	return settings, nil
+}
 
-	// Get Process metrics
-	processes, _ := process.Processes()
-	processMetrics := domain.ProcessMetrics{
-		Total: len(processes),
-	}
-	for _, p := range processes {
-		status, _ := p.Status()
-		if len(status) > 0 {
-			switch status[0] {
-			case "R":
-				processMetrics.Running++
-			case "S", "D":
-				processMetrics.Sleeping++
-			case "T":
-				processMetrics.Stopped++
-			case "Z":
-				processMetrics.Zombie++
-			}
+// overlaySettings sets the keys of a server-managed config over base settings
+func overlaySettings(base domain.AgentSettings, overlay json.RawMessage) (domain.AgentSettings, error) {
+	settings := base
+	// Lists are replaced, not appended to
+	settings.IgnoreMounts = append([]string(nil), base.IgnoreMounts...)
+	settings.IgnoreFsTypes = append([]string(nil), base.IgnoreFsTypes...)
+	settings.IgnoreInterfaces = append([]string(nil), base.IgnoreInterfaces...)
+	settings.Services = append([]string(nil), base.Services...)
+	settings.Ping.Targets = append([]domain.PingTarget(nil), base.Ping.Targets...)
+	settings.Logs.Files = append([]string(nil), base.Logs.Files...)
+	settings.Logs.JournalUnits = append([]string(nil), base.Logs.JournalUnits...)
+
+	if len(overlay) > 0 {
+		if err := json.Unmarshal(overlay, &settings); err != nil {
+			return base, fmt.Errorf("invalid server config: %w", err)
 		}
-		numThreads, _ := p.NumThreads()
-		processMetrics.Threads += int(numThreads)
 	}
+	if err := settings.Validate(); err != nil {
+		return base, fmt.Errorf("invalid settings: %w", err)
+	}
+	return settings, nil
+}
 
-	// Get Load average
-	loadAvg, _ := load.Avg()
-	loadMetrics := domain.LoadMetrics{
-		Load1:  loadAvg.Load1,
-		Load5:  loadAvg.Load5,
-		Load15: loadAvg.Load15,
+// applySettings puts settings in effect; a.mu must be held or the agent not yet shared
+func (a *AgentServer) applySettings(settings domain.AgentSettings) {
+	a.settings = settings
+
+	if a.services != nil {
+		units := settings.Services
+		if !settings.Collectors.Services {
+			units = nil
+		}
+		a.services.SetUnits(units)
+	}
+	if a.plugins != nil {
+		a.plugins.Configure(settings.Collectors.Plugins, time.Duration(settings.PluginInterval), time.Duration(settings.PluginTimeout))
+	}
+	if a.pinger != nil {
+		a.pinger.Configure(settings.Ping)
 	}
+	if a.logs != nil {
+		a.logs.Configure(settings.Logs)
+	}
+}
 
-	// Get temperature
-	var thermalMetrics domain.ThermalMetrics
-	temps, err := host.SensorsTemperatures()
-	if err == nil && len(temps) > 0 {
-		var sensors []domain.ThermalSensor
-		for _, temp := range temps {
-			sensors = append(sensors, domain.ThermalSensor{
-				Name:        temp.SensorKey,
-				Temperature: temp.Temperature,
-				High:        temp.High,
-				Critical:    temp.Critical,
-			})
-			if thermalMetrics.CPUTemp == 0 && (temp.SensorKey == "coretemp" || temp.SensorKey == "cpu_thermal" || temp.SensorKey == "k10temp") {
-				thermalMetrics.CPUTemp = temp.Temperature
-			}
+// matchAny reports whether name matches one of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
 		}
-		thermalMetrics.Sensors = sensors
 	}
+	return false
+}
+
+// CollectMetrics collects system metrics, leaving out the sections whose collector is off
+func (a *AgentServer) CollectMetrics() (*domain.SystemMetrics, error) {
+	settings := a.Settings()
+	collectors := settings.Collectors
 
 	// Get Host info
 	hostInfo, _ := host.Info()
 
 	metrics := &domain.SystemMetrics{
 		Timestamp: time.Now(),
-		CPU: domain.CPUMetrics{
-			UsagePercent: cpuPercent[0],
-			PerCore:      cpuPerCore,
-			Cores:        cpuCores,
-			Threads:      cpuThreads,
-			ModelName:    cpuModel,
-			Frequency:    cpuFreq,
-			User:         cpuUser,
-			System:       cpuSystem,
-			Idle:         cpuIdle,
-		},
-		Memory: domain.MemoryMetrics{
-			Total:       vmem.Total,
-			Available:   vmem.Available,
-			Used:        vmem.Used,
-			Free:        vmem.Free,
-			UsedPercent: vmem.UsedPercent,
-			Cached:      vmem.Cached,
-			Buffers:     vmem.Buffers,
-			Swap: domain.SwapMetrics{
-				Total:       swap.Total,
-				Used:        swap.Used,
-				Free:        swap.Free,
-				UsedPercent: swap.UsedPercent,
-			},
-		},
-		Disk:        diskMetrics,
-		Network:     networkMetrics,
-		Process:     processMetrics,
-		Load:        loadMetrics,
-		Temperature: thermalMetrics,
 		System: domain.SystemInfo{
 			Hostname:        a.hostname,
 			OS:              hostInfo.OS,
@@ -253,6 +204,231 @@ func (a *AgentServer) CollectMetrics() (*domain.SystemMetrics, error) {
 		},
 	}
 
+	// Get CPU metrics
+	if collectors.CPU {
+		cpuPercent, _ := cpu.Percent(time.Second, false)
+		cpuPerCore, _ := cpu.Percent(time.Second, true)
+
+		cpuInfo, _ := cpu.Info()
+		if len(cpuInfo) > 0 {
+			metrics.CPU.ModelName = cpuInfo[0].ModelName
+			metrics.CPU.Frequency = cpuInfo[0].Mhz
+		}
+		if len(cpuPercent) > 0 {
+			metrics.CPU.UsagePercent = cpuPercent[0]
+		}
+		metrics.CPU.PerCore = cpuPerCore
+		metrics.CPU.Cores, _ = cpu.Counts(false)
+		metrics.CPU.Threads, _ = cpu.Counts(true)
+
+		cpuTimes, _ := cpu.Times(false)
+		if len(cpuTimes) > 0 {
+			total := cpuTimes[0].User + cpuTimes[0].System + cpuTimes[0].Idle + cpuTimes[0].Iowait
+			if total > 0 {
+				metrics.CPU.User = (cpuTimes[0].User / total) * 100
+				metrics.CPU.System = (cpuTimes[0].System / total) * 100
+				metrics.CPU.Idle = (cpuTimes[0].Idle / total) * 100
+			}
+		}
+	}
+
+	// Get Memory metrics
+	if collectors.Memory {
+		if vmem, err := mem.VirtualMemory(); err == nil {
+			metrics.Memory = domain.MemoryMetrics{
+				Total:       vmem.Total,
+				Available:   vmem.Available,
+				Used:        vmem.Used,
+				Free:        vmem.Free,
+				UsedPercent: vmem.UsedPercent,
+				Cached:      vmem.Cached,
+				Buffers:     vmem.Buffers,
+			}
+		}
+		if swap, err := mem.SwapMemory(); err == nil {
+			metrics.Memory.Swap = domain.SwapMetrics{
+				Total:       swap.Total,
+				Used:        swap.Used,
+				Free:        swap.Free,
+				UsedPercent: swap.UsedPercent,
+			}
+		}
+	}
+
+	// Get Disk metrics
+	if collectors.Disk {
+		partitions, _ := disk.Partitions(false)
+		for _, partition := range partitions {
+			if matchAny(settings.IgnoreMounts, partition.Mountpoint) || slices.Contains(settings.IgnoreFsTypes, partition.Fstype) {
+				continue
+			}
+			usage, err := disk.Usage(partition.Mountpoint)
+			if err != nil {
+				continue
+			}
+			metrics.Disk = append(metrics.Disk, domain.DiskMetrics{
+				Device:      partition.Device,
+				MountPoint:  partition.Mountpoint,
+				FsType:      partition.Fstype,
+				Total:       usage.Total,
+				Used:        usage.Used,
+				Free:        usage.Free,
+				UsedPercent: usage.UsedPercent,
+				InodesTotal: usage.InodesTotal,
+				InodesUsed:  usage.InodesUsed,
+				InodesFree:  usage.InodesFree,
+			})
+		}
+	}
+
+	// Get Network metrics
+	if collectors.Network {
+		netStats, _ := net.IOCounters(false)
+		if len(netStats) > 0 {
+			metrics.Network.BytesSent = netStats[0].BytesSent
+			metrics.Network.BytesRecv = netStats[0].BytesRecv
+			metrics.Network.PacketsSent = netStats[0].PacketsSent
+			metrics.Network.PacketsRecv = netStats[0].PacketsRecv
+			metrics.Network.ErrorsIn = netStats[0].Errin
+			metrics.Network.ErrorsOut = netStats[0].Errout
+			metrics.Network.DropIn = netStats[0].Dropin
+			metrics.Network.DropOut = netStats[0].Dropout
+		}
+
+		// Get Network interfaces
+		interfaces, _ := net.Interfaces()
+		ifaceStats, _ := net.IOCounters(true)
+		for _, iface := range interfaces {
+			if matchAny(settings.IgnoreInterfaces, iface.Name) {
+				continue
+			}
+			var ifaceMetric domain.NetworkInterface
+			ifaceMetric.Name = iface.Name
+			ifaceMetric.MTU = iface.MTU
+
+			var addrs []string
+			for _, addr := range iface.Addrs {
+				addrs = append(addrs, addr.Addr)
+			}
+			ifaceMetric.Addrs = addrs
+
+			for _, stat := range ifaceStats {
+				if stat.Name == iface.Name {
+					ifaceMetric.BytesSent = stat.BytesSent
+					ifaceMetric.BytesRecv = stat.BytesRecv
+					ifaceMetric.PacketsSent = stat.PacketsSent
+					ifaceMetric.PacketsRecv = stat.PacketsRecv
+					break
+				}
+			}
+			metrics.Network.Interfaces = append(metrics.Network.Interfaces, ifaceMetric)
+		}
+	}
+
+	// Get connection stats
+	if collectors.Connections {
+		connections, _ := net.Connections("all")
+		connStats := domain.ConnectionStats{Total: len(connections)}
+		for _, conn := range connections {
+			switch conn.Status {
+			case "ESTABLISHED":
+				connStats.Established++
+			case "LISTEN":
+				connStats.Listen++
+			case "TIME_WAIT":
+				connStats.TimeWait++
+			case "CLOSE_WAIT":
+				connStats.CloseWait++
+			}
+		}
+		metrics.Network.Connections = connStats
+	}
+
+	// Get Process metrics
+	if collectors.Processes {
+		processes, _ := process.Processes()
+		metrics.Process.Total = len(processes)
+		for _, p := range processes {
+			status, _ := p.Status()
+			if len(status) > 0 {
+				switch status[0] {
+				case "R":
+					metrics.Process.Running++
+				case "S", "D":
+					metrics.Process.Sleeping++
+				case "T":
+					metrics.Process.Stopped++
+				case "Z":
+					metrics.Process.Zombie++
+				}
+			}
+			numThreads, _ := p.NumThreads()
+			metrics.Process.Threads += int(numThreads)
+		}
+	}
+
+	// Get Load average
+	if collectors.Load {
+		if loadAvg, err := load.Avg(); err == nil {
+			metrics.Load = domain.LoadMetrics{
+				Load1:  loadAvg.Load1,
+				Load5:  loadAvg.Load5,
+				Load15: loadAvg.Load15,
+			}
+		}
+	}
+
+	// Get temperature
+	if collectors.Temperature {
+		temps, err := host.SensorsTemperatures()
+		if err == nil && len(temps) > 0 {
+			var sensors []domain.ThermalSensor
+			for _, temp := range temps {
+				sensors = append(sensors, domain.ThermalSensor{
+					Name:        temp.SensorKey,
+					Temperature: temp.Temperature,
+					High:        temp.High,
+					Critical:    temp.Critical,
+				})
+				if metrics.Temperature.CPUTemp == 0 && (temp.SensorKey == "coretemp" || temp.SensorKey == "cpu_thermal" || temp.SensorKey == "k10temp") {
+					metrics.Temperature.CPUTemp = temp.Temperature
+				}
+			}
+			metrics.Temperature.Sensors = sensors
+		}
+	}
+
+	// Get container metrics from cgroups
+	if collectors.Containers && a.containers != nil {
+		metrics.Containers, _ = a.containers.Collect()
+	}
+
+	// Get systemd unit states
+	if collectors.Services && a.services != nil {
+		var err error
+		metrics.Services, err = a.services.Collect()
+		if err != nil {
+			log.Printf("Failed to collect service states: %v", err)
+		}
+	}
+
+	// Get pressure stall information and extended memory counters
+	if collectors.Pressure && a.proc != nil {
+		metrics.Pressure = a.proc.Pressure()
+		metrics.MemoryDetail = a.proc.MemoryDetail()
+		metrics.VMStat = a.proc.VMStat()
+	}
+
+	// Attach the latest plugin results
+	if collectors.Plugins && a.plugins != nil {
+		metrics.Custom = a.plugins.Results()
+	}
+
+	// Attach the latest ping results
+	if a.pinger != nil {
+		metrics.Ping = a.pinger.Results()
+	}
+
 	return metrics, nil
 }
 
@@ -281,8 +457,8 @@ func (a *AgentServer) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// InfoHandler handles GET /info
-func (a *AgentServer) InfoHandler(w http.ResponseWriter, r *http.Request) {
+// Info describes the agent, as served on GET /info and reported at registration
+func (a *AgentServer) Info() domain.AgentInfo {
 	// Get IP address
 	ipAddress := "unknown"
 	interfaces, err := net.Interfaces()
@@ -295,15 +471,20 @@ func (a *AgentServer) InfoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	return domain.AgentInfo{
+		Name:      a.name,
+		Hostname:  a.hostname,
+		IPAddress: ipAddress,
+		Version:   version,
+	}
+}
+
+// InfoHandler handles GET /info
+func (a *AgentServer) InfoHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"data": map[string]interface{}{
-			"name":       a.name,
-			"hostname":   a.hostname,
-			"ip_address": ipAddress,
-			"version":    version,
-		},
+		"data":    a.Info(),
 	})
 }
 
@@ -319,11 +500,42 @@ func (a *AgentServer) MetricsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	// Protobuf carries the bare metrics without the JSON envelope
+	if acceptsProtobuf(r.Header.Get("Accept")) {
+		body, err := protostruct.Marshal(metrics)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeBody(w, r, protostruct.ContentType, body)
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
 		"success": true,
 		"data":    metrics,
 	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeBody(w, r, "application/json", append(body, '\n'))
+}
+
+// PrometheusHandler handles GET /metrics/prometheus
+func (a *AgentServer) PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	metrics, err := a.CollectMetrics()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exposition := openmetrics.New()
+	exposition.AddSystemMetrics(metrics, nil)
+
+	var body bytes.Buffer
+	exposition.WriteTo(&body)
+	writeBody(w, r, openmetrics.ContentType, body.Bytes())
 }
 
 func (a *AgentServer) Start() error {
@@ -331,6 +543,7 @@ func (a *AgentServer) Start() error {
 	mux.HandleFunc("/health", a.HealthHandler)
 	mux.HandleFunc("/info", a.InfoHandler)
 	mux.HandleFunc("/metrics", a.MetricsHandler)
+	mux.HandleFunc("/metrics/prometheus", a.PrometheusHandler)
 
 	// Wrap with CORS
 	handler := corsMiddleware(mux)
@@ -360,6 +573,7 @@ func (a *AgentServer) Start() error {
 	log.Printf("  - GET http://localhost:%s/health", a.port)
 	log.Printf("  - GET http://localhost:%s/info", a.port)
 	log.Printf("  - GET http://localhost:%s/metrics", a.port)
+	log.Printf("  - GET http://localhost:%s/metrics/prometheus", a.port)
 
 	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server: %w", err)
@@ -368,18 +582,118 @@ func (a *AgentServer) Start() error {
 	return nil
 }
 
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func main() {
-	port := flag.String("port", "9090", "Agent server port")
-	name := flag.String("name", "", "Agent name (required)")
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	flag.Parse()
+	var containers *ContainerCollector
+	if cfg.CgroupRoot != "" {
+		containers = NewContainerCollector(cfg.CgroupRoot, cfg.DockerSocket)
+	}
 
-	if *name == "" {
-		log.Fatal("Agent name is required (use -name flag)")
+	serviceCollector := NewServiceCollector(nil, cfg.CgroupRoot)
+
+	var proc *ProcCollector
+	if cfg.ProcRoot != "" {
+		proc = NewProcCollector(cfg.ProcRoot)
+	}
+
+	var plugins *PluginRunner
+	if cfg.PluginDir != "" {
+		plugins = NewPluginRunner(cfg.PluginDir, time.Duration(cfg.Settings.PluginInterval), time.Duration(cfg.Settings.PluginTimeout))
 	}
 
-	agent := NewAgentServer(*name, *port)
+	pinger := NewPingRunner(cfg.Settings.Ping)
+
+	// Logs are tailed only to be shipped to the server
+	var logs *LogCollector
+	if cfg.Server.URL != "" {
+		logs = NewLogCollector(cfg.Settings.Logs, cfg.LogPositions, cfg.LogRoots)
+	}
+
+	agent := NewAgentServer(cfg.Name, cfg.Port, cfg.Settings, containers, serviceCollector, proc, plugins, pinger, logs)
+	if plugins != nil {
+		plugins.Start()
+		defer plugins.Stop()
+	}
+	pinger.Start()
+	defer pinger.Stop()
+	if logs != nil {
+		logs.Start()
+		defer logs.Stop()
+	}
+
+	var uplink *Uplink
+	if cfg.Server.URL != "" {
+		queue, err := OpenWALQueue(cfg.Queue.Dir, cfg.Queue.MaxBytes, cfg.Queue.MaxAge)
+		if err != nil {
+			log.Fatalf("Failed to open queue: %v", err)
+		}
+		advertise := cfg.Server.Advertise
+		if advertise == "" {
+			advertise = ":" + cfg.Port
+		}
+		uplink = NewUplink(agent, queue, UplinkOptions{
+			ServerURL:         cfg.Server.URL,
+			AgentID:           cfg.Server.AgentID,
+			IDFile:            cfg.Server.IDFile,
+			Protocol:          cfg.Server.Protocol,
+			Advertise:         advertise,
+			Tags:              cfg.Tags,
+			Description:       cfg.Description,
+			HeartbeatInterval: cfg.Server.HeartbeatInterval,
+			ConfigInterval:    cfg.Server.ConfigInterval,
+			PushBatch:         cfg.Server.PushBatch,
+			Encoding:          cfg.Server.PushEncoding,
+			Coding:            cfg.pushCoding(),
+		})
+		uplink.Start()
+		defer uplink.Stop()
+	}
+
+	go reloadOnHangup(cfg, agent, uplink)
+
 	if err := agent.Start(); err != nil {
 		log.Fatalf("Agent error: %v", err)
 	}
 }
+
+// reloadOnHangup reloads the config file on SIGHUP. The settings are applied right away,
+// under the server-managed config; other changes only take effect after a restart.
+func reloadOnHangup(cfg *Config, agent *AgentServer, uplink *Uplink) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		next, err := loadConfig(os.Args[1:])
+		if err != nil {
+			log.Printf("Failed to reload config, keeping the current one: %v", err)
+			continue
+		}
+		if err := agent.SetFileSettings(next.Settings); err != nil {
+			log.Printf("Failed to apply reloaded config, keeping the current one: %v", err)
+			continue
+		}
+		if cfg.restartRequired(next) {
+			log.Printf("Config reloaded; changes outside settings take effect after a restart")
+		} else {
+			log.Printf("Config reloaded")
+		}
+		if uplink != nil {
+			uplink.ReloadConfig()
+		}
+	}
+}
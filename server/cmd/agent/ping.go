@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/probe"
+)
+
+// PingRunner periodically pings the configured targets and keeps their latest results
+type PingRunner struct {
+	mu       sync.RWMutex
+	settings domain.PingSettings
+	results  map[string]domain.PingResult
+
+	reconfigured chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func NewPingRunner(settings domain.PingSettings) *PingRunner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PingRunner{
+		settings:     settings,
+		results:      make(map[string]domain.PingResult),
+		reconfigured: make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Configure changes the targets and the schedule; results of removed targets are dropped
+func (p *PingRunner) Configure(settings domain.PingSettings) {
+	p.mu.Lock()
+	changed := !reflect.DeepEqual(p.settings, settings)
+	p.settings = settings
+	kept := make(map[string]domain.PingResult)
+	for _, target := range settings.Targets {
+		if result, ok := p.results[target.Label()]; ok {
+			kept[target.Label()] = result
+		}
+	}
+	p.results = kept
+	p.mu.Unlock()
+
+	if changed {
+		select {
+		case p.reconfigured <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (p *PingRunner) current() domain.PingSettings {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.settings
+}
+
+// Start begins pinging on schedule
+func (p *PingRunner) Start() {
+	settings := p.current()
+	log.Printf("Ping runner started (%d targets, interval: %s)", len(settings.Targets), time.Duration(settings.Interval))
+	p.wg.Add(1)
+	go p.runLoop()
+}
+
+// Stop cancels the current run and stops the schedule
+func (p *PingRunner) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// Results returns the latest result of every target, sorted by target name
+func (p *PingRunner) Results() []domain.PingResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	results := make([]domain.PingResult, 0, len(p.results))
+	for _, result := range p.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Target < results[j].Target
+	})
+	return results
+}
+
+func (p *PingRunner) runLoop() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(time.Duration(p.current().Interval))
+	defer ticker.Stop()
+
+	for {
+		p.runAll()
+
+		select {
+		case <-ticker.C:
+		case <-p.reconfigured:
+			settings := p.current()
+			ticker.Reset(time.Duration(settings.Interval))
+			log.Printf("Ping runner reconfigured (%d targets, interval: %s)", len(settings.Targets), time.Duration(settings.Interval))
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// runAll pings every target concurrently
+func (p *PingRunner) runAll() {
+	settings := p.current()
+
+	var wg sync.WaitGroup
+	for _, target := range settings.Targets {
+		wg.Add(1)
+		go func(target domain.PingTarget) {
+			defer wg.Done()
+			result := probe.Ping(p.ctx, target, settings.Count, time.Duration(settings.Timeout))
+			if p.ctx.Err() != nil {
+				return
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			// Skip targets removed while they were pinged
+			for _, current := range p.settings.Targets {
+				if current.Label() == target.Label() {
+					p.results[target.Label()] = result
+				}
+			}
+		}(target)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// maxPluginOutput caps how much plugin output is kept in a check result
+const maxPluginOutput = 4096
+
+// perfdataPattern matches one Nagios perfdata item: 'label'=value[UOM];[warn];[crit];[min];[max]
+var perfdataPattern = regexp.MustCompile(`('[^']+'|[^\s=]+)=([-+]?[0-9.]+(?:[eE][-+]?[0-9]+)?)([a-zA-Z%]*)((?:;[^;\s]*){0,4})`)
+
+// PluginRunner periodically executes plugins from a directory and keeps their latest results
+type PluginRunner struct {
+	dir string
+
+	mu       sync.RWMutex
+	enabled  bool
+	interval time.Duration
+	timeout  time.Duration
+	results  map[string]domain.CustomCheck
+
+	reconfigured chan struct{}
+	stopChan     chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewPluginRunner(dir string, interval, timeout time.Duration) *PluginRunner {
+	return &PluginRunner{
+		dir:          dir,
+		enabled:      true,
+		interval:     interval,
+		timeout:      timeout,
+		results:      make(map[string]domain.CustomCheck),
+		reconfigured: make(chan struct{}, 1),
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Configure changes the schedule; a disabled runner keeps no results and runs nothing
+func (p *PluginRunner) Configure(enabled bool, interval, timeout time.Duration) {
+	p.mu.Lock()
+	changed := p.enabled != enabled || p.interval != interval || p.timeout != timeout
+	p.enabled, p.interval, p.timeout = enabled, interval, timeout
+	if !enabled {
+		p.results = make(map[string]domain.CustomCheck)
+	}
+	p.mu.Unlock()
+
+	if changed {
+		select {
+		case p.reconfigured <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// schedule returns the current settings
+func (p *PluginRunner) schedule() (bool, time.Duration, time.Duration) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.enabled, p.interval, p.timeout
+}
+
+// Start begins running plugins on schedule
+func (p *PluginRunner) Start() {
+	_, interval, timeout := p.schedule()
+	log.Printf("Plugin runner started (dir: %s, interval: %s, timeout: %s)", p.dir, interval, timeout)
+	p.wg.Add(1)
+	go p.runLoop()
+}
+
+// Stop waits for the current run to finish and stops the schedule
+func (p *PluginRunner) Stop() {
+	close(p.stopChan)
+	p.wg.Wait()
+}
+
+// Results returns the latest result of every plugin, sorted by plugin name
+func (p *PluginRunner) Results() []domain.CustomCheck {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	checks := make([]domain.CustomCheck, 0, len(p.results))
+	for _, check := range p.results {
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(i, j int) bool {
+		return checks[i].Plugin < checks[j].Plugin
+	})
+	return checks
+}
+
+func (p *PluginRunner) runLoop() {
+	defer p.wg.Done()
+
+	_, interval, _ := p.schedule()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if enabled, _, _ := p.schedule(); enabled {
+			p.runAll()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.reconfigured:
+			enabled, interval, timeout := p.schedule()
+			ticker.Reset(interval)
+			log.Printf("Plugin runner reconfigured (enabled: %t, interval: %s, timeout: %s)", enabled, interval, timeout)
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// runAll runs every executable in the plugin directory concurrently
+func (p *PluginRunner) runAll() {
+	entries, err := os.ReadDir(p.dir)
+	if err != nil {
+		log.Printf("Failed to read plugin directory: %v", err)
+		return
+	}
+
+	present := make(map[string]bool)
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		present[entry.Name()] = true
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			check := p.run(name)
+			p.mu.Lock()
+			p.results[name] = check
+			p.mu.Unlock()
+		}(entry.Name())
+	}
+	wg.Wait()
+
+	// Forget plugins that were removed from the directory
+	p.mu.Lock()
+	for name := range p.results {
+		if !present[name] {
+			delete(p.results, name)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// run executes a single plugin and parses its output
+func (p *PluginRunner) run(name string) domain.CustomCheck {
+	_, _, timeout := p.schedule()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, filepath.Join(p.dir, name))
+	cmd.Stdout = &stdout
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	err := cmd.Run()
+	check := domain.CustomCheck{
+		Plugin:     name,
+		DurationMs: time.Since(start).Milliseconds(),
+		CheckedAt:  start,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		check.ExitCode = 3
+		check.Status = domain.CheckStatusUnknown
+		check.Output = "plugin timed out after " + timeout.String()
+		return check
+	case errors.As(err, &exitErr):
+		check.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		check.ExitCode = 3
+		check.Status = domain.CheckStatusUnknown
+		check.Output = err.Error()
+		return check
+	}
+
+	check.Status = statusFromExitCode(check.ExitCode)
+	check.Output, check.Metrics = parsePluginOutput(stdout.Bytes())
+	if len(check.Output) > maxPluginOutput {
+		check.Output = check.Output[:maxPluginOutput]
+	}
+	return check
+}
+
+func statusFromExitCode(code int) string {
+	switch code {
+	case 0:
+		return domain.CheckStatusOK
+	case 1:
+		return domain.CheckStatusWarning
+	case 2:
+		return domain.CheckStatusCritical
+	}
+	return domain.CheckStatusUnknown
+}
+
+// parsePluginOutput accepts JSON, "name[,label=value...] value" lines, or Nagios text with perfdata
+func parsePluginOutput(out []byte) (string, []domain.CustomMetric) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return "", nil
+	}
+
+	if trimmed[0] == '[' || trimmed[0] == '{' {
+		if metrics, err := parseJSONMetrics(trimmed); err == nil {
+			return "", metrics
+		}
+	}
+
+	if metrics, ok := parseLineMetrics(trimmed); ok {
+		return "", metrics
+	}
+
+	return parseNagiosOutput(trimmed)
+}
+
+// parseJSONMetrics accepts either a list of metrics or {"metrics": [...]}
+func parseJSONMetrics(data []byte) ([]domain.CustomMetric, error) {
+	var metrics []domain.CustomMetric
+	if data[0] == '[' {
+		err := json.Unmarshal(data, &metrics)
+		return metrics, err
+	}
+
+	var wrapped struct {
+		Metrics []domain.CustomMetric `json:"metrics"`
+	}
+	err := json.Unmarshal(data, &wrapped)
+	return wrapped.Metrics, err
+}
+
+// parseLineMetrics parses lines such as "queue_depth 42" or "replication_lag,db=main 1.5"
+func parseLineMetrics(data []byte) ([]domain.CustomMetric, bool) {
+	var metrics []domain.CustomMetric
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, false
+		}
+		value, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, false
+		}
+
+		parts := strings.Split(fields[0], ",")
+		metric := domain.CustomMetric{Name: parts[0], Value: value}
+		for _, tag := range parts[1:] {
+			k, v, ok := strings.Cut(tag, "=")
+			if !ok {
+				return nil, false
+			}
+			if metric.Labels == nil {
+				metric.Labels = make(map[string]string)
+			}
+			metric.Labels[k] = v
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, len(metrics) > 0
+}
+
+// parseNagiosOutput splits "TEXT | perfdata" on every line and parses the perfdata
+func parseNagiosOutput(data []byte) (string, []domain.CustomMetric) {
+	var text []string
+	var metrics []domain.CustomMetric
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line, perfdata, _ := strings.Cut(scanner.Text(), "|")
+		if line = strings.TrimSpace(line); line != "" {
+			text = append(text, line)
+		}
+
+		for _, m := range perfdataPattern.FindAllStringSubmatch(perfdata, -1) {
+			value, err := strconv.ParseFloat(m[2], 64)
+			if err != nil {
+				continue
+			}
+			metric := domain.CustomMetric{
+				Name:  strings.Trim(m[1], "'"),
+				Value: value,
+				Unit:  m[3],
+			}
+			thresholds := strings.Split(strings.TrimPrefix(m[4], ";"), ";")
+			targets := []**float64{&metric.Warn, &metric.Crit, &metric.Min, &metric.Max}
+			for i, t := range thresholds {
+				if i >= len(targets) || t == "" {
+					continue
+				}
+				// Ranges such as "10:20" are kept as plain thresholds only when numeric
+				if v, err := strconv.ParseFloat(t, 64); err == nil {
+					*targets[i] = &v
+				}
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+
+	return strings.Join(text, "\n"), metrics
+}
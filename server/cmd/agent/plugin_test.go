@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestParsePluginOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		text    string
+		metrics []domain.CustomMetric
+	}{
+		{name: "empty", out: " \n\n"},
+		{
+			name: "perfdata with units and thresholds",
+			out:  "DISK OK - free space: / 3326 MB (56%); | /=2643MB;5948;5958;0;5968 inodes=12%;80;90\n",
+			text: "DISK OK - free space: / 3326 MB (56%);",
+			metrics: []domain.CustomMetric{
+				{Name: "/", Value: 2643, Unit: "MB", Warn: float(5948), Crit: float(5958), Min: float(0), Max: float(5968)},
+				{Name: "inodes", Value: 12, Unit: "%", Warn: float(80), Crit: float(90)},
+			},
+		},
+		{
+			name: "perfdata with quoted labels",
+			out:  "OK - 2 queues | 'mail queue'=42;100;200 'dead letters'=0c 'rtt'=1.5e-3s;;;0",
+			text: "OK - 2 queues",
+			metrics: []domain.CustomMetric{
+				{Name: "mail queue", Value: 42, Warn: float(100), Crit: float(200)},
+				{Name: "dead letters", Value: 0, Unit: "c"},
+				{Name: "rtt", Value: 0.0015, Unit: "s", Min: float(0)},
+			},
+		},
+		{
+			name: "perfdata ranges and unknown values are skipped",
+			out:  "WARNING - load | load1=4.2;2:8;@10:20 load5=U;2;4",
+			text: "WARNING - load",
+			metrics: []domain.CustomMetric{
+				{Name: "load1", Value: 4.2},
+			},
+		},
+		{
+			name: "multi-line text with perfdata on several lines",
+			out:  "OK - 3 jobs | running=1\nbackup done\nreport done | queued=2;5;10",
+			text: "OK - 3 jobs\nbackup done\nreport done",
+			metrics: []domain.CustomMetric{
+				{Name: "running", Value: 1},
+				{Name: "queued", Value: 2, Warn: float(5), Crit: float(10)},
+			},
+		},
+		{name: "text without perfdata", out: "CRITICAL - connection refused\n", text: "CRITICAL - connection refused"},
+		{
+			name: "JSON list",
+			out:  `[{"name": "queue_depth", "value": 42, "labels": {"queue": "mail"}}, {"name": "lag_seconds", "value": 1.5, "unit": "s", "warn": 5}]`,
+			metrics: []domain.CustomMetric{
+				{Name: "queue_depth", Value: 42, Labels: map[string]string{"queue": "mail"}},
+				{Name: "lag_seconds", Value: 1.5, Unit: "s", Warn: float(5)},
+			},
+		},
+		{
+			name:    "JSON object",
+			out:     `{"metrics": [{"name": "connections", "value": 87}]}`,
+			metrics: []domain.CustomMetric{{Name: "connections", Value: 87}},
+		},
+		// Output that only looks like JSON is the plugin's text
+		{name: "invalid JSON", out: `{"metrics": [{"name": "connections", "value": }]}`, text: `{"metrics": [{"name": "connections", "value": }]}`},
+		{name: "JSON of the wrong shape", out: `{"metrics": {"connections": 87}}`, text: `{"metrics": {"connections": 87}}`},
+		{name: "plain NAME value", out: "queue_depth 42\n", metrics: []domain.CustomMetric{{Name: "queue_depth", Value: 42}}},
+		{
+			name: "lines with labels, comments and blank lines",
+			out:  "# replication\nreplication_lag,db=main,replica=r1 1.5\n\nconnections -3\n",
+			metrics: []domain.CustomMetric{
+				{Name: "replication_lag", Value: 1.5, Labels: map[string]string{"db": "main", "replica": "r1"}},
+				{Name: "connections", Value: -3},
+			},
+		},
+		// A single malformed line makes the whole output text
+		{name: "lines with a malformed one", out: "queue_depth 42\nqueue_state busy\n", text: "queue_depth 42\nqueue_state busy"},
+		{name: "line label without value", out: "queue_depth,mail 42", text: "queue_depth,mail 42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, metrics := parsePluginOutput([]byte(tt.out))
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+			if !reflect.DeepEqual(metrics, tt.metrics) {
+				t.Errorf("metrics =\n%s\nwant\n%s", formatMetrics(metrics), formatMetrics(tt.metrics))
+			}
+		})
+	}
+}
+
+// formatMetrics prints metrics with the values of their thresholds rather than pointers
+func formatMetrics(metrics []domain.CustomMetric) string {
+	var s string
+	for _, m := range metrics {
+		s += m.Name + " " + strconv.FormatFloat(m.Value, 'g', -1, 64) + m.Unit
+		for _, threshold := range []*float64{m.Warn, m.Crit, m.Min, m.Max} {
+			s += ";"
+			if threshold != nil {
+				s += strconv.FormatFloat(*threshold, 'g', -1, 64)
+			}
+		}
+		s += fmt.Sprintf(" %v\n", m.Labels)
+	}
+	return s
+}
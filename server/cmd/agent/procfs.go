@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// ProcCollector reads the pressure stall information, meminfo fields and vmstat counters
+// that gopsutil does not expose. Its root is -proc-root, the host's /proc mounted elsewhere
+// when the agent runs in a container; each file missing on older kernels is reported as nil.
+type ProcCollector struct {
+	root string
+}
+
+func NewProcCollector(root string) *ProcCollector {
+	return &ProcCollector{
+		root: root,
+	}
+}
+
+// Pressure reads /proc/pressure/{cpu,memory,io}, returning nil when PSI is unavailable
+func (p *ProcCollector) Pressure() *domain.PressureMetrics {
+	cpu := readPressure(filepath.Join(p.root, "pressure", "cpu"))
+	memory := readPressure(filepath.Join(p.root, "pressure", "memory"))
+	io := readPressure(filepath.Join(p.root, "pressure", "io"))
+	if cpu == nil && memory == nil && io == nil {
+		return nil
+	}
+	return &domain.PressureMetrics{
+		CPU:    cpu,
+		Memory: memory,
+		IO:     io,
+	}
+}
+
+// MemoryDetail reads the /proc/meminfo fields not covered by MemoryMetrics
+func (p *ProcCollector) MemoryDetail() *domain.MemoryDetailMetrics {
+	f, err := os.Open(filepath.Join(p.root, "meminfo"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	// Lines look like "Dirty:  1234 kB", hugepage counts have no unit
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		values[key] = v
+	}
+
+	return &domain.MemoryDetailMetrics{
+		Dirty:          values["Dirty"],
+		Writeback:      values["Writeback"],
+		Slab:           values["Slab"],
+		SReclaimable:   values["SReclaimable"],
+		SUnreclaim:     values["SUnreclaim"],
+		Shmem:          values["Shmem"],
+		CommittedAS:    values["Committed_AS"],
+		CommitLimit:    values["CommitLimit"],
+		HugePagesTotal: values["HugePages_Total"],
+		HugePagesFree:  values["HugePages_Free"],
+		HugePagesRsvd:  values["HugePages_Rsvd"],
+		HugePagesSurp:  values["HugePages_Surp"],
+		HugePageSize:   values["Hugepagesize"],
+	}
+}
+
+// VMStat reads paging, swap and OOM counters from /proc/vmstat
+func (p *ProcCollector) VMStat() *domain.VMStatMetrics {
+	path := filepath.Join(p.root, "vmstat")
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	values := readKeyValues(path)
+	return &domain.VMStatMetrics{
+		PageFaults:      values["pgfault"],
+		MajorPageFaults: values["pgmajfault"],
+		PagesIn:         values["pgpgin"],
+		PagesOut:        values["pgpgout"],
+		SwapIn:          values["pswpin"],
+		SwapOut:         values["pswpout"],
+		OOMKills:        values["oom_kill"],
+	}
+}
+
+// readPressure parses lines of the form "some avg10=0.00 avg60=0.00 avg300=0.00 total=0"
+func readPressure(path string) *domain.PressureStat {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var stat domain.PressureStat
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var avg domain.PressureAvg
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "avg10":
+				avg.Avg10, _ = strconv.ParseFloat(value, 64)
+			case "avg60":
+				avg.Avg60, _ = strconv.ParseFloat(value, 64)
+			case "avg300":
+				avg.Avg300, _ = strconv.ParseFloat(value, 64)
+			case "total":
+				avg.Total, _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			stat.Some = avg
+			found = true
+		case "full":
+			stat.Full = &avg
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return &stat
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestProcCollectorPressure(t *testing.T) {
+	got := NewProcCollector("testdata/proc").Pressure()
+	want := &domain.PressureMetrics{
+		// Kernels before 5.13 report no full line for cpu
+		CPU: &domain.PressureStat{Some: domain.PressureAvg{Avg10: 1.53, Avg60: 0.87, Avg300: 0.25, Total: 8765432}},
+		Memory: &domain.PressureStat{
+			Some: domain.PressureAvg{Avg60: 0.12, Avg300: 0.04, Total: 123456},
+			Full: &domain.PressureAvg{Avg60: 0.05, Avg300: 0.01, Total: 65432},
+		},
+		IO: &domain.PressureStat{
+			Some: domain.PressureAvg{Avg10: 12.4, Avg60: 8.1, Avg300: 3.3, Total: 987654321},
+			Full: &domain.PressureAvg{Avg10: 10.2, Avg60: 6.7, Avg300: 2.8, Total: 876543210},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Pressure() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// Kernels before 4.20, or without CONFIG_PSI, have no /proc/pressure
+	if got := NewProcCollector("testdata/proc-4.9").Pressure(); got != nil {
+		t.Errorf("Pressure() without /proc/pressure = %+v, want nil", got)
+	}
+}
+
+func TestProcCollectorMemoryDetail(t *testing.T) {
+	tests := []struct {
+		root string
+		want *domain.MemoryDetailMetrics
+	}{
+		{"testdata/proc", &domain.MemoryDetailMetrics{
+			Dirty:          2048 << 10,
+			Writeback:      16 << 10,
+			Slab:           614400 << 10,
+			SReclaimable:   409600 << 10,
+			SUnreclaim:     204800 << 10,
+			Shmem:          131072 << 10,
+			CommittedAS:    12582912 << 10,
+			CommitLimit:    10248860 << 10,
+			HugePagesTotal: 64, // page counts have no unit
+			HugePagesFree:  48,
+			HugePagesRsvd:  8,
+			HugePageSize:   2048 << 10,
+		}},
+		{"testdata/proc-4.9", &domain.MemoryDetailMetrics{
+			Dirty:        128 << 10,
+			Slab:         65536 << 10,
+			SReclaimable: 32768 << 10,
+			SUnreclaim:   32768 << 10,
+			Shmem:        8192 << 10,
+			CommittedAS:  1536000 << 10,
+			CommitLimit:  1024000 << 10,
+			HugePageSize: 2048 << 10,
+		}},
+		{"testdata/missing", nil},
+	}
+	for _, tt := range tests {
+		if got := NewProcCollector(tt.root).MemoryDetail(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MemoryDetail() of %s =\n%+v\nwant\n%+v", tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestProcCollectorVMStat(t *testing.T) {
+	tests := []struct {
+		root string
+		want *domain.VMStatMetrics
+	}{
+		{"testdata/proc", &domain.VMStatMetrics{
+			PageFaults:      5678901234,
+			MajorPageFaults: 4321,
+			PagesIn:         123456789,
+			PagesOut:        987654321,
+			SwapIn:          42,
+			SwapOut:         84,
+			OOMKills:        3,
+		}},
+		// oom_kill appeared in 4.13
+		{"testdata/proc-4.9", &domain.VMStatMetrics{PageFaults: 300000, MajorPageFaults: 12, PagesIn: 1000, PagesOut: 2000}},
+		{"testdata/missing", nil},
+	}
+	for _, tt := range tests {
+		if got := NewProcCollector(tt.root).VMStat(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VMStat() of %s = %+v, want %+v", tt.root, got, tt.want)
+		}
+	}
+}
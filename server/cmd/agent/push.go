@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+)
+
+// Pusher periodically collects a sample, appends it to the on-disk queue and delivers
+// queued samples to the server in batches, oldest first. Samples recorded while the
+// server is unreachable are replayed once it is back. The interval follows the agent's
+// collect_interval setting.
+type Pusher struct {
+	agent     *AgentServer
+	queue     *WALQueue
+	sender    sender
+	agentID   string
+	batchSize int
+	encoding  string // json or protobuf
+
+	// offline is set after a failed delivery so the outage is logged once
+	offline bool
+
+	trigger  chan struct{}
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// sender delivers a batch of queued samples, reporting whether a failure is worth retrying
+type sender interface {
+	send(records []WALRecord) (bool, error)
+	String() string
+}
+
+func NewPusher(agent *AgentServer, queue *WALQueue, sender sender, agentID string, batchSize int, encoding string) *Pusher {
+	return &Pusher{
+		agent:     agent,
+		queue:     queue,
+		sender:    sender,
+		agentID:   agentID,
+		batchSize: batchSize,
+		encoding:  encoding,
+		trigger:   make(chan struct{}, 1),
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// Start begins collecting and pushing samples
+func (p *Pusher) Start() {
+	log.Printf("Pushing metrics to %s every %s (agent ID: %s)", p.sender, time.Duration(p.agent.Settings().CollectInterval), p.agentID)
+	p.wg.Add(1)
+	go p.pushLoop()
+}
+
+// Trigger collects and pushes a sample now instead of waiting for the next tick
+func (p *Pusher) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Stop finishes the current push and stops; undelivered samples stay queued on disk
+func (p *Pusher) Stop() {
+	close(p.stopChan)
+	p.wg.Wait()
+	p.queue.Close()
+}
+
+func (p *Pusher) pushLoop() {
+	defer p.wg.Done()
+
+	interval := time.Duration(p.agent.Settings().CollectInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.sample()
+		p.flush()
+
+		if next := time.Duration(p.agent.Settings().CollectInterval); next != interval {
+			log.Printf("Collect interval changed from %s to %s", interval, next)
+			interval = next
+			ticker.Reset(interval)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-p.trigger:
+		case <-p.stopChan:
+			return
+		}
+	}
+}
+
+// sample collects the current metrics and queues them
+func (p *Pusher) sample() {
+	metrics, err := p.agent.CollectMetrics()
+	if err != nil {
+		log.Printf("Failed to collect metrics: %v", err)
+		return
+	}
+
+	payload, err := encodeSample(p.encoding, &domain.AgentMetrics{AgentID: p.agentID, Metrics: *metrics})
+	if err != nil {
+		log.Printf("Failed to encode metrics: %v", err)
+		return
+	}
+
+	if err := p.queue.Append(metrics.Timestamp, payload); err != nil {
+		log.Printf("Failed to queue metrics: %v", err)
+	}
+}
+
+// flush delivers queued samples batch by batch until the queue is empty or the server fails
+func (p *Pusher) flush() {
+	for {
+		select {
+		case <-p.stopChan:
+			return
+		default:
+		}
+
+		records, next, err := p.queue.Read(p.batchSize)
+		if err != nil {
+			log.Printf("Failed to read queue: %v", err)
+			return
+		}
+		if len(records) > 0 {
+			retry, err := p.sender.send(records)
+			if err != nil && retry {
+				if !p.offline {
+					log.Printf("Server unreachable, queueing samples (%d bytes pending): %v", p.queue.Pending(), err)
+					p.offline = true
+				}
+				return
+			}
+			if err != nil {
+				log.Printf("Server rejected %d sample(s), dropping them: %v", len(records), err)
+			}
+		}
+
+		if err := p.queue.Ack(next); err != nil {
+			log.Printf("Failed to save queue position: %v", err)
+			return
+		}
+		if p.offline && len(records) > 0 {
+			log.Printf("Server reachable again, replaying queued samples (%d bytes pending)", p.queue.Pending())
+			p.offline = false
+		}
+		if len(records) < p.batchSize {
+			return
+		}
+	}
+}
+
+// httpSender posts batches to the HTTP metrics endpoint
+type httpSender struct {
+	url      string
+	encoding string // json or protobuf
+	coding   string // request content coding: gzip, zstd or "" for none
+	client   *http.Client
+}
+
+func newHTTPSender(serverURL, encoding, coding string) *httpSender {
+	return &httpSender{
+		url:      strings.TrimSuffix(serverURL, "/") + "/api/v1/agents/metrics",
+		encoding: encoding,
+		coding:   coding,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *httpSender) String() string { return s.url }
+
+// send posts a batch in the push encoding
+func (s *httpSender) send(records []WALRecord) (bool, error) {
+	body, err := compression.Compress(s.coding, encodeBatch(s.encoding, records))
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType(s.encoding))
+	if s.coding != "" {
+		req.Header.Set("Content-Encoding", s.coding)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+
+	// Client errors other than timeouts and rate limits will not succeed on retry
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
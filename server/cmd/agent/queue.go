@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Every queued record is framed as: length (4 bytes), CRC-32 of the rest (4 bytes),
+// timestamp in Unix nanoseconds (8 bytes), payload. Records live in numbered segment files;
+// the "head" file holds the position of the first record not yet delivered.
+const (
+	walHeaderSize    = 16
+	walSegmentSuffix = ".wal"
+	walHeadFile      = "head"
+	walMaxRecord     = 64 << 20
+)
+
+var errCorruptRecord = errors.New("corrupt queue record")
+
+// WALRecord is one queued payload with the time it was recorded
+type WALRecord struct {
+	At      time.Time
+	Payload []byte
+}
+
+// WALPosition points at a record in the queue
+type WALPosition struct {
+	Segment int64
+	Offset  int64
+}
+
+// WALQueue is a bounded on-disk write-ahead queue. When it grows past maxBytes the oldest
+// segment is dropped, and records older than maxAge are skipped when read.
+type WALQueue struct {
+	dir         string
+	maxBytes    int64
+	maxAge      time.Duration
+	segmentSize int64
+
+	mu       sync.Mutex
+	segments []int64 // ascending segment IDs, the last one being appended to
+	sizes    map[int64]int64
+	head     WALPosition
+	tail     *os.File
+}
+
+// OpenWALQueue opens or creates a queue in dir, discarding a torn record at the end of the last segment
+func OpenWALQueue(dir string, maxBytes int64, maxAge time.Duration) (*WALQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	segmentSize := maxBytes / 16
+	if segmentSize < 64<<10 {
+		segmentSize = 64 << 10
+	}
+	if segmentSize > 8<<20 {
+		segmentSize = 8 << 20
+	}
+
+	q := &WALQueue{
+		dir:         dir,
+		maxBytes:    maxBytes,
+		maxAge:      maxAge,
+		segmentSize: segmentSize,
+		sizes:       make(map[int64]int64),
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		id, err := strconv.ParseInt(strings.TrimSuffix(entry.Name(), walSegmentSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(entry.Name(), walSegmentSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, id)
+		q.sizes[id] = info.Size()
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	if len(q.segments) == 0 {
+		q.segments = []int64{1}
+		q.sizes[1] = 0
+	}
+	if err := q.repairTail(); err != nil {
+		return nil, err
+	}
+
+	q.head = q.readHead()
+
+	last := q.segments[len(q.segments)-1]
+	if q.tail, err = os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// Append adds a record at the end of the queue and syncs it to disk
+func (q *WALQueue) Append(at time.Time, payload []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	record := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint64(record[8:16], uint64(at.UnixNano()))
+	copy(record[walHeaderSize:], payload)
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(record[8:]))
+
+	last := q.segments[len(q.segments)-1]
+	if q.sizes[last] > 0 && q.sizes[last]+int64(len(record)) > q.segmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		last = q.segments[len(q.segments)-1]
+	}
+
+	if _, err := q.tail.Write(record); err != nil {
+		return err
+	}
+	if err := q.tail.Sync(); err != nil {
+		return err
+	}
+	q.sizes[last] += int64(len(record))
+
+	q.enforceSize()
+	return nil
+}
+
+// Read returns up to max records from the head of the queue, skipping expired ones,
+// and the position following them to pass to Ack once they are delivered
+func (q *WALQueue) Read(max int) ([]WALRecord, WALPosition, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var records []WALRecord
+	pos := q.head
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for len(records) < max {
+		if pos.Offset >= q.sizes[pos.Segment] {
+			next, ok := q.nextSegment(pos.Segment)
+			if !ok {
+				break
+			}
+			pos = WALPosition{Segment: next}
+			continue
+		}
+
+		if file == nil || file.Name() != q.segmentPath(pos.Segment) {
+			if file != nil {
+				file.Close()
+			}
+			var err error
+			if file, err = os.Open(q.segmentPath(pos.Segment)); err != nil {
+				return records, pos, err
+			}
+		}
+
+		record, size, err := readRecord(file, pos.Offset)
+		if err != nil {
+			// Skip the unreadable rest of a segment rather than blocking the queue forever
+			log.Printf("Queue: %v in segment %d at offset %d, skipping rest of segment", err, pos.Segment, pos.Offset)
+			pos.Offset = q.sizes[pos.Segment]
+			continue
+		}
+		pos.Offset += size
+
+		if q.maxAge > 0 && time.Since(record.At) > q.maxAge {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, pos, nil
+}
+
+// Ack marks everything before pos as delivered and removes fully delivered segments
+func (q *WALQueue) Ack(pos WALPosition) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// The records may have been dropped for size in the meantime
+	if pos.Segment < q.segments[0] {
+		return nil
+	}
+
+	for len(q.segments) > 1 && q.segments[0] < pos.Segment {
+		q.removeOldest()
+	}
+	q.head = pos
+	return q.writeHead()
+}
+
+// Pending returns the number of bytes not yet delivered
+func (q *WALQueue) Pending() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var total int64
+	for _, id := range q.segments {
+		if id >= q.head.Segment {
+			total += q.sizes[id]
+		}
+	}
+	return total - q.head.Offset
+}
+
+// Close closes the segment being appended to
+func (q *WALQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tail.Close()
+}
+
+func (q *WALQueue) rotate() error {
+	if err := q.tail.Close(); err != nil {
+		return err
+	}
+
+	id := q.segments[len(q.segments)-1] + 1
+	tail, err := os.OpenFile(q.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.tail = tail
+	q.segments = append(q.segments, id)
+	q.sizes[id] = 0
+	return nil
+}
+
+// enforceSize drops the oldest segments while the queue is over its size limit
+func (q *WALQueue) enforceSize() {
+	for len(q.segments) > 1 {
+		var total int64
+		for _, id := range q.segments {
+			total += q.sizes[id]
+		}
+		if total <= q.maxBytes {
+			return
+		}
+
+		dropped := q.segments[0]
+		log.Printf("Queue: over %d bytes, dropping oldest segment %d", q.maxBytes, dropped)
+		q.removeOldest()
+		if q.head.Segment <= dropped {
+			q.head = WALPosition{Segment: q.segments[0]}
+			if err := q.writeHead(); err != nil {
+				log.Printf("Queue: failed to save head: %v", err)
+			}
+		}
+	}
+}
+
+func (q *WALQueue) removeOldest() {
+	id := q.segments[0]
+	if err := os.Remove(q.segmentPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Queue: failed to remove segment %d: %v", id, err)
+	}
+	q.segments = q.segments[1:]
+	delete(q.sizes, id)
+}
+
+func (q *WALQueue) nextSegment(id int64) (int64, bool) {
+	for _, s := range q.segments {
+		if s > id {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// repairTail truncates the last segment after its last complete record
+func (q *WALQueue) repairTail() error {
+	last := q.segments[len(q.segments)-1]
+	file, err := os.OpenFile(q.segmentPath(last), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var offset int64
+	for offset < q.sizes[last] {
+		_, size, err := readRecord(file, offset)
+		if err != nil {
+			break
+		}
+		offset += size
+	}
+
+	if offset < q.sizes[last] {
+		log.Printf("Queue: discarding %d bytes of incomplete record at the end of segment %d", q.sizes[last]-offset, last)
+		if err := file.Truncate(offset); err != nil {
+			return err
+		}
+		q.sizes[last] = offset
+	}
+	return nil
+}
+
+// readHead loads the saved head, falling back to the start of the oldest segment
+func (q *WALQueue) readHead() WALPosition {
+	oldest := WALPosition{Segment: q.segments[0]}
+
+	data, err := os.ReadFile(filepath.Join(q.dir, walHeadFile))
+	if err != nil {
+		return oldest
+	}
+
+	var pos WALPosition
+	if _, err := fmt.Sscanf(string(data), "%d %d", &pos.Segment, &pos.Offset); err != nil {
+		return oldest
+	}
+	if _, ok := q.sizes[pos.Segment]; !ok || pos.Offset > q.sizes[pos.Segment] {
+		return oldest
+	}
+	return pos
+}
+
+func (q *WALQueue) writeHead() error {
+	path := filepath.Join(q.dir, walHeadFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", q.head.Segment, q.head.Offset)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (q *WALQueue) segmentPath(id int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%016d%s", id, walSegmentSuffix))
+}
+
+// readRecord reads and verifies the record at offset, returning it and its framed size
+func readRecord(r io.ReaderAt, offset int64) (WALRecord, int64, error) {
+	header := make([]byte, walHeaderSize)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return WALRecord{}, 0, errCorruptRecord
+	}
+
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if length > walMaxRecord {
+		return WALRecord{}, 0, errCorruptRecord
+	}
+	record := make([]byte, 8+length)
+	copy(record, header[8:16])
+	if _, err := r.ReadAt(record[8:], offset+walHeaderSize); err != nil {
+		return WALRecord{}, 0, errCorruptRecord
+	}
+	if crc32.ChecksumIEEE(record) != binary.BigEndian.Uint32(header[4:8]) {
+		return WALRecord{}, 0, errCorruptRecord
+	}
+
+	return WALRecord{
+		At:      time.Unix(0, int64(binary.BigEndian.Uint64(record[0:8]))),
+		Payload: record[8:],
+	}, walHeaderSize + length, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// openQueue opens a queue in dir, closing it at the end of the test
+func openQueue(t *testing.T, dir string, maxBytes int64, maxAge time.Duration) *WALQueue {
+	t.Helper()
+	q, err := OpenWALQueue(dir, maxBytes, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+func appendPayloads(t *testing.T, q *WALQueue, at time.Time, payloads ...string) {
+	t.Helper()
+	for _, payload := range payloads {
+		if err := q.Append(at, []byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readPayloads reads up to max records and returns their payloads and the position after them
+func readPayloads(t *testing.T, q *WALQueue, max int) ([]string, WALPosition) {
+	t.Helper()
+	records, pos, err := q.Read(max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads []string
+	for _, record := range records {
+		payloads = append(payloads, string(record.Payload))
+	}
+	return payloads, pos
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+walSegmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestWALQueueTornTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tear func(segment []byte) []byte
+		lost int // bytes of complete records discarded with the torn one
+		want []string
+	}{
+		{"partial header", func(segment []byte) []byte {
+			return append(segment, 0, 0, 0, 5, 0xde, 0xad)
+		}, 0, []string{"one", "two", "three", "four"}},
+		{"partial payload", func(segment []byte) []byte {
+			last := segment[len(segment)-(walHeaderSize+len("three")):]
+			return append(segment, last[:walHeaderSize+2]...)
+		}, 0, []string{"one", "two", "three", "four"}},
+		{"bad checksum", func(segment []byte) []byte {
+			segment[len(segment)-1] ^= 0xff
+			return segment
+		}, walHeaderSize + len("three"), []string{"one", "two", "four"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			q := openQueue(t, dir, 1<<20, 0)
+			appendPayloads(t, q, time.Now(), "one", "two", "three")
+			q.Close()
+
+			path := segmentFiles(t, dir)[0]
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			intact := int64(len(data) - tt.lost)
+			if err := os.WriteFile(path, tt.tear(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			q = openQueue(t, dir, 1<<20, 0)
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Size() != intact {
+				t.Fatalf("segment is %d bytes after repair, want %d", info.Size(), intact)
+			}
+
+			appendPayloads(t, q, time.Now(), "four")
+			if payloads, _ := readPayloads(t, q, 10); !slices.Equal(payloads, tt.want) {
+				t.Fatalf("Read() = %q, want %q", payloads, tt.want)
+			}
+		})
+	}
+}
+
+func TestWALQueueHeadAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 1<<20, 0)
+	appendPayloads(t, q, time.Now(), "one", "two", "three")
+
+	payloads, pos := readPayloads(t, q, 2)
+	if !slices.Equal(payloads, []string{"one", "two"}) {
+		t.Fatalf("Read(2) = %q", payloads)
+	}
+	if err := q.Ack(pos); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openQueue(t, dir, 1<<20, 0)
+	if pending, want := q.Pending(), int64(walHeaderSize+len("three")); pending != want {
+		t.Errorf("Pending() = %d, want %d", pending, want)
+	}
+	payloads, pos = readPayloads(t, q, 10)
+	if !slices.Equal(payloads, []string{"three"}) {
+		t.Fatalf("Read() after reopen = %q, want [three]", payloads)
+	}
+
+	// Reading again without an Ack redelivers the same records
+	if again, _ := readPayloads(t, q, 10); !slices.Equal(again, payloads) {
+		t.Fatalf("second Read() = %q, want %q", again, payloads)
+	}
+	if err := q.Ack(pos); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openQueue(t, dir, 1<<20, 0)
+	if payloads, _ := readPayloads(t, q, 10); len(payloads) != 0 || q.Pending() != 0 {
+		t.Fatalf("Read() after everything was acknowledged = %q, %d bytes pending", payloads, q.Pending())
+	}
+}
+
+func TestWALQueueSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	// 64 KiB segments, each holding four records of 16 KiB
+	const maxBytes = 256 << 10
+	q := openQueue(t, dir, maxBytes, 0)
+	filler := string(make([]byte, 16<<10-walHeaderSize))
+
+	for i := 0; i < 24; i++ {
+		appendPayloads(t, q, time.Now(), fmt.Sprintf("%02d", i)+filler[2:])
+	}
+
+	if files := segmentFiles(t, dir); len(files) != 4 {
+		t.Errorf("%d segment files, want 4: %q", len(files), files)
+	}
+	if pending := q.Pending(); pending > maxBytes {
+		t.Errorf("Pending() = %d, over the %d bytes limit", pending, maxBytes)
+	}
+
+	records, _, err := q.Read(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 16 {
+		t.Fatalf("Read() = %d records, want the 16 of the newest segments", len(records))
+	}
+	for i, record := range records {
+		if got, want := string(record.Payload[:2]), fmt.Sprintf("%02d", i+8); got != want {
+			t.Fatalf("record %d is %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestWALQueueAckAfterDrop(t *testing.T) {
+	dir := t.TempDir()
+	q := openQueue(t, dir, 256<<10, 0)
+	filler := string(make([]byte, 16<<10-walHeaderSize))
+	at := time.Now()
+
+	appendPayloads(t, q, at, "a"+filler[1:], "b"+filler[1:])
+	records, pos, err := q.Read(2)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Read(2) = %d records, %v", len(records), err)
+	}
+
+	// While the records are being delivered, the queue grows past its limit and drops them
+	for i := 0; i < 22; i++ {
+		appendPayloads(t, q, at, filler)
+	}
+	if err := q.Ack(pos); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _, err = q.Read(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 16 {
+		t.Fatalf("Read() after Ack = %d records, want the 16 not yet delivered", len(records))
+	}
+	for _, record := range records {
+		if record.Payload[0] != 0 {
+			t.Fatalf("Read() after Ack returned the dropped record %q", record.Payload[:1])
+		}
+	}
+
+	// The head is still valid after a reopen
+	q.Close()
+	q = openQueue(t, dir, 256<<10, 0)
+	if records, _, err := q.Read(100); err != nil || len(records) != 16 {
+		t.Fatalf("Read() after reopen = %d records, %v", len(records), err)
+	}
+}
+
+func TestWALQueueMaxAge(t *testing.T) {
+	q := openQueue(t, t.TempDir(), 1<<20, time.Hour)
+	now := time.Now()
+	appendPayloads(t, q, now.Add(-2*time.Hour), "expired")
+	appendPayloads(t, q, now.Add(-30*time.Minute), "recent")
+	appendPayloads(t, q, now.Add(-3*time.Hour), "expired too")
+	appendPayloads(t, q, now, "new")
+
+	records, pos, err := q.Read(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads []string
+	for _, record := range records {
+		payloads = append(payloads, string(record.Payload))
+	}
+	if !slices.Equal(payloads, []string{"recent", "new"}) {
+		t.Fatalf("Read() = %q, want [recent new]", payloads)
+	}
+	if !records[0].At.Equal(now.Add(-30 * time.Minute)) {
+		t.Errorf("record time = %s, want %s", records[0].At, now.Add(-30*time.Minute))
+	}
+
+	// The position passes the expired records too, so that they are acknowledged with the rest
+	if err := q.Ack(pos); err != nil {
+		t.Fatal(err)
+	}
+	if pending := q.Pending(); pending != 0 {
+		t.Errorf("Pending() after Ack = %d, want 0", pending)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+var serviceProperties = []string{
+	"Id", "LoadState", "ActiveState", "SubState", "NRestarts",
+	"MainPID", "MemoryCurrent", "CPUUsageNSec", "ControlGroup",
+}
+
+// ServiceCollector reports the state of configured systemd units
+type ServiceCollector struct {
+	cgroupRoot string
+
+	mu       sync.Mutex
+	units    []string
+	previous map[string]cpuSample
+}
+
+func NewServiceCollector(units []string, cgroupRoot string) *ServiceCollector {
+	return &ServiceCollector{
+		units:      units,
+		cgroupRoot: cgroupRoot,
+		previous:   make(map[string]cpuSample),
+	}
+}
+
+// SetUnits replaces the units to report
+func (s *ServiceCollector) SetUnits(units []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.units = units
+}
+
+// Collect queries systemd for every configured unit
+func (s *ServiceCollector) Collect() ([]domain.ServiceStatus, error) {
+	s.mu.Lock()
+	units := s.units
+	s.mu.Unlock()
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	args := []string{"show", "--property=" + strings.Join(serviceProperties, ",")}
+	args = append(args, units...)
+	out, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.parse(out, units, time.Now()), nil
+}
+
+// parse reads the output of systemctl show for units, computing CPU usage since the
+// previous output; s.mu must be held
+func (s *ServiceCollector) parse(out []byte, units []string, now time.Time) []domain.ServiceStatus {
+	var services []domain.ServiceStatus
+
+	// systemctl show prints one block of properties per unit, separated by blank lines
+	for i, block := range bytes.Split(bytes.TrimSpace(out), []byte("\n\n")) {
+		props := parseProperties(block)
+		unit := props["Id"]
+		if unit == "" && i < len(units) {
+			unit = units[i]
+		}
+
+		status := domain.ServiceStatus{
+			Unit:        unit,
+			LoadState:   props["LoadState"],
+			ActiveState: props["ActiveState"],
+			SubState:    props["SubState"],
+		}
+		status.Restarts, _ = strconv.ParseUint(props["NRestarts"], 10, 64)
+		status.MainPID, _ = strconv.Atoi(props["MainPID"])
+
+		status.MemoryBytes, status.CPUUsageNanos = s.cgroupUsage(props)
+
+		if prev, ok := s.previous[unit]; ok && status.CPUUsageNanos >= prev.usage {
+			elapsed := now.Sub(prev.at).Nanoseconds()
+			if elapsed > 0 {
+				status.CPUPercent = float64(status.CPUUsageNanos-prev.usage) / float64(elapsed) * 100
+			}
+		}
+		s.previous[unit] = cpuSample{usage: status.CPUUsageNanos, at: now}
+
+		services = append(services, status)
+	}
+
+	return services
+}
+
+// cgroupUsage returns memory and CPU usage from systemd accounting,
+// falling back to the unit's cgroup when accounting is disabled
+func (s *ServiceCollector) cgroupUsage(props map[string]string) (memory, cpuNanos uint64) {
+	memory, memErr := strconv.ParseUint(props["MemoryCurrent"], 10, 64)
+	cpuNanos, cpuErr := strconv.ParseUint(props["CPUUsageNSec"], 10, 64)
+
+	// systemd reports unset values as "[not set]" or as the max uint64
+	memSet := memErr == nil && memory != ^uint64(0)
+	cpuSet := cpuErr == nil && cpuNanos != ^uint64(0)
+	if !memSet {
+		memory = 0
+	}
+	if !cpuSet {
+		cpuNanos = 0
+	}
+
+	cgroup := props["ControlGroup"]
+	if (memSet && cpuSet) || cgroup == "" || s.cgroupRoot == "" {
+		return memory, cpuNanos
+	}
+
+	unified := filepath.Join(s.cgroupRoot, cgroup)
+	if _, err := os.Stat(filepath.Join(unified, "cgroup.procs")); err == nil {
+		if !memSet {
+			memory = readUint(filepath.Join(unified, "memory.current"))
+		}
+		if !cpuSet {
+			cpuNanos = readKeyValues(filepath.Join(unified, "cpu.stat"))["usage_usec"] * 1000
+		}
+		return memory, cpuNanos
+	}
+
+	if !memSet {
+		memory = readUint(filepath.Join(s.cgroupRoot, "memory", cgroup, "memory.usage_in_bytes"))
+	}
+	if !cpuSet {
+		cpuNanos = readUint(filepath.Join(s.cgroupRoot, "cpuacct", cgroup, "cpuacct.usage"))
+	}
+	return memory, cpuNanos
+}
+
+func parseProperties(block []byte) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(block))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			props[key] = value
+		}
+	}
+	return props
+}
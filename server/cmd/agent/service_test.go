@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestServiceCollectorParse(t *testing.T) {
+	out, err := os.ReadFile("testdata/systemctl-show.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []string{"nginx.service", "postgresql.service", "missing.service", "worker.service"}
+	s := NewServiceCollector(units, "testdata/cgroup-v2")
+	at := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	want := []domain.ServiceStatus{
+		{Unit: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 2, MainPID: 1234, MemoryBytes: 50 << 20, CPUUsageNanos: 1.5e9},
+		// Without systemd accounting, usage is read from the unit's cgroup
+		{Unit: "postgresql.service", LoadState: "loaded", ActiveState: domain.ServiceStateFailed, SubState: "failed", Restarts: 5, MemoryBytes: 256 << 20, CPUUsageNanos: 42e9},
+		{Unit: "missing.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+		// A block without an Id is the unit asked for in its position; the unit has no cgroup
+		{Unit: "worker.service", LoadState: "loaded", ActiveState: "activating", SubState: "auto-restart", Restarts: 17},
+	}
+	if got := s.parse(out, units, at); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parse() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// CPU usage is the share of the time since the previous output
+	later := strings.Replace(string(out), "CPUUsageNSec=1500000000", "CPUUsageNSec=2000000000", 1)
+	got := s.parse([]byte(later), units, at.Add(10*time.Second))
+	if got[0].CPUPercent != 5 {
+		t.Errorf("nginx.service CPUPercent = %v, want 5", got[0].CPUPercent)
+	}
+	if got[1].CPUPercent != 0 {
+		t.Errorf("postgresql.service CPUPercent = %v with unchanged usage, want 0", got[1].CPUPercent)
+	}
+}
+
+func TestServiceCollectorCgroupV1(t *testing.T) {
+	s := NewServiceCollector(nil, "testdata/cgroup-v1")
+	memory, cpu := s.cgroupUsage(parseProperties([]byte(
+		"MemoryCurrent=18446744073709551615\nCPUUsageNSec=700000000\nControlGroup=/system.slice/redis-server.service\n")))
+	if memory != 32<<20 || cpu != 7e8 {
+		t.Errorf("cgroupUsage() = %d, %d, want %d from the memory controller and %d from systemd", memory, cpu, 32<<20, int64(7e8))
+	}
+}
+
+func TestParseProperties(t *testing.T) {
+	got := parseProperties([]byte("Id=nginx.service\nDescription=A=B\nMalformed\nControlGroup=\n"))
+	want := map[string]string{"Id": "nginx.service", "Description": "A=B", "ControlGroup": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseProperties() = %v, want %v", got, want)
+	}
+}
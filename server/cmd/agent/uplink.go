@@ -0,0 +1,595 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/agentpb"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Protocols the agent can use to talk to the server
+const (
+	protocolAuto = "auto" // gRPC when the server offers it and it is reachable, HTTP otherwise
+	protocolGRPC = "grpc"
+	protocolHTTP = "http"
+)
+
+// UplinkOptions configures the connection to the monitoring server
+type UplinkOptions struct {
+	ServerURL         string
+	AgentID           string // empty to use the ID file or register
+	IDFile            string
+	Protocol          string
+	Advertise         string // host:port the server reaches the agent's HTTP endpoints on
+	Tags              []string
+	Description       string
+	HeartbeatInterval time.Duration
+	ConfigInterval    time.Duration // how often to fetch the server-managed config
+	PushBatch         int
+	Encoding          string
+	Coding            string
+}
+
+// Uplink connects the agent to the monitoring server. It negotiates the protocol, registers
+// the agent when it has no ID yet, then pushes metrics, ships logs, sends heartbeats and
+// keeps the server-managed config applied; over gRPC it also serves commands. Until the
+// server is reachable it retries every collect interval.
+type Uplink struct {
+	agent *AgentServer
+	queue *WALQueue
+	opts  UplinkOptions
+
+	conn   *grpc.ClientConn
+	pusher *Pusher
+
+	// configETag is the ETag of the last config fetched, sent back as If-None-Match
+	configETag string
+	reload     chan struct{}
+	beat       chan struct{}
+
+	mu       sync.Mutex // guards pusher against Stop
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewUplink(agent *AgentServer, queue *WALQueue, opts UplinkOptions) *Uplink {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Uplink{
+		agent:  agent,
+		queue:  queue,
+		opts:   opts,
+		reload: make(chan struct{}, 1),
+		beat:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start connects to the server in the background
+func (u *Uplink) Start() {
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		for {
+			err := u.connect()
+			if err == nil {
+				return
+			}
+			if u.ctx.Err() != nil {
+				return
+			}
+			retry := time.Duration(u.agent.Settings().CollectInterval)
+			log.Printf("Failed to connect to %s, retrying in %s: %v", u.opts.ServerURL, retry, err)
+
+			select {
+			case <-time.After(retry):
+			case <-u.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the connection; undelivered samples stay queued on disk
+func (u *Uplink) Stop() {
+	u.stopOnce.Do(func() {
+		u.cancel()
+		u.wg.Wait()
+
+		u.mu.Lock()
+		if u.pusher != nil {
+			u.pusher.Stop()
+		} else {
+			u.queue.Close()
+		}
+		u.mu.Unlock()
+
+		if u.conn != nil {
+			u.conn.Close()
+		}
+	})
+}
+
+// connect negotiates the protocol, registers the agent if needed and starts pushing
+func (u *Uplink) connect() error {
+	agentID := u.opts.AgentID
+	if agentID == "" {
+		agentID = readAgentID(u.opts.IDFile)
+	}
+
+	var client *agentpb.Client
+	var grpcAddr string
+	if u.opts.Protocol != protocolHTTP {
+		addr, err := negotiateGRPC(u.opts.ServerURL)
+		if err != nil {
+			return err
+		}
+		if addr == "" && u.opts.Protocol == protocolGRPC {
+			return errors.New("server does not offer gRPC")
+		}
+
+		if addr != "" {
+			conn, err := dialGRPC(addr)
+			if err != nil {
+				return err
+			}
+			client = agentpb.NewClient(conn)
+
+			id, err := u.registerGRPC(client, agentID)
+			if err != nil {
+				conn.Close()
+				// A blocked or unreachable gRPC port falls back to HTTP in auto mode
+				if code := status.Code(err); u.opts.Protocol == protocolAuto && (code == codes.Unavailable || code == codes.DeadlineExceeded) {
+					log.Printf("gRPC unreachable at %s, falling back to HTTP: %v", addr, err)
+					client = nil
+				} else {
+					return err
+				}
+			} else {
+				u.conn, agentID, grpcAddr = conn, id, addr
+			}
+		}
+	}
+
+	if client == nil && agentID == "" {
+		id, err := u.registerHTTP()
+		if err != nil {
+			return err
+		}
+		agentID = id
+	}
+
+	if u.opts.AgentID == "" {
+		if err := writeAgentID(u.opts.IDFile, agentID); err != nil {
+			log.Printf("Failed to save agent ID to %s: %v", u.opts.IDFile, err)
+		}
+	}
+
+	var snd sender
+	if client != nil {
+		snd = newGRPCSender(client, grpcAddr, agentID)
+	} else {
+		snd = newHTTPSender(u.opts.ServerURL, u.opts.Encoding, u.opts.Coding)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.ctx.Err() != nil {
+		return nil
+	}
+	u.pusher = NewPusher(u.agent, u.queue, snd, agentID, u.opts.PushBatch, u.opts.Encoding)
+	u.pusher.Start()
+
+	heartbeat := u.httpHeartbeat
+	if client != nil {
+		heartbeat = func(ctx context.Context, agentID string) error {
+			_, err := client.Heartbeat(ctx, &agentpb.HeartbeatRequest{
+				AgentID:       agentID,
+				Status:        "online",
+				ConfigVersion: u.agent.ConfigVersion(),
+			})
+			return err
+		}
+		u.wg.Add(1)
+		go u.serveCommands(u.ctx, client, agentID)
+	}
+
+	u.wg.Add(2)
+	go u.sendHeartbeats(u.ctx, heartbeat, agentID)
+	go u.syncConfig(u.ctx, agentID)
+	if u.agent.logs != nil {
+		u.wg.Add(1)
+		go u.shipLogs(u.ctx, agentID)
+	}
+	return nil
+}
+
+// ReloadConfig fetches the server-managed config now instead of waiting for the next poll
+func (u *Uplink) ReloadConfig() {
+	select {
+	case u.reload <- struct{}{}:
+	default:
+	}
+}
+
+// sendHeartbeats reports the agent online, with the config version it runs, every heartbeat
+// interval and right after a new config is applied, until ctx is done
+func (u *Uplink) sendHeartbeats(ctx context.Context, heartbeat func(context.Context, string) error, agentID string) {
+	defer u.wg.Done()
+
+	ticker := time.NewTicker(u.opts.HeartbeatInterval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-u.beat:
+		case <-ctx.Done():
+			return
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := heartbeat(callCtx, agentID)
+		cancel()
+		if err != nil && !failing {
+			log.Printf("Heartbeat failed: %v", err)
+		}
+		failing = err != nil
+	}
+}
+
+// httpHeartbeat sends a heartbeat through the HTTP API
+func (u *Uplink) httpHeartbeat(ctx context.Context, agentID string) error {
+	body, err := json.Marshal(domain.AgentHeartbeat{
+		AgentID:       agentID,
+		Timestamp:     time.Now(),
+		Status:        "online",
+		ConfigVersion: u.agent.ConfigVersion(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(u.opts.ServerURL, "/")+"/api/v1/agents/heartbeat", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// syncConfig fetches and applies the server-managed config at start, every config interval
+// and when asked to reload, until ctx is done
+func (u *Uplink) syncConfig(ctx context.Context, agentID string) {
+	defer u.wg.Done()
+
+	ticker := time.NewTicker(u.opts.ConfigInterval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		err := u.fetchConfig(ctx, agentID)
+		if err != nil && !failing && ctx.Err() == nil {
+			log.Printf("Failed to fetch config: %v", err)
+		}
+		failing = err != nil
+
+		select {
+		case <-ticker.C:
+		case <-u.reload:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// fetchConfig gets the effective config the server stores for the agent and applies it
+// when its version changed, then reports the new version with a heartbeat
+func (u *Uplink) fetchConfig(ctx context.Context, agentID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(u.opts.ServerURL, "/")+"/api/v1/agents/"+url.PathEscape(agentID)+"/config", nil)
+	if err != nil {
+		return err
+	}
+	if u.configETag != "" {
+		req.Header.Set("If-None-Match", u.configETag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data struct {
+			Version  string          `json:"version"`
+			Settings json.RawMessage `json:"settings"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return fmt.Errorf("invalid config response: %w", err)
+	}
+
+	etag := resp.Header.Get("ETag")
+	if result.Data.Version == u.agent.ConfigVersion() {
+		u.configETag = etag
+		return nil
+	}
+	if err := u.agent.SetServerSettings(result.Data.Version, result.Data.Settings); err != nil {
+		return err
+	}
+	u.configETag = etag
+	log.Printf("Applied server config version %s", result.Data.Version)
+
+	select {
+	case u.beat <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// shipLogs sends the lines the log collector read to the server in batches, every flush
+// interval, until ctx is done. Lines are kept while the server is unreachable and dropped
+// when it rejects them.
+func (u *Uplink) shipLogs(ctx context.Context, agentID string) {
+	defer u.wg.Done()
+
+	interval := time.Duration(u.agent.Settings().Logs.FlushInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		settings := u.agent.Settings().Logs
+		if next := time.Duration(settings.FlushInterval); next != interval {
+			interval = next
+			ticker.Reset(interval)
+		}
+
+		for {
+			lines := u.agent.logs.Pending(settings.BatchSize)
+			if len(lines) == 0 {
+				break
+			}
+
+			retry, err := u.postLogs(ctx, agentID, lines)
+			if err != nil && retry {
+				if !failing && ctx.Err() == nil {
+					log.Printf("Failed to ship logs, keeping them: %v", err)
+				}
+				failing = true
+				break
+			}
+			if err != nil {
+				log.Printf("Server rejected %d log line(s), dropping them: %v", len(lines), err)
+			}
+			u.agent.logs.Commit(len(lines))
+			failing = false
+
+			if len(lines) < settings.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// postLogs sends a batch of log lines through the HTTP API, reporting whether a failure is
+// worth retrying
+func (u *Uplink) postLogs(ctx context.Context, agentID string, lines []domain.LogLine) (bool, error) {
+	data, err := json.Marshal(domain.LogBatch{Lines: lines})
+	if err != nil {
+		return false, err
+	}
+	body, err := compression.Compress(u.opts.Coding, data)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(u.opts.ServerURL, "/")+"/api/v1/agents/"+url.PathEscape(agentID)+"/logs", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if u.opts.Coding != "" {
+		req.Header.Set("Content-Encoding", u.opts.Coding)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+		return false, nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("server returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+
+	// Client errors other than timeouts and rate limits will not succeed on retry
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, err
+}
+
+// registerGRPC registers the agent over gRPC, updating its details when it already has an
+// ID, and returns the ID. An ID the server no longer knows is replaced by a new registration.
+func (u *Uplink) registerGRPC(client *agentpb.Client, agentID string) (string, error) {
+	info := u.agent.Info()
+	req := &agentpb.RegisterRequest{
+		AgentID:     agentID,
+		Name:        info.Name,
+		Host:        u.opts.Advertise,
+		Hostname:    info.Hostname,
+		IPAddress:   info.IPAddress,
+		Version:     info.Version,
+		Tags:        u.opts.Tags,
+		Description: u.opts.Description,
+	}
+
+	ctx, cancel := context.WithTimeout(u.ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := client.Register(ctx, req)
+	if status.Code(err) == codes.NotFound && u.opts.AgentID == "" {
+		log.Printf("Agent ID %s is unknown to the server, registering again", agentID)
+		req.AgentID = ""
+		resp, err = client.Register(ctx, req)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Registered over gRPC as %s", resp.Agent.ID)
+	return resp.Agent.ID, nil
+}
+
+// registerHTTP registers the agent through the HTTP API, which checks it can reach the
+// agent's /info endpoint, and returns the assigned ID
+func (u *Uplink) registerHTTP() (string, error) {
+	body, err := json.Marshal(domain.AgentRegistration{
+		Name:        u.agent.name,
+		Host:        u.opts.Advertise,
+		Tags:        u.opts.Tags,
+		Description: u.opts.Description,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(strings.TrimSuffix(u.opts.ServerURL, "/")+"/api/v1/agents/register", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success bool         `json:"success"`
+		Message string       `json:"message"`
+		Error   string       `json:"error"`
+		Data    domain.Agent `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid registration response (status %d): %w", resp.StatusCode, err)
+	}
+	if !result.Success || result.Data.ID == "" {
+		return "", fmt.Errorf("registration failed: %s %s", result.Message, result.Error)
+	}
+
+	log.Printf("Registered over HTTP as %s", result.Data.ID)
+	return result.Data.ID, nil
+}
+
+// negotiateGRPC asks the server which protocols it offers and returns its gRPC address,
+// or "" when it only offers HTTP. Servers predating gRPC do not know the endpoint.
+func negotiateGRPC(serverURL string) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(serverURL, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(base.String() + "/api/v1/agents/protocols")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("protocol negotiation returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data struct {
+			GRPC string `json:"grpc"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid protocol negotiation response: %w", err)
+	}
+
+	addr := result.Data.GRPC
+	if strings.HasPrefix(addr, ":") {
+		addr = net.JoinHostPort(base.Hostname(), addr[1:])
+	}
+	return addr, nil
+}
+
+// readAgentID returns the ID saved by a previous registration, or "" if there is none
+func readAgentID(path string) string {
+	if path == "" {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// writeAgentID saves the agent ID so restarts keep the same identity
+func writeAgentID(path, agentID string) error {
+	if path == "" || readAgentID(path) == agentID {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(agentID+"\n"), 0644)
+}
@@ -0,0 +1,499 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file read when none is given and it exists
+const DefaultFile = "config.yaml"
+
+// AppConfig is the server configuration: defaults, overridden by the YAML config file,
+// overridden by environment variables. The agents, cors and terminal sections are applied
+// again when the config is reloaded; changes to the others need a restart.
+type AppConfig struct {
+	Port   string `yaml:"port"`
+	Env    string `yaml:"env"`
+	DBPath string `yaml:"db_path"`
+
+	Supabase   SupabaseConfig   `yaml:"supabase"`
+	StatsD     StatsDConfig     `yaml:"statsd"`
+	EnvMetrics EnvMetricsConfig `yaml:"env_metrics"`
+	MQTT       MQTTConfig       `yaml:"mqtt"`
+	GRPC       GRPCConfig       `yaml:"grpc"`
+	Discovery  DiscoveryConfig  `yaml:"discovery"`
+	Logs       LogsConfig       `yaml:"logs"`
+
+	Agents   AgentsConfig   `yaml:"agents"`
+	CORS     CORSConfig     `yaml:"cors"`
+	Terminal TerminalConfig `yaml:"terminal"`
+}
+
+// SupabaseConfig selects Supabase for env_metrics when both values are set
+type SupabaseConfig struct {
+	URL string `yaml:"url"`
+	Key string `yaml:"key"`
+}
+
+// StatsDConfig configures the StatsD listener, disabled when Addr is empty
+type StatsDConfig struct {
+	Addr          string        `yaml:"addr"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	Percentiles   []float64     `yaml:"percentiles"`
+}
+
+// EnvMetricsConfig holds the API keys accepted by POST /api/v1/env-metrics and the embedded MQTT broker
+type EnvMetricsConfig struct {
+	APIKeys []string `yaml:"api_keys"`
+}
+
+// MQTTConfig configures the sensor subscriber, disabled when both Broker and EmbeddedAddr are empty
+type MQTTConfig struct {
+	Broker       string   `yaml:"broker"`
+	EmbeddedAddr string   `yaml:"embedded_addr"`
+	ClientID     string   `yaml:"client_id"`
+	Username     string   `yaml:"username"`
+	Password     string   `yaml:"password"`
+	Topics       []string `yaml:"topics"`
+	QoS          byte     `yaml:"qos"`
+}
+
+// GRPCConfig configures the gRPC agent service, disabled unless Addr is set. AdvertiseAddr
+// is the address announced to agents negotiating the protocol, defaulting to Addr.
+type GRPCConfig struct {
+	Addr          string `yaml:"addr"`
+	AdvertiseAddr string `yaml:"advertise_addr"`
+}
+
+// DiscoveryConfig configures the discovery of agents, disabled when no scan CIDRs, files or
+// SRV names are set
+type DiscoveryConfig struct {
+	// Interval is how often the CIDRs are scanned and the SRV names looked up
+	Interval time.Duration `yaml:"interval"`
+	// Timeout bounds the check of a found agent's /info
+	Timeout time.Duration `yaml:"timeout"`
+	// Concurrency limits the agents checked at once
+	Concurrency int `yaml:"concurrency"`
+	// Approval queues found agents for approval instead of registering them
+	Approval    bool                `yaml:"approval"`
+	DefaultTags []string            `yaml:"default_tags"`
+	Scan        DiscoveryScanConfig `yaml:"scan"`
+	// Files are target lists in the Prometheus file_sd format, JSON or YAML; globs are expanded
+	Files []string `yaml:"files"`
+	// FileRefresh is how often the files are checked for changes
+	FileRefresh time.Duration `yaml:"file_refresh"`
+	// SRV names are looked up for the host and port of agents
+	SRV []string `yaml:"srv"`
+}
+
+// DiscoveryScanConfig lists the networks scanned for agents and the ports tried on each address
+type DiscoveryScanConfig struct {
+	CIDRs []string `yaml:"cidrs"`
+	Ports []int    `yaml:"ports"`
+}
+
+// Enabled reports whether any discovery provider is configured
+func (d *DiscoveryConfig) Enabled() bool {
+	return len(d.Scan.CIDRs) > 0 || len(d.Files) > 0 || len(d.SRV) > 0
+}
+
+// maxScanAddresses limits the addresses of a scanned CIDR
+const maxScanAddresses = 1 << 16
+
+// LogsConfig limits the log lines kept from agents
+type LogsConfig struct {
+	// Retention is how long lines are kept
+	Retention time.Duration `yaml:"retention"`
+	// MaxLinesPerAgent bounds the lines kept of each agent, the oldest going first
+	MaxLinesPerAgent int `yaml:"max_lines_per_agent"`
+}
+
+// AgentsConfig configures how the server talks to agents
+type AgentsConfig struct {
+	// PollInterval is how often agents that do not push are polled
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// PullTimeout bounds a poll of an agent's /metrics
+	PullTimeout time.Duration `yaml:"pull_timeout"`
+	// RegisterTimeout bounds the check of an agent's /info at HTTP registration
+	RegisterTimeout time.Duration `yaml:"register_timeout"`
+}
+
+// CORSConfig lists the origins allowed to call the API from a browser, "*" allowing any
+type CORSConfig struct {
+	AllowOrigins []string `yaml:"allow_origins"`
+}
+
+// TerminalConfig configures the WebSocket terminal
+type TerminalConfig struct {
+	// IdleTimeout closes sessions without input for this long
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// CleanupInterval is how often idle sessions are looked for
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+func defaultAppConfig() AppConfig {
+	return AppConfig{
+		Port:   "8080",
+		Env:    "development",
+		DBPath: "./data/monitoring.db",
+		StatsD: StatsDConfig{
+			FlushInterval: 10 * time.Second,
+			Percentiles:   []float64{50, 90, 95, 99},
+		},
+		MQTT: MQTTConfig{
+			ClientID: "realtime-monitoring-server",
+			Topics:   []string{"sensors/#"},
+			QoS:      1,
+		},
+		Discovery: DiscoveryConfig{
+			Interval:    10 * time.Minute,
+			Timeout:     2 * time.Second,
+			Concurrency: 64,
+			Scan: DiscoveryScanConfig{
+				Ports: []int{9090},
+			},
+			FileRefresh: 30 * time.Second,
+		},
+		Logs: LogsConfig{
+			Retention:        7 * 24 * time.Hour,
+			MaxLinesPerAgent: 1000000,
+		},
+		Agents: AgentsConfig{
+			PollInterval:    30 * time.Second,
+			PullTimeout:     10 * time.Second,
+			RegisterTimeout: 10 * time.Second,
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{"*"},
+		},
+		Terminal: TerminalConfig{
+			IdleTimeout:     30 * time.Minute,
+			CleanupInterval: 5 * time.Minute,
+		},
+	}
+}
+
+// LoadApp builds the configuration from the defaults, the YAML file at path and the
+// environment, then validates it. An empty path reads DefaultFile if it exists. Every
+// problem found is reported, each prefixed with the key or variable it concerns.
+func LoadApp(path string) (*AppConfig, error) {
+	app := defaultAppConfig()
+
+	required := path != ""
+	if path == "" {
+		path = DefaultFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, err
+	}
+	if err == nil {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&app); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	var errs []error
+	for _, override := range envOverrides {
+		value, ok := os.LookupEnv(override.key)
+		if !ok || (value == "" && !override.allowEmpty) {
+			continue
+		}
+		if err := override.set(&app, strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", override.key, err))
+		}
+	}
+	if app.GRPC.AdvertiseAddr == "" {
+		app.GRPC.AdvertiseAddr = app.GRPC.Addr
+	}
+	// Browsers send the Origin header without a trailing slash, and origins match exactly
+	for i, origin := range app.CORS.AllowOrigins {
+		app.CORS.AllowOrigins[i] = strings.TrimSuffix(origin, "/")
+	}
+
+	errs = append(errs, app.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return &app, nil
+}
+
+// envOverrides maps environment variables onto config keys. Empty variables are ignored,
+// except where allowEmpty is set: GRPC_ADDR= disables the gRPC service.
+var envOverrides = []struct {
+	key        string
+	allowEmpty bool
+	set        func(app *AppConfig, value string) error
+}{
+	{key: "PORT", set: func(app *AppConfig, v string) error { app.Port = v; return nil }},
+	{key: "ENV", set: func(app *AppConfig, v string) error { app.Env = v; return nil }},
+	{key: "DB_PATH", set: func(app *AppConfig, v string) error { app.DBPath = v; return nil }},
+	{key: "SUPABASE_URL", set: func(app *AppConfig, v string) error { app.Supabase.URL = v; return nil }},
+	{key: "SUPABASE_KEY", set: func(app *AppConfig, v string) error { app.Supabase.Key = v; return nil }},
+	{key: "STATSD_ADDR", set: func(app *AppConfig, v string) error { app.StatsD.Addr = v; return nil }},
+	{key: "STATSD_FLUSH_INTERVAL", set: func(app *AppConfig, v string) error { return setDuration(&app.StatsD.FlushInterval, v) }},
+	{key: "STATSD_PERCENTILES", set: func(app *AppConfig, v string) error {
+		var percentiles []float64
+		for _, item := range splitList(v) {
+			p, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return fmt.Errorf("invalid percentile %q", item)
+			}
+			percentiles = append(percentiles, p)
+		}
+		app.StatsD.Percentiles = percentiles
+		return nil
+	}},
+	{key: "ENV_METRICS_API_KEYS", set: func(app *AppConfig, v string) error { app.EnvMetrics.APIKeys = splitList(v); return nil }},
+	{key: "MQTT_BROKER", set: func(app *AppConfig, v string) error { app.MQTT.Broker = v; return nil }},
+	{key: "MQTT_EMBEDDED_ADDR", set: func(app *AppConfig, v string) error { app.MQTT.EmbeddedAddr = v; return nil }},
+	{key: "MQTT_CLIENT_ID", set: func(app *AppConfig, v string) error { app.MQTT.ClientID = v; return nil }},
+	{key: "MQTT_USERNAME", set: func(app *AppConfig, v string) error { app.MQTT.Username = v; return nil }},
+	{key: "MQTT_PASSWORD", set: func(app *AppConfig, v string) error { app.MQTT.Password = v; return nil }},
+	{key: "MQTT_TOPICS", set: func(app *AppConfig, v string) error { app.MQTT.Topics = splitList(v); return nil }},
+	{key: "MQTT_QOS", set: func(app *AppConfig, v string) error {
+		qos, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		app.MQTT.QoS = byte(qos)
+		return nil
+	}},
+	{key: "GRPC_ADDR", allowEmpty: true, set: func(app *AppConfig, v string) error { app.GRPC.Addr = v; return nil }},
+	{key: "GRPC_ADVERTISE_ADDR", set: func(app *AppConfig, v string) error { app.GRPC.AdvertiseAddr = v; return nil }},
+	{key: "DISCOVERY_INTERVAL", set: func(app *AppConfig, v string) error { return setDuration(&app.Discovery.Interval, v) }},
+	{key: "DISCOVERY_TIMEOUT", set: func(app *AppConfig, v string) error { return setDuration(&app.Discovery.Timeout, v) }},
+	{key: "DISCOVERY_CONCURRENCY", set: func(app *AppConfig, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		app.Discovery.Concurrency = n
+		return nil
+	}},
+	{key: "DISCOVERY_APPROVAL", set: func(app *AppConfig, v string) error {
+		approval, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		app.Discovery.Approval = approval
+		return nil
+	}},
+	{key: "DISCOVERY_DEFAULT_TAGS", set: func(app *AppConfig, v string) error { app.Discovery.DefaultTags = splitList(v); return nil }},
+	{key: "DISCOVERY_CIDRS", set: func(app *AppConfig, v string) error { app.Discovery.Scan.CIDRs = splitList(v); return nil }},
+	{key: "DISCOVERY_PORTS", set: func(app *AppConfig, v string) error {
+		var ports []int
+		for _, item := range splitList(v) {
+			port, err := strconv.Atoi(item)
+			if err != nil {
+				return fmt.Errorf("invalid port %q", item)
+			}
+			ports = append(ports, port)
+		}
+		app.Discovery.Scan.Ports = ports
+		return nil
+	}},
+	{key: "DISCOVERY_FILES", set: func(app *AppConfig, v string) error { app.Discovery.Files = splitList(v); return nil }},
+	{key: "DISCOVERY_SRV", set: func(app *AppConfig, v string) error { app.Discovery.SRV = splitList(v); return nil }},
+	{key: "LOG_RETENTION", set: func(app *AppConfig, v string) error { return setDuration(&app.Logs.Retention, v) }},
+	{key: "LOG_MAX_LINES_PER_AGENT", set: func(app *AppConfig, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", v)
+		}
+		app.Logs.MaxLinesPerAgent = n
+		return nil
+	}},
+	{key: "AGENT_POLL_INTERVAL", set: func(app *AppConfig, v string) error { return setDuration(&app.Agents.PollInterval, v) }},
+	{key: "AGENT_PULL_TIMEOUT", set: func(app *AppConfig, v string) error { return setDuration(&app.Agents.PullTimeout, v) }},
+	{key: "AGENT_REGISTER_TIMEOUT", set: func(app *AppConfig, v string) error { return setDuration(&app.Agents.RegisterTimeout, v) }},
+	{key: "CORS_ALLOW_ORIGINS", set: func(app *AppConfig, v string) error { app.CORS.AllowOrigins = splitList(v); return nil }},
+	{key: "TERMINAL_IDLE_TIMEOUT", set: func(app *AppConfig, v string) error { return setDuration(&app.Terminal.IdleTimeout, v) }},
+	{key: "TERMINAL_CLEANUP_INTERVAL", set: func(app *AppConfig, v string) error { return setDuration(&app.Terminal.CleanupInterval, v) }},
+}
+
+func setDuration(d *time.Duration, value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", value)
+	}
+	*d = parsed
+	return nil
+}
+
+// validate returns every invalid value, each prefixed with its key
+func (a *AppConfig) validate() []error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if port, err := strconv.Atoi(a.Port); err != nil || port < 1 || port > 65535 {
+		fail("port", "must be a port number, got %q", a.Port)
+	}
+	if a.DBPath == "" {
+		fail("db_path", "is required")
+	}
+	if (a.Supabase.URL == "") != (a.Supabase.Key == "") {
+		fail("supabase", "url and key must be set together")
+	}
+	if a.Supabase.URL != "" {
+		if u, err := url.Parse(a.Supabase.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			fail("supabase.url", "must be an absolute URL, got %q", a.Supabase.URL)
+		}
+	}
+
+	checkAddr := func(key, addr string) {
+		if addr == "" {
+			return
+		}
+		if _, port, err := net.SplitHostPort(addr); err != nil || port == "" {
+			fail(key, "must be host:port or :port, got %q", addr)
+		}
+	}
+	checkPositive := func(key string, d time.Duration) {
+		if d <= 0 {
+			fail(key, "must be positive, got %s", d)
+		}
+	}
+
+	checkAddr("statsd.addr", a.StatsD.Addr)
+	checkPositive("statsd.flush_interval", a.StatsD.FlushInterval)
+	for _, p := range a.StatsD.Percentiles {
+		if p <= 0 || p > 100 {
+			fail("statsd.percentiles", "must be between 0 (exclusive) and 100, got %g", p)
+		}
+	}
+
+	checkAddr("mqtt.embedded_addr", a.MQTT.EmbeddedAddr)
+	if a.MQTT.QoS > 2 {
+		fail("mqtt.qos", "must be 0, 1 or 2, got %d", a.MQTT.QoS)
+	}
+	if a.MQTT.Broker != "" || a.MQTT.EmbeddedAddr != "" {
+		if a.MQTT.ClientID == "" {
+			fail("mqtt.client_id", "is required when MQTT is enabled")
+		}
+		if len(a.MQTT.Topics) == 0 {
+			fail("mqtt.topics", "at least one topic is required when MQTT is enabled")
+		}
+	}
+	if a.MQTT.EmbeddedAddr != "" && len(a.EnvMetrics.APIKeys) == 0 {
+		fail("mqtt.embedded_addr", "requires env_metrics.api_keys, the passwords clients connect with")
+	}
+
+	checkAddr("grpc.addr", a.GRPC.Addr)
+	checkAddr("grpc.advertise_addr", a.GRPC.AdvertiseAddr)
+
+	checkPositive("discovery.interval", a.Discovery.Interval)
+	checkPositive("discovery.timeout", a.Discovery.Timeout)
+	checkPositive("discovery.file_refresh", a.Discovery.FileRefresh)
+	if a.Discovery.Concurrency < 1 {
+		fail("discovery.concurrency", "must be at least 1, got %d", a.Discovery.Concurrency)
+	}
+	for _, cidr := range a.Discovery.Scan.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			fail("discovery.scan.cidrs", "invalid CIDR %q", cidr)
+			continue
+		}
+		if bits := prefix.Addr().BitLen() - prefix.Bits(); bits > 16 {
+			fail("discovery.scan.cidrs", "%q has more than %d addresses", cidr, maxScanAddresses)
+		}
+	}
+	if len(a.Discovery.Scan.CIDRs) > 0 && len(a.Discovery.Scan.Ports) == 0 {
+		fail("discovery.scan.ports", "at least one port is required to scan")
+	}
+	for _, port := range a.Discovery.Scan.Ports {
+		if port < 1 || port > 65535 {
+			fail("discovery.scan.ports", "must be port numbers, got %d", port)
+		}
+	}
+
+	checkPositive("logs.retention", a.Logs.Retention)
+	if a.Logs.MaxLinesPerAgent < 1 {
+		fail("logs.max_lines_per_agent", "must be at least 1, got %d", a.Logs.MaxLinesPerAgent)
+	}
+
+	if a.Agents.PollInterval < time.Second {
+		fail("agents.poll_interval", "must be at least 1s, got %s", a.Agents.PollInterval)
+	}
+	checkPositive("agents.pull_timeout", a.Agents.PullTimeout)
+	checkPositive("agents.register_timeout", a.Agents.RegisterTimeout)
+
+	if len(a.CORS.AllowOrigins) == 0 {
+		fail("cors.allow_origins", "at least one origin is required (\"*\" allows any)")
+	}
+	for _, origin := range a.CORS.AllowOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			fail("cors.allow_origins", "must be \"*\" or scheme://host[:port], got %q", origin)
+		}
+	}
+
+	checkPositive("terminal.idle_timeout", a.Terminal.IdleTimeout)
+	checkPositive("terminal.cleanup_interval", a.Terminal.CleanupInterval)
+	return errs
+}
+
+// RestartRequired lists the sections of next that differ from a outside the ones applied
+// on reload
+func (a *AppConfig) RestartRequired(next *AppConfig) []string {
+	current, updated := *a, *next
+	current.Agents, updated.Agents = AgentsConfig{}, AgentsConfig{}
+	current.CORS, updated.CORS = CORSConfig{}, CORSConfig{}
+	current.Terminal, updated.Terminal = TerminalConfig{}, TerminalConfig{}
+
+	var changed []string
+	cv, uv := reflect.ValueOf(current), reflect.ValueOf(updated)
+	for i := 0; i < cv.NumField(); i++ {
+		if !reflect.DeepEqual(cv.Field(i).Interface(), uv.Field(i).Interface()) {
+			changed = append(changed, cv.Type().Field(i).Tag.Get("yaml"))
+		}
+	}
+	return changed
+}
+
+// Redacted returns a copy with secrets masked, for printing
+func (a AppConfig) Redacted() AppConfig {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "********"
+	}
+	a.Supabase.Key = mask(a.Supabase.Key)
+	a.MQTT.Password = mask(a.MQTT.Password)
+	keys := make([]string, len(a.EnvMetrics.APIKeys))
+	for i, key := range a.EnvMetrics.APIKeys {
+		keys[i] = mask(key)
+	}
+	a.EnvMetrics.APIKeys = keys
+	return a
+}
+
+// splitList splits a comma-separated value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAppCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     string
+		want    []string
+		wantErr string
+	}{
+		{name: "trailing slash dropped", file: `["https://app.example.com/", "http://localhost:5173"]`, want: []string{"https://app.example.com", "http://localhost:5173"}},
+		{name: "trailing slash dropped from the environment", env: "https://app.example.com/, *", want: []string{"https://app.example.com", "*"}},
+		{name: "path", file: `["https://app.example.com/dashboard"]`, wantErr: `cors.allow_origins: must be "*" or scheme://host[:port], got "https://app.example.com/dashboard"`},
+		{name: "no scheme", file: `["app.example.com"]`, wantErr: `got "app.example.com"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			file := "{}"
+			if tt.file != "" {
+				file = "cors:\n  allow_origins: " + tt.file + "\n"
+			}
+			if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CORS_ALLOW_ORIGINS", tt.env)
+
+			app, err := LoadApp(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadApp() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(app.CORS.AllowOrigins, tt.want) {
+				t.Errorf("allow_origins = %q, want %q", app.CORS.AllowOrigins, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadAppTerminalEnv(t *testing.T) {
+	t.Setenv("TERMINAL_IDLE_TIMEOUT", "10m")
+	t.Setenv("TERMINAL_CLEANUP_INTERVAL", "30s")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("terminal:\n  cleanup_interval: 1m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app, err := LoadApp(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Terminal.IdleTimeout != 10*time.Minute || app.Terminal.CleanupInterval != 30*time.Second {
+		t.Errorf("terminal = %+v, want the idle timeout and cleanup interval of the environment", app.Terminal)
+	}
+
+	t.Setenv("TERMINAL_CLEANUP_INTERVAL", "soon")
+	if _, err := LoadApp(path); err == nil || !strings.Contains(err.Error(), "TERMINAL_CLEANUP_INTERVAL") {
+		t.Errorf("LoadApp() error = %v, want one naming TERMINAL_CLEANUP_INTERVAL", err)
+	}
+}
+
+func TestLoadAppGRPC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	app, err := LoadApp(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.GRPC.Addr != "" || app.GRPC.AdvertiseAddr != "" {
+		t.Errorf("grpc = %+v by default, want it disabled", app.GRPC)
+	}
+
+	t.Setenv("GRPC_ADDR", ":9091")
+	if app, err = LoadApp(path); err != nil {
+		t.Fatal(err)
+	}
+	if app.GRPC.Addr != ":9091" || app.GRPC.AdvertiseAddr != ":9091" {
+		t.Errorf("grpc = %+v with GRPC_ADDR set, want it on :9091", app.GRPC)
+	}
+}
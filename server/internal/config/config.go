@@ -16,30 +16,21 @@ type Config struct {
 	SupabaseClient *supabase.Client // Supabase for env_metrics
 }
 
-type AppConfig struct {
-	Port        string
-	Env         string
-	DBPath      string
-	SupabaseURL string
-	SupabaseKey string
-}
-
-func Load() (*Config, error) {
-	// Load App Config
-	port := getEnv("PORT", "8080")
-	env := getEnv("ENV", "development")
-	dbPath := getEnv("DB_PATH", "./data/monitoring.db")
-	supabaseURL := getEnv("SUPABASE_URL", "")
-	supabaseKey := getEnv("SUPABASE_KEY", "")
+// Load reads the application config (see LoadApp) and opens the databases it names
+func Load(path string) (*Config, error) {
+	app, err := LoadApp(path)
+	if err != nil {
+		return nil, err
+	}
 
 	// Ensure data directory exists
-	dbDir := filepath.Dir(dbPath)
+	dbDir := filepath.Dir(app.DBPath)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
 	// Initialize SQLite Database (for agents)
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open("sqlite3", app.DBPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -56,29 +47,16 @@ func Load() (*Config, error) {
 
 	// Initialize Supabase Client (for env_metrics) - optional
 	var supabaseClient *supabase.Client
-	if supabaseURL != "" && supabaseKey != "" {
-		supabaseClient, err = supabase.NewClient(supabaseURL, supabaseKey, nil)
+	if app.Supabase.URL != "" && app.Supabase.Key != "" {
+		supabaseClient, err = supabase.NewClient(app.Supabase.URL, app.Supabase.Key, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create supabase client: %w", err)
 		}
 	}
 
 	return &Config{
-		App: AppConfig{
-			Port:        port,
-			Env:         env,
-			DBPath:      dbPath,
-			SupabaseURL: supabaseURL,
-			SupabaseKey: supabaseKey,
-		},
+		App:            *app,
 		DB:             db,
 		SupabaseClient: supabaseClient,
 	}, nil
 }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
-}
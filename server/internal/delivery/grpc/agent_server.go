@@ -0,0 +1,263 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/agentpb"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// statusInterval limits how often a metrics stream refreshes the agent's last seen time
+const statusInterval = 10 * time.Second
+
+// AgentServer implements the gRPC agent service
+type AgentServer struct {
+	agentRepo    domain.AgentRepository
+	agentService *service.AgentService
+	processor    *service.MetricsProcessor
+	sessions     *service.AgentSessions
+}
+
+func NewAgentServer(agentRepo domain.AgentRepository, agentService *service.AgentService, processor *service.MetricsProcessor, sessions *service.AgentSessions) *AgentServer {
+	return &AgentServer{
+		agentRepo:    agentRepo,
+		agentService: agentService,
+		processor:    processor,
+		sessions:     sessions,
+	}
+}
+
+// Register adds an agent, or updates the details of a known agent when AgentID is set.
+// Unlike the HTTP registration the agent reports its own details, so it need not be reachable.
+// A known agent keeps its name, tags and description, which are edited through the API.
+func (s *AgentServer) Register(ctx context.Context, req *agentpb.RegisterRequest) (*agentpb.RegisterResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	host := req.Host
+	if strings.HasPrefix(host, ":") {
+		host = peerIP(ctx) + host
+	}
+
+	now := time.Now()
+	agent := &domain.Agent{
+		ID:          req.AgentID,
+		Name:        req.Name,
+		Type:        domain.AgentTypeAgent,
+		Host:        host,
+		Hostname:    req.Hostname,
+		IPAddress:   req.IPAddress,
+		Status:      "online",
+		LastSeen:    now,
+		Version:     req.Version,
+		Tags:        req.Tags,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if agent.ID != "" {
+		existing, err := s.agentRepo.GetByID(ctx, agent.ID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to get agent: %v", err)
+		}
+		if existing == nil {
+			return nil, status.Error(codes.NotFound, "agent not found")
+		}
+		agent.Name = existing.Name
+		agent.Tags = existing.Tags
+		agent.Description = existing.Description
+		agent.CreatedAt = existing.CreatedAt
+		if err := s.agentRepo.Update(ctx, agent); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to update agent: %v", err)
+		}
+		s.agentService.Record(ctx, domain.AgentAuditRegister, "agent", existing, agent)
+	} else {
+		agent.ID = uuid.New().String()
+		if err := s.agentRepo.Register(ctx, agent); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to register agent: %v", err)
+		}
+		s.agentService.Record(ctx, domain.AgentAuditRegister, "agent", nil, agent)
+	}
+
+	log.Printf("🔌 Agent %s (%s) registered over gRPC from %s", agent.Name, agent.ID, agent.Host)
+	return &agentpb.RegisterResponse{Agent: *agent}, nil
+}
+
+// Heartbeat updates an agent's status and last seen time
+func (s *AgentServer) Heartbeat(ctx context.Context, req *agentpb.HeartbeatRequest) (*agentpb.HeartbeatResponse, error) {
+	if _, err := s.agent(ctx, req.AgentID); err != nil {
+		return nil, err
+	}
+
+	agentStatus := req.Status
+	if agentStatus == "" {
+		agentStatus = "online"
+	}
+	if err := s.agentRepo.UpdateStatus(ctx, req.AgentID, agentStatus, time.Now()); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to update heartbeat: %v", err)
+	}
+	if req.ConfigVersion != "" {
+		if err := s.agentRepo.UpdateConfigVersion(ctx, req.AgentID, req.ConfigVersion); err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to update config version: %v", err)
+		}
+	}
+
+	return &agentpb.HeartbeatResponse{ServerTime: time.Now()}, nil
+}
+
+// StreamMetrics saves every received sample and acknowledges it. Samples already stored
+// for the agent and timestamp are acknowledged as duplicates, so agents can resend after
+// a broken stream. While the stream is open the poller does not pull from the agent.
+func (s *AgentServer) StreamMetrics(stream grpc.BidiStreamingServer[domain.AgentMetrics, agentpb.MetricsAck]) error {
+	ctx := stream.Context()
+
+	agent, err := s.streamAgent(ctx)
+	if err != nil {
+		return err
+	}
+
+	closed := s.sessions.StreamOpened(agent.ID)
+	defer closed()
+	log.Printf("🔌 Agent %s (%s) opened a metrics stream", agent.Name, agent.ID)
+	defer log.Printf("🔌 Agent %s (%s) closed its metrics stream", agent.Name, agent.ID)
+
+	var lastStatus time.Time
+	for {
+		sample, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		sample.AgentID = agent.ID
+		sample.AgentName = agent.Name
+		sample.ReceivedAt = time.Now()
+		ack := agentpb.MetricsAck{Timestamp: sample.Metrics.Timestamp}
+
+		// Like the HTTP API, a duplicate is processed again
+		err = s.agentRepo.SaveMetrics(ctx, sample)
+		ack.Duplicate = errors.Is(err, domain.ErrDuplicate)
+		if err != nil && !ack.Duplicate {
+			return status.Errorf(codes.Internal, "failed to save metrics: %v", err)
+		}
+		if err := s.processor.Process(ctx, sample); err != nil {
+			log.Printf("⚠️  Agent %s (%s): Failed to process metrics - %v", agent.Name, agent.ID, err)
+		}
+
+		if time.Since(lastStatus) >= statusInterval {
+			if err := s.agentRepo.UpdateStatus(ctx, agent.ID, "online", time.Now()); err != nil {
+				return status.Errorf(codes.Internal, "failed to update status: %v", err)
+			}
+			lastStatus = time.Now()
+		}
+
+		if err := stream.Send(&ack); err != nil {
+			return err
+		}
+	}
+}
+
+// Commands delivers commands issued through the HTTP API to the agent and hands back
+// the results it streams in return. A newer channel from the same agent replaces this one.
+func (s *AgentServer) Commands(stream grpc.BidiStreamingServer[domain.AgentCommandResult, domain.AgentCommand]) error {
+	ctx := stream.Context()
+
+	agent, err := s.streamAgent(ctx)
+	if err != nil {
+		return err
+	}
+
+	commands, closeChannel := s.sessions.OpenCommandChannel(agent.ID)
+	defer closeChannel()
+
+	// Headers tell the agent the channel is open before any command arrives
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	log.Printf("🔌 Agent %s (%s) opened its command channel", agent.Name, agent.ID)
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			result, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			s.sessions.Complete(agent.ID, *result)
+		}
+	}()
+
+	for {
+		select {
+		case command, ok := <-commands:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&command); err != nil {
+				return err
+			}
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// streamAgent returns the agent named by the agent-id metadata of a stream
+func (s *AgentServer) streamAgent(ctx context.Context) (*domain.Agent, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ids := md.Get(agentpb.MetadataAgentID)
+	if len(ids) == 0 || ids[0] == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "missing %s metadata", agentpb.MetadataAgentID)
+	}
+	return s.agent(ctx, ids[0])
+}
+
+func (s *AgentServer) agent(ctx context.Context, id string) (*domain.Agent, error) {
+	agent, err := s.agentRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get agent: %v", err)
+	}
+	if agent == nil {
+		return nil, status.Error(codes.NotFound, "agent not found")
+	}
+	return agent, nil
+}
+
+// peerIP returns the IP address of the caller
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
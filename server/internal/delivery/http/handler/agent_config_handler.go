@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+type AgentConfigHandler struct {
+	configRepo    domain.AgentConfigRepository
+	configService *service.AgentConfigService
+}
+
+func NewAgentConfigHandler(configRepo domain.AgentConfigRepository, configService *service.AgentConfigService) *AgentConfigHandler {
+	return &AgentConfigHandler{
+		configRepo:    configRepo,
+		configService: configService,
+	}
+}
+
+// GetConfigs lists the config documents of every agent and tag
+func (h *AgentConfigHandler) GetConfigs(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	docs, err := h.configRepo.GetAll(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent configs", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent configs retrieved successfully", docs)
+}
+
+// GetConfig retrieves the document of a scope (tag or agent) and target
+func (h *AgentConfigHandler) GetConfig(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	doc, err := h.configRepo.Get(ctx, (*c).Param("scope"), (*c).Param("target"))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent config", err)
+	}
+	if doc == nil {
+		return response.Error(c, http.StatusNotFound, "Agent config not found", nil)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent config retrieved successfully", doc)
+}
+
+// SaveConfig creates or replaces the document of a scope and target
+func (h *AgentConfigHandler) SaveConfig(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var req struct {
+		Settings map[string]interface{} `json:"settings"`
+	}
+	if err := (*c).Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+
+	doc := domain.AgentConfigDocument{
+		Scope:    (*c).Param("scope"),
+		Target:   (*c).Param("target"),
+		Settings: req.Settings,
+	}
+	if doc.Settings == nil {
+		doc.Settings = make(map[string]interface{})
+	}
+
+	err := h.configService.Save(ctx, &doc)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid agent config", err)
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Agent or group not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to save agent config", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent config saved successfully", doc)
+}
+
+// DeleteConfig removes the document of a scope and target
+func (h *AgentConfigHandler) DeleteConfig(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	err := h.configService.Delete(ctx, (*c).Param("scope"), (*c).Param("target"))
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Agent config not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to delete agent config", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent config deleted successfully", nil)
+}
+
+// GetAgentConfig returns the effective config of an agent, which agents fetch and apply.
+// The version is sent as ETag, so agents polling with If-None-Match get 304 until it changes.
+func (h *AgentConfigHandler) GetAgentConfig(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	config, err := h.configService.Effective(ctx, (*c).Param("id"))
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent config", err)
+	}
+
+	etag := `"` + config.Version + `"`
+	(*c).Response().Header().Set("ETag", etag)
+	if (*c).Request().Header.Get("If-None-Match") == etag {
+		return (*c).NoContent(http.StatusNotModified)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent config retrieved successfully", config)
+}
@@ -1,24 +1,70 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v5"
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
 	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
 )
 
+// maxMetricsBatch limits the number of samples accepted in one metrics request
+const maxMetricsBatch = 1000
+
+// commandTimeout limits how long a command request waits for the agent's result
+const commandTimeout = 30 * time.Second
+
 type AgentHandler struct {
-	agentRepo domain.AgentRepository
+	agentRepo    domain.AgentRepository
+	agentService *service.AgentService
+	processor    *service.MetricsProcessor
+	sessions     *service.AgentSessions
+	grpcAddr     string // advertised gRPC address, empty when gRPC is disabled
+
+	// registerTimeout bounds the check of the agent's /info at registration and re-probes
+	registerTimeout atomic.Int64
+}
+
+func NewAgentHandler(agentRepo domain.AgentRepository, agentService *service.AgentService, processor *service.MetricsProcessor, sessions *service.AgentSessions, grpcAddr string, registerTimeout time.Duration) *AgentHandler {
+	h := &AgentHandler{
+		agentRepo:    agentRepo,
+		agentService: agentService,
+		processor:    processor,
+		sessions:     sessions,
+		grpcAddr:     grpcAddr,
+	}
+	h.SetRegisterTimeout(registerTimeout)
+	return h
+}
+
+// SetRegisterTimeout changes how long registration waits for the agent's /info
+func (h *AgentHandler) SetRegisterTimeout(timeout time.Duration) {
+	h.registerTimeout.Store(int64(timeout))
 }
 
-func NewAgentHandler(agentRepo domain.AgentRepository) *AgentHandler {
-	return &AgentHandler{
-		agentRepo: agentRepo,
+// Protocols lists the protocols agents can use to talk to the server. Agents call it at
+// startup and prefer gRPC when an address is returned; an address starting with ":" is on
+// the host of the HTTP API.
+func (h *AgentHandler) Protocols(c *echo.Context) error {
+	protocols := map[string]interface{}{
+		"http": true,
+		"grpc": h.grpcAddr,
 	}
+	return response.Success(c, http.StatusOK, "Protocols retrieved successfully", protocols)
 }
 
 // RegisterAgent handles agent registration
@@ -30,45 +76,29 @@ func (h *AgentHandler) RegisterAgent(c *echo.Context) error {
 		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
 	}
 
-	// Test connection to agent
-	url := "http://" + req.Host + "/info"
-	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
+	// A host given as ":port" is the agent calling from its own machine
+	if strings.HasPrefix(req.Host, ":") {
+		req.Host = (*c).RealIP() + req.Host
 	}
-	resp, err := httpClient.Get(url)
+
+	// Test connection to agent
+	probeCtx, cancel := context.WithTimeout(ctx, time.Duration(h.registerTimeout.Load()))
+	info, err := service.ProbeAgent(probeCtx, req.Host)
+	cancel()
 	if err != nil {
 		return response.Error(c, http.StatusBadRequest, "Cannot connect to agent at "+req.Host, err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return response.Error(c, http.StatusBadRequest, "Agent returned non-OK status", nil)
-	}
-
-	// Parse agent info from response
-	var agentInfoResp struct {
-		Success bool `json:"success"`
-		Data    struct {
-			Name      string `json:"name"`
-			Hostname  string `json:"hostname"`
-			IPAddress string `json:"ip_address"`
-			Version   string `json:"version"`
-		} `json:"data"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&agentInfoResp); err != nil {
-		return response.Error(c, http.StatusBadRequest, "Invalid agent info response", err)
-	}
 
 	// Create agent
 	agent := &domain.Agent{
 		ID:          uuid.New().String(),
 		Name:        req.Name,
 		Host:        req.Host,
-		Hostname:    agentInfoResp.Data.Hostname,
-		IPAddress:   agentInfoResp.Data.IPAddress,
+		Hostname:    info.Hostname,
+		IPAddress:   info.IPAddress,
 		Status:      "online",
 		LastSeen:    time.Now(),
-		Version:     agentInfoResp.Data.Version,
+		Version:     info.Version,
 		Tags:        req.Tags,
 		Description: req.Description,
 		CreatedAt:   time.Now(),
@@ -78,22 +108,104 @@ func (h *AgentHandler) RegisterAgent(c *echo.Context) error {
 	if err := h.agentRepo.Register(ctx, agent); err != nil {
 		return response.Error(c, http.StatusInternalServerError, "Failed to register agent", err)
 	}
+	h.agentService.Record(ctx, domain.AgentAuditRegister, (*c).RealIP(), nil, agent)
 
 	return response.Success(c, http.StatusCreated, "Agent registered successfully", agent)
 }
 
-// GetAllAgents retrieves all agents
+// maxAgentPage limits the limit parameter of the agent list
+const maxAgentPage = 1000
+
+// GetAllAgents lists agents, filtered, sorted and paged by the query parameters. Without a
+// limit every matching agent is returned. When there is a next page its cursor is sent in
+// the X-Next-Cursor header and as a Link header.
 func (h *AgentHandler) GetAllAgents(c *echo.Context) error {
 	ctx := (*c).Request().Context()
 
-	agents, err := h.agentRepo.GetAll(ctx)
+	query, err := parseAgentQuery(c)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid query", err)
+	}
+
+	agents, next, err := h.agentRepo.Query(ctx, query)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid query", err)
+	}
 	if err != nil {
 		return response.Error(c, http.StatusInternalServerError, "Failed to get agents", err)
 	}
 
+	if next != "" {
+		params := (*c).QueryParams()
+		params.Set("cursor", next)
+		(*c).Response().Header().Set("X-Next-Cursor", next)
+		(*c).Response().Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, (*c).Request().URL.Path, params.Encode()))
+	}
+
 	return response.Success(c, http.StatusOK, "Agents retrieved successfully", agents)
 }
 
+// parseAgentQuery reads the agent list parameters: status, type, version, q (search),
+// tag (repeatable or comma-separated) with tag_match any|all, seen_after/seen_before
+// (RFC3339) or seen_within (duration), sort with order asc|desc, limit and cursor
+func parseAgentQuery(c *echo.Context) (domain.AgentQuery, error) {
+	params := (*c).QueryParams()
+	query := domain.AgentQuery{
+		Status:  params.Get("status"),
+		Type:    params.Get("type"),
+		Version: params.Get("version"),
+		Search:  strings.TrimSpace(params.Get("q")),
+		Sort:    params.Get("sort"),
+		Cursor:  params.Get("cursor"),
+	}
+
+	for _, value := range params["tag"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+
+	switch query.TagMatch = params.Get("tag_match"); query.TagMatch {
+	case "", domain.AgentTagMatchAny, domain.AgentTagMatchAll:
+	default:
+		return query, fmt.Errorf("tag_match must be %q or %q", domain.AgentTagMatchAny, domain.AgentTagMatchAll)
+	}
+
+	var err error
+	if query.SeenAfter, err = parseTimeParam(c, "seen_after"); err != nil {
+		return query, fmt.Errorf("invalid seen_after: %w", err)
+	}
+	if query.SeenBefore, err = parseTimeParam(c, "seen_before"); err != nil {
+		return query, fmt.Errorf("invalid seen_before: %w", err)
+	}
+	if v := params.Get("seen_within"); v != "" {
+		within, err := time.ParseDuration(v)
+		if err != nil || within <= 0 {
+			return query, fmt.Errorf("invalid seen_within %q", v)
+		}
+		query.SeenAfter = time.Now().Add(-within)
+	}
+
+	// The unsorted list keeps its historical order, newest first
+	switch order := params.Get("order"); order {
+	case "":
+		query.Descending = query.Sort == ""
+	case "asc", "desc":
+		query.Descending = order == "desc"
+	default:
+		return query, fmt.Errorf("order must be asc or desc")
+	}
+
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 || query.Limit > maxAgentPage {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxAgentPage)
+		}
+	}
+	return query, nil
+}
+
 // GetAgent retrieves a single agent
 func (h *AgentHandler) GetAgent(c *echo.Context) error {
 	ctx := (*c).Request().Context()
@@ -111,14 +223,93 @@ func (h *AgentHandler) GetAgent(c *echo.Context) error {
 	return response.Success(c, http.StatusOK, "Agent retrieved successfully", agent)
 }
 
+// UpdateAgent edits the name, host, tags and description of an agent; fields left out of
+// the body keep their value
+func (h *AgentHandler) UpdateAgent(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var update domain.AgentUpdate
+	if err := (*c).Bind(&update); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(&update); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid agent update", err)
+	}
+
+	agent, err := h.agentService.Update(ctx, (*c).Param("id"), update, (*c).RealIP())
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid agent update", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to update agent", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent updated successfully", agent)
+}
+
+// ReprobeAgent fetches the agent's /info again to refresh its hostname, IP address and version
+func (h *AgentHandler) ReprobeAgent(c *echo.Context) error {
+	ctx, cancel := context.WithTimeout((*c).Request().Context(), time.Duration(h.registerTimeout.Load()))
+	defer cancel()
+
+	agent, err := h.agentService.Reprobe(ctx, (*c).Param("id"), (*c).RealIP())
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Agent cannot be probed", err)
+	}
+	if errors.Is(err, service.ErrProbeFailed) {
+		return response.Error(c, http.StatusBadGateway, "Failed to probe agent", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to update agent", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent probed successfully", agent)
+}
+
+// GetAgentAudit returns the audit trail of an agent, newest first (limit, default 100),
+// also after the agent was deleted
+func (h *AgentHandler) GetAgentAudit(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	limit := 100
+	if v := (*c).QueryParam("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 || parsed > maxAgentPage {
+			return response.Error(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAgentPage), nil)
+		}
+		limit = parsed
+	}
+
+	entries, err := h.agentService.History(ctx, (*c).Param("id"), limit)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent audit trail", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent audit trail retrieved successfully", entries)
+}
+
 // DeleteAgent deletes an agent
 func (h *AgentHandler) DeleteAgent(c *echo.Context) error {
 	ctx := (*c).Request().Context()
 	id := (*c).Param("id")
 
+	agent, err := h.agentRepo.GetByID(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+	}
+
 	if err := h.agentRepo.Delete(ctx, id); err != nil {
 		return response.Error(c, http.StatusInternalServerError, "Failed to delete agent", err)
 	}
+	if agent != nil {
+		h.agentService.Record(ctx, domain.AgentAuditDelete, (*c).RealIP(), agent, nil)
+	}
 
 	return response.Success(c, http.StatusOK, "Agent deleted successfully", nil)
 }
@@ -136,43 +327,102 @@ func (h *AgentHandler) Heartbeat(c *echo.Context) error {
 		return response.Error(c, http.StatusInternalServerError, "Failed to update heartbeat", err)
 	}
 
+	if req.ConfigVersion != "" {
+		if err := h.agentRepo.UpdateConfigVersion(ctx, req.AgentID, req.ConfigVersion); err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to update config version", err)
+		}
+	}
+
 	return response.Success(c, http.StatusOK, "Heartbeat received", nil)
 }
 
-// ReceiveMetrics handles metrics from agent
+// ReceiveMetrics handles metrics from agent. The body is a single sample or an array of
+// samples replayed in order, as JSON or a protobuf AgentMetricsBatch, optionally gzip or
+// zstd compressed. Samples already stored for the same agent and timestamp are not stored
+// again but are processed again, so a batch resent after a failure is processed in full.
 func (h *AgentHandler) ReceiveMetrics(c *echo.Context) error {
 	ctx := (*c).Request().Context()
 
-	var req domain.AgentMetrics
-	if err := (*c).Bind(&req); err != nil {
-		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	body, err := readBody((*c).Request())
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
 	}
 
-	// Get agent info
-	agent, err := h.agentRepo.GetByID(ctx, req.AgentID)
+	contentType, _, _ := mime.ParseMediaType((*c).Request().Header.Get(echo.HeaderContentType))
+
+	var batch []domain.AgentMetrics
+	if contentType == protostruct.ContentType {
+		var decoded domain.AgentMetricsBatch
+		err = protostruct.Unmarshal(body, &decoded)
+		batch = decoded.Samples
+	} else if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &batch)
+	} else {
+		var single domain.AgentMetrics
+		err = json.Unmarshal(trimmed, &single)
+		batch = []domain.AgentMetrics{single}
+	}
 	if err != nil {
-		return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
 	}
-
-	if agent == nil {
-		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	if len(batch) == 0 || len(batch) > maxMetricsBatch {
+		return response.Error(c, http.StatusBadRequest, fmt.Sprintf("A batch must contain between 1 and %d samples", maxMetricsBatch), nil)
 	}
 
-	// Update agent name in metrics
-	req.AgentName = agent.Name
-	req.ReceivedAt = time.Now()
+	// Get agent info
+	agents := make(map[string]*domain.Agent)
+	for _, req := range batch {
+		if _, ok := agents[req.AgentID]; ok {
+			continue
+		}
+		agent, err := h.agentRepo.GetByID(ctx, req.AgentID)
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+		}
+		if agent == nil {
+			return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+		}
+		agents[req.AgentID] = agent
+	}
 
-	// Save metrics
-	if err := h.agentRepo.SaveMetrics(ctx, &req); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "Failed to save metrics", err)
+	accepted, duplicates := 0, 0
+	for i := range batch {
+		req := &batch[i]
+
+		// Update agent name in metrics
+		req.AgentName = agents[req.AgentID].Name
+		req.ReceivedAt = time.Now()
+
+		// Save metrics
+		err := h.agentRepo.SaveMetrics(ctx, req)
+		duplicate := errors.Is(err, domain.ErrDuplicate)
+		if err != nil && !duplicate {
+			return response.Error(c, http.StatusInternalServerError, "Failed to save metrics", err)
+		}
+
+		// Record service state changes and plugin results. A duplicate may be the replay of a
+		// sample whose processing failed; processing it again stores nothing twice.
+		if err := h.processor.Process(ctx, req); err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to process metrics", err)
+		}
+		if duplicate {
+			duplicates++
+		} else {
+			accepted++
+		}
 	}
 
 	// Update last seen
-	if err := h.agentRepo.UpdateStatus(ctx, req.AgentID, "online", time.Now()); err != nil {
-		return response.Error(c, http.StatusInternalServerError, "Failed to update status", err)
+	for id := range agents {
+		if err := h.agentRepo.UpdateStatus(ctx, id, "online", time.Now()); err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to update status", err)
+		}
 	}
 
-	return response.Success(c, http.StatusOK, "Metrics received successfully", nil)
+	return response.Success(c, http.StatusOK, "Metrics received successfully", map[string]int{
+		"accepted":   accepted,
+		"duplicates": duplicates,
+	})
 }
 
 // GetAgentMetrics retrieves latest metrics for an agent
@@ -191,3 +441,39 @@ func (h *AgentHandler) GetAgentMetrics(c *echo.Context) error {
 
 	return response.Success(c, http.StatusOK, "Metrics retrieved successfully", metrics)
 }
+
+// SendCommand sends a command to an agent connected over gRPC and returns its result
+func (h *AgentHandler) SendCommand(c *echo.Context) error {
+	id := (*c).Param("id")
+
+	var command domain.AgentCommand
+	if err := (*c).Bind(&command); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(&command); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid command", err)
+	}
+
+	ctx, cancel := context.WithTimeout((*c).Request().Context(), commandTimeout)
+	defer cancel()
+
+	agent, err := h.agentRepo.GetByID(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+	}
+	if agent == nil {
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	}
+
+	result, err := h.sessions.Send(ctx, id, command)
+	switch {
+	case errors.Is(err, service.ErrAgentNotConnected):
+		return response.Error(c, http.StatusConflict, "Agent has no open command channel", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return response.Error(c, http.StatusGatewayTimeout, "Agent did not reply in time", err)
+	case err != nil:
+		return response.Error(c, http.StatusInternalServerError, "Failed to send command", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Command completed", result)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+// openTestDB opens an initialized database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// failingPoints fails to save plugin metric points while fail is set
+type failingPoints struct {
+	domain.CustomMetricRepository
+	fail bool
+}
+
+func (r *failingPoints) SavePoints(ctx context.Context, points []domain.CustomMetricPoint) error {
+	if r.fail {
+		return errors.New("disk I/O error")
+	}
+	return r.CustomMetricRepository.SavePoints(ctx, points)
+}
+
+func TestReceiveMetricsReplayAfterFailedProcess(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	agentRepo := sqlite.NewAgentRepository(db)
+	customRepo := sqlite.NewCustomMetricRepository(db)
+	serviceRepo := sqlite.NewServiceRepository(db)
+	custom := &failingPoints{CustomMetricRepository: customRepo, fail: true}
+	processor := service.NewMetricsProcessor(service.NewServiceTracker(serviceRepo), custom, sqlite.NewPingRepository(db))
+	h := NewAgentHandler(agentRepo, nil, processor, nil, "", time.Second)
+
+	if err := agentRepo.Register(ctx, &domain.Agent{ID: "agent-1", Name: "web-1", Host: "127.0.0.1", Status: "online", LastSeen: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := `[{
+		"agent_id": "agent-1",
+		"metrics": {
+			"timestamp": "2026-10-19T02:00:00Z",
+			"services": [{"unit": "nginx.service", "active_state": "active", "sub_state": "running"}],
+			"custom": [{"plugin": "backup", "status": "ok", "checked_at": "2026-10-19T01:59:30Z", "metrics": [{"name": "backup_age", "value": 180}]}]
+		}
+	}]`
+	e := echo.New()
+	send := func() (int, string) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/agents/metrics", strings.NewReader(batch))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		if err := h.ReceiveMetrics(e.NewContext(req, rec)); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, rec.Body.String()
+	}
+
+	// The sample is stored but its plugin results are not, and the agent keeps the batch
+	if code, body := send(); code != http.StatusInternalServerError {
+		t.Fatalf("first send = %d %s, want 500", code, body)
+	}
+
+	// The replay is a duplicate sample, processed again
+	custom.fail = false
+	code, body := send()
+	if code != http.StatusOK || !strings.Contains(body, `"duplicates":1`) {
+		t.Fatalf("replay = %d %s, want 200 with 1 duplicate", code, body)
+	}
+	points, err := customRepo.QueryPoints(ctx, domain.CustomMetricQuery{AgentID: "agent-1", Name: "backup_age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Value != 180 {
+		t.Fatalf("backup_age points after the replay = %+v, want the one point of the sample", points)
+	}
+
+	// Replaying it once more stores nothing twice
+	if code, body := send(); code != http.StatusOK {
+		t.Fatalf("second replay = %d %s, want 200", code, body)
+	}
+	if points, err := customRepo.QueryPoints(ctx, domain.CustomMetricQuery{AgentID: "agent-1", Name: "backup_age"}); err != nil || len(points) != 1 {
+		t.Errorf("backup_age points after another replay = %d, %v, want 1", len(points), err)
+	}
+	events, err := serviceRepo.GetEvents(ctx, "agent-1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("%d service events, want the 1 of the unit first seen", len(events))
+	}
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+// maxUptimeWindow is the longest uptime window, matching the retention of check results
+const maxUptimeWindow = 30 * 24 * time.Hour
+
+// maxHeartbeatMessage is how much of the body of a heartbeat ping is kept
+const maxHeartbeatMessage = 1024
+
+type CheckHandler struct {
+	checkService *service.CheckService
+	checkRepo    domain.CheckRepository
+}
+
+func NewCheckHandler(checkService *service.CheckService, checkRepo domain.CheckRepository) *CheckHandler {
+	return &CheckHandler{
+		checkService: checkService,
+		checkRepo:    checkRepo,
+	}
+}
+
+// GetChecks lists the checks with their status at each location
+func (h *CheckHandler) GetChecks(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	checks, err := h.checkService.GetAll(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get checks", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Checks retrieved successfully", checks)
+}
+
+// GetCheck retrieves a check with its status at each location
+func (h *CheckHandler) GetCheck(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	check, err := h.checkService.Get(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Check not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get check", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check retrieved successfully", check)
+}
+
+// CreateCheck adds a check, which runs right away
+func (h *CheckHandler) CreateCheck(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var check domain.Check
+	if ok, err := bindCheck(c, &check); !ok {
+		return err
+	}
+
+	err := h.checkService.Create(ctx, &check)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid check", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to create check", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "Check created successfully", check)
+}
+
+// UpdateCheck replaces a check
+func (h *CheckHandler) UpdateCheck(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	var check domain.Check
+	if ok, err := bindCheck(c, &check); !ok {
+		return err
+	}
+	check.ID = id
+
+	err = h.checkService.Update(ctx, &check)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Check not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid check", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to update check", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check updated successfully", check)
+}
+
+// DeleteCheck removes a check with its results and alerts
+func (h *CheckHandler) DeleteCheck(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	err = h.checkService.Delete(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Check not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to delete check", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check deleted successfully", nil)
+}
+
+// RunCheck runs a check now at each of its locations and returns the results
+func (h *CheckHandler) RunCheck(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	results, err := h.checkService.Run(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Check not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Check cannot be run", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to run check", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check run successfully", results)
+}
+
+// GetResults lists the results of a check, newest first.
+// Supports ?location=, ?since= (RFC3339) and ?limit=
+func (h *CheckHandler) GetResults(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	query := domain.CheckResultQuery{CheckID: id, Location: (*c).QueryParam("location")}
+	if query.Since, err = parseTimeParam(c, "since"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid since", err)
+	}
+	if v := (*c).QueryParam("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	results, err := h.checkRepo.GetResults(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get check results", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check results retrieved successfully", results)
+}
+
+// GetUptime reports the uptime of a check over ?window= (a duration, 24h by default, at
+// most 720h), overall and per location
+func (h *CheckHandler) GetUptime(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	window := 24 * time.Hour
+	if v := (*c).QueryParam("window"); v != "" {
+		if window, err = time.ParseDuration(v); err != nil || window <= 0 || window > maxUptimeWindow {
+			return response.Error(c, http.StatusBadRequest, "Invalid window", err)
+		}
+	}
+
+	report, err := h.checkService.Uptime(ctx, id, window)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Check not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get check uptime", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check uptime retrieved successfully", report)
+}
+
+// GetAlerts lists the alerts of a check, or of every check on /checks/alerts, newest first.
+// Supports ?status=firing|resolved and ?limit=
+func (h *CheckHandler) GetAlerts(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var query domain.CheckAlertQuery
+	if v := (*c).Param("id"); v != "" {
+		var err error
+		if query.CheckID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+		}
+	}
+	query.Status = (*c).QueryParam("status")
+	if v := (*c).QueryParam("limit"); v != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	alerts, err := h.checkRepo.GetAlerts(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get check alerts", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Check alerts retrieved successfully", alerts)
+}
+
+// PingHeartbeat records a call of the ping URL of a heartbeat check by its job:
+// /heartbeats/:token when it succeeded, /start, /fail or /<exit code>.
+// Supports ?exit_code= and ?duration= (seconds, or a duration such as 1m30s); the start of
+// the request body is kept as the ping's message
+func (h *CheckHandler) PingHeartbeat(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	ping := domain.HeartbeatPing{
+		Kind:       domain.HeartbeatPingSuccess,
+		Source:     (*c).RealIP(),
+		UserAgent:  (*c).Request().UserAgent(),
+		ReceivedAt: time.Now(),
+	}
+
+	exitCode := (*c).QueryParam("exit_code")
+	switch kind := (*c).Param("kind"); kind {
+	case "":
+	case domain.HeartbeatPingStart, domain.HeartbeatPingFail:
+		ping.Kind = kind
+	default:
+		exitCode = kind
+	}
+	if exitCode != "" {
+		code, err := strconv.Atoi(exitCode)
+		if err != nil || code < 0 || code > 255 {
+			return response.Error(c, http.StatusBadRequest, "Invalid exit code", err)
+		}
+		ping.ExitCode = &code
+	}
+	if v := (*c).QueryParam("duration"); v != "" {
+		duration, err := parseSeconds(v)
+		if err != nil || duration < 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid duration", err)
+		}
+		ms := float64(duration.Microseconds()) / 1000
+		ping.DurationMs = &ms
+	}
+	if body := (*c).Request().Body; body != nil {
+		data, err := io.ReadAll(io.LimitReader(body, maxHeartbeatMessage))
+		if err != nil {
+			return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+		}
+		ping.Message = strings.TrimSpace(strings.ToValidUTF8(string(data), ""))
+	}
+
+	err := h.checkService.Ping(ctx, (*c).Param("token"), &ping)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Heartbeat check not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to record ping", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Ping recorded successfully", ping)
+}
+
+// GetPings lists the pings of a heartbeat check, newest first.
+// Supports ?kind=start|success|fail, ?since= (RFC3339) and ?limit=
+func (h *CheckHandler) GetPings(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid check ID", err)
+	}
+
+	query := domain.HeartbeatPingQuery{CheckID: id, Kind: (*c).QueryParam("kind")}
+	if query.Since, err = parseTimeParam(c, "since"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid since", err)
+	}
+	if v := (*c).QueryParam("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	pings, err := h.checkRepo.GetPings(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get heartbeat pings", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Heartbeat pings retrieved successfully", pings)
+}
+
+// parseSeconds parses a number of seconds or a duration string
+func parseSeconds(v string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil && !math.IsNaN(seconds) && !math.IsInf(seconds, 0) {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(v)
+}
+
+// bindCheck decodes and validates a check.
+// When it reports false the error response has already been written.
+func bindCheck(c *echo.Context, check *domain.Check) (bool, error) {
+	if err := (*c).Bind(check); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(check); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid check", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+)
+
+type CustomMetricHandler struct {
+	customRepo domain.CustomMetricRepository
+}
+
+func NewCustomMetricHandler(customRepo domain.CustomMetricRepository) *CustomMetricHandler {
+	return &CustomMetricHandler{
+		customRepo: customRepo,
+	}
+}
+
+// GetAgentChecks retrieves the latest result of every plugin on an agent
+func (h *CustomMetricHandler) GetAgentChecks(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+	id := (*c).Param("id")
+
+	checks, err := h.customRepo.GetChecks(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get custom checks", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Custom checks retrieved successfully", checks)
+}
+
+// GetAgentCustomMetrics retrieves custom metric samples for an agent.
+// Supports ?name=, ?source=, ?from= and ?to= (RFC3339) and ?limit=
+func (h *CustomMetricHandler) GetAgentCustomMetrics(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	query := domain.CustomMetricQuery{
+		AgentID: (*c).Param("id"),
+		Name:    (*c).QueryParam("name"),
+		Source:  (*c).QueryParam("source"),
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(c, "from"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid from", err)
+	}
+	if query.To, err = parseTimeParam(c, "to"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid to", err)
+	}
+	if v := (*c).QueryParam("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	points, err := h.customRepo.QueryPoints(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get custom metrics", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Custom metrics retrieved successfully", points)
+}
+
+// parseTimeParam parses an optional RFC3339 query parameter, returning the zero time when absent
+func parseTimeParam(c *echo.Context, name string) (time.Time, error) {
+	v := (*c).QueryParam(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+type DiscoveryHandler struct {
+	discoveryService *service.DiscoveryService
+	discoveryRepo    domain.DiscoveryRepository
+}
+
+func NewDiscoveryHandler(discoveryService *service.DiscoveryService, discoveryRepo domain.DiscoveryRepository) *DiscoveryHandler {
+	return &DiscoveryHandler{
+		discoveryService: discoveryService,
+		discoveryRepo:    discoveryRepo,
+	}
+}
+
+// GetStatus reports the discovery providers and the outcome of the last run
+func (h *DiscoveryHandler) GetStatus(c *echo.Context) error {
+	return response.Success(c, http.StatusOK, "Discovery status retrieved successfully", h.discoveryService.Status())
+}
+
+// Run starts a discovery run in the background; GET /discovery reports its outcome
+func (h *DiscoveryHandler) Run(c *echo.Context) error {
+	if !h.discoveryService.Enabled() {
+		return response.Error(c, http.StatusConflict, "Discovery is not configured", nil)
+	}
+	if !h.discoveryService.Trigger() {
+		return response.Error(c, http.StatusConflict, "A discovery run is already in progress", nil)
+	}
+	return response.Success(c, http.StatusAccepted, "Discovery run started", nil)
+}
+
+// GetDiscovered lists the agents awaiting approval and the rejected ones.
+// Supports ?status=pending|rejected
+func (h *DiscoveryHandler) GetDiscovered(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	status := (*c).QueryParam("status")
+	if status != "" && status != domain.DiscoveredPending && status != domain.DiscoveredRejected {
+		return response.Error(c, http.StatusBadRequest, "Invalid status", nil)
+	}
+
+	agents, err := h.discoveryRepo.GetAll(ctx, status)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get discovered agents", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Discovered agents retrieved successfully", agents)
+}
+
+// Approve registers a discovered agent with the tags it was queued with
+func (h *DiscoveryHandler) Approve(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid discovered agent ID", err)
+	}
+
+	agent, err := h.discoveryService.Approve(ctx, id, (*c).RealIP())
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Discovered agent not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusConflict, "Agent cannot be approved", err)
+	}
+	if errors.Is(err, service.ErrProbeFailed) {
+		return response.Error(c, http.StatusBadGateway, "Agent did not answer", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to register agent", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "Agent registered successfully", agent)
+}
+
+// Reject keeps a discovered agent from being registered or queued again
+func (h *DiscoveryHandler) Reject(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid discovered agent ID", err)
+	}
+
+	err = h.discoveryService.Reject(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Discovered agent not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to reject agent", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent rejected successfully", nil)
+}
+
+// Forget removes a discovered agent, so the next run finds it again
+func (h *DiscoveryHandler) Forget(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid discovered agent ID", err)
+	}
+
+	err = h.discoveryRepo.Delete(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Discovered agent not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to remove discovered agent", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Discovered agent removed successfully", nil)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+)
+
+type EnvAlertHandler struct {
+	alertRepo domain.EnvAlertRepository
+	envRepo   domain.EnvMetricsRepository
+}
+
+func NewEnvAlertHandler(alertRepo domain.EnvAlertRepository, envRepo domain.EnvMetricsRepository) *EnvAlertHandler {
+	return &EnvAlertHandler{
+		alertRepo: alertRepo,
+		envRepo:   envRepo,
+	}
+}
+
+// GetRules lists all alert rules
+func (h *EnvAlertHandler) GetRules(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	rules, err := h.alertRepo.GetRules(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get alert rules", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Alert rules retrieved successfully", rules)
+}
+
+// GetRule retrieves a single alert rule
+func (h *EnvAlertHandler) GetRule(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid rule ID", err)
+	}
+
+	rule, err := h.alertRepo.GetRule(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get alert rule", err)
+	}
+	if rule == nil {
+		return response.Error(c, http.StatusNotFound, "Alert rule not found", nil)
+	}
+
+	return response.Success(c, http.StatusOK, "Alert rule retrieved successfully", rule)
+}
+
+// CreateRule adds an alert rule; rules are enabled unless "enabled": false is sent
+func (h *EnvAlertHandler) CreateRule(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	rule := domain.EnvAlertRule{Enabled: true}
+	if ok, err := h.bindRule(c, &rule); !ok {
+		return err
+	}
+
+	if err := h.alertRepo.CreateRule(ctx, &rule); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to create alert rule", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "Alert rule created successfully", rule)
+}
+
+// UpdateRule replaces an alert rule
+func (h *EnvAlertHandler) UpdateRule(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid rule ID", err)
+	}
+
+	rule := domain.EnvAlertRule{Enabled: true}
+	if ok, err := h.bindRule(c, &rule); !ok {
+		return err
+	}
+	rule.ID = id
+
+	err = h.alertRepo.UpdateRule(ctx, &rule)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Alert rule not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to update alert rule", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Alert rule updated successfully", rule)
+}
+
+// DeleteRule removes an alert rule and its alerts
+func (h *EnvAlertHandler) DeleteRule(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid rule ID", err)
+	}
+
+	if err := h.alertRepo.DeleteRule(ctx, id); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to delete alert rule", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Alert rule deleted successfully", nil)
+}
+
+// GetAlerts lists raised alerts, newest first.
+// Supports ?status=firing|resolved, ?rule_id= and ?limit=
+func (h *EnvAlertHandler) GetAlerts(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	query := domain.EnvAlertQuery{Status: (*c).QueryParam("status")}
+
+	var err error
+	if v := (*c).QueryParam("rule_id"); v != "" {
+		if query.RuleID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return response.Error(c, http.StatusBadRequest, "Invalid rule_id", err)
+		}
+	}
+	if v := (*c).QueryParam("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	alerts, err := h.alertRepo.GetAlerts(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get alerts", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Alerts retrieved successfully", alerts)
+}
+
+// bindRule decodes and validates a rule, checking that its sensor is registered.
+// When it reports false the error response has already been written.
+func (h *EnvAlertHandler) bindRule(c *echo.Context, rule *domain.EnvAlertRule) (bool, error) {
+	if h.envRepo == nil {
+		return false, response.Error(c, http.StatusServiceUnavailable, "Environmental metrics unavailable", errEnvUnavailable)
+	}
+
+	if err := (*c).Bind(rule); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(rule); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid alert rule", err)
+	}
+
+	sensor, err := h.envRepo.GetSensor((*c).Request().Context(), rule.SensorID)
+	if err != nil {
+		return false, response.Error(c, http.StatusInternalServerError, "Failed to get sensor", err)
+	}
+	if sensor == nil {
+		return false, response.Error(c, http.StatusBadRequest, "Sensor not found", nil)
+	}
+	return true, nil
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/delivery/http/middleware"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+// maxEnvSubmissions limits the number of submissions in one bulk upload
+const maxEnvSubmissions = 1000
+
+type EnvMetricsHandler struct {
+	ingest  *service.EnvIngestService
+	history *service.EnvHistoryService
+}
+
+func NewEnvMetricsHandler(ingest *service.EnvIngestService, history *service.EnvHistoryService) *EnvMetricsHandler {
+	return &EnvMetricsHandler{
+		ingest:  ingest,
+		history: history,
+	}
+}
+
+// Submit handles POST /api/v1/env-metrics.
+// The body is a single submission or an array of them; an Idempotency-Key header
+// applies to a single submission, or to every entry of a bulk upload suffixed with its index.
+func (h *EnvMetricsHandler) Submit(c *echo.Context) error {
+	if h.ingest == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Environmental metrics unavailable", errEnvUnavailable)
+	}
+	req := (*c).Request()
+
+	body, err := readBody(req)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
+	}
+
+	submissions, bulk, err := service.DecodeEnvSubmissions(body)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if len(submissions) > maxEnvSubmissions {
+		return response.Error(c, http.StatusRequestEntityTooLarge, "Too many submissions", errors.New("at most "+strconv.Itoa(maxEnvSubmissions)+" per request"))
+	}
+
+	if key := req.Header.Get(middleware.HeaderIdempotencyKey); key != "" {
+		for i := range submissions {
+			if submissions[i].IdempotencyKey != "" {
+				continue
+			}
+			submissions[i].IdempotencyKey = key
+			if bulk {
+				submissions[i].IdempotencyKey += ":" + strconv.Itoa(i)
+			}
+		}
+	}
+
+	results, err := h.ingest.Submit(req.Context(), submissions)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid readings", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to store readings", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "Readings stored successfully", results)
+}
+
+// GetHistory handles GET /api/v1/env-metrics/history.
+// Supports ?sensor= (comma-separated, all sensors when absent), ?from= and ?to= (RFC3339,
+// default the last 24 hours), ?bucket= (default 5m), ?agg=avg|min|max|last|count and ?derived=true
+func (h *EnvMetricsHandler) GetHistory(c *echo.Context) error {
+	if h.history == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Environmental metrics unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	from, to, err := parseTimeRange(c, 24*time.Hour)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid time range", err)
+	}
+
+	query := domain.EnvHistoryQuery{
+		SensorIDs: splitQueryList((*c).QueryParam("sensor")),
+		From:      from,
+		To:        to,
+		Bucket:    5 * time.Minute,
+		Aggregate: domain.AggregateAvg,
+		Derived:   (*c).QueryParam("derived") == "true",
+	}
+	if v := (*c).QueryParam("bucket"); v != "" {
+		if query.Bucket, err = time.ParseDuration(v); err != nil {
+			return response.Error(c, http.StatusBadRequest, "Invalid bucket", err)
+		}
+	}
+	if v := (*c).QueryParam("agg"); v != "" {
+		query.Aggregate = v
+	}
+
+	series, err := h.history.History(ctx, query)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid history query", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get history", err)
+	}
+
+	return response.Success(c, http.StatusOK, "History retrieved successfully", series)
+}
+
+// GetDailySummary handles GET /api/v1/env-metrics/summary.
+// Supports ?sensor=, ?from= and ?to= (default the last 7 days) and ?tz= (IANA name, default UTC)
+func (h *EnvMetricsHandler) GetDailySummary(c *echo.Context) error {
+	if h.history == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Environmental metrics unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	from, to, err := parseTimeRange(c, 7*24*time.Hour)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid time range", err)
+	}
+
+	loc := time.UTC
+	if v := (*c).QueryParam("tz"); v != "" {
+		if loc, err = time.LoadLocation(v); err != nil {
+			return response.Error(c, http.StatusBadRequest, "Invalid tz", err)
+		}
+	}
+
+	summaries, err := h.history.DailySummaries(ctx, splitQueryList((*c).QueryParam("sensor")), from, to, loc)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid summary query", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get daily summary", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Daily summary retrieved successfully", summaries)
+}
+
+// parseTimeRange reads ?from= and ?to=, defaulting to now and to the given span before to
+func parseTimeRange(c *echo.Context, span time.Duration) (time.Time, time.Time, error) {
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-span)
+	}
+	return from, to, nil
+}
+
+// splitQueryList splits a comma-separated query parameter, dropping empty entries
+func splitQueryList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+type FleetHandler struct {
+	fleet *service.FleetCache
+}
+
+func NewFleetHandler(fleet *service.FleetCache) *FleetHandler {
+	return &FleetHandler{
+		fleet: fleet,
+	}
+}
+
+// GetSummary handles GET /api/v1/fleet/summary.
+// It returns every agent with its latest key metrics and the fleet-wide aggregates in one call.
+func (h *FleetHandler) GetSummary(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	summary, err := h.fleet.Summary(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get fleet summary", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Fleet summary retrieved successfully", summary)
+}
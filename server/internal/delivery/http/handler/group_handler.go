@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+type GroupHandler struct {
+	groupService *service.GroupService
+	groupRepo    domain.GroupRepository
+	alertRepo    domain.AgentAlertRepository
+	agentRepo    domain.AgentRepository
+}
+
+func NewGroupHandler(groupService *service.GroupService, groupRepo domain.GroupRepository, alertRepo domain.AgentAlertRepository, agentRepo domain.AgentRepository) *GroupHandler {
+	return &GroupHandler{
+		groupService: groupService,
+		groupRepo:    groupRepo,
+		alertRepo:    alertRepo,
+		agentRepo:    agentRepo,
+	}
+}
+
+// GetGroups lists every group; the hierarchy is given by parent_id
+func (h *GroupHandler) GetGroups(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	groups, err := h.groupRepo.GetAll(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get groups", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Groups retrieved successfully", groups)
+}
+
+// GetGroup retrieves a single group
+func (h *GroupHandler) GetGroup(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	group, err := h.groupRepo.Get(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get group", err)
+	}
+	if group == nil {
+		return response.Error(c, http.StatusNotFound, "Group not found", nil)
+	}
+
+	return response.Success(c, http.StatusOK, "Group retrieved successfully", group)
+}
+
+// CreateGroup adds a group
+func (h *GroupHandler) CreateGroup(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var group domain.Group
+	if ok, err := bindGroup(c, &group); !ok {
+		return err
+	}
+
+	err := h.groupService.Create(ctx, &group)
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid group", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to create group", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "Group created successfully", group)
+}
+
+// UpdateGroup replaces a group
+func (h *GroupHandler) UpdateGroup(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	var group domain.Group
+	if ok, err := bindGroup(c, &group); !ok {
+		return err
+	}
+	group.ID = id
+
+	err = h.groupService.Update(ctx, &group)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusBadRequest, "Invalid group", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to update group", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group updated successfully", group)
+}
+
+// DeleteGroup removes a group without child groups, with its config document and its alerts
+func (h *GroupHandler) DeleteGroup(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	err = h.groupService.Delete(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusConflict, "Group cannot be deleted", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to delete group", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group deleted successfully", nil)
+}
+
+// GetMembers lists the agents in a group and the groups under it, or only its own
+// members with ?direct=true
+func (h *GroupHandler) GetMembers(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+	direct, _ := strconv.ParseBool((*c).QueryParam("direct"))
+
+	agents, err := h.groupService.Members(ctx, id, direct)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get group members", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group members retrieved successfully", agents)
+}
+
+// AddMember adds an agent to a group whose members are managed by hand
+func (h *GroupHandler) AddMember(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	err = h.groupService.AddMember(ctx, id, (*c).Param("agent_id"))
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group or agent not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusConflict, "Group members cannot be added", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to add group member", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group member added successfully", nil)
+}
+
+// RemoveMember removes an agent from a group whose members are managed by hand
+func (h *GroupHandler) RemoveMember(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	err = h.groupService.RemoveMember(ctx, id, (*c).Param("agent_id"))
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group or member not found", nil)
+	}
+	if errors.Is(err, domain.ErrInvalidInput) {
+		return response.Error(c, http.StatusConflict, "Group members cannot be removed", err)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to remove group member", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group member removed successfully", nil)
+}
+
+// GetHealth aggregates the status, worst CPU and worst disk of the agents in a group
+func (h *GroupHandler) GetHealth(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+
+	health, err := h.groupService.Health(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return response.Error(c, http.StatusNotFound, "Group not found", nil)
+	}
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get group health", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Group health retrieved successfully", health)
+}
+
+// GetGroupAlerts lists the alerts raised by a group's rules, newest first.
+// Supports ?status=firing|resolved and ?limit=
+func (h *GroupHandler) GetGroupAlerts(c *echo.Context) error {
+	id, err := strconv.ParseInt((*c).Param("id"), 10, 64)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid group ID", err)
+	}
+	return h.getAlerts(c, domain.AgentAlertQuery{GroupID: id})
+}
+
+// GetAgentAlerts lists the alerts raised for an agent, newest first.
+// Supports ?status=firing|resolved and ?limit=
+func (h *GroupHandler) GetAgentAlerts(c *echo.Context) error {
+	return h.getAlerts(c, domain.AgentAlertQuery{AgentID: (*c).Param("id")})
+}
+
+// GetAgentGroups lists the groups containing an agent, outermost first
+func (h *GroupHandler) GetAgentGroups(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	agent, err := h.agentRepo.GetByID(ctx, (*c).Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+	}
+	if agent == nil {
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	}
+
+	groups, err := h.groupService.GroupsOf(ctx, agent)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agent groups", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Agent groups retrieved successfully", groups)
+}
+
+func (h *GroupHandler) getAlerts(c *echo.Context, query domain.AgentAlertQuery) error {
+	ctx := (*c).Request().Context()
+
+	query.Status = (*c).QueryParam("status")
+	if v := (*c).QueryParam("limit"); v != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	alerts, err := h.alertRepo.GetAlerts(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get alerts", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Alerts retrieved successfully", alerts)
+}
+
+// bindGroup decodes and validates a group.
+// When it reports false the error response has already been written.
+func bindGroup(c *echo.Context, group *domain.Group) (bool, error) {
+	if err := (*c).Bind(group); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(group); err != nil {
+		return false, response.Error(c, http.StatusBadRequest, "Invalid group", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/lineprotocol"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/otlp"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/remotewrite"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+// maxIngestBody limits the size of a single ingest request after decompression
+const maxIngestBody = 32 << 20
+
+type IngestHandler struct {
+	ingest *service.IngestService
+}
+
+func NewIngestHandler(ingest *service.IngestService) *IngestHandler {
+	return &IngestHandler{
+		ingest: ingest,
+	}
+}
+
+// PrometheusWrite handles POST /api/v1/ingest/prometheus/write (remote-write 1.0).
+// Series are grouped into virtual agents by their instance label.
+func (h *IngestHandler) PrometheusWrite(c *echo.Context) error {
+	req := (*c).Request()
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxIngestBody))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
+	}
+
+	series, err := remotewrite.Decode(body)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid remote-write payload", err)
+	}
+
+	batches := service.GroupByLabel(series, "instance")
+	if err := h.ingest.Ingest(req.Context(), domain.AgentTypePrometheus, batches); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to ingest metrics", err)
+	}
+
+	return (*c).NoContent(http.StatusNoContent)
+}
+
+// OTLPMetrics handles POST /api/v1/ingest/otlp/v1/metrics (OTLP/HTTP, protobuf or JSON).
+// Resources are grouped into virtual agents by their host.name attribute.
+func (h *IngestHandler) OTLPMetrics(c *echo.Context) error {
+	req := (*c).Request()
+
+	body, err := readBody(req)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+
+	var batches []domain.SeriesBatch
+	switch contentType {
+	case otlp.ContentTypeProtobuf:
+		batches, err = otlp.DecodeProtobuf(body)
+	case otlp.ContentTypeJSON:
+		batches, err = otlp.DecodeJSON(body)
+	default:
+		return response.Error(c, http.StatusUnsupportedMediaType, "Unsupported content type", errors.New(contentType))
+	}
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid OTLP payload", err)
+	}
+
+	if err := h.ingest.Ingest(req.Context(), domain.AgentTypeOTLP, batches); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to ingest metrics", err)
+	}
+
+	// An empty ExportMetricsServiceResponse, in the encoding of the request
+	if contentType == otlp.ContentTypeJSON {
+		return (*c).Blob(http.StatusOK, otlp.ContentTypeJSON, []byte("{}"))
+	}
+	return (*c).Blob(http.StatusOK, otlp.ContentTypeProtobuf, nil)
+}
+
+// InfluxWrite handles POST /api/v1/ingest/influx/write (InfluxDB line protocol, ?precision=).
+// Points are attributed to the agent named by their agent_id or host tag, falling back to the sender's IP.
+func (h *IngestHandler) InfluxWrite(c *echo.Context) error {
+	req := (*c).Request()
+
+	precision, err := lineprotocol.Precision((*c).QueryParam("precision"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid precision", err)
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
+	}
+
+	series, err := lineprotocol.Decode(string(body), precision, time.Now())
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid line protocol", err)
+	}
+
+	index := make(map[string]int)
+	var batches []domain.SeriesBatch
+	for _, s := range series {
+		source := (*c).RealIP()
+		if v := s.Labels["host"]; v != "" {
+			source = v
+		}
+		if v := s.Labels["agent_id"]; v != "" {
+			source = v
+		}
+
+		i, ok := index[source]
+		if !ok {
+			i = len(batches)
+			index[source] = i
+			batches = append(batches, domain.SeriesBatch{Source: source})
+		}
+		batches[i].Series = append(batches[i].Series, s)
+	}
+
+	if err := h.ingest.Ingest(req.Context(), domain.AgentTypeInflux, batches); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to ingest metrics", err)
+	}
+
+	return (*c).NoContent(http.StatusNoContent)
+}
+
+// readBody reads a request body of at most maxIngestBody bytes, decompressing gzip and zstd
+func readBody(req *http.Request) ([]byte, error) {
+	reader, err := compression.NewReader(req.Header.Get(echo.HeaderContentEncoding), req.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, maxIngestBody))
+}
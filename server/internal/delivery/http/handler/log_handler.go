@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+const (
+	// maxLogLimit bounds the lines returned by a query and sent as the backlog of a live tail
+	maxLogLimit = 1000
+	// logTailPing is how often an idle live tail is pinged to notice closed connections
+	logTailPing = 30 * time.Second
+)
+
+type LogHandler struct {
+	logService *service.LogService
+}
+
+func NewLogHandler(logService *service.LogService) *LogHandler {
+	return &LogHandler{
+		logService: logService,
+	}
+}
+
+// ReceiveLogs stores a batch of log lines shipped by an agent, gzip or zstd compressed
+func (h *LogHandler) ReceiveLogs(c *echo.Context) error {
+	req := (*c).Request()
+
+	body, err := readBody(req)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Failed to read request body", err)
+	}
+
+	var batch domain.LogBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid log batch", err)
+	}
+	if err := validator.Validate(&batch); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid log batch", err)
+	}
+
+	stored, err := h.logService.Ingest(req.Context(), (*c).Param("id"), batch.Lines)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	case err != nil:
+		return response.Error(c, http.StatusInternalServerError, "Failed to store log lines", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Log lines stored successfully", map[string]int{
+		"received": len(batch.Lines),
+		"stored":   stored,
+	})
+}
+
+// GetLogs searches the log lines of an agent, newest first.
+// Supports ?since= and ?until= (RFC3339), ?source=, ?unit=, ?level=, ?q= (text, case-insensitive),
+// ?regex=, ?limit= (at most 1000) and ?before_id= to page back
+func (h *LogHandler) GetLogs(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid log filter", err)
+	}
+	query := domain.LogQuery{
+		AgentID:   (*c).Param("id"),
+		LogFilter: filter,
+	}
+
+	if query.Since, err = parseTimeParam(c, "since"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid since", err)
+	}
+	if query.Until, err = parseTimeParam(c, "until"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid until", err)
+	}
+	if query.Limit, err = parseLogLimit(c, "limit"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+	}
+	if v := (*c).QueryParam("before_id"); v != "" {
+		if query.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil || query.BeforeID <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid before_id", err)
+		}
+	}
+
+	lines, err := h.logService.Query(ctx, query)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	case errors.Is(err, domain.ErrInvalidInput):
+		return response.Error(c, http.StatusBadRequest, "Invalid log query", err)
+	case err != nil:
+		return response.Error(c, http.StatusInternalServerError, "Failed to get log lines", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Log lines retrieved successfully", lines)
+}
+
+// TailLogs streams the new log lines of an agent over a WebSocket, as "line" messages and
+// "dropped" messages counting the lines left out when the client reads too slowly. It takes
+// the filters of GetLogs and ?backlog=, the latest lines to send first.
+func (h *LogHandler) TailLogs(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+	agentID := (*c).Param("id")
+
+	filter, err := parseLogFilter(c)
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid log filter", err)
+	}
+	backlog, err := parseLogLimit(c, "backlog")
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid backlog", err)
+	}
+
+	// Subscribing before reading the backlog may send a line twice but never misses one
+	tail, err := h.logService.Tail(ctx, agentID, filter)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	case err != nil:
+		return response.Error(c, http.StatusInternalServerError, "Failed to tail logs", err)
+	}
+	defer h.logService.Untail(tail)
+
+	var lines []domain.LogLine
+	if backlog > 0 {
+		lines, err = h.logService.Query(ctx, domain.LogQuery{AgentID: agentID, LogFilter: filter, Limit: backlog})
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to get log lines", err)
+		}
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true // Allow all origins, as the terminal does
+		},
+	}
+	ws, err := upgrader.Upgrade((*c).Response(), (*c).Request(), nil)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	// The client sends nothing; reading notices when it goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var lastID int64
+	for i := len(lines) - 1; i >= 0; i-- {
+		if err := ws.WriteJSON(domain.LogTailMessage{Type: domain.LogTailLine, Line: &lines[i]}); err != nil {
+			return nil
+		}
+		lastID = lines[i].ID
+	}
+
+	ping := time.NewTicker(logTailPing)
+	defer ping.Stop()
+
+	for {
+		select {
+		case line := <-tail.Lines():
+			if line.ID <= lastID {
+				continue // already sent in the backlog
+			}
+			if dropped := h.logService.Dropped(tail); dropped > 0 {
+				if err := ws.WriteJSON(domain.LogTailMessage{Type: domain.LogTailDropped, Dropped: dropped}); err != nil {
+					return nil
+				}
+			}
+			if err := ws.WriteJSON(domain.LogTailMessage{Type: domain.LogTailLine, Line: &line}); err != nil {
+				return nil
+			}
+		case <-ping.C:
+			if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return nil
+			}
+		case <-closed:
+			return nil
+		}
+	}
+}
+
+// parseLogFilter reads ?source=, ?unit=, ?level=, ?q= and ?regex=
+func parseLogFilter(c *echo.Context) (domain.LogFilter, error) {
+	filter := domain.LogFilter{
+		Source:   (*c).QueryParam("source"),
+		Unit:     (*c).QueryParam("unit"),
+		Level:    (*c).QueryParam("level"),
+		Contains: (*c).QueryParam("q"),
+	}
+	if v := (*c).QueryParam("regex"); v != "" {
+		pattern, err := regexp.Compile(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Pattern = pattern
+	}
+	return filter, nil
+}
+
+// parseLogLimit reads a line count of at most maxLogLimit, 0 when absent
+func parseLogLimit(c *echo.Context, name string) (int, error) {
+	v := (*c).QueryParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 || n > maxLogLimit {
+		return 0, fmt.Errorf("%s must be between 1 and %d", name, maxLogLimit)
+	}
+	return n, nil
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+)
+
+type PingHandler struct {
+	pingService *service.PingService
+	pingRepo    domain.PingRepository
+}
+
+func NewPingHandler(pingService *service.PingService, pingRepo domain.PingRepository) *PingHandler {
+	return &PingHandler{
+		pingService: pingService,
+		pingRepo:    pingRepo,
+	}
+}
+
+// GetMatrix returns the latency matrix: the latest result of every agent and target pair.
+// Supports ?max_age= (a duration, 1h by default) to leave out older results
+func (h *PingHandler) GetMatrix(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	maxAge := time.Hour
+	if v := (*c).QueryParam("max_age"); v != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(v); err != nil || maxAge <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid max_age", err)
+		}
+	}
+
+	matrix, err := h.pingService.Matrix(ctx, maxAge)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get latency matrix", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Latency matrix retrieved successfully", matrix)
+}
+
+// GetAgentResults lists the ping results of an agent, newest first.
+// Supports ?target=, ?since= (RFC3339) and ?limit=
+func (h *PingHandler) GetAgentResults(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	query := domain.PingResultQuery{
+		AgentID: (*c).Param("id"),
+		Target:  (*c).QueryParam("target"),
+	}
+
+	var err error
+	if query.Since, err = parseTimeParam(c, "since"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid since", err)
+	}
+	if v := (*c).QueryParam("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+	}
+
+	results, err := h.pingRepo.GetResults(ctx, query)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get ping results", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Ping results retrieved successfully", results)
+}
+
+// Traceroute has an agent connected over gRPC trace the route to a host
+func (h *PingHandler) Traceroute(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	var req domain.TracerouteRequest
+	if err := (*c).Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid traceroute", err)
+	}
+
+	result, err := h.pingService.Traceroute(ctx, (*c).Param("id"), req)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return response.Error(c, http.StatusNotFound, "Agent not found", nil)
+	case errors.Is(err, service.ErrAgentNotConnected):
+		return response.Error(c, http.StatusConflict, "Agent has no open command channel", err)
+	case errors.Is(err, service.ErrTracerouteFailed):
+		return response.Error(c, http.StatusBadGateway, "Traceroute failed", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return response.Error(c, http.StatusGatewayTimeout, "Agent did not reply in time", err)
+	case err != nil:
+		return response.Error(c, http.StatusInternalServerError, "Failed to run traceroute", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Traceroute completed", result)
+}
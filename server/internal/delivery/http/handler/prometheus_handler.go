@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/openmetrics"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+)
+
+type PrometheusHandler struct {
+	agentRepo domain.AgentRepository
+}
+
+func NewPrometheusHandler(agentRepo domain.AgentRepository) *PrometheusHandler {
+	return &PrometheusHandler{
+		agentRepo: agentRepo,
+	}
+}
+
+// Federate handles GET /api/v1/metrics/prometheus.
+// It exposes the latest stored sample of every registered agent in OpenMetrics format.
+func (h *PrometheusHandler) Federate(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+
+	agents, err := h.agentRepo.GetAll(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get agents", err)
+	}
+
+	exposition := openmetrics.New()
+	for _, agent := range agents {
+		labels := agentLabels(agent)
+
+		up := 0.0
+		if agent.Status == "online" {
+			up = 1
+		}
+		exposition.Gauge(openmetrics.Prefix+"agent_up", "Whether the agent was reachable on the last poll", up, labels)
+		exposition.Gauge(openmetrics.Prefix+"agent_last_seen_seconds", "Last time the agent was seen as a Unix timestamp", float64(agent.LastSeen.Unix()), labels)
+
+		metrics, err := h.agentRepo.GetLatestMetrics(ctx, agent.ID)
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to get metrics", err)
+		}
+		if metrics == nil {
+			continue
+		}
+
+		receivedAt := metrics.ReceivedAt
+		exposition.SetTimestamp(&receivedAt)
+		exposition.AddSystemMetrics(&metrics.Metrics, labels)
+		exposition.SetTimestamp(nil)
+	}
+
+	res := (*c).Response()
+	res.Header().Set(echo.HeaderContentType, openmetrics.ContentType)
+	res.WriteHeader(http.StatusOK)
+	_, err = exposition.WriteTo(res)
+	return err
+}
+
+// agentLabels labels samples with the agent identity and its tags.
+// Tags of the form key=value or key:value also become tag_<key> labels.
+func agentLabels(agent domain.Agent) openmetrics.Labels {
+	labels := openmetrics.Labels{
+		{Name: "agent_id", Value: agent.ID},
+		{Name: "agent", Value: agent.Name},
+	}
+	if len(agent.Tags) == 0 {
+		return labels
+	}
+
+	labels = labels.With("tags", ","+strings.Join(agent.Tags, ",")+",")
+
+	keyed := make(map[string]string)
+	for _, tag := range agent.Tags {
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			key, value, ok = strings.Cut(tag, ":")
+		}
+		if ok && key != "" {
+			keyed["tag_"+openmetrics.SanitizeName(key)] = value
+		}
+	}
+	keys := make([]string, 0, len(keyed))
+	for key := range keyed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		labels = labels.With(key, keyed[key])
+	}
+
+	return labels
+}
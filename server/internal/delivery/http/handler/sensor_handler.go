@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/validator"
+)
+
+// errEnvUnavailable is returned when no environmental metrics storage is configured
+var errEnvUnavailable = errors.New("environmental metrics storage is not configured")
+
+type SensorHandler struct {
+	envRepo   domain.EnvMetricsRepository
+	agentRepo domain.AgentRepository
+}
+
+func NewSensorHandler(envRepo domain.EnvMetricsRepository, agentRepo domain.AgentRepository) *SensorHandler {
+	return &SensorHandler{
+		envRepo:   envRepo,
+		agentRepo: agentRepo,
+	}
+}
+
+// GetSensors lists registered sensors with their latest reading
+func (h *SensorHandler) GetSensors(c *echo.Context) error {
+	if h.envRepo == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Sensors unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	sensors, err := h.envRepo.GetSensors(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get sensors", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Sensors retrieved successfully", sensors)
+}
+
+// GetSensor retrieves a single sensor with its latest reading
+func (h *SensorHandler) GetSensor(c *echo.Context) error {
+	if h.envRepo == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Sensors unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	sensor, err := h.envRepo.GetSensor(ctx, (*c).Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get sensor", err)
+	}
+
+	if sensor == nil {
+		return response.Error(c, http.StatusNotFound, "Sensor not found", nil)
+	}
+
+	return response.Success(c, http.StatusOK, "Sensor retrieved successfully", sensor)
+}
+
+// SaveSensor registers a sensor or updates an existing one with the same ID
+func (h *SensorHandler) SaveSensor(c *echo.Context) error {
+	if h.envRepo == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Sensors unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	var req domain.Sensor
+	if err := (*c).Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid request body", err)
+	}
+	if err := validator.Validate(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "Invalid sensor", err)
+	}
+
+	if req.AgentID != "" {
+		agent, err := h.agentRepo.GetByID(ctx, req.AgentID)
+		if err != nil {
+			return response.Error(c, http.StatusInternalServerError, "Failed to get agent", err)
+		}
+		if agent == nil {
+			return response.Error(c, http.StatusBadRequest, "Agent not found", nil)
+		}
+	}
+
+	req.LatestReading = nil
+	if err := h.envRepo.SaveSensor(ctx, &req); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to save sensor", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Sensor saved successfully", req)
+}
+
+// DeleteSensor removes a sensor and its readings
+func (h *SensorHandler) DeleteSensor(c *echo.Context) error {
+	if h.envRepo == nil {
+		return response.Error(c, http.StatusServiceUnavailable, "Sensors unavailable", errEnvUnavailable)
+	}
+	ctx := (*c).Request().Context()
+
+	if err := h.envRepo.DeleteSensor(ctx, (*c).Param("id")); err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to delete sensor", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Sensor deleted successfully", nil)
+}
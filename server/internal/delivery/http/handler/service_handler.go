@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+)
+
+type ServiceHandler struct {
+	serviceRepo domain.ServiceRepository
+}
+
+func NewServiceHandler(serviceRepo domain.ServiceRepository) *ServiceHandler {
+	return &ServiceHandler{
+		serviceRepo: serviceRepo,
+	}
+}
+
+// GetAgentServices retrieves the latest systemd unit states for an agent
+func (h *ServiceHandler) GetAgentServices(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+	id := (*c).Param("id")
+
+	states, err := h.serviceRepo.GetStates(ctx, id)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get services", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Services retrieved successfully", states)
+}
+
+// GetAgentServiceEvents retrieves systemd unit state changes for an agent
+func (h *ServiceHandler) GetAgentServiceEvents(c *echo.Context) error {
+	ctx := (*c).Request().Context()
+	id := (*c).Param("id")
+
+	limit := 100
+	if v := (*c).QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return response.Error(c, http.StatusBadRequest, "Invalid limit", err)
+		}
+		limit = n
+	}
+
+	events, err := h.serviceRepo.GetEvents(ctx, id, limit)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "Failed to get service events", err)
+	}
+
+	return response.Success(c, http.StatusOK, "Service events retrieved successfully", events)
+}
@@ -32,15 +32,14 @@ func (h *SystemMetricsHandler) GetMetrics(c *echo.Context) error {
 	ctx := (*c).Request().Context()
 
 	// Get environmental metrics from database
-	envMetrics, err := h.envRepo.GetLatest(ctx)
-	if err != nil {
-		// Log error but don't fail the request
-		println("Error getting env metrics:", err.Error())
-	}
-	if envMetrics != nil {
-		println("Found env metrics with ID:", envMetrics.ID)
-	} else {
-		println("No env metrics found in database")
+	var envMetrics *domain.EnvMetrics
+	if h.envRepo != nil {
+		var err error
+		envMetrics, err = h.envRepo.GetLatest(ctx)
+		if err != nil {
+			// Log error but don't fail the request
+			println("Error getting env metrics:", err.Error())
+		}
 	}
 
 	// Get CPU metrics
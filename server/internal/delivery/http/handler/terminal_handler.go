@@ -17,6 +17,11 @@ import (
 type TerminalHandler struct {
 	sessions map[string]*TerminalSession
 	mu       sync.RWMutex
+
+	// Sessions idle for idleTimeout are closed, checked every cleanupInterval
+	idleTimeout     time.Duration
+	cleanupInterval time.Duration
+	rescheduled     chan struct{}
 }
 
 type TerminalSession struct {
@@ -41,26 +46,55 @@ type TerminalResponse struct {
 	Session string `json:"session"` // session ID
 }
 
-func NewTerminalHandler() *TerminalHandler {
+func NewTerminalHandler(idleTimeout, cleanupInterval time.Duration) *TerminalHandler {
 	handler := &TerminalHandler{
-		sessions: make(map[string]*TerminalSession),
+		sessions:        make(map[string]*TerminalSession),
+		idleTimeout:     idleTimeout,
+		cleanupInterval: cleanupInterval,
+		rescheduled:     make(chan struct{}, 1),
 	}
 
-	// Cleanup old sessions every 5 minutes
+	// Cleanup idle sessions periodically
 	go handler.cleanupSessions()
 
 	return handler
 }
 
+// SetTimeouts changes the idle timeout of sessions and how often it is checked
+func (h *TerminalHandler) SetTimeouts(idleTimeout, cleanupInterval time.Duration) {
+	h.mu.Lock()
+	changed := h.cleanupInterval != cleanupInterval
+	h.idleTimeout, h.cleanupInterval = idleTimeout, cleanupInterval
+	h.mu.Unlock()
+
+	if changed {
+		select {
+		case h.rescheduled <- struct{}{}:
+		default:
+		}
+	}
+}
+
 func (h *TerminalHandler) cleanupSessions() {
-	ticker := time.NewTicker(5 * time.Minute)
+	h.mu.RLock()
+	ticker := time.NewTicker(h.cleanupInterval)
+	h.mu.RUnlock()
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.rescheduled:
+			h.mu.RLock()
+			ticker.Reset(h.cleanupInterval)
+			h.mu.RUnlock()
+			continue
+		}
+
 		h.mu.Lock()
 		now := time.Now()
 		for id, session := range h.sessions {
-			if now.Sub(session.lastUse) > 30*time.Minute {
+			if now.Sub(session.lastUse) > h.idleTimeout {
 				session.cleanup()
 				delete(h.sessions, id)
 				log.Printf("Cleaned up inactive session: %s", id)
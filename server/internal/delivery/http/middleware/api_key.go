@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/response"
+)
+
+const (
+	// HeaderAPIKey is the header checked besides "Authorization: Bearer <key>"
+	HeaderAPIKey = "X-API-Key"
+
+	// HeaderIdempotencyKey lets clients retry a request without storing it twice
+	HeaderIdempotencyKey = "Idempotency-Key"
+)
+
+// APIKey rejects requests that do not carry one of the given keys.
+// With no keys configured every request is rejected.
+func APIKey(keys []string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			req := (*c).Request()
+
+			provided := req.Header.Get(HeaderAPIKey)
+			if auth := req.Header.Get(echo.HeaderAuthorization); provided == "" && strings.HasPrefix(auth, "Bearer ") {
+				provided = strings.TrimPrefix(auth, "Bearer ")
+			}
+			if provided == "" {
+				return response.Unauthorized(c, "Missing API key")
+			}
+
+			for _, key := range keys {
+				if subtle.ConstantTimeCompare([]byte(provided), []byte(key)) == 1 {
+					return next(c)
+				}
+			}
+			return response.Unauthorized(c, "Invalid API key")
+		}
+	}
+}
@@ -2,15 +2,47 @@ package middleware
 
 import (
 	"net/http"
+	"slices"
+	"sync"
 
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
 )
 
-func CORS() echo.MiddlewareFunc {
+// CORSOrigins holds the origins allowed by CORS, replaceable while the server runs
+type CORSOrigins struct {
+	mu      sync.RWMutex
+	origins []string
+}
+
+func NewCORSOrigins(origins []string) *CORSOrigins {
+	return &CORSOrigins{origins: origins}
+}
+
+// Set replaces the allowed origins; "*" allows any
+func (o *CORSOrigins) Set(origins []string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.origins = origins
+}
+
+func (o *CORSOrigins) allow(c *echo.Context, origin string) (string, bool, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if slices.Contains(o.origins, "*") {
+		return "*", true, nil
+	}
+	if slices.Contains(o.origins, origin) {
+		return origin, true, nil
+	}
+	return "", false, nil
+}
+
+func CORS(origins *CORSOrigins) echo.MiddlewareFunc {
 	return middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodOptions},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
+		UnsafeAllowOriginFunc: origins.allow,
+		AllowMethods:          []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodOptions},
+		AllowHeaders:          []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, HeaderAPIKey, HeaderIdempotencyKey},
 	})
 }
@@ -6,9 +6,30 @@ import (
 	"github.com/rafia9005/realtime-monitoring-server/internal/delivery/http/middleware"
 )
 
-func SetupRouter(e *echo.Echo, systemMetricsHandler *handler.SystemMetricsHandler, terminalHandler *handler.TerminalHandler, agentHandler *handler.AgentHandler) {
+// Handlers are the handlers of the routes registered by SetupRouter
+type Handlers struct {
+	SystemMetrics *handler.SystemMetricsHandler
+	Terminal      *handler.TerminalHandler
+	Agent         *handler.AgentHandler
+	Service       *handler.ServiceHandler
+	CustomMetric  *handler.CustomMetricHandler
+	Prometheus    *handler.PrometheusHandler
+	Ingest        *handler.IngestHandler
+	Sensor        *handler.SensorHandler
+	EnvMetrics    *handler.EnvMetricsHandler
+	EnvAlert      *handler.EnvAlertHandler
+	AgentConfig   *handler.AgentConfigHandler
+	Group         *handler.GroupHandler
+	Fleet         *handler.FleetHandler
+	Discovery     *handler.DiscoveryHandler
+	Check         *handler.CheckHandler
+	Ping          *handler.PingHandler
+	Log           *handler.LogHandler
+}
+
+func SetupRouter(e *echo.Echo, h *Handlers, envAPIKeys []string, corsOrigins *middleware.CORSOrigins) {
 	// Middleware
-	e.Use(middleware.CORS())
+	e.Use(middleware.CORS(corsOrigins))
 
 	// Health check
 	e.GET("/health", func(c *echo.Context) error {
@@ -21,18 +42,122 @@ func SetupRouter(e *echo.Echo, systemMetricsHandler *handler.SystemMetricsHandle
 	v1 := e.Group("/api/v1")
 
 	// System Metrics endpoint (includes environmental metrics from database)
-	v1.GET("/system-metrics", systemMetricsHandler.GetMetrics)
+	v1.GET("/system-metrics", h.SystemMetrics.GetMetrics)
+
+	// Authenticated sensor reading submission (single, bulk and idempotent uploads)
+	v1.POST("/env-metrics", h.EnvMetrics.Submit, middleware.APIKey(envAPIKeys))
+
+	// Environmental history, daily summaries and threshold alerts
+	env := v1.Group("/env-metrics")
+	env.GET("/history", h.EnvMetrics.GetHistory)
+	env.GET("/summary", h.EnvMetrics.GetDailySummary)
+	env.GET("/alerts", h.EnvAlert.GetAlerts)
+	env.GET("/alert-rules", h.EnvAlert.GetRules)
+	env.POST("/alert-rules", h.EnvAlert.CreateRule)
+	env.GET("/alert-rules/:id", h.EnvAlert.GetRule)
+	env.PUT("/alert-rules/:id", h.EnvAlert.UpdateRule)
+	env.DELETE("/alert-rules/:id", h.EnvAlert.DeleteRule)
+
+	// Environmental sensor registry
+	sensors := v1.Group("/sensors")
+	sensors.GET("", h.Sensor.GetSensors)
+	sensors.POST("", h.Sensor.SaveSensor)
+	sensors.GET("/:id", h.Sensor.GetSensor)
+	sensors.DELETE("/:id", h.Sensor.DeleteSensor)
+
+	// Prometheus federation endpoint (latest sample of every agent)
+	v1.GET("/metrics/prometheus", h.Prometheus.Federate)
+
+	// Ingest endpoints for hosts running node_exporter or an OpenTelemetry collector,
+	// and for application metrics in Influx line protocol
+	ingest := v1.Group("/ingest")
+	ingest.POST("/prometheus/write", h.Ingest.PrometheusWrite)
+	ingest.POST("/otlp/v1/metrics", h.Ingest.OTLPMetrics)
+	ingest.POST("/influx/write", h.Ingest.InfluxWrite)
 
 	// WebSocket Terminal endpoint
-	v1.GET("/terminal", terminalHandler.HandleTerminal)
+	v1.GET("/terminal", h.Terminal.HandleTerminal)
 
 	// Agent endpoints
 	agents := v1.Group("/agents")
-	agents.POST("/register", agentHandler.RegisterAgent)
-	agents.GET("", agentHandler.GetAllAgents)
-	agents.GET("/:id", agentHandler.GetAgent)
-	agents.DELETE("/:id", agentHandler.DeleteAgent)
-	agents.POST("/heartbeat", agentHandler.Heartbeat)
-	agents.POST("/metrics", agentHandler.ReceiveMetrics)
-	agents.GET("/:id/metrics", agentHandler.GetAgentMetrics)
+	agents.POST("/register", h.Agent.RegisterAgent)
+	agents.GET("", h.Agent.GetAllAgents)
+	agents.GET("/protocols", h.Agent.Protocols)
+	agents.GET("/:id", h.Agent.GetAgent)
+	agents.PATCH("/:id", h.Agent.UpdateAgent)
+	agents.DELETE("/:id", h.Agent.DeleteAgent)
+	agents.POST("/:id/reprobe", h.Agent.ReprobeAgent)
+	agents.GET("/:id/audit", h.Agent.GetAgentAudit)
+	agents.POST("/heartbeat", h.Agent.Heartbeat)
+	agents.POST("/metrics", h.Agent.ReceiveMetrics)
+	agents.GET("/:id/metrics", h.Agent.GetAgentMetrics)
+	agents.POST("/:id/commands", h.Agent.SendCommand)
+	agents.GET("/:id/config", h.AgentConfig.GetAgentConfig)
+	agents.GET("/:id/services", h.Service.GetAgentServices)
+	agents.GET("/:id/services/events", h.Service.GetAgentServiceEvents)
+	agents.GET("/:id/custom", h.CustomMetric.GetAgentChecks)
+	agents.GET("/:id/custom/metrics", h.CustomMetric.GetAgentCustomMetrics)
+	agents.GET("/:id/ping", h.Ping.GetAgentResults)
+	agents.POST("/:id/traceroute", h.Ping.Traceroute)
+	agents.POST("/:id/logs", h.Log.ReceiveLogs)
+	agents.GET("/:id/logs", h.Log.GetLogs)
+	agents.GET("/:id/logs/tail", h.Log.TailLogs)
+	agents.GET("/:id/groups", h.Group.GetAgentGroups)
+	agents.GET("/:id/alerts", h.Group.GetAgentAlerts)
+
+	// Fleet overview: every agent with its latest key metrics and fleet-wide aggregates
+	v1.GET("/fleet/summary", h.Fleet.GetSummary)
+
+	// Agent discovery and the agents it found awaiting approval
+	discovery := v1.Group("/discovery")
+	discovery.GET("", h.Discovery.GetStatus)
+	discovery.POST("/run", h.Discovery.Run)
+	discovery.GET("/agents", h.Discovery.GetDiscovered)
+	discovery.POST("/agents/:id/approve", h.Discovery.Approve)
+	discovery.POST("/agents/:id/reject", h.Discovery.Reject)
+	discovery.DELETE("/agents/:id", h.Discovery.Forget)
+
+	// Latency matrix of the ping checks agents run against each other and other sites
+	v1.GET("/ping/matrix", h.Ping.GetMatrix)
+
+	// Synthetic HTTP, TCP, DNS, TLS and heartbeat checks with their results, uptime and alerts
+	checks := v1.Group("/checks")
+	checks.GET("", h.Check.GetChecks)
+	checks.POST("", h.Check.CreateCheck)
+	checks.GET("/alerts", h.Check.GetAlerts)
+	checks.GET("/:id", h.Check.GetCheck)
+	checks.PUT("/:id", h.Check.UpdateCheck)
+	checks.DELETE("/:id", h.Check.DeleteCheck)
+	checks.POST("/:id/run", h.Check.RunCheck)
+	checks.GET("/:id/results", h.Check.GetResults)
+	checks.GET("/:id/uptime", h.Check.GetUptime)
+	checks.GET("/:id/alerts", h.Check.GetAlerts)
+	checks.GET("/:id/pings", h.Check.GetPings)
+
+	// Ping URLs of heartbeat checks, called by their jobs with GET or POST
+	heartbeats := v1.Group("/heartbeats")
+	heartbeats.GET("/:token", h.Check.PingHeartbeat)
+	heartbeats.POST("/:token", h.Check.PingHeartbeat)
+	heartbeats.GET("/:token/:kind", h.Check.PingHeartbeat)
+	heartbeats.POST("/:token/:kind", h.Check.PingHeartbeat)
+
+	// Agent groups: the environment → datacenter → role hierarchy, members, health and alerts
+	groups := v1.Group("/groups")
+	groups.GET("", h.Group.GetGroups)
+	groups.POST("", h.Group.CreateGroup)
+	groups.GET("/:id", h.Group.GetGroup)
+	groups.PUT("/:id", h.Group.UpdateGroup)
+	groups.DELETE("/:id", h.Group.DeleteGroup)
+	groups.GET("/:id/members", h.Group.GetMembers)
+	groups.PUT("/:id/members/:agent_id", h.Group.AddMember)
+	groups.DELETE("/:id/members/:agent_id", h.Group.RemoveMember)
+	groups.GET("/:id/health", h.Group.GetHealth)
+	groups.GET("/:id/alerts", h.Group.GetGroupAlerts)
+
+	// Server-managed agent config documents, per tag, group or agent
+	agentConfigs := v1.Group("/agent-configs")
+	agentConfigs.GET("", h.AgentConfig.GetConfigs)
+	agentConfigs.GET("/:scope/:target", h.AgentConfig.GetConfig)
+	agentConfigs.PUT("/:scope/:target", h.AgentConfig.SaveConfig)
+	agentConfigs.DELETE("/:scope/:target", h.AgentConfig.DeleteConfig)
 }
@@ -2,28 +2,82 @@ package domain
 
 import "time"
 
+// Agent types. Only AgentTypeAgent is polled, the others are virtual agents fed by ingest endpoints
+const (
+	AgentTypeAgent      = "agent"
+	AgentTypePrometheus = "prometheus"
+	AgentTypeOTLP       = "otlp"
+	AgentTypeStatsD     = "statsd"
+	AgentTypeInflux     = "influx"
+)
+
 // Agent represents a monitored server
 type Agent struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Host        string    `json:"host"` // e.g., "192.168.1.100:9090" or "localhost:9090"
-	Hostname    string    `json:"hostname"`
-	IPAddress   string    `json:"ip_address"`
-	Status      string    `json:"status"` // online, offline, error
-	LastSeen    time.Time `json:"last_seen"`
-	Version     string    `json:"version"`
-	Tags        []string  `json:"tags,omitempty"`
-	Description string    `json:"description,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string    `json:"id" proto:"1"`
+	Name        string    `json:"name" proto:"2"`
+	Type        string    `json:"type" proto:"3"`
+	Host        string    `json:"host" proto:"4"` // e.g., "192.168.1.100:9090" or "localhost:9090"
+	Hostname    string    `json:"hostname" proto:"5"`
+	IPAddress   string    `json:"ip_address" proto:"6"`
+	Status      string    `json:"status" proto:"7"` // online, offline, error
+	LastSeen    time.Time `json:"last_seen" proto:"8"`
+	Version     string    `json:"version" proto:"9"`
+	Tags        []string  `json:"tags,omitempty" proto:"10"`
+	Description string    `json:"description,omitempty" proto:"11"`
+	CreatedAt   time.Time `json:"created_at" proto:"12"`
+	UpdatedAt   time.Time `json:"updated_at" proto:"13"`
+	// ConfigVersion is the version of the server-managed config the agent last reported running
+	ConfigVersion string `json:"config_version,omitempty" proto:"14"`
+}
+
+// IsVirtual reports whether the agent is fed by an ingest endpoint instead of being polled
+func (a *Agent) IsVirtual() bool {
+	return a.Type != "" && a.Type != AgentTypeAgent
+}
+
+// Fields agents can be sorted by
+const (
+	AgentSortName      = "name"
+	AgentSortHostname  = "hostname"
+	AgentSortStatus    = "status"
+	AgentSortVersion   = "version"
+	AgentSortLastSeen  = "last_seen"
+	AgentSortCreatedAt = "created_at"
+)
+
+// Ways of matching the tags of an AgentQuery
+const (
+	AgentTagMatchAny = "any" // agents carrying at least one of the tags
+	AgentTagMatchAll = "all" // agents carrying every tag
+)
+
+// AgentQuery filters, sorts and pages the agent list. Zero values do not filter.
+type AgentQuery struct {
+	Status     string
+	Type       string
+	Tags       []string
+	TagMatch   string // AgentTagMatchAny (default) or AgentTagMatchAll
+	Search     string // substring of the name, hostname or IP address, case-insensitive
+	Version    string
+	SeenAfter  time.Time // last_seen at or after
+	SeenBefore time.Time // last_seen before
+	Sort       string    // an AgentSort field, AgentSortCreatedAt by default
+	Descending bool
+	Cursor     string // from the previous page, empty for the first page
+	Limit      int    // 0 for every agent
 }
 
 // AgentMetrics combines agent info with system metrics
 type AgentMetrics struct {
-	AgentID    string        `json:"agent_id"`
-	AgentName  string        `json:"agent_name"`
-	Metrics    SystemMetrics `json:"metrics"`
-	ReceivedAt time.Time     `json:"received_at"`
+	AgentID    string        `json:"agent_id" proto:"1"`
+	AgentName  string        `json:"agent_name" proto:"2"`
+	Metrics    SystemMetrics `json:"metrics" proto:"3"`
+	ReceivedAt time.Time     `json:"received_at" proto:"4"`
+}
+
+// AgentMetricsBatch is the protobuf form of a batch of samples pushed by an agent
+type AgentMetricsBatch struct {
+	Samples []AgentMetrics `json:"samples" proto:"1"`
 }
 
 // AgentRegistration represents agent registration request
@@ -37,9 +91,28 @@ type AgentRegistration struct {
 	Description string   `json:"description,omitempty"`
 }
 
+// AgentUpdate edits the details of a registered agent. Fields left out (nil) keep their
+// value; an empty tags list removes every tag.
+type AgentUpdate struct {
+	Name        *string   `json:"name" validate:"omitempty,min=1,max=128"`
+	Host        *string   `json:"host" validate:"omitempty,hostname_port"`
+	Tags        *[]string `json:"tags" validate:"omitempty,max=32,dive,min=1,max=64"`
+	Description *string   `json:"description" validate:"omitempty,max=1024"`
+}
+
+// AgentInfo is what an agent reports about itself on GET /info
+type AgentInfo struct {
+	Name      string `json:"name"`
+	Hostname  string `json:"hostname"`
+	IPAddress string `json:"ip_address"`
+	Version   string `json:"version"`
+}
+
 // AgentHeartbeat represents periodic agent check-in
 type AgentHeartbeat struct {
 	AgentID   string    `json:"agent_id" validate:"required"`
 	Timestamp time.Time `json:"timestamp" validate:"required"`
 	Status    string    `json:"status"`
+	// ConfigVersion is the version of the server-managed config the agent is running
+	ConfigVersion string `json:"config_version,omitempty"`
 }
@@ -0,0 +1,63 @@
+package domain
+
+import "time"
+
+// Metrics watched by agent alert rules
+const (
+	AgentAlertMetricCPU     = "cpu"     // CPU usage percent
+	AgentAlertMetricMemory  = "memory"  // memory used percent
+	AgentAlertMetricDisk    = "disk"    // used percent of the fullest disk
+	AgentAlertMetricLoad    = "load"    // 1-minute load average
+	AgentAlertMetricOffline = "offline" // 1 while the agent is not online, 0 otherwise
+	// AgentAlertMetricFailedUnits is the number of the systemd units an agent monitors that
+	// are in the failed state
+	AgentAlertMetricFailedUnits = "failed_units"
+)
+
+// AgentAlertRule raises an alert for each agent of its group whose metric stays above or
+// below a threshold for a duration, e.g. disk above 90% for 300 seconds
+type AgentAlertRule struct {
+	Name            string  `json:"name" validate:"required,max=128"`
+	Metric          string  `json:"metric" validate:"required,oneof=cpu memory disk load offline failed_units"`
+	Condition       string  `json:"condition" validate:"required,oneof=above below"`
+	Threshold       float64 `json:"threshold"`
+	DurationSeconds int64   `json:"duration_seconds" validate:"min=0,max=86400"`
+}
+
+// Duration is how long the condition must hold before the alert fires
+func (r *AgentAlertRule) Duration() time.Duration {
+	return time.Duration(r.DurationSeconds) * time.Second
+}
+
+// Breached reports whether a value meets the rule's condition
+func (r *AgentAlertRule) Breached(value float64) bool {
+	if r.Condition == AlertConditionBelow {
+		return value < r.Threshold
+	}
+	return value > r.Threshold
+}
+
+// AgentAlert is one firing of a group alert rule for an agent
+type AgentAlert struct {
+	ID         int64      `json:"id"`
+	GroupID    int64      `json:"group_id"`
+	RuleName   string     `json:"rule_name"`
+	AgentID    string     `json:"agent_id"`
+	AgentName  string     `json:"agent_name"`
+	Metric     string     `json:"metric"`
+	Condition  string     `json:"condition"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"` // latest value while firing
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"` // first evaluation that saw the breach
+	FiredAt    time.Time  `json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// AgentAlertQuery filters stored agent alerts, newest first
+type AgentAlertQuery struct {
+	GroupID int64
+	AgentID string
+	Status  string
+	Limit   int
+}
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// Actions recorded in the audit trail of an agent
+const (
+	AgentAuditRegister = "register"
+	AgentAuditUpdate   = "update"
+	AgentAuditReprobe  = "reprobe"
+	AgentAuditDelete   = "delete"
+)
+
+// AgentChange is the value of an agent field before and after a change
+type AgentChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// AgentAuditEntry records a change of an agent's details. Entries outlive the agent, so
+// its history stays available after it is deleted.
+type AgentAuditEntry struct {
+	ID      int64  `json:"id"`
+	AgentID string `json:"agent_id"`
+	Action  string `json:"action"`
+	// Actor is who made the change: the client address of an API call, or "agent" when the
+	// agent registered itself
+	Actor     string                 `json:"actor"`
+	Changes   map[string]AgentChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scopes of server-managed agent config documents
+const (
+	AgentConfigScopeTag   = "tag"   // applies to every agent carrying the tag
+	AgentConfigScopeGroup = "group" // applies to every agent in the group, over its tag documents
+	AgentConfigScopeAgent = "agent" // applies to a single agent, over its tag and group documents
+)
+
+// Duration is a time.Duration written as a Go duration string ("15s", "1m30s") in JSON and YAML
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// AgentSettings are the agent settings applied without a restart: they come from the
+// agent's config file, overlaid with the config documents the server stores for it
+type AgentSettings struct {
+	// CollectInterval is how often a pushing agent collects a sample
+	CollectInterval Duration        `json:"collect_interval,omitempty" yaml:"collect_interval,omitempty"`
+	Collectors      AgentCollectors `json:"collectors" yaml:"collectors"`
+	// Mount points (glob patterns), filesystem types and interfaces (glob patterns) left out of samples
+	IgnoreMounts     []string `json:"ignore_mounts,omitempty" yaml:"ignore_mounts,omitempty"`
+	IgnoreFsTypes    []string `json:"ignore_fs_types,omitempty" yaml:"ignore_fs_types,omitempty"`
+	IgnoreInterfaces []string `json:"ignore_interfaces,omitempty" yaml:"ignore_interfaces,omitempty"`
+	// Services are the systemd units reported under services
+	Services       []string `json:"services,omitempty" yaml:"services,omitempty"`
+	PluginInterval Duration `json:"plugin_interval,omitempty" yaml:"plugin_interval,omitempty"`
+	PluginTimeout  Duration `json:"plugin_timeout,omitempty" yaml:"plugin_timeout,omitempty"`
+	// Ping schedules the ping checks reported under ping
+	Ping PingSettings `json:"ping" yaml:"ping"`
+	// Logs chooses the log files and journal units shipped to the server
+	Logs LogSettings `json:"logs" yaml:"logs"`
+}
+
+// AgentCollectors switches the sections of a sample on and off
+type AgentCollectors struct {
+	CPU         bool `json:"cpu" yaml:"cpu"`
+	Memory      bool `json:"memory" yaml:"memory"`
+	Disk        bool `json:"disk" yaml:"disk"`
+	Network     bool `json:"network" yaml:"network"`
+	Connections bool `json:"connections" yaml:"connections"`
+	Processes   bool `json:"processes" yaml:"processes"`
+	Load        bool `json:"load" yaml:"load"`
+	Temperature bool `json:"temperature" yaml:"temperature"`
+	Containers  bool `json:"containers" yaml:"containers"`
+	Services    bool `json:"services" yaml:"services"`
+	Pressure    bool `json:"pressure" yaml:"pressure"` // pressure, memory detail and vmstat from procfs
+	Plugins     bool `json:"plugins" yaml:"plugins"`
+}
+
+// DefaultAgentSettings returns the settings of an agent without config: every collector on
+func DefaultAgentSettings() AgentSettings {
+	return AgentSettings{
+		CollectInterval: Duration(15 * time.Second),
+		Collectors: AgentCollectors{
+			CPU: true, Memory: true, Disk: true, Network: true, Connections: true, Processes: true,
+			Load: true, Temperature: true, Containers: true, Services: true, Pressure: true, Plugins: true,
+		},
+		PluginInterval: Duration(60 * time.Second),
+		PluginTimeout:  Duration(10 * time.Second),
+		Ping: PingSettings{
+			Interval: Duration(60 * time.Second),
+			Count:    5,
+			Timeout:  Duration(2 * time.Second),
+		},
+		Logs: LogSettings{
+			FlushInterval: Duration(2 * time.Second),
+			BatchSize:     500,
+			MaxLineBytes:  16 << 10,
+		},
+	}
+}
+
+// Validate checks the intervals of the settings, the ping targets and the log settings
+func (s *AgentSettings) Validate() error {
+	if s.CollectInterval < Duration(time.Second) {
+		return fmt.Errorf("collect_interval must be at least 1s")
+	}
+	if s.PluginInterval < Duration(time.Second) {
+		return fmt.Errorf("plugin_interval must be at least 1s")
+	}
+	if s.PluginTimeout <= 0 {
+		return fmt.Errorf("plugin_timeout must be positive")
+	}
+	if err := s.Ping.Validate(); err != nil {
+		return err
+	}
+	return s.Logs.Validate()
+}
+
+// AgentConfigDocument is a partial AgentSettings object stored on the server for an agent
+// or a tag. Only the keys it sets override the agent's own settings.
+type AgentConfigDocument struct {
+	Scope     string                 `json:"scope"`
+	Target    string                 `json:"target"` // agent ID or tag
+	Settings  map[string]interface{} `json:"settings"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// AgentConfig is the effective server-managed config of an agent: its tag documents (in tag
+// order), its group documents (outermost group first) and its own document merged,
+// identified by a version hash
+type AgentConfig struct {
+	AgentID        string                 `json:"agent_id"`
+	Version        string                 `json:"version"`
+	Settings       map[string]interface{} `json:"settings"`
+	Sources        []string               `json:"sources"`         // documents merged, e.g. "tag:production", "group:3", "agent"
+	AppliedVersion string                 `json:"applied_version"` // version the agent last reported running
+	InSync         bool                   `json:"in_sync"`
+}
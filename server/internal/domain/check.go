@@ -0,0 +1,235 @@
+package domain
+
+import "time"
+
+// Synthetic check types
+const (
+	CheckTypeHTTP = "http" // target is an http or https URL
+	CheckTypeTCP  = "tcp"  // target is host:port
+	CheckTypeDNS  = "dns"  // target is a name to resolve
+	CheckTypeTLS  = "tls"  // target is host:port
+	// CheckTypeHeartbeat is not probed: its job calls the check's ping URL, whose token the
+	// server issues as the target
+	CheckTypeHeartbeat = "heartbeat"
+)
+
+// Synthetic check statuses. A check without results yet, or whose agent could not run it,
+// is CheckStatusUnknown.
+const (
+	CheckStatusUp   = "up"
+	CheckStatusDown = "down"
+)
+
+// CheckLocationServer runs a check from the server itself; other locations are agent IDs
+const CheckLocationServer = "server"
+
+// Check is a synthetic probe of an endpoint that runs no agent, repeated every interval from
+// each of its locations
+type Check struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name" validate:"required,max=128"`
+	CheckSpec
+	IntervalSeconds int `json:"interval_seconds" validate:"omitempty,min=10,max=86400"` // 60 by default
+	// Locations run the check: CheckLocationServer and agent IDs, the server when empty
+	Locations []string `json:"locations" validate:"max=16,dive,required"`
+	// FailureThreshold is the number of consecutive failures at a location that fire an
+	// alert, 1 by default
+	FailureThreshold int                 `json:"failure_threshold" validate:"omitempty,min=1,max=100"`
+	Notifications    []NotificationRoute `json:"notifications" validate:"max=16,dive"`
+	Paused           bool                `json:"paused"`
+	// Status is down while an alert fires at any location, unknown before the first result
+	Status         string                `json:"status"`
+	LocationStatus []CheckLocationStatus `json:"location_status"`
+	HeartbeatState *HeartbeatState       `json:"heartbeat_state,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// Interval is how often the check runs
+func (c *Check) Interval() time.Duration {
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+// CheckSpec is what a probe needs to run a check; agents running a check are sent it as is
+type CheckSpec struct {
+	Type           string                 `json:"type" validate:"required,oneof=http tcp dns tls heartbeat"`
+	Target         string                 `json:"target" validate:"max=2048"`
+	TimeoutSeconds int                    `json:"timeout_seconds" validate:"omitempty,min=1,max=120"` // 10 by default
+	HTTP           *HTTPCheckOptions      `json:"http,omitempty"`
+	DNS            *DNSCheckOptions       `json:"dns,omitempty"`
+	TLS            *TLSCheckOptions       `json:"tls,omitempty"`
+	Heartbeat      *HeartbeatCheckOptions `json:"heartbeat,omitempty"`
+}
+
+// Timeout bounds a single run of the probe
+func (s *CheckSpec) Timeout() time.Duration {
+	return time.Duration(s.TimeoutSeconds) * time.Second
+}
+
+// HTTPCheckOptions tune an HTTP check
+type HTTPCheckOptions struct {
+	Method  string            `json:"method,omitempty" validate:"omitempty,oneof=GET HEAD POST PUT OPTIONS"` // GET by default
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	// ExpectedStatus lists the accepted status codes, any 2xx or 3xx when empty
+	ExpectedStatus []int `json:"expected_status,omitempty" validate:"dive,min=100,max=599"`
+	// BodyMatch is a regular expression the response body must match
+	BodyMatch string `json:"body_match,omitempty"`
+	// MaxLatencyMs fails responses slower than this
+	MaxLatencyMs  int  `json:"max_latency_ms,omitempty" validate:"omitempty,min=1"`
+	SkipTLSVerify bool `json:"skip_tls_verify,omitempty"`
+}
+
+// DNSCheckOptions tune a DNS check
+type DNSCheckOptions struct {
+	RecordType string `json:"record_type,omitempty" validate:"omitempty,oneof=A AAAA CNAME MX TXT NS"` // A by default
+	// Expected values must all be in the answer, which only has to be non-empty otherwise
+	Expected []string `json:"expected,omitempty"`
+	// Resolver is the host:port of the DNS server asked, the system resolver when empty
+	Resolver string `json:"resolver,omitempty" validate:"omitempty,hostname_port"`
+}
+
+// TLSCheckOptions tune a TLS certificate check
+type TLSCheckOptions struct {
+	// MinDaysRemaining fails certificates expiring sooner, 14 by default
+	MinDaysRemaining int `json:"min_days_remaining,omitempty" validate:"omitempty,min=1,max=3650"`
+	// ServerName is sent for SNI and verified, the target's host by default
+	ServerName string `json:"server_name,omitempty"`
+	SkipVerify bool   `json:"skip_verify,omitempty"`
+}
+
+// HeartbeatCheckOptions tune a heartbeat check
+type HeartbeatCheckOptions struct {
+	// Schedule is the cron expression the job runs on, such as "0 2 * * *"
+	Schedule string `json:"schedule" validate:"required,max=128"`
+	// Timezone is the IANA zone of the schedule, UTC by default
+	Timezone string `json:"timezone,omitempty" validate:"max=64"`
+	// GraceSeconds is how long after a scheduled run its ping may arrive, 300 by default
+	GraceSeconds int `json:"grace_seconds,omitempty" validate:"omitempty,min=1,max=604800"`
+}
+
+// Grace is how long after a scheduled run its ping may arrive
+func (o *HeartbeatCheckOptions) Grace() time.Duration {
+	return time.Duration(o.GraceSeconds) * time.Second
+}
+
+// HeartbeatState is where the job of a heartbeat check stands
+type HeartbeatState struct {
+	// PingURL is the path the job calls, relative to the server
+	PingURL    string     `json:"ping_url"`
+	LastPingAt *time.Time `json:"last_ping_at,omitempty"`
+	// RunningSince is when the job pinged its start, while it has not finished
+	RunningSince *time.Time `json:"running_since,omitempty"`
+	// NextRunAt is the next scheduled run; it is missed when no ping arrives by DueBy
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	DueBy     *time.Time `json:"due_by,omitempty"`
+}
+
+// Heartbeat ping kinds
+const (
+	HeartbeatPingStart   = "start"
+	HeartbeatPingSuccess = "success"
+	HeartbeatPingFail    = "fail"
+)
+
+// HeartbeatPing is a call of a heartbeat check's ping URL by its job
+type HeartbeatPing struct {
+	ID       int64  `json:"id"`
+	CheckID  int64  `json:"check_id"`
+	Kind     string `json:"kind"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	// DurationMs is the run time the job reported, or else the time since its start ping
+	DurationMs *float64 `json:"duration_ms,omitempty"`
+	// Message is the start of the request body, such as the tail of the job's output
+	Message    string    `json:"message,omitempty"`
+	Source     string    `json:"source"` // client IP address
+	UserAgent  string    `json:"user_agent,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// HeartbeatPingQuery filters stored heartbeat pings, newest first
+type HeartbeatPingQuery struct {
+	CheckID int64
+	Kind    string
+	Since   time.Time
+	Limit   int
+}
+
+// ProbeResult is the outcome of one run of a probe
+type ProbeResult struct {
+	Status    string  `json:"status"` // CheckStatusUp or CheckStatusDown
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	// StatusCode is the HTTP status of HTTP checks
+	StatusCode int `json:"status_code,omitempty"`
+	// CertExpiresAt is when the certificate of TLS checks expires
+	CertExpiresAt *time.Time `json:"cert_expires_at,omitempty"`
+	// Answers are the records found by DNS checks
+	Answers   []string  `json:"answers,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// CheckResult is a stored probe result of a check at one location
+type CheckResult struct {
+	ID       int64  `json:"id"`
+	CheckID  int64  `json:"check_id"`
+	Location string `json:"location"`
+	ProbeResult
+}
+
+// CheckLocationStatus is the state of a check at one location
+type CheckLocationStatus struct {
+	Location string `json:"location"`
+	// Status is that of the latest result, or unknown with Error when the agent could not run it
+	Status        string     `json:"status"`
+	Firing        bool       `json:"firing"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+	LatencyMs     float64    `json:"latency_ms"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// CheckResultQuery filters stored results, newest first
+type CheckResultQuery struct {
+	CheckID  int64
+	Location string
+	Since    time.Time
+	Limit    int
+}
+
+// CheckUptime is the share of successful results of a check over a window
+type CheckUptime struct {
+	Location      string  `json:"location,omitempty"` // empty for every location together
+	Results       int     `json:"results"`
+	Up            int     `json:"up"`
+	UptimePercent float64 `json:"uptime_percent"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+}
+
+// CheckUptimeReport is the uptime of a check over a window, overall and per location
+type CheckUptimeReport struct {
+	CheckID   int64         `json:"check_id"`
+	Window    string        `json:"window"`
+	Since     time.Time     `json:"since"`
+	Overall   CheckUptime   `json:"overall"`
+	Locations []CheckUptime `json:"locations"`
+}
+
+// CheckAlert is a check failing at a location for FailureThreshold consecutive runs
+type CheckAlert struct {
+	ID         int64      `json:"id"`
+	CheckID    int64      `json:"check_id"`
+	CheckName  string     `json:"check_name"`
+	Location   string     `json:"location"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error"` // of the failure that fired the alert
+	StartedAt  time.Time  `json:"started_at"`
+	FiredAt    time.Time  `json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// CheckAlertQuery filters stored check alerts, newest first
+type CheckAlertQuery struct {
+	CheckID int64
+	Status  string
+	Limit   int
+}
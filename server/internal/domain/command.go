@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// Commands understood by agents connected over gRPC
+const (
+	AgentCommandPing    = "ping"    // replies "pong"
+	AgentCommandCollect = "collect" // collects and pushes a sample immediately
+	AgentCommandInfo    = "info"    // replies with the agent's name, hostname, IP address and version as JSON
+	// AgentCommandReloadConfig makes the agent fetch its server-managed config now
+	AgentCommandReloadConfig = "reload-config"
+	// AgentCommandProbe runs the synthetic check given as JSON CheckSpec in the "check"
+	// argument and replies with the ProbeResult as JSON
+	AgentCommandProbe = "probe"
+	// AgentCommandTraceroute traces the route to the "target" argument, with the optional
+	// "max_hops", "probes" and "timeout_ms" arguments of a TracerouteRequest, and replies
+	// with the TracerouteResult as JSON
+	AgentCommandTraceroute = "traceroute"
+)
+
+// AgentCommand is an instruction sent to a connected agent over its command channel
+type AgentCommand struct {
+	ID       string            `json:"id" proto:"1"`
+	Name     string            `json:"name" proto:"2" validate:"required"`
+	Args     map[string]string `json:"args,omitempty" proto:"3"`
+	IssuedAt time.Time         `json:"issued_at" proto:"4"`
+}
+
+// AgentCommandResult is an agent's reply to a command
+type AgentCommandResult struct {
+	CommandID   string    `json:"command_id" proto:"1"`
+	Success     bool      `json:"success" proto:"2"`
+	Output      string    `json:"output,omitempty" proto:"3"`
+	Error       string    `json:"error,omitempty" proto:"4"`
+	CompletedAt time.Time `json:"completed_at" proto:"5"`
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// Plugin check statuses, following Nagios exit code conventions
+const (
+	CheckStatusOK       = "ok"       // exit 0
+	CheckStatusWarning  = "warning"  // exit 1
+	CheckStatusCritical = "critical" // exit 2
+	CheckStatusUnknown  = "unknown"  // exit 3 or anything else
+)
+
+// CustomCheck is the result of one plugin run on an agent
+type CustomCheck struct {
+	Plugin     string         `json:"plugin" proto:"1"`
+	Status     string         `json:"status" proto:"2"`
+	ExitCode   int            `json:"exit_code" proto:"3"`
+	Output     string         `json:"output,omitempty" proto:"4"`
+	DurationMs int64          `json:"duration_ms" proto:"5"`
+	Metrics    []CustomMetric `json:"metrics,omitempty" proto:"6"`
+	CheckedAt  time.Time      `json:"checked_at" proto:"7"`
+}
+
+// CustomMetric is a named gauge reported by a plugin
+type CustomMetric struct {
+	Name   string            `json:"name" proto:"1"`
+	Value  float64           `json:"value" proto:"2"`
+	Unit   string            `json:"unit,omitempty" proto:"3"`
+	Labels map[string]string `json:"labels,omitempty" proto:"4"`
+	Warn   *float64          `json:"warn,omitempty" proto:"5"`
+	Crit   *float64          `json:"crit,omitempty" proto:"6"`
+	Min    *float64          `json:"min,omitempty" proto:"7"`
+	Max    *float64          `json:"max,omitempty" proto:"8"`
+}
+
+// CustomMetricPoint is a stored custom metric sample
+type CustomMetricPoint struct {
+	AgentID    string            `json:"agent_id"`
+	Source     string            `json:"source"` // plugin name or ingest source
+	Name       string            `json:"name"`
+	Value      float64           `json:"value"`
+	Unit       string            `json:"unit,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	RecordedAt time.Time         `json:"recorded_at"`
+}
+
+// CustomMetricQuery filters stored custom metric samples
+type CustomMetricQuery struct {
+	AgentID string
+	Source  string
+	Name    string
+	From    time.Time
+	To      time.Time
+	Limit   int
+}
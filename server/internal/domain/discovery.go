@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+// Discovery providers an agent can be found by
+const (
+	DiscoverySourceScan = "scan" // a CIDR scan
+	DiscoverySourceFile = "file" // a file_sd target list
+	DiscoverySourceSRV  = "srv"  // a DNS SRV lookup
+)
+
+// States of a discovered agent awaiting approval
+const (
+	DiscoveredPending  = "pending"
+	DiscoveredRejected = "rejected" // not registered nor queued again until forgotten
+)
+
+// DiscoveredAgent is an agent found by discovery that awaits approval, with the info it
+// reported on /info. Approving it registers it with Tags.
+type DiscoveredAgent struct {
+	ID        int64     `json:"id"`
+	Host      string    `json:"host"`
+	Source    string    `json:"source"`
+	Name      string    `json:"name"`
+	Hostname  string    `json:"hostname"`
+	IPAddress string    `json:"ip_address"`
+	Version   string    `json:"version"`
+	Tags      []string  `json:"tags"`
+	Status    string    `json:"status"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DiscoveryRun reports the outcome of a discovery run
+type DiscoveryRun struct {
+	Sources    []string  `json:"sources"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	// Targets is the number of addresses checked, Found the agents that answered on /info
+	Targets    int      `json:"targets"`
+	Found      int      `json:"found"`
+	Registered int      `json:"registered"`
+	Queued     int      `json:"queued"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// DiscoveryStatus describes the discovery configuration and its last run
+type DiscoveryStatus struct {
+	Enabled bool `json:"enabled"`
+	// Approval is set when found agents are queued for approval instead of registered
+	Approval bool          `json:"approval"`
+	Sources  []string      `json:"sources"`
+	Running  bool          `json:"running"`
+	LastRun  *DiscoveryRun `json:"last_run"`
+}
@@ -0,0 +1,64 @@
+package domain
+
+import "time"
+
+// Alert rule conditions
+const (
+	AlertConditionAbove = "above"
+	AlertConditionBelow = "below"
+)
+
+// Alert statuses
+const (
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+// EnvAlertRule raises an alert when a sensor stays above or below a threshold for a duration,
+// e.g. server room temperature above 27°C for 600 seconds
+type EnvAlertRule struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name" validate:"required,max=128"`
+	SensorID        string    `json:"sensor_id" validate:"required,max=64"`
+	Condition       string    `json:"condition" validate:"required,oneof=above below"`
+	Threshold       float64   `json:"threshold"`
+	DurationSeconds int64     `json:"duration_seconds" validate:"min=0,max=86400"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// Duration is how long the condition must hold before the alert fires
+func (r *EnvAlertRule) Duration() time.Duration {
+	return time.Duration(r.DurationSeconds) * time.Second
+}
+
+// Breached reports whether a value meets the rule's condition
+func (r *EnvAlertRule) Breached(value float64) bool {
+	if r.Condition == AlertConditionBelow {
+		return value < r.Threshold
+	}
+	return value > r.Threshold
+}
+
+// EnvAlert is one firing of an alert rule
+type EnvAlert struct {
+	ID         int64      `json:"id"`
+	RuleID     int64      `json:"rule_id"`
+	RuleName   string     `json:"rule_name"`
+	SensorID   string     `json:"sensor_id"`
+	Condition  string     `json:"condition"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"` // latest value while firing
+	Status     string     `json:"status"`
+	StartedAt  time.Time  `json:"started_at"` // first reading of the breach
+	FiredAt    time.Time  `json:"fired_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// EnvAlertQuery filters stored alerts, newest first
+type EnvAlertQuery struct {
+	RuleID int64
+	Status string
+	Limit  int
+}
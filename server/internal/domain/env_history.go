@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// History aggregations applied to the readings of each bucket
+const (
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+	AggregateLast  = "last"
+	AggregateCount = "count"
+)
+
+// Derived series kinds, computed from a temperature and a humidity sensor
+const (
+	DerivedDewPoint  = "dew_point"
+	DerivedHeatIndex = "heat_index"
+)
+
+// ReadingQuery filters stored sensor readings. Results are ordered by sensor and time.
+type ReadingQuery struct {
+	SensorIDs []string // all sensors when empty
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// EnvHistoryQuery selects bucketed sensor history
+type EnvHistoryQuery struct {
+	SensorIDs []string
+	From      time.Time
+	To        time.Time
+	Bucket    time.Duration
+	Aggregate string
+	Derived   bool // include dew point and heat index series
+}
+
+// EnvSeries is the bucketed history of one sensor or derived value
+type EnvSeries struct {
+	SensorID string     `json:"sensor_id"`
+	Kind     string     `json:"kind"`
+	Unit     string     `json:"unit,omitempty"`
+	Points   []EnvPoint `json:"points"`
+}
+
+// EnvPoint is one aggregated bucket, Time being the bucket start
+type EnvPoint struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Count int       `json:"count"`
+}
+
+// EnvDailySummary holds the min, max and average of one sensor over a day
+type EnvDailySummary struct {
+	SensorID string  `json:"sensor_id"`
+	Date     string  `json:"date"` // YYYY-MM-DD in the requested time zone
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Avg      float64 `json:"avg"`
+	Count    int     `json:"count"`
+}
+
+// SensorPair is a temperature and a humidity sensor measuring the same air
+type SensorPair struct {
+	Temperature string
+	Humidity    string
+}
+
+// SensorPairs pairs the legacy probes, and temperature and humidity sensors sharing a location
+func SensorPairs(sensors []Sensor) []SensorPair {
+	pairs := []SensorPair{
+		{SensorFirstTemperature, SensorFirstHumidity},
+		{SensorSecondTemperature, SensorSecondHumidity},
+	}
+
+	humidity := make(map[string]string)
+	for _, s := range sensors {
+		if s.Kind == SensorKindHumidity && s.Location != "" && humidity[s.Location] == "" {
+			humidity[s.Location] = s.ID
+		}
+	}
+	for _, s := range sensors {
+		if s.Kind != SensorKindTemperature || s.Location == "" || s.ID == SensorFirstTemperature || s.ID == SensorSecondTemperature {
+			continue
+		}
+		if id := humidity[s.Location]; id != "" {
+			pairs = append(pairs, SensorPair{s.ID, id})
+		}
+	}
+
+	return pairs
+}
+
+// DerivedSensorID names a derived series after its temperature sensor, e.g. "first_temperature:dew_point"
+func DerivedSensorID(temperatureID, kind string) string {
+	return temperatureID + ":" + kind
+}
+
+// DewPoint returns the dew point in °C for a temperature in °C and relative humidity in %,
+// using the Magnus formula
+func DewPoint(temperature, humidity float64) float64 {
+	const a, b = 17.62, 243.12
+	if humidity <= 0 {
+		return math.NaN()
+	}
+	gamma := math.Log(humidity/100) + a*temperature/(b+temperature)
+	return b * gamma / (a - gamma)
+}
+
+// HeatIndex returns the apparent temperature in °C for a temperature in °C and relative
+// humidity in %, following the NOAA (Rothfusz) algorithm
+func HeatIndex(temperature, humidity float64) float64 {
+	t := temperature*9/5 + 32
+
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + humidity*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*humidity -
+			0.22475541*t*humidity - 0.00683783*t*t - 0.05481717*humidity*humidity +
+			0.00122874*t*t*humidity + 0.00085282*t*humidity*humidity - 0.00000199*t*t*humidity*humidity
+
+		switch {
+		case humidity < 13 && t >= 80 && t <= 112:
+			hi -= (13 - humidity) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+		case humidity > 85 && t >= 80 && t <= 87:
+			hi += (humidity - 85) / 10 * (87 - t) / 5
+		}
+	}
+
+	return (hi - 32) * 5 / 9
+}
+
+// roundDerived keeps derived values at two decimals, nil when undefined
+func roundDerived(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	v = math.Round(v*100) / 100
+	return &v
+}
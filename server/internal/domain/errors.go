@@ -17,4 +17,7 @@ var (
 
 	// ErrForbidden is returned when access is forbidden
 	ErrForbidden = errors.New("forbidden")
+
+	// ErrDuplicate is returned when a record that was already stored is saved again
+	ErrDuplicate = errors.New("duplicate record")
 )
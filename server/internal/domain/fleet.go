@@ -0,0 +1,87 @@
+package domain
+
+import "time"
+
+// Resources ranked in the top hosts of a FleetSummary
+const (
+	FleetResourceCPU    = "cpu"
+	FleetResourceMemory = "memory"
+	FleetResourceDisk   = "disk"
+	FleetResourceLoad   = "load"
+)
+
+// FleetSummary is the state of every agent and the fleet-wide aggregates of their latest
+// metrics, as shown by the dashboard overview
+type FleetSummary struct {
+	Totals FleetTotals `json:"totals"`
+	// Stats and Top only cover online agents, as the last sample of an offline agent is stale
+	Stats       map[string]FleetStats     `json:"stats"`
+	Top         map[string][]FleetTopHost `json:"top"`
+	Agents      []FleetAgent              `json:"agents"`
+	GeneratedAt time.Time                 `json:"generated_at"`
+}
+
+// FleetTotals counts the agents by status and sums the capacity of those reporting metrics
+type FleetTotals struct {
+	Agents  int `json:"agents"`
+	Online  int `json:"online"`
+	Offline int `json:"offline"`
+	Error   int `json:"error"`
+	// Reporting is the number of online agents with a sample, which the sums below cover
+	Reporting   int    `json:"reporting"`
+	CPUCores    int    `json:"cpu_cores"`
+	MemoryTotal uint64 `json:"memory_total"`
+	MemoryUsed  uint64 `json:"memory_used"`
+	DiskTotal   uint64 `json:"disk_total"`
+	DiskUsed    uint64 `json:"disk_used"`
+}
+
+// FleetStats describes the distribution of a metric across the fleet. Percentiles are nearest-rank.
+type FleetStats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+// FleetTopHost is one of the hosts using the most of a resource
+type FleetTopHost struct {
+	AgentID    string  `json:"agent_id"`
+	AgentName  string  `json:"agent_name"`
+	Value      float64 `json:"value"`
+	MountPoint string  `json:"mount_point,omitempty"`
+}
+
+// FleetAgent is an agent with its latest key metrics, nil when it never sent a sample
+type FleetAgent struct {
+	ID       string             `json:"id"`
+	Name     string             `json:"name"`
+	Type     string             `json:"type"`
+	Status   string             `json:"status"`
+	LastSeen time.Time          `json:"last_seen"`
+	Tags     []string           `json:"tags,omitempty"`
+	Metrics  *FleetAgentMetrics `json:"metrics"`
+}
+
+// FleetAgentMetrics are the key metrics of an agent's latest sample
+type FleetAgentMetrics struct {
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+	// Disk is the fullest disk, nil when the agent reports none
+	Disk       *FleetDiskUsage `json:"disk,omitempty"`
+	Load1      float64         `json:"load1"`
+	Load5      float64         `json:"load5"`
+	Load15     float64         `json:"load15"`
+	Uptime     uint64          `json:"uptime"` // seconds
+	ReceivedAt time.Time       `json:"received_at"`
+}
+
+// FleetDiskUsage is the used percent of a mount point
+type FleetDiskUsage struct {
+	MountPoint  string  `json:"mount_point"`
+	UsedPercent float64 `json:"used_percent"`
+}
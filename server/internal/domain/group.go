@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// Group kinds, from the outermost level of the fleet hierarchy to the innermost
+const (
+	GroupKindEnvironment = "environment"
+	GroupKindDatacenter  = "datacenter"
+	GroupKindRole        = "role"
+)
+
+// GroupParentKinds maps each kind of group to the kind of its parent; environments have no parent
+var GroupParentKinds = map[string]string{
+	GroupKindDatacenter: GroupKindEnvironment,
+	GroupKindRole:       GroupKindDatacenter,
+}
+
+// Group organizes agents in the environment → datacenter → role hierarchy. A group
+// contains its own members and those of the groups under it.
+type Group struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name" validate:"required,max=128"`
+	Kind        string `json:"kind" validate:"required,oneof=environment datacenter role"`
+	ParentID    *int64 `json:"parent_id,omitempty"`
+	Description string `json:"description,omitempty" validate:"max=1024"`
+	// Selector makes the group dynamic: its members are the agents matching it, and
+	// cannot be added or removed by hand
+	Selector *GroupSelector `json:"selector,omitempty"`
+	// AlertRules apply to every agent in the group, those of the groups under it included
+	AlertRules []AgentAlertRule `json:"alert_rules" validate:"max=50,dive"`
+	// Notifications receive the alerts of the group's rules. A group without routes uses
+	// those of its nearest ancestor that has some.
+	Notifications []NotificationRoute `json:"notifications" validate:"max=10,dive"`
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
+}
+
+// IsDynamic reports whether the members of the group come from its selector
+func (g *Group) IsDynamic() bool {
+	return g.Selector != nil
+}
+
+// GroupSelector matches agents by their tags, like the tag filter of AgentQuery
+type GroupSelector struct {
+	Tags     []string `json:"tags" validate:"required,min=1,max=32,dive,min=1,max=64"`
+	TagMatch string   `json:"tag_match,omitempty" validate:"omitempty,oneof=any all"` // AgentTagMatchAny (default) or AgentTagMatchAll
+}
+
+// Matches reports whether an agent carrying tags matches the selector
+func (s *GroupSelector) Matches(tags []string) bool {
+	for _, tag := range s.Tags {
+		found := slices.Contains(tags, tag)
+		if found && s.TagMatch != AgentTagMatchAll {
+			return true
+		}
+		if !found && s.TagMatch == AgentTagMatchAll {
+			return false
+		}
+	}
+	return s.TagMatch == AgentTagMatchAll
+}
+
+// GroupHealth aggregates the state of the agents in a group and the groups under it
+type GroupHealth struct {
+	GroupID int64 `json:"group_id"`
+	Agents  int   `json:"agents"`
+	Online  int   `json:"online"`
+	Offline int   `json:"offline"`
+	Error   int   `json:"error"`
+	// WorstCPU is the busiest online agent, WorstDisk the fullest disk of any agent
+	WorstCPU     *AgentResourceUsage `json:"worst_cpu,omitempty"`
+	WorstDisk    *AgentResourceUsage `json:"worst_disk,omitempty"`
+	FiringAlerts int                 `json:"firing_alerts"`
+}
+
+// AgentResourceUsage is the usage percent of a resource on an agent
+type AgentResourceUsage struct {
+	AgentID    string  `json:"agent_id"`
+	AgentName  string  `json:"agent_name"`
+	Percent    float64 `json:"percent"`
+	MountPoint string  `json:"mount_point,omitempty"`
+}
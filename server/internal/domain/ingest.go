@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+// Series is a single sample received from an external metrics source
+// such as Prometheus remote-write or OTLP, before it is mapped onto SystemMetrics
+type Series struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+	Unit      string            `json:"unit,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// SeriesBatch groups the samples of one source host
+type SeriesBatch struct {
+	Source string // instance label or host.name resource attribute
+	Series []Series
+}
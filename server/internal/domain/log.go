@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LogSourceJournal is the source of lines read from the systemd journal; other sources are
+// file paths
+const LogSourceJournal = "journal"
+
+// Limits of log shipping
+const (
+	MaxLogBatch     = 5000     // lines in a batch shipped by an agent
+	MaxLogLineBytes = 64 << 10 // length of a line
+)
+
+// LogSettings choose the logs an agent tails and ships to the server
+type LogSettings struct {
+	// Files are absolute paths or glob patterns of log files. Files present when the agent
+	// starts or the pattern is added are read from their end, files appearing later from
+	// their start.
+	Files []string `json:"files,omitempty" yaml:"files,omitempty"`
+	// Journal ships the systemd journal, only the entries of JournalUnits when set
+	Journal      bool     `json:"journal,omitempty" yaml:"journal,omitempty"`
+	JournalUnits []string `json:"journal_units,omitempty" yaml:"journal_units,omitempty"`
+	// FlushInterval is how often the lines read are shipped
+	FlushInterval Duration `json:"flush_interval,omitempty" yaml:"flush_interval,omitempty"`
+	// BatchSize is the most lines shipped in a request
+	BatchSize int `json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
+	// MaxLineBytes truncates longer lines
+	MaxLineBytes int `json:"max_line_bytes,omitempty" yaml:"max_line_bytes,omitempty"`
+}
+
+// Validate checks the file patterns and the batching of the settings
+func (s *LogSettings) Validate() error {
+	for _, pattern := range s.Files {
+		if !filepath.IsAbs(pattern) {
+			return fmt.Errorf("logs.files: %q must be an absolute path", pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("logs.files: invalid pattern %q", pattern)
+		}
+	}
+	if s.FlushInterval < Duration(100*time.Millisecond) {
+		return fmt.Errorf("logs.flush_interval must be at least 100ms")
+	}
+	if s.BatchSize < 1 || s.BatchSize > MaxLogBatch {
+		return fmt.Errorf("logs.batch_size must be between 1 and %d", MaxLogBatch)
+	}
+	if s.MaxLineBytes < 256 || s.MaxLineBytes > MaxLogLineBytes {
+		return fmt.Errorf("logs.max_line_bytes must be between 256 and %d", MaxLogLineBytes)
+	}
+	return nil
+}
+
+// LogLine is a line of a log file or a journal entry shipped by an agent
+type LogLine struct {
+	ID      int64  `json:"id,omitempty"`
+	AgentID string `json:"agent_id,omitempty"`
+	// Source is the path of the file, or LogSourceJournal
+	Source string `json:"source" validate:"required,max=1024"`
+	// Unit is the systemd unit or syslog identifier of journal entries
+	Unit string `json:"unit,omitempty" validate:"max=256"`
+	// Level is the syslog priority of journal entries: emerg, alert, crit, err, warning,
+	// notice, info or debug
+	Level   string `json:"level,omitempty" validate:"max=16"`
+	Message string `json:"message" validate:"max=65536"`
+	// Timestamp is when the journal recorded the entry, or when the line was read from a file
+	Timestamp time.Time `json:"timestamp" validate:"required"`
+	// Cursor is the position of the line in its source; a line shipped again is stored once
+	Cursor string `json:"cursor,omitempty" validate:"max=512"`
+}
+
+// LogBatch is a batch of lines shipped by an agent, oldest first
+type LogBatch struct {
+	Lines []LogLine `json:"lines" validate:"max=5000,dive"`
+}
+
+// LogFilter selects log lines. Contains is matched case-insensitively; Pattern is a
+// regular expression.
+type LogFilter struct {
+	Source   string
+	Unit     string
+	Level    string
+	Contains string
+	Pattern  *regexp.Regexp
+}
+
+// Match reports whether a line passes the filter
+func (f *LogFilter) Match(line *LogLine) bool {
+	if f.Source != "" && line.Source != f.Source {
+		return false
+	}
+	if f.Unit != "" && line.Unit != f.Unit {
+		return false
+	}
+	if f.Level != "" && line.Level != f.Level {
+		return false
+	}
+	if f.Contains != "" && !strings.Contains(strings.ToLower(line.Message), strings.ToLower(f.Contains)) {
+		return false
+	}
+	return f.Pattern == nil || f.Pattern.MatchString(line.Message)
+}
+
+// LogQuery filters the stored lines of an agent, newest first
+type LogQuery struct {
+	AgentID string
+	LogFilter
+	Since time.Time
+	Until time.Time
+	// BeforeID pages back from the oldest line of the previous page
+	BeforeID int64
+	Limit    int
+}
+
+// Types of the messages of a live tail
+const (
+	LogTailLine    = "line"
+	LogTailDropped = "dropped" // lines were left out because the client read too slowly
+)
+
+// LogTailMessage is a message of a live tail WebSocket
+type LogTailMessage struct {
+	Type    string   `json:"type"`
+	Line    *LogLine `json:"line,omitempty"`
+	Dropped int      `json:"dropped,omitempty"`
+}
@@ -4,139 +4,249 @@ import "time"
 
 // EnvMetrics represents environmental metrics from sensors
 type EnvMetrics struct {
-	ID                int64     `json:"id" db:"id"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
-	FirstTemperature  *float64  `json:"first_temperature" db:"first_temperature"`
-	FirstHumidity     *float64  `json:"first_humidity" db:"first_humidity"`
-	SecondTemperature *float64  `json:"second_temperature" db:"second_temperature"`
-	SecondHumidity    *float64  `json:"second_humidity" db:"second_humidity"`
+	ID                int64     `json:"id" proto:"1" db:"id"`
+	CreatedAt         time.Time `json:"created_at" proto:"2" db:"created_at"`
+	FirstTemperature  *float64  `json:"first_temperature" proto:"3" db:"first_temperature"`
+	FirstHumidity     *float64  `json:"first_humidity" proto:"4" db:"first_humidity"`
+	SecondTemperature *float64  `json:"second_temperature" proto:"5" db:"second_temperature"`
+	SecondHumidity    *float64  `json:"second_humidity" proto:"6" db:"second_humidity"`
+
+	// Derived from each probe's temperature and humidity, in °C
+	FirstDewPoint   *float64 `json:"first_dew_point,omitempty" proto:"7" db:"-"`
+	FirstHeatIndex  *float64 `json:"first_heat_index,omitempty" proto:"8" db:"-"`
+	SecondDewPoint  *float64 `json:"second_dew_point,omitempty" proto:"9" db:"-"`
+	SecondHeatIndex *float64 `json:"second_heat_index,omitempty" proto:"10" db:"-"`
+
+	// Latest reading of every registered sensor, legacy ones included
+	Readings []SensorReading `json:"readings,omitempty" proto:"11" db:"-"`
 }
 
-// SystemMetrics represents comprehensive system and environmental metrics
+// SystemMetrics represents comprehensive system and environmental metrics.
+// Agents may send it protobuf encoded (see internal/pkg/protostruct) with the field numbers
+// of the proto tags, which must match internal/pkg/agentpb/agent.proto and never change.
 type SystemMetrics struct {
-	Timestamp   time.Time      `json:"timestamp"`
-	Environment *EnvMetrics    `json:"environment"`
-	CPU         CPUMetrics     `json:"cpu"`
-	Memory      MemoryMetrics  `json:"memory"`
-	Disk        []DiskMetrics  `json:"disk"`
-	Network     NetworkMetrics `json:"network"`
-	Process     ProcessMetrics `json:"process"`
-	Load        LoadMetrics    `json:"load"`
-	Temperature ThermalMetrics `json:"temperature,omitempty"`
-	System      SystemInfo     `json:"system"`
+	Timestamp   time.Time          `json:"timestamp" proto:"1"`
+	Environment *EnvMetrics        `json:"environment" proto:"2"`
+	CPU         CPUMetrics         `json:"cpu" proto:"3"`
+	Memory      MemoryMetrics      `json:"memory" proto:"4"`
+	Disk        []DiskMetrics      `json:"disk" proto:"5"`
+	Network     NetworkMetrics     `json:"network" proto:"6"`
+	Process     ProcessMetrics     `json:"process" proto:"7"`
+	Load        LoadMetrics        `json:"load" proto:"8"`
+	Temperature ThermalMetrics     `json:"temperature,omitempty" proto:"9"`
+	System      SystemInfo         `json:"system" proto:"10"`
+	Containers  []ContainerMetrics `json:"containers,omitempty" proto:"11"`
+	Services    []ServiceStatus    `json:"services,omitempty" proto:"12"`
+	Custom      []CustomCheck      `json:"custom,omitempty" proto:"13"`
+
+	// Optional Linux-only sections, nil when the kernel does not expose them
+	Pressure     *PressureMetrics     `json:"pressure,omitempty" proto:"14"`
+	MemoryDetail *MemoryDetailMetrics `json:"memory_detail,omitempty" proto:"15"`
+	VMStat       *VMStatMetrics       `json:"vmstat,omitempty" proto:"16"`
+
+	// Latest result of each ping target of the agent
+	Ping []PingResult `json:"ping,omitempty" proto:"17"`
 }
 
 type CPUMetrics struct {
-	UsagePercent float64   `json:"usage_percent"`
-	PerCore      []float64 `json:"per_core,omitempty"`
-	Cores        int       `json:"cores"`
-	Threads      int       `json:"threads"`
-	ModelName    string    `json:"model_name,omitempty"`
-	Frequency    float64   `json:"frequency_mhz,omitempty"`
-	User         float64   `json:"user_percent,omitempty"`
-	System       float64   `json:"system_percent,omitempty"`
-	Idle         float64   `json:"idle_percent,omitempty"`
+	UsagePercent float64   `json:"usage_percent" proto:"1"`
+	PerCore      []float64 `json:"per_core,omitempty" proto:"2"`
+	Cores        int       `json:"cores" proto:"3"`
+	Threads      int       `json:"threads" proto:"4"`
+	ModelName    string    `json:"model_name,omitempty" proto:"5"`
+	Frequency    float64   `json:"frequency_mhz,omitempty" proto:"6"`
+	User         float64   `json:"user_percent,omitempty" proto:"7"`
+	System       float64   `json:"system_percent,omitempty" proto:"8"`
+	Idle         float64   `json:"idle_percent,omitempty" proto:"9"`
 }
 
 type MemoryMetrics struct {
-	Total       uint64      `json:"total"`
-	Available   uint64      `json:"available"`
-	Used        uint64      `json:"used"`
-	Free        uint64      `json:"free"`
-	UsedPercent float64     `json:"used_percent"`
-	Cached      uint64      `json:"cached,omitempty"`
-	Buffers     uint64      `json:"buffers,omitempty"`
-	Swap        SwapMetrics `json:"swap"`
+	Total       uint64      `json:"total" proto:"1"`
+	Available   uint64      `json:"available" proto:"2"`
+	Used        uint64      `json:"used" proto:"3"`
+	Free        uint64      `json:"free" proto:"4"`
+	UsedPercent float64     `json:"used_percent" proto:"5"`
+	Cached      uint64      `json:"cached,omitempty" proto:"6"`
+	Buffers     uint64      `json:"buffers,omitempty" proto:"7"`
+	Swap        SwapMetrics `json:"swap" proto:"8"`
+}
+
+// MemoryDetailMetrics holds extra /proc/meminfo fields, in bytes
+type MemoryDetailMetrics struct {
+	Dirty          uint64 `json:"dirty" proto:"1"`
+	Writeback      uint64 `json:"writeback" proto:"2"`
+	Slab           uint64 `json:"slab" proto:"3"`
+	SReclaimable   uint64 `json:"slab_reclaimable" proto:"4"`
+	SUnreclaim     uint64 `json:"slab_unreclaimable" proto:"5"`
+	Shmem          uint64 `json:"shmem" proto:"6"`
+	CommittedAS    uint64 `json:"committed_as" proto:"7"`
+	CommitLimit    uint64 `json:"commit_limit" proto:"8"`
+	HugePagesTotal uint64 `json:"hugepages_total" proto:"9"`
+	HugePagesFree  uint64 `json:"hugepages_free" proto:"10"`
+	HugePagesRsvd  uint64 `json:"hugepages_reserved" proto:"11"`
+	HugePagesSurp  uint64 `json:"hugepages_surplus" proto:"12"`
+	HugePageSize   uint64 `json:"hugepage_size" proto:"13"`
 }
 
 type SwapMetrics struct {
-	Total       uint64  `json:"total"`
-	Used        uint64  `json:"used"`
-	Free        uint64  `json:"free"`
-	UsedPercent float64 `json:"used_percent"`
+	Total       uint64  `json:"total" proto:"1"`
+	Used        uint64  `json:"used" proto:"2"`
+	Free        uint64  `json:"free" proto:"3"`
+	UsedPercent float64 `json:"used_percent" proto:"4"`
 }
 
 type DiskMetrics struct {
-	Device      string  `json:"device"`
-	MountPoint  string  `json:"mount_point"`
-	FsType      string  `json:"fs_type"`
-	Total       uint64  `json:"total"`
-	Used        uint64  `json:"used"`
-	Free        uint64  `json:"free"`
-	UsedPercent float64 `json:"used_percent"`
-	InodesTotal uint64  `json:"inodes_total,omitempty"`
-	InodesUsed  uint64  `json:"inodes_used,omitempty"`
-	InodesFree  uint64  `json:"inodes_free,omitempty"`
+	Device      string  `json:"device" proto:"1"`
+	MountPoint  string  `json:"mount_point" proto:"2"`
+	FsType      string  `json:"fs_type" proto:"3"`
+	Total       uint64  `json:"total" proto:"4"`
+	Used        uint64  `json:"used" proto:"5"`
+	Free        uint64  `json:"free" proto:"6"`
+	UsedPercent float64 `json:"used_percent" proto:"7"`
+	InodesTotal uint64  `json:"inodes_total,omitempty" proto:"8"`
+	InodesUsed  uint64  `json:"inodes_used,omitempty" proto:"9"`
+	InodesFree  uint64  `json:"inodes_free,omitempty" proto:"10"`
 }
 
 type NetworkMetrics struct {
-	BytesSent   uint64             `json:"bytes_sent"`
-	BytesRecv   uint64             `json:"bytes_recv"`
-	PacketsSent uint64             `json:"packets_sent"`
-	PacketsRecv uint64             `json:"packets_recv"`
-	ErrorsIn    uint64             `json:"errors_in"`
-	ErrorsOut   uint64             `json:"errors_out"`
-	DropIn      uint64             `json:"drop_in"`
-	DropOut     uint64             `json:"drop_out"`
-	Interfaces  []NetworkInterface `json:"interfaces,omitempty"`
-	Connections ConnectionStats    `json:"connections"`
+	BytesSent   uint64             `json:"bytes_sent" proto:"1"`
+	BytesRecv   uint64             `json:"bytes_recv" proto:"2"`
+	PacketsSent uint64             `json:"packets_sent" proto:"3"`
+	PacketsRecv uint64             `json:"packets_recv" proto:"4"`
+	ErrorsIn    uint64             `json:"errors_in" proto:"5"`
+	ErrorsOut   uint64             `json:"errors_out" proto:"6"`
+	DropIn      uint64             `json:"drop_in" proto:"7"`
+	DropOut     uint64             `json:"drop_out" proto:"8"`
+	Interfaces  []NetworkInterface `json:"interfaces,omitempty" proto:"9"`
+	Connections ConnectionStats    `json:"connections" proto:"10"`
 }
 
 type NetworkInterface struct {
-	Name        string   `json:"name"`
-	BytesSent   uint64   `json:"bytes_sent"`
-	BytesRecv   uint64   `json:"bytes_recv"`
-	PacketsSent uint64   `json:"packets_sent"`
-	PacketsRecv uint64   `json:"packets_recv"`
-	Addrs       []string `json:"addrs,omitempty"`
-	MTU         int      `json:"mtu,omitempty"`
+	Name        string   `json:"name" proto:"1"`
+	BytesSent   uint64   `json:"bytes_sent" proto:"2"`
+	BytesRecv   uint64   `json:"bytes_recv" proto:"3"`
+	PacketsSent uint64   `json:"packets_sent" proto:"4"`
+	PacketsRecv uint64   `json:"packets_recv" proto:"5"`
+	Addrs       []string `json:"addrs,omitempty" proto:"6"`
+	MTU         int      `json:"mtu,omitempty" proto:"7"`
 }
 
 type ConnectionStats struct {
-	Established int `json:"established"`
-	Listen      int `json:"listen"`
-	TimeWait    int `json:"time_wait"`
-	CloseWait   int `json:"close_wait"`
-	Total       int `json:"total"`
+	Established int `json:"established" proto:"1"`
+	Listen      int `json:"listen" proto:"2"`
+	TimeWait    int `json:"time_wait" proto:"3"`
+	CloseWait   int `json:"close_wait" proto:"4"`
+	Total       int `json:"total" proto:"5"`
 }
 
 type ProcessMetrics struct {
-	Total    int `json:"total"`
-	Running  int `json:"running"`
-	Sleeping int `json:"sleeping"`
-	Stopped  int `json:"stopped"`
-	Zombie   int `json:"zombie"`
-	Threads  int `json:"threads"`
+	Total    int `json:"total" proto:"1"`
+	Running  int `json:"running" proto:"2"`
+	Sleeping int `json:"sleeping" proto:"3"`
+	Stopped  int `json:"stopped" proto:"4"`
+	Zombie   int `json:"zombie" proto:"5"`
+	Threads  int `json:"threads" proto:"6"`
 }
 
 type LoadMetrics struct {
-	Load1  float64 `json:"load1"`
-	Load5  float64 `json:"load5"`
-	Load15 float64 `json:"load15"`
+	Load1  float64 `json:"load1" proto:"1"`
+	Load5  float64 `json:"load5" proto:"2"`
+	Load15 float64 `json:"load15" proto:"3"`
+}
+
+// PressureMetrics holds Pressure Stall Information from /proc/pressure
+type PressureMetrics struct {
+	CPU    *PressureStat `json:"cpu,omitempty" proto:"1"`
+	Memory *PressureStat `json:"memory,omitempty" proto:"2"`
+	IO     *PressureStat `json:"io,omitempty" proto:"3"`
+}
+
+type PressureStat struct {
+	Some PressureAvg  `json:"some" proto:"1"`
+	Full *PressureAvg `json:"full,omitempty" proto:"2"` // not reported for cpu on older kernels
+}
+
+type PressureAvg struct {
+	Avg10  float64 `json:"avg10" proto:"1"`
+	Avg60  float64 `json:"avg60" proto:"2"`
+	Avg300 float64 `json:"avg300" proto:"3"`
+	Total  uint64  `json:"total_us" proto:"4"`
+}
+
+// VMStatMetrics holds cumulative counters from /proc/vmstat
+type VMStatMetrics struct {
+	PageFaults      uint64 `json:"page_faults" proto:"1"`
+	MajorPageFaults uint64 `json:"major_page_faults" proto:"2"`
+	PagesIn         uint64 `json:"pages_in" proto:"3"`
+	PagesOut        uint64 `json:"pages_out" proto:"4"`
+	SwapIn          uint64 `json:"swap_in" proto:"5"`
+	SwapOut         uint64 `json:"swap_out" proto:"6"`
+	OOMKills        uint64 `json:"oom_kills" proto:"7"`
 }
 
 type ThermalMetrics struct {
-	CPUTemp float64         `json:"cpu_temp,omitempty"`
-	Sensors []ThermalSensor `json:"sensors,omitempty"`
+	CPUTemp float64         `json:"cpu_temp,omitempty" proto:"1"`
+	Sensors []ThermalSensor `json:"sensors,omitempty" proto:"2"`
 }
 
 type ThermalSensor struct {
-	Name        string  `json:"name"`
-	Temperature float64 `json:"temperature"`
-	High        float64 `json:"high,omitempty"`
-	Critical    float64 `json:"critical,omitempty"`
+	Name        string  `json:"name" proto:"1"`
+	Temperature float64 `json:"temperature" proto:"2"`
+	High        float64 `json:"high,omitempty" proto:"3"`
+	Critical    float64 `json:"critical,omitempty" proto:"4"`
 }
 
 type SystemInfo struct {
-	Hostname        string `json:"hostname"`
-	OS              string `json:"os"`
-	Platform        string `json:"platform"`
-	PlatformFamily  string `json:"platform_family"`
-	PlatformVersion string `json:"platform_version"`
-	KernelVersion   string `json:"kernel_version"`
-	KernelArch      string `json:"kernel_arch"`
-	Virtualization  string `json:"virtualization,omitempty"`
-	Uptime          uint64 `json:"uptime"`
-	BootTime        uint64 `json:"boot_time"`
-	Processes       uint64 `json:"processes"`
+	Hostname        string `json:"hostname" proto:"1"`
+	OS              string `json:"os" proto:"2"`
+	Platform        string `json:"platform" proto:"3"`
+	PlatformFamily  string `json:"platform_family" proto:"4"`
+	PlatformVersion string `json:"platform_version" proto:"5"`
+	KernelVersion   string `json:"kernel_version" proto:"6"`
+	KernelArch      string `json:"kernel_arch" proto:"7"`
+	Virtualization  string `json:"virtualization,omitempty" proto:"8"`
+	Uptime          uint64 `json:"uptime" proto:"9"`
+	BootTime        uint64 `json:"boot_time" proto:"10"`
+	Processes       uint64 `json:"processes" proto:"11"`
+}
+
+// ContainerMetrics represents resource usage of a single container read from cgroups
+type ContainerMetrics struct {
+	ID            string                `json:"id" proto:"1"`
+	Name          string                `json:"name,omitempty" proto:"2"`
+	Image         string                `json:"image,omitempty" proto:"3"`
+	Runtime       string                `json:"runtime,omitempty" proto:"4"` // docker, containerd, crio, podman
+	CgroupPath    string                `json:"cgroup_path" proto:"5"`
+	CgroupVersion int                   `json:"cgroup_version" proto:"6"`
+	CPU           ContainerCPUMetrics   `json:"cpu" proto:"7"`
+	Memory        ContainerMemMetrics   `json:"memory" proto:"8"`
+	BlockIO       ContainerBlkIOMetrics `json:"block_io" proto:"9"`
+	PIDs          ContainerPIDMetrics   `json:"pids" proto:"10"`
+}
+
+type ContainerCPUMetrics struct {
+	UsageNanos       uint64  `json:"usage_ns" proto:"1"`
+	UsagePercent     float64 `json:"usage_percent" proto:"2"`
+	Periods          uint64  `json:"periods" proto:"3"`
+	ThrottledPeriods uint64  `json:"throttled_periods" proto:"4"`
+	ThrottledNanos   uint64  `json:"throttled_ns" proto:"5"`
+}
+
+type ContainerMemMetrics struct {
+	Usage       uint64  `json:"usage" proto:"1"`
+	Limit       uint64  `json:"limit,omitempty" proto:"2"` // 0 means unlimited
+	UsedPercent float64 `json:"used_percent,omitempty" proto:"3"`
+	OOMKills    uint64  `json:"oom_kills" proto:"4"`
+}
+
+type ContainerBlkIOMetrics struct {
+	ReadBytes  uint64 `json:"read_bytes" proto:"1"`
+	WriteBytes uint64 `json:"write_bytes" proto:"2"`
+	ReadOps    uint64 `json:"read_ops" proto:"3"`
+	WriteOps   uint64 `json:"write_ops" proto:"4"`
+}
+
+type ContainerPIDMetrics struct {
+	Current uint64 `json:"current" proto:"1"`
+	Limit   uint64 `json:"limit,omitempty" proto:"2"` // 0 means unlimited
 }
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"slices"
+	"time"
+)
+
+// NotificationRoute is a webhook that alerts are posted to as a Notification
+type NotificationRoute struct {
+	Name string `json:"name" validate:"required,max=128"`
+	URL  string `json:"url" validate:"required,http_url"`
+	// Statuses limits the route to alerts in these statuses, every status when empty
+	Statuses []string `json:"statuses,omitempty" validate:"dive,oneof=firing resolved"`
+}
+
+// Accepts reports whether the route receives notifications of an alert status
+func (r *NotificationRoute) Accepts(status string) bool {
+	return len(r.Statuses) == 0 || slices.Contains(r.Statuses, status)
+}
+
+// Notification kinds
+const (
+	NotificationKindAgentAlert = "agent_alert"
+	NotificationKindCheckAlert = "check_alert"
+)
+
+// Notification is the JSON body posted to notification routes
+type Notification struct {
+	Kind   string `json:"kind"`   // what raised it, e.g. "agent_alert" or "check_alert"
+	Status string `json:"status"` // firing or resolved
+	// Text is a one-line summary; the field name lets Slack and Mattermost webhooks show it as is
+	Text   string      `json:"text"`
+	Group  string      `json:"group,omitempty"`
+	Alert  interface{} `json:"alert"`
+	SentAt time.Time   `json:"sent_at"`
+}
@@ -0,0 +1,164 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Ping and traceroute methods
+const (
+	PingMethodICMP = "icmp" // ICMP echo, over an unprivileged ICMP socket or a raw socket
+	PingMethodTCP  = "tcp"  // TCP connect time, when ICMP is unavailable or not wanted
+	PingMethodUDP  = "udp"  // traceroute only: UDP probes whose ICMP errors are read unprivileged
+)
+
+// MaxPingCount limits the echo requests sent to a target at each run
+const MaxPingCount = 100
+
+// PingSettings schedules the ping checks an agent runs against other sites
+type PingSettings struct {
+	Targets []PingTarget `json:"targets,omitempty" yaml:"targets,omitempty"`
+	// Interval is how often every target is pinged
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// Count is the echo requests sent to a target at each run, one per second
+	Count int `json:"count,omitempty" yaml:"count,omitempty"`
+	// Timeout is how long a reply is waited for
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+}
+
+// PingTarget is a host pinged by an agent
+type PingTarget struct {
+	// Name identifies the target in results, the host by default
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	Host string `json:"host" yaml:"host"`
+	// Method is icmp (falling back to tcp when no ICMP socket can be opened) or tcp; icmp by default
+	Method string `json:"method,omitempty" yaml:"method,omitempty"`
+	// Port is connected to by the tcp method, 80 by default
+	Port int `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+// Label returns the name of the target in results
+func (t *PingTarget) Label() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.Host
+}
+
+// Validate checks the schedule and the targets of the settings
+func (s *PingSettings) Validate() error {
+	if s.Interval < Duration(time.Second) {
+		return fmt.Errorf("ping.interval must be at least 1s")
+	}
+	if s.Count < 1 || s.Count > MaxPingCount {
+		return fmt.Errorf("ping.count must be between 1 and %d", MaxPingCount)
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("ping.timeout must be positive")
+	}
+
+	seen := make(map[string]bool)
+	for _, target := range s.Targets {
+		if target.Host == "" {
+			return fmt.Errorf("ping.targets: host is required")
+		}
+		if target.Method != "" && target.Method != PingMethodICMP && target.Method != PingMethodTCP {
+			return fmt.Errorf("ping.targets: method of %s must be %s or %s", target.Label(), PingMethodICMP, PingMethodTCP)
+		}
+		if target.Port < 0 || target.Port > 65535 {
+			return fmt.Errorf("ping.targets: port of %s must be between 1 and 65535", target.Label())
+		}
+		if seen[target.Label()] {
+			return fmt.Errorf("ping.targets: %s is listed twice", target.Label())
+		}
+		seen[target.Label()] = true
+	}
+	return nil
+}
+
+// PingResult is the outcome of one run of a ping check on an agent.
+// Agents send it in samples, so its proto tags must never change (see SystemMetrics).
+type PingResult struct {
+	Target      string    `json:"target" proto:"1"`
+	Host        string    `json:"host" proto:"2"`
+	Address     string    `json:"address,omitempty" proto:"3"` // address the host resolved to
+	Method      string    `json:"method" proto:"4"`
+	Sent        int       `json:"sent" proto:"5"`
+	Received    int       `json:"received" proto:"6"`
+	LossPercent float64   `json:"loss_percent" proto:"7"`
+	MinMs       float64   `json:"min_ms" proto:"8"`
+	AvgMs       float64   `json:"avg_ms" proto:"9"`
+	MaxMs       float64   `json:"max_ms" proto:"10"`
+	JitterMs    float64   `json:"jitter_ms" proto:"11"` // mean difference between consecutive round trips
+	Error       string    `json:"error,omitempty" proto:"12"`
+	CheckedAt   time.Time `json:"checked_at" proto:"13"`
+}
+
+// AgentPingResult is a ping result stored for the agent that sent it
+type AgentPingResult struct {
+	AgentID string `json:"agent_id"`
+	PingResult
+}
+
+// PingResultQuery filters the stored ping results of an agent
+type PingResultQuery struct {
+	AgentID string
+	Target  string
+	Since   time.Time
+	Limit   int
+}
+
+// PingMatrix is the latest result of every agent and target pair, for drawing a latency
+// matrix with an agent per row and a target per column
+type PingMatrix struct {
+	Agents  []PingMatrixAgent `json:"agents"`
+	Targets []string          `json:"targets"`
+	// Cells maps agent ID → target → latest result; missing pairs have no result
+	Cells       map[string]map[string]PingMatrixCell `json:"cells"`
+	GeneratedAt time.Time                            `json:"generated_at"`
+}
+
+// PingMatrixAgent is a row of the matrix
+type PingMatrixAgent struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// PingMatrixCell is the latest result of an agent for a target
+type PingMatrixCell struct {
+	PingResult
+	// TargetAgentID is the agent at the target's host, when the target is another agent
+	TargetAgentID string `json:"target_agent_id,omitempty"`
+}
+
+// TracerouteRequest asks an agent to trace the route to a host
+type TracerouteRequest struct {
+	Target string `json:"target" validate:"required,max=253"`
+	// MaxHops is the highest TTL probed, 30 by default
+	MaxHops int `json:"max_hops,omitempty" validate:"omitempty,min=1,max=64"`
+	// Probes is the number of probes sent to each hop, 3 by default
+	Probes int `json:"probes,omitempty" validate:"omitempty,min=1,max=5"`
+	// TimeoutMs is how long the replies of a hop are waited for, 1000 by default
+	TimeoutMs int `json:"timeout_ms,omitempty" validate:"omitempty,min=100,max=5000"`
+}
+
+// TracerouteResult is the route from an agent to a host
+type TracerouteResult struct {
+	Target     string          `json:"target"`
+	Address    string          `json:"address"`
+	Method     string          `json:"method"`
+	Hops       []TracerouteHop `json:"hops"`
+	Reached    bool            `json:"reached"`
+	StartedAt  time.Time       `json:"started_at"`
+	DurationMs float64         `json:"duration_ms"`
+}
+
+// TracerouteHop is the router that answered probes sent with a TTL, or none when every
+// probe was lost
+type TracerouteHop struct {
+	TTL     int       `json:"ttl"`
+	Address string    `json:"address,omitempty"`
+	RTTMs   []float64 `json:"rtt_ms"` // round trips of the answered probes
+	Lost    int       `json:"lost"`
+}
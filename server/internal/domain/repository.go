@@ -5,20 +5,170 @@ import (
 	"time"
 )
 
-// EnvMetricsRepository interface for environmental metrics operations
+// EnvMetricsRepository interface for environmental metrics operations.
+// GetLatest and Create work on the legacy two-probe view; sensors and readings are the generic model.
 type EnvMetricsRepository interface {
 	GetLatest(ctx context.Context) (*EnvMetrics, error)
 	Create(ctx context.Context, metrics *EnvMetrics) error
+
+	GetSensors(ctx context.Context) ([]Sensor, error)
+	GetSensor(ctx context.Context, id string) (*Sensor, error)
+	SaveSensor(ctx context.Context, sensor *Sensor) error
+	DeleteSensor(ctx context.Context, id string) error
+	SaveReadings(ctx context.Context, readings []SensorReading) error
+	GetLatestReadings(ctx context.Context) ([]SensorReading, error)
+	GetReadings(ctx context.Context, query ReadingQuery) ([]SensorReading, error)
+}
+
+// EnvSyncRepository reads local environmental rows in insertion order and keeps
+// named cursors recording how far they have been mirrored
+type EnvSyncRepository interface {
+	GetCursor(ctx context.Context, name string) (int64, error)
+	SaveCursor(ctx context.Context, name string, position int64) error
+	GetEnvMetricsAfter(ctx context.Context, id int64, limit int) ([]EnvMetrics, error)
+	GetReadingsAfter(ctx context.Context, id int64, limit int) ([]SensorReading, error)
+}
+
+// EnvAlertRepository interface for environmental alert rules and the alerts they raise
+type EnvAlertRepository interface {
+	GetRules(ctx context.Context) ([]EnvAlertRule, error)
+	GetRule(ctx context.Context, id int64) (*EnvAlertRule, error)
+	CreateRule(ctx context.Context, rule *EnvAlertRule) error
+	UpdateRule(ctx context.Context, rule *EnvAlertRule) error
+	DeleteRule(ctx context.Context, id int64) error
+	SaveAlert(ctx context.Context, alert *EnvAlert) error
+	GetAlerts(ctx context.Context, query EnvAlertQuery) ([]EnvAlert, error)
 }
 
 // AgentRepository interface for agent operations
 type AgentRepository interface {
 	Register(ctx context.Context, agent *Agent) error
+	Update(ctx context.Context, agent *Agent) error
 	GetByID(ctx context.Context, id string) (*Agent, error)
+	GetByHost(ctx context.Context, agentType, host string) (*Agent, error)
 	GetAll(ctx context.Context) ([]Agent, error)
+	// Query returns a page of the agents matching q and the cursor of the next page, "" on the last.
+	// A cursor that does not belong to q's sort order wraps ErrInvalidInput.
+	Query(ctx context.Context, q AgentQuery) ([]Agent, string, error)
 	UpdateStatus(ctx context.Context, id string, status string, lastSeen time.Time) error
+	UpdateConfigVersion(ctx context.Context, id string, version string) error
 	Delete(ctx context.Context, id string) error
 	SaveMetrics(ctx context.Context, metrics *AgentMetrics) error
 	GetLatestMetrics(ctx context.Context, agentID string) (*AgentMetrics, error)
 	PullMetrics(ctx context.Context, agentID string) (*AgentMetrics, error)
 }
+
+// AgentAuditRepository stores the audit trail of agent changes
+type AgentAuditRepository interface {
+	Record(ctx context.Context, entry *AgentAuditEntry) error
+	// GetEntries returns the latest entries of an agent, newest first
+	GetEntries(ctx context.Context, agentID string, limit int) ([]AgentAuditEntry, error)
+}
+
+// DiscoveryRepository stores the agents found by discovery awaiting approval, and the rejected ones
+type DiscoveryRepository interface {
+	// Save inserts an agent or, for a known host, updates its info and last_seen, keeping its status
+	Save(ctx context.Context, agent *DiscoveredAgent) error
+	Get(ctx context.Context, id int64) (*DiscoveredAgent, error)
+	// GetAll lists the agents with the given status, every agent when status is empty
+	GetAll(ctx context.Context, status string) ([]DiscoveredAgent, error)
+	SetStatus(ctx context.Context, id int64, status string) error
+	// Delete removes an agent, returning ErrNotFound for an unknown one
+	Delete(ctx context.Context, id int64) error
+}
+
+// CheckRepository stores synthetic checks, their results and their alerts
+type CheckRepository interface {
+	GetAll(ctx context.Context) ([]Check, error)
+	Get(ctx context.Context, id int64) (*Check, error)
+	Create(ctx context.Context, check *Check) error
+	Update(ctx context.Context, check *Check) error
+	// Delete removes a check with its results and alerts, returning ErrNotFound for an unknown check
+	Delete(ctx context.Context, id int64) error
+	SaveResult(ctx context.Context, result *CheckResult) error
+	GetResults(ctx context.Context, query CheckResultQuery) ([]CheckResult, error)
+	// GetLatestResults returns the latest result of every check at every location
+	GetLatestResults(ctx context.Context) ([]CheckResult, error)
+	// GetUptime returns the uptime of a check at each location since a time
+	GetUptime(ctx context.Context, checkID int64, since time.Time) ([]CheckUptime, error)
+	// PruneResults deletes the results and heartbeat pings older than before
+	PruneResults(ctx context.Context, before time.Time) error
+	SaveAlert(ctx context.Context, alert *CheckAlert) error
+	GetAlerts(ctx context.Context, query CheckAlertQuery) ([]CheckAlert, error)
+	SavePing(ctx context.Context, ping *HeartbeatPing) error
+	GetPings(ctx context.Context, query HeartbeatPingQuery) ([]HeartbeatPing, error)
+}
+
+// GroupRepository interface for agent groups and their static members
+type GroupRepository interface {
+	GetAll(ctx context.Context) ([]Group, error)
+	Get(ctx context.Context, id int64) (*Group, error)
+	Create(ctx context.Context, group *Group) error
+	Update(ctx context.Context, group *Group) error
+	// Delete removes a group and its memberships, returning ErrNotFound for an unknown group
+	Delete(ctx context.Context, id int64) error
+	// GetMembers returns the agent IDs added by hand to each group
+	GetMembers(ctx context.Context) (map[int64][]string, error)
+	AddMember(ctx context.Context, groupID int64, agentID string) error
+	RemoveMember(ctx context.Context, groupID int64, agentID string) error
+}
+
+// AgentAlertRepository interface for the alerts raised by group alert rules
+type AgentAlertRepository interface {
+	SaveAlert(ctx context.Context, alert *AgentAlert) error
+	GetAlerts(ctx context.Context, query AgentAlertQuery) ([]AgentAlert, error)
+}
+
+// AgentConfigRepository interface for the config documents managed on the server for agents and tags
+type AgentConfigRepository interface {
+	GetAll(ctx context.Context) ([]AgentConfigDocument, error)
+	Get(ctx context.Context, scope, target string) (*AgentConfigDocument, error)
+	// GetForAgent returns the documents of the agent's tags, in tag order, then those of its
+	// groups, in the order given, followed by its own
+	GetForAgent(ctx context.Context, agentID string, tags []string, groups []string) ([]AgentConfigDocument, error)
+	Save(ctx context.Context, doc *AgentConfigDocument) error
+	Delete(ctx context.Context, scope, target string) error
+}
+
+// ServiceRepository interface for systemd unit state tracking
+type ServiceRepository interface {
+	GetStates(ctx context.Context, agentID string) ([]ServiceState, error)
+	SaveState(ctx context.Context, state *ServiceState) error
+	RecordEvent(ctx context.Context, event *ServiceEvent) error
+	GetEvents(ctx context.Context, agentID string, limit int) ([]ServiceEvent, error)
+}
+
+// CustomMetricRepository interface for plugin checks and custom metric samples
+type CustomMetricRepository interface {
+	SaveChecks(ctx context.Context, agentID string, checks []CustomCheck) error
+	GetChecks(ctx context.Context, agentID string) ([]CustomCheck, error)
+	SavePoints(ctx context.Context, points []CustomMetricPoint) error
+	QueryPoints(ctx context.Context, query CustomMetricQuery) ([]CustomMetricPoint, error)
+}
+
+// PingRepository interface for the ping results agents report
+type PingRepository interface {
+	// SaveResults stores the results of an agent, skipping those already stored
+	SaveResults(ctx context.Context, agentID string, results []PingResult) error
+	GetResults(ctx context.Context, query PingResultQuery) ([]PingResult, error)
+	// GetLatest returns the latest result of every agent and target pair checked since a time
+	GetLatest(ctx context.Context, since time.Time) ([]AgentPingResult, error)
+}
+
+// LogRepository interface for the log lines agents ship
+type LogRepository interface {
+	// SaveLines stores the lines of an agent, skipping those already stored, and returns the
+	// lines stored with their IDs
+	SaveLines(ctx context.Context, agentID string, lines []LogLine) ([]LogLine, error)
+	Query(ctx context.Context, query LogQuery) ([]LogLine, error)
+	// Prune deletes the lines older than before and the oldest lines of agents keeping more
+	// than maxPerAgent
+	Prune(ctx context.Context, before time.Time, maxPerAgent int) error
+}
+
+// IdempotencyRepository remembers idempotency keys of processed submissions
+type IdempotencyRepository interface {
+	// Claim records a key, returning false when it was already claimed within the retention window
+	Claim(ctx context.Context, scope, key string) (bool, error)
+	Release(ctx context.Context, scope, key string) error
+}
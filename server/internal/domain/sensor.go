@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Sensor kinds
+const (
+	SensorKindTemperature = "temperature"
+	SensorKindHumidity    = "humidity"
+	SensorKindCO2         = "co2"
+	SensorKindPressure    = "pressure"
+	SensorKindOther       = "other"
+)
+
+// Legacy sensor IDs. Readings of these sensors fill the matching EnvMetrics fields.
+const (
+	SensorFirstTemperature  = "first_temperature"
+	SensorFirstHumidity     = "first_humidity"
+	SensorSecondTemperature = "second_temperature"
+	SensorSecondHumidity    = "second_humidity"
+)
+
+// LegacySensors are registered by default so the original two-probe setup keeps working
+var LegacySensors = []Sensor{
+	{ID: SensorFirstTemperature, Name: "First temperature", Kind: SensorKindTemperature, Unit: "°C"},
+	{ID: SensorFirstHumidity, Name: "First humidity", Kind: SensorKindHumidity, Unit: "%"},
+	{ID: SensorSecondTemperature, Name: "Second temperature", Kind: SensorKindTemperature, Unit: "°C"},
+	{ID: SensorSecondHumidity, Name: "Second humidity", Kind: SensorKindHumidity, Unit: "%"},
+}
+
+// Sensor is a registered environmental sensor
+type Sensor struct {
+	ID            string         `json:"id" validate:"required,max=64"`
+	Name          string         `json:"name"`
+	Location      string         `json:"location,omitempty"`
+	Kind          string         `json:"kind" validate:"required,oneof=temperature humidity co2 pressure other"`
+	Unit          string         `json:"unit,omitempty"`
+	AgentID       string         `json:"agent_id,omitempty"` // agent the sensor is attached to, if any
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	LatestReading *SensorReading `json:"latest_reading,omitempty"`
+}
+
+// SensorReading is a single value reported by a sensor
+type SensorReading struct {
+	ID         int64     `json:"id,omitempty" proto:"1"`
+	SensorID   string    `json:"sensor_id" proto:"2"`
+	Value      float64   `json:"value" proto:"3"`
+	RecordedAt time.Time `json:"recorded_at" proto:"4"`
+}
+
+// NewEnvironment builds the legacy EnvMetrics view from the latest legacy row (may be nil)
+// and the latest reading of every sensor. Legacy sensor readings newer than the row win.
+func NewEnvironment(legacy *EnvMetrics, readings []SensorReading) *EnvMetrics {
+	if legacy == nil && len(readings) == 0 {
+		return nil
+	}
+
+	env := &EnvMetrics{}
+	if legacy != nil {
+		*env = *legacy
+	}
+	env.Readings = readings
+
+	for i := range readings {
+		r := readings[i]
+		var field **float64
+		switch r.SensorID {
+		case SensorFirstTemperature:
+			field = &env.FirstTemperature
+		case SensorFirstHumidity:
+			field = &env.FirstHumidity
+		case SensorSecondTemperature:
+			field = &env.SecondTemperature
+		case SensorSecondHumidity:
+			field = &env.SecondHumidity
+		}
+		if field != nil && (legacy == nil || !r.RecordedAt.Before(legacy.CreatedAt)) {
+			value := r.Value
+			*field = &value
+		}
+		if r.RecordedAt.After(env.CreatedAt) {
+			env.CreatedAt = r.RecordedAt
+		}
+	}
+
+	if env.FirstTemperature != nil && env.FirstHumidity != nil {
+		env.FirstDewPoint = roundDerived(DewPoint(*env.FirstTemperature, *env.FirstHumidity))
+		env.FirstHeatIndex = roundDerived(HeatIndex(*env.FirstTemperature, *env.FirstHumidity))
+	}
+	if env.SecondTemperature != nil && env.SecondHumidity != nil {
+		env.SecondDewPoint = roundDerived(DewPoint(*env.SecondTemperature, *env.SecondHumidity))
+		env.SecondHeatIndex = roundDerived(HeatIndex(*env.SecondTemperature, *env.SecondHumidity))
+	}
+
+	return env
+}
+
+// Submission result statuses
+const (
+	SubmissionStored    = "stored"
+	SubmissionDuplicate = "duplicate"
+)
+
+// EnvSubmission is one upload of sensor readings, either as generic readings,
+// in the legacy two-probe shape, or both. RecordedAt applies to entries without a timestamp.
+type EnvSubmission struct {
+	IdempotencyKey    string          `json:"idempotency_key,omitempty" validate:"max=128"`
+	RecordedAt        time.Time       `json:"recorded_at,omitempty"`
+	Readings          []SensorReading `json:"readings,omitempty"`
+	FirstTemperature  *float64        `json:"first_temperature,omitempty"`
+	FirstHumidity     *float64        `json:"first_humidity,omitempty"`
+	SecondTemperature *float64        `json:"second_temperature,omitempty"`
+	SecondHumidity    *float64        `json:"second_humidity,omitempty"`
+}
+
+// IsLegacy reports whether the submission carries any legacy two-probe field
+func (s *EnvSubmission) IsLegacy() bool {
+	return s.FirstTemperature != nil || s.FirstHumidity != nil || s.SecondTemperature != nil || s.SecondHumidity != nil
+}
+
+// LegacyReadings returns the legacy fields as readings of the legacy sensors
+func (s *EnvSubmission) LegacyReadings() []SensorReading {
+	fields := []struct {
+		sensorID string
+		value    *float64
+	}{
+		{SensorFirstTemperature, s.FirstTemperature},
+		{SensorFirstHumidity, s.FirstHumidity},
+		{SensorSecondTemperature, s.SecondTemperature},
+		{SensorSecondHumidity, s.SecondHumidity},
+	}
+
+	var readings []SensorReading
+	for _, f := range fields {
+		if f.value != nil {
+			readings = append(readings, SensorReading{SensorID: f.sensorID, Value: *f.value, RecordedAt: s.RecordedAt})
+		}
+	}
+	return readings
+}
+
+// EnvSubmissionResult reports what happened to one submission
+type EnvSubmissionResult struct {
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	Status         string `json:"status"`
+	Readings       int    `json:"readings"`
+}
+
+// ValidateReading checks a value against the plausible range of a sensor kind
+func ValidateReading(kind string, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return errors.New("value must be a finite number")
+	}
+
+	var min, max float64
+	switch kind {
+	case SensorKindTemperature:
+		min, max = -80, 150
+	case SensorKindHumidity:
+		min, max = 0, 100
+	case SensorKindCO2:
+		min, max = 0, 100000
+	case SensorKindPressure:
+		min, max = 0, 2000
+	default:
+		return nil
+	}
+	if value < min || value > max {
+		return fmt.Errorf("%s value %g outside %g..%g", kind, value, min, max)
+	}
+	return nil
+}
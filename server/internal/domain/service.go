@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// ServiceStatus represents the state of a systemd unit as reported by an agent
+type ServiceStatus struct {
+	Unit          string  `json:"unit" proto:"1"`
+	LoadState     string  `json:"load_state" proto:"2"`
+	ActiveState   string  `json:"active_state" proto:"3"` // active, inactive, failed, activating, deactivating
+	SubState      string  `json:"sub_state" proto:"4"`    // running, exited, dead, ...
+	Restarts      uint64  `json:"restarts" proto:"5"`
+	MainPID       int     `json:"main_pid" proto:"6"`
+	MemoryBytes   uint64  `json:"memory_bytes" proto:"7"`
+	CPUUsageNanos uint64  `json:"cpu_usage_ns" proto:"8"`
+	CPUPercent    float64 `json:"cpu_percent" proto:"9"`
+}
+
+// ServiceState is the last known state of a unit on an agent
+type ServiceState struct {
+	AgentID string `json:"agent_id"`
+	ServiceStatus
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ServiceEvent records a change of a unit's active state
+type ServiceEvent struct {
+	ID         int64     `json:"id"`
+	AgentID    string    `json:"agent_id"`
+	Unit       string    `json:"unit"`
+	FromState  string    `json:"from_state"`
+	ToState    string    `json:"to_state"`
+	SubState   string    `json:"sub_state"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// ServiceStateFailed is the systemd active state of a failed unit
+const ServiceStateFailed = "failed"
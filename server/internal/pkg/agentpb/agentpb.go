@@ -0,0 +1,184 @@
+// Package agentpb implements the gRPC agent protocol described in agent.proto.
+//
+// There is no generated code: messages are plain Go structs (mostly the domain types)
+// encoded by protostruct, with the field numbers of agent.proto in their proto tags. The service
+// descriptor and client below follow what protoc-gen-go-grpc would generate.
+package agentpb
+
+import (
+	"context"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// ServiceName is the fully qualified name of the agent service
+const ServiceName = "monitor.agent.v1.AgentService"
+
+// MetadataAgentID is the metadata key identifying the agent on StreamMetrics and Commands
+const MetadataAgentID = "agent-id"
+
+// RegisterRequest registers a new agent, or updates a known one when AgentID is set
+type RegisterRequest struct {
+	AgentID     string   `json:"agent_id,omitempty" proto:"1"`
+	Name        string   `json:"name" proto:"2"`
+	Host        string   `json:"host" proto:"3"` // address of the agent's HTTP endpoints; ":port" uses the caller's IP
+	Hostname    string   `json:"hostname" proto:"4"`
+	IPAddress   string   `json:"ip_address" proto:"5"`
+	Version     string   `json:"version" proto:"6"`
+	Tags        []string `json:"tags,omitempty" proto:"7"`
+	Description string   `json:"description,omitempty" proto:"8"`
+}
+
+type RegisterResponse struct {
+	Agent domain.Agent `json:"agent" proto:"1"`
+}
+
+type HeartbeatRequest struct {
+	AgentID       string `json:"agent_id" proto:"1"`
+	Status        string `json:"status" proto:"2"`
+	ConfigVersion string `json:"config_version,omitempty" proto:"3"` // version of the server-managed config the agent is running
+}
+
+type HeartbeatResponse struct {
+	ServerTime time.Time `json:"server_time" proto:"1"`
+}
+
+// MetricsAck acknowledges one sample of the metrics stream, in the order they were sent
+type MetricsAck struct {
+	Timestamp time.Time `json:"timestamp" proto:"1"` // metrics timestamp of the acknowledged sample
+	Duplicate bool      `json:"duplicate" proto:"2"` // the sample was already stored
+}
+
+// Codec marshals gRPC messages: generated protobuf messages with the protobuf runtime,
+// anything else with protostruct. Both produce the wire format of agent.proto, so it uses
+// the standard "proto" content subtype and clients in other languages can use generated stubs.
+type Codec struct{}
+
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+	return protostruct.Marshal(v)
+}
+
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, m)
+	}
+	return protostruct.Unmarshal(data, v)
+}
+
+func (Codec) Name() string { return "proto" }
+
+// AgentServiceServer is the server API of the agent service
+type AgentServiceServer interface {
+	Register(context.Context, *RegisterRequest) (*RegisterResponse, error)
+	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
+	// StreamMetrics receives samples from an agent and acknowledges each of them
+	StreamMetrics(grpc.BidiStreamingServer[domain.AgentMetrics, MetricsAck]) error
+	// Commands sends commands to an agent and receives their results
+	Commands(grpc.BidiStreamingServer[domain.AgentCommandResult, domain.AgentCommand]) error
+}
+
+// RegisterAgentServiceServer registers the agent service on a gRPC server
+func RegisterAgentServiceServer(s grpc.ServiceRegistrar, srv AgentServiceServer) {
+	s.RegisterService(&ServiceDesc, srv)
+}
+
+// ServiceDesc describes the agent service for grpc.Server.RegisterService
+var ServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*AgentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Register", Handler: registerHandler},
+		{MethodName: "Heartbeat", Handler: heartbeatHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamMetrics", Handler: streamMetricsHandler, ServerStreams: true, ClientStreams: true},
+		{StreamName: "Commands", Handler: commandsHandler, ServerStreams: true, ClientStreams: true},
+	},
+	Metadata: "agent.proto",
+}
+
+func registerHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Register"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func heartbeatHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Heartbeat"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServiceServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func streamMetricsHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentServiceServer).StreamMetrics(&grpc.GenericServerStream[domain.AgentMetrics, MetricsAck]{ServerStream: stream})
+}
+
+func commandsHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AgentServiceServer).Commands(&grpc.GenericServerStream[domain.AgentCommandResult, domain.AgentCommand]{ServerStream: stream})
+}
+
+// Client is the client API of the agent service
+type Client struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{cc: cc}
+}
+
+func (c *Client) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*RegisterResponse, error) {
+	out := new(RegisterResponse)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/Register", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error) {
+	out := new(HeartbeatResponse)
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/Heartbeat", in, out, opts...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[domain.AgentMetrics, MetricsAck], error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[0], "/"+ServiceName+"/StreamMetrics", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[domain.AgentMetrics, MetricsAck]{ClientStream: stream}, nil
+}
+
+func (c *Client) Commands(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[domain.AgentCommandResult, domain.AgentCommand], error) {
+	stream, err := c.cc.NewStream(ctx, &ServiceDesc.Streams[1], "/"+ServiceName+"/Commands", opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[domain.AgentCommandResult, domain.AgentCommand]{ClientStream: stream}, nil
+}
@@ -0,0 +1,422 @@
+package agentpb
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// messages are the Go types sent on the wire, by the agent.proto message they implement.
+// The types they contain are matched to the messages of their fields by name.
+var messages = map[string]interface{}{
+	"RegisterRequest":    RegisterRequest{},
+	"RegisterResponse":   RegisterResponse{},
+	"HeartbeatRequest":   HeartbeatRequest{},
+	"HeartbeatResponse":  HeartbeatResponse{},
+	"MetricsAck":         MetricsAck{},
+	"AgentCommand":       domain.AgentCommand{},
+	"AgentCommandResult": domain.AgentCommandResult{},
+	"AgentMetrics":       domain.AgentMetrics{},
+	"AgentMetricsBatch":  domain.AgentMetricsBatch{},
+}
+
+var (
+	protoMessage = regexp.MustCompile(`(?s)message (\w+) \{(.*?)\n\}`)
+	protoField   = regexp.MustCompile(`(?m)^\s*(optional |repeated )?(map<(\w+), (\w+)>|\w+) (\w+) = (\d+);`)
+)
+
+var scalarTypes = map[string]descriptorpb.FieldDescriptorProto_Type{
+	"double": descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	"float":  descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	"int32":  descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	"int64":  descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	"uint32": descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	"uint64": descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	"sint32": descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	"sint64": descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+	"bool":   descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	"string": descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	"bytes":  descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+}
+
+// loadProto builds the descriptor of agent.proto, as protoc would for generated code. It
+// reads the subset of the language the file uses: top-level messages with scalar, message,
+// optional, repeated and map fields.
+func loadProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	data, err := os.ReadFile("agent.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "//")
+		lines = append(lines, line)
+	}
+	source := strings.Join(lines, "\n")
+
+	const pkg = "monitor.agent.v1"
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("agent.proto"),
+		Package: proto.String(pkg),
+		Syntax:  proto.String("proto3"),
+	}
+	typeOf := func(name string, field *descriptorpb.FieldDescriptorProto) {
+		if scalar, ok := scalarTypes[name]; ok {
+			field.Type = scalar.Enum()
+			return
+		}
+		field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+		field.TypeName = proto.String("." + pkg + "." + name)
+	}
+
+	for _, m := range protoMessage.FindAllStringSubmatch(source, -1) {
+		msg := &descriptorpb.DescriptorProto{Name: proto.String(m[1])}
+		for _, f := range protoField.FindAllStringSubmatch(m[2], -1) {
+			number, _ := strconv.Atoi(f[6])
+			field := &descriptorpb.FieldDescriptorProto{
+				Name:   proto.String(f[5]),
+				Number: proto.Int32(int32(number)),
+				Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			}
+			switch {
+			case f[3] != "":
+				entry := &descriptorpb.DescriptorProto{
+					Name:    proto.String(strings.ToUpper(f[5][:1]) + f[5][1:] + "Entry"),
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}
+				for i, name := range []string{"key", "value"} {
+					entryField := &descriptorpb.FieldDescriptorProto{
+						Name:   proto.String(name),
+						Number: proto.Int32(int32(i + 1)),
+						Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					}
+					typeOf(f[3+i], entryField)
+					entry.Field = append(entry.Field, entryField)
+				}
+				msg.NestedType = append(msg.NestedType, entry)
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+				field.Type = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+				field.TypeName = proto.String("." + pkg + "." + m[1] + "." + entry.GetName())
+			case f[1] == "repeated ":
+				field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+				typeOf(f[2], field)
+			case f[1] == "optional ":
+				field.Proto3Optional = proto.Bool(true)
+				field.OneofIndex = proto.Int32(int32(len(msg.OneofDecl)))
+				msg.OneofDecl = append(msg.OneofDecl, &descriptorpb.OneofDescriptorProto{Name: proto.String("_" + f[5])})
+				typeOf(f[2], field)
+			default:
+				typeOf(f[2], field)
+			}
+			msg.Field = append(msg.Field, field)
+		}
+		file.MessageType = append(file.MessageType, msg)
+	}
+
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatalf("agent.proto: %v", err)
+	}
+	return fd
+}
+
+// encodedFields returns the fields protostruct encodes, by field number
+func encodedFields(t *testing.T, typ reflect.Type) map[protoreflect.FieldNumber]reflect.StructField {
+	t.Helper()
+	fields := make(map[protoreflect.FieldNumber]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("proto")
+		if !f.IsExported() || f.Tag.Get("json") == "-" || tag == "-" {
+			continue
+		}
+		n, err := strconv.Atoi(tag)
+		if err != nil {
+			t.Fatalf("%s.%s: invalid proto tag %q", typ, f.Name, tag)
+		}
+		fields[protoreflect.FieldNumber(n)] = f
+	}
+	return fields
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// scalarKinds are the Go kinds protostruct encodes as each protobuf scalar kind
+var scalarKinds = map[protoreflect.Kind][]reflect.Kind{
+	protoreflect.DoubleKind: {reflect.Float64},
+	protoreflect.FloatKind:  {reflect.Float32},
+	protoreflect.Sint64Kind: {reflect.Int, reflect.Int64},
+	protoreflect.Sint32Kind: {reflect.Int32, reflect.Int16, reflect.Int8},
+	protoreflect.Uint64Kind: {reflect.Uint, reflect.Uint64},
+	protoreflect.Uint32Kind: {reflect.Uint32, reflect.Uint16},
+	protoreflect.BoolKind:   {reflect.Bool},
+	protoreflect.StringKind: {reflect.String},
+}
+
+// checkScalar reports whether a Go type has the wire encoding of a protobuf scalar field
+func checkScalar(fd protoreflect.FieldDescriptor, typ reflect.Type) bool {
+	if typ == timeType {
+		return fd.Kind() == protoreflect.Sint64Kind
+	}
+	if fd.Kind() == protoreflect.BytesKind {
+		return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+	}
+	for _, kind := range scalarKinds[fd.Kind()] {
+		if typ.Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// TestLayoutMatchesProto checks that every Go type sent on the wire has exactly the fields
+// of its agent.proto message, with the same numbers, names and wire types
+func TestLayoutMatchesProto(t *testing.T) {
+	fd := loadProto(t)
+	checked := make(map[protoreflect.FullName]bool)
+
+	var check func(md protoreflect.MessageDescriptor, typ reflect.Type)
+	check = func(md protoreflect.MessageDescriptor, typ reflect.Type) {
+		if checked[md.FullName()] {
+			return
+		}
+		checked[md.FullName()] = true
+
+		fields := encodedFields(t, typ)
+		if len(fields) != md.Fields().Len() {
+			t.Errorf("%s has %d encoded fields, message %s has %d", typ, len(fields), md.Name(), md.Fields().Len())
+		}
+		for num, f := range fields {
+			if md.Fields().ByNumber(num) == nil {
+				t.Errorf("%s.%s: field number %d is not in message %s", typ, f.Name, num, md.Name())
+			}
+		}
+
+		for i := 0; i < md.Fields().Len(); i++ {
+			field := md.Fields().Get(i)
+			f, ok := fields[field.Number()]
+			if !ok {
+				t.Errorf("%s.%s = %d has no field in %s", md.Name(), field.Name(), field.Number(), typ)
+				continue
+			}
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name != string(field.Name()) {
+				t.Errorf("%s.%s = %d is %s.%s (json %q)", md.Name(), field.Name(), field.Number(), typ, f.Name, name)
+			}
+
+			ft := f.Type
+			switch {
+			case field.IsMap():
+				if ft.Kind() != reflect.Map || !checkScalar(field.MapKey(), ft.Key()) || !checkScalar(field.MapValue(), ft.Elem()) {
+					t.Errorf("%s.%s: %s does not match %s", md.Name(), field.Name(), ft, field.Kind())
+				}
+				continue
+			case field.IsList():
+				if ft.Kind() != reflect.Slice || ft.Elem().Kind() == reflect.Uint8 {
+					t.Errorf("%s.%s: repeated field is %s", md.Name(), field.Name(), ft)
+					continue
+				}
+				ft = ft.Elem()
+			case field.HasOptionalKeyword():
+				if ft.Kind() != reflect.Pointer {
+					t.Errorf("%s.%s: optional field is %s", md.Name(), field.Name(), ft)
+					continue
+				}
+				ft = ft.Elem()
+			case field.Kind() == protoreflect.MessageKind && ft.Kind() == reflect.Pointer:
+				ft = ft.Elem()
+			}
+
+			if field.Kind() == protoreflect.MessageKind {
+				if ft.Kind() != reflect.Struct || ft == timeType || ft.Name() != string(field.Message().Name()) {
+					t.Errorf("%s.%s: %s does not implement message %s", md.Name(), field.Name(), ft, field.Message().Name())
+					continue
+				}
+				check(field.Message(), ft)
+			} else if !checkScalar(field, ft) {
+				t.Errorf("%s.%s: %s is not encoded as %s", md.Name(), field.Name(), ft, field.Kind())
+			}
+		}
+	}
+
+	for name, v := range messages {
+		md := fd.Messages().ByName(protoreflect.Name(name))
+		if md == nil {
+			t.Errorf("message %s is not in agent.proto", name)
+			continue
+		}
+		check(md, reflect.TypeOf(v))
+	}
+
+	for i := 0; i < fd.Messages().Len(); i++ {
+		if md := fd.Messages().Get(i); !checked[md.FullName()] {
+			t.Errorf("message %s has no Go type", md.Name())
+		}
+	}
+}
+
+// fill sets every field of v to a distinct non-zero value; n counts the values set
+func fill(v reflect.Value, n *int) {
+	*n++
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(time.Unix(int64(*n)*3600, int64(*n)).UTC()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fill(v.Field(i), n)
+		}
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), n)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+		fill(v.Index(0), n)
+		fill(v.Index(1), n)
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		for i := 0; i < 2; i++ {
+			key := reflect.New(v.Type().Key()).Elem()
+			value := reflect.New(v.Type().Elem()).Elem()
+			fill(key, n)
+			fill(value, n)
+			v.SetMapIndex(key, value)
+		}
+	case reflect.String:
+		v.SetString("value-" + strconv.Itoa(*n))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(-*n)) // negative, to check the zigzag encoding
+	case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(*n) + 0.25)
+	}
+}
+
+// compareMessage checks that a message decoded by the protobuf runtime holds the values of
+// the Go struct v and no unknown fields
+func compareMessage(t *testing.T, path string, v reflect.Value, msg protoreflect.Message) {
+	t.Helper()
+	if len(msg.GetUnknown()) > 0 {
+		t.Errorf("%s: unknown fields in the decoded message", path)
+	}
+	for num, f := range encodedFields(t, v.Type()) {
+		fd := msg.Descriptor().Fields().ByNumber(num)
+		if fd == nil {
+			t.Errorf("%s.%s: no field %d", path, f.Name, num)
+			continue
+		}
+		fieldPath := path + "." + string(fd.Name())
+		fv := v.FieldByIndex(f.Index)
+		switch {
+		case fd.IsMap():
+			m := msg.Get(fd).Map()
+			if m.Len() != fv.Len() {
+				t.Errorf("%s: %d entries, want %d", fieldPath, m.Len(), fv.Len())
+			}
+			iter := fv.MapRange()
+			for iter.Next() {
+				key := protoreflect.ValueOf(iter.Key().Interface()).MapKey()
+				compareValue(t, fieldPath+"["+iter.Key().String()+"]", iter.Value(), fd.MapValue(), m.Get(key))
+			}
+		case fd.IsList():
+			list := msg.Get(fd).List()
+			if list.Len() != fv.Len() {
+				t.Errorf("%s: %d elements, want %d", fieldPath, list.Len(), fv.Len())
+				continue
+			}
+			for i := 0; i < list.Len(); i++ {
+				compareValue(t, fieldPath+"["+strconv.Itoa(i)+"]", fv.Index(i), fd, list.Get(i))
+			}
+		default:
+			if !msg.Has(fd) {
+				t.Errorf("%s: not set", fieldPath)
+				continue
+			}
+			compareValue(t, fieldPath, fv, fd, msg.Get(fd))
+		}
+	}
+}
+
+func compareValue(t *testing.T, path string, v reflect.Value, fd protoreflect.FieldDescriptor, value protoreflect.Value) {
+	t.Helper()
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	var got, want interface{}
+	switch {
+	case fd.Kind() == protoreflect.MessageKind:
+		compareMessage(t, path, v, value.Message())
+		return
+	case v.Type() == timeType:
+		got, want = value.Int(), v.Interface().(time.Time).UnixNano()
+	case v.Kind() == reflect.Slice:
+		got, want = string(value.Bytes()), string(v.Bytes())
+	default:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			got, want = value.Int(), v.Int()
+		case reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			got, want = value.Uint(), v.Uint()
+		case reflect.Float32, reflect.Float64:
+			got, want = value.Float(), v.Float()
+		default:
+			got, want = value.Interface(), v.Interface()
+		}
+	}
+	if got != want {
+		t.Errorf("%s = %v, want %v", path, got, want)
+	}
+}
+
+// TestRoundTripWithProtobufRuntime encodes every message with protostruct, decodes it with
+// the protobuf runtime from the agent.proto descriptor as generated code would, and back
+func TestRoundTripWithProtobufRuntime(t *testing.T) {
+	fd := loadProto(t)
+
+	for name, v := range messages {
+		t.Run(name, func(t *testing.T) {
+			original := reflect.New(reflect.TypeOf(v))
+			n := 0
+			fill(original.Elem(), &n)
+
+			data, err := protostruct.Marshal(original.Interface())
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg := dynamicpb.NewMessage(fd.Messages().ByName(protoreflect.Name(name)))
+			if err := proto.Unmarshal(data, msg); err != nil {
+				t.Fatalf("protobuf runtime cannot decode protostruct output: %v", err)
+			}
+			compareMessage(t, name, original.Elem(), msg)
+
+			data, err = proto.Marshal(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := reflect.New(reflect.TypeOf(v))
+			if err := protostruct.Unmarshal(data, decoded.Interface()); err != nil {
+				t.Fatalf("protostruct cannot decode protobuf runtime output: %v", err)
+			}
+			if !reflect.DeepEqual(decoded.Interface(), original.Interface()) {
+				t.Errorf("round trip through the protobuf runtime changed the message:\n got %+v\nwant %+v", decoded.Elem(), original.Elem())
+			}
+		})
+	}
+}
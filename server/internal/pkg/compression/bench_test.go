@@ -0,0 +1,89 @@
+package compression_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
+)
+
+// Benchmarks of the content codings on metrics payloads, reporting the compressed size as
+// bytes/sample. They use the sample of the protostruct benchmarks, or $METRICS_SAMPLE.
+
+// payloads returns the sample encoded as JSON and as protobuf
+func payloads(b *testing.B) map[string][]byte {
+	b.Helper()
+	path := os.Getenv("METRICS_SAMPLE")
+	if path == "" {
+		path = "../protostruct/testdata/metrics.json"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var envelope struct {
+		Data *domain.SystemMetrics `json:"data"`
+	}
+	metrics := new(domain.SystemMetrics)
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Data != nil {
+		metrics = envelope.Data
+	} else if err := json.Unmarshal(data, metrics); err != nil {
+		b.Fatalf("%s: %v", path, err)
+	}
+
+	encodedJSON, err := json.Marshal(metrics)
+	if err != nil {
+		b.Fatal(err)
+	}
+	encodedProtobuf, err := protostruct.Marshal(metrics)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return map[string][]byte{"json": encodedJSON, "protobuf": encodedProtobuf}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	for _, format := range []string{"json", "protobuf"} {
+		data := payloads(b)[format]
+		for _, coding := range []string{compression.Gzip, compression.Zstd} {
+			b.Run(format+"+"+coding, func(b *testing.B) {
+				b.ReportAllocs()
+				var size int
+				for i := 0; i < b.N; i++ {
+					compressed, err := compression.Compress(coding, data)
+					if err != nil {
+						b.Fatal(err)
+					}
+					size = len(compressed)
+				}
+				b.ReportMetric(float64(size), "bytes/sample")
+			})
+		}
+	}
+}
+
+func BenchmarkDecompress(b *testing.B) {
+	for _, format := range []string{"json", "protobuf"} {
+		data := payloads(b)[format]
+		for _, coding := range []string{compression.Gzip, compression.Zstd} {
+			b.Run(format+"+"+coding, func(b *testing.B) {
+				compressed, err := compression.Compress(coding, data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, err := compression.Decompress(coding, compressed, 32<<20); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(len(compressed)), "bytes/sample")
+			})
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package compression handles the gzip and zstd content codings used for
+// request and response bodies between agents and the server.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported content codings; an empty coding means the body is not compressed
+const (
+	Gzip     = "gzip"
+	Zstd     = "zstd"
+	Identity = "identity"
+)
+
+// AcceptEncoding is the Accept-Encoding header sent by clients that can decode every supported coding
+const AcceptEncoding = "zstd, gzip"
+
+// NewReader returns a reader decompressing r according to a Content-Encoding header value
+func NewReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch normalize(encoding) {
+	case "", Identity:
+		return io.NopCloser(r), nil
+	case Gzip, "x-gzip":
+		return gzip.NewReader(r)
+	case Zstd:
+		decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// NewWriter returns a writer compressing into w; Close must be called to flush it
+func NewWriter(encoding string, w io.Writer) (io.WriteCloser, error) {
+	switch normalize(encoding) {
+	case "", Identity:
+		return nopWriteCloser{w}, nil
+	case Gzip, "x-gzip":
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// zstdEncoder is shared by Compress; EncodeAll is safe for concurrent use
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// Compress compresses data in one call
+func Compress(encoding string, data []byte) ([]byte, error) {
+	if normalize(encoding) == Zstd {
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses data in one call, failing when the result exceeds limit bytes
+func Decompress(encoding string, data []byte, limit int64) ([]byte, error) {
+	r, err := NewReader(encoding, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("decompressed body exceeds %d bytes", limit)
+	}
+	return out, nil
+}
+
+// Negotiate picks the coding for a response from an Accept-Encoding header:
+// zstd, then gzip, or "" (uncompressed) when the client accepts neither
+func Negotiate(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = normalize(coding)
+		if coding == "" {
+			continue
+		}
+		accepted[coding] = true
+
+		// A quality of zero means the coding is refused
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q == 0 {
+				accepted[coding] = false
+			}
+		}
+	}
+
+	for _, coding := range []string{Zstd, Gzip} {
+		if accepted[coding] {
+			return coding
+		}
+	}
+	return ""
+}
+
+func normalize(encoding string) string {
+	return strings.ToLower(strings.TrimSpace(encoding))
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
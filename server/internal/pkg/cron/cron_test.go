@@ -0,0 +1,169 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // the DST tests need zones on hosts without a zoneinfo database
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "must have 5 fields, got 0"},
+		{"* * * *", "must have 5 fields, got 4"},
+		{"* * * * * *", "must have 5 fields, got 6"},
+		{"@every 5m", "must have 5 fields"},
+		{"60 * * * *", `invalid value "60" in minute field, must be 0-59`},
+		{"* 24 * * *", "hour field"},
+		{"* * 0 * *", "day of month field"},
+		{"* * 32 * *", "day of month field"},
+		{"* * * 13 * ", "month field"},
+		{"* * * * 8", "day of week field"},
+		{"* * * foo *", `invalid value "foo" in month field`},
+		{"* * * * monday", "day of week field"},
+		{"5-1 * * * *", `invalid range "5-1"`},
+		{"*/0 * * * *", `invalid step "0"`},
+		{"*/x * * * *", `invalid step "x"`},
+		{"1-/2 * * * *", "minute field"},
+		{"1,,2 * * * *", "minute field"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.expr); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+// bits returns the bit set of the given values
+func bits(values ...int) uint64 {
+	var b uint64
+	for _, v := range values {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+// span returns the bit set of low to high, every step
+func span(low, high, step int) uint64 {
+	var b uint64
+	for v := low; v <= high; v += step {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		expr string
+		want Schedule
+	}{
+		{"* * * * *", Schedule{minute: span(0, 59, 1), hour: span(0, 23, 1), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domStar: true, dowStar: true}},
+		{"*/15 */6 */10 */3 */2", Schedule{minute: bits(0, 15, 30, 45), hour: bits(0, 6, 12, 18), dom: bits(1, 11, 21, 31), month: bits(1, 4, 7, 10), dow: bits(0, 2, 4, 6), domStar: true, dowStar: true}},
+		{"5/15 1-5/2 1-3,15 1-12/4 1-5", Schedule{minute: bits(5, 20, 35, 50), hour: bits(1, 3, 5), dom: bits(1, 2, 3, 15), month: bits(1, 5, 9), dow: bits(1, 2, 3, 4, 5)}},
+		{"0 0 * JAN,jul-Sep mon-FRI", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: bits(1, 7, 8, 9), dow: bits(1, 2, 3, 4, 5), domStar: true}},
+		{"0 0 * * 7", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0, 7), domStar: true}},
+		{"0 0 * * 5-7", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0, 5, 6, 7), domStar: true}},
+		{"0 0 * * sat,sun", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0, 6), domStar: true}},
+		{"0 0 1,15 * */1", Schedule{minute: bits(0), hour: bits(0), dom: bits(1, 15), month: span(1, 12, 1), dow: span(0, 7, 1), dowStar: true}},
+		{"  30 2 * * *  ", Schedule{minute: bits(30), hour: bits(2), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domStar: true, dowStar: true}},
+		{"@yearly", Schedule{minute: bits(0), hour: bits(0), dom: bits(1), month: bits(1), dow: span(0, 7, 1), dowStar: true}},
+		{"@annually", Schedule{minute: bits(0), hour: bits(0), dom: bits(1), month: bits(1), dow: span(0, 7, 1), dowStar: true}},
+		{"@monthly", Schedule{minute: bits(0), hour: bits(0), dom: bits(1), month: span(1, 12, 1), dow: span(0, 7, 1), dowStar: true}},
+		{"@weekly", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: bits(0), domStar: true}},
+		{"@daily", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domStar: true, dowStar: true}},
+		{"@Midnight", Schedule{minute: bits(0), hour: bits(0), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domStar: true, dowStar: true}},
+		{"@HOURLY", Schedule{minute: bits(0), hour: span(0, 23, 1), dom: span(1, 31, 1), month: span(1, 12, 1), dow: span(0, 7, 1), domStar: true, dowStar: true}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if *s != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.expr, *s, tt.want)
+		}
+	}
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestNext(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	santiago := mustLoad(t, "America/Santiago") // skips midnight when daylight saving starts
+	at := func(loc *time.Location, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+	edt := time.FixedZone("EDT", -4*3600)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"next minute", "* * * * *", at(time.UTC, 10, 19, 8, 30).Add(25 * time.Second), at(time.UTC, 10, 19, 8, 31)},
+		{"strictly after", "30 8 * * *", at(time.UTC, 10, 19, 8, 30), at(time.UTC, 10, 20, 8, 30)},
+		{"step from start", "5/15 * * * *", at(time.UTC, 10, 19, 8, 51), at(time.UTC, 10, 19, 9, 5)},
+		{"range with step", "0 1-5/2 * * *", at(time.UTC, 10, 19, 3, 1), at(time.UTC, 10, 19, 5, 0)},
+		{"weekday names", "0 9 * * mon-fri", at(time.UTC, 10, 23, 10, 0), at(time.UTC, 10, 26, 9, 0)}, // Friday to Monday
+		{"7 is Sunday", "0 9 * * 7", at(time.UTC, 10, 19, 10, 0), at(time.UTC, 10, 25, 9, 0)},
+		{"month names", "0 0 1 feb,aug *", at(time.UTC, 10, 19, 0, 0), time.Date(2027, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"end of month", "0 0 31 * *", at(time.UTC, 4, 1, 0, 0), at(time.UTC, 5, 31, 0, 0)},
+		{"leap day", "0 12 29 2 *", at(time.UTC, 10, 19, 0, 0), time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC)},
+		{"@weekly", "@weekly", at(time.UTC, 10, 19, 0, 0), at(time.UTC, 10, 25, 0, 0)},
+		{"@monthly", "@monthly", at(time.UTC, 10, 19, 0, 0), at(time.UTC, 11, 1, 0, 0)},
+		{"@yearly", "@yearly", at(time.UTC, 10, 19, 0, 0), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+
+		// Vixie cron: with both day fields restricted a day matching either one matches
+		{"day of month or week, the 13th first", "0 0 13 * fri", at(time.UTC, 2, 1, 0, 0), at(time.UTC, 2, 6, 0, 0)},
+		{"day of month or week, the 13th", "0 0 13 * fri", at(time.UTC, 2, 7, 0, 0), at(time.UTC, 2, 13, 0, 0)},
+		{"day of month or week, a Friday", "0 0 13 * fri", at(time.UTC, 2, 13, 0, 0), at(time.UTC, 2, 20, 0, 0)},
+		{"day of week with day of month *", "0 0 * * fri", at(time.UTC, 2, 7, 0, 0), at(time.UTC, 2, 13, 0, 0)},
+		{"day of month with stepped day of week *", "0 0 1 * */2", at(time.UTC, 2, 2, 0, 0), at(time.UTC, 3, 1, 0, 0)},
+
+		// The location of from
+		{"in the location of from", "0 9 * * *", at(time.UTC, 10, 19, 3, 0).In(newYork), at(newYork, 10, 19, 9, 0)},
+
+		// 2026-03-08 02:00 EST jumps to 03:00 EDT: a time in the gap does not exist that day
+		{"spring forward, skipped time", "30 2 * * *", at(newYork, 3, 8, 1, 0), at(newYork, 3, 9, 2, 30)},
+		{"spring forward, every 30 minutes", "*/30 * * * *", at(newYork, 3, 8, 1, 45), at(newYork, 3, 8, 3, 0)},
+		{"spring forward, after the gap", "30 3 * * *", at(newYork, 3, 8, 1, 0), at(newYork, 3, 8, 3, 30)},
+		// 2026-11-01 02:00 EDT falls back to 01:00 EST: the repeated hour matches once
+		{"fall back, first 01:30", "30 1 * * *", at(newYork, 11, 1, 0, 0), time.Date(2026, 11, 1, 1, 30, 0, 0, edt)},
+		{"fall back, not again", "30 1 * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, edt).In(newYork), at(newYork, 11, 2, 1, 30)},
+		{"fall back, hourly", "0 * * * *", time.Date(2026, 11, 1, 1, 0, 0, 0, edt).In(newYork), time.Date(2026, 11, 1, 2, 0, 0, 0, est)},
+		// 2026-09-06 00:00 in Santiago jumps to 01:00: that day has no midnight
+		{"skipped midnight", "0 0 * * *", at(santiago, 9, 5, 12, 0), at(santiago, 9, 7, 0, 0)},
+		{"skipped midnight, hourly", "0 * * * *", at(santiago, 9, 5, 23, 30), at(santiago, 9, 6, 1, 0)},
+		{"skipped midnight, next day", "0 12 * * *", at(santiago, 9, 5, 12, 0), at(santiago, 9, 6, 12, 0)},
+		{"skipped midnight, next month", "0 12 * 10 *", at(santiago, 9, 5, 12, 0), at(santiago, 10, 1, 12, 0)},
+
+		// Never matching
+		{"February 30th", "0 0 30 2 *", at(time.UTC, 1, 1, 0, 0), time.Time{}},
+		{"April 31st", "0 0 31 apr *", at(time.UTC, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := s.Next(tt.from)
+			if !got.Equal(tt.want) || (!got.IsZero() && got.Location() != tt.from.Location()) {
+				t.Errorf("Next(%s) of %q = %s, want %s", tt.from, tt.expr, got, tt.want.In(tt.from.Location()))
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+// Package lineprotocol decodes InfluxDB line protocol into series.
+//
+//	<measurement>[,<tag>=<value>...] <field>=<value>[,<field>=<value>...] [timestamp]
+//
+// Every numeric or boolean field becomes a series named <measurement>_<field>,
+// or just <measurement> for a field called "value". String fields are skipped.
+package lineprotocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// ErrMalformed is returned for lines that are not valid line protocol
+var ErrMalformed = errors.New("malformed line protocol")
+
+// Precision returns the timestamp unit for an InfluxDB precision parameter (default ns)
+func Precision(s string) (time.Duration, error) {
+	switch s {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown precision %q", s)
+}
+
+// Decode parses a line protocol body. Lines without a timestamp get now.
+func Decode(body string, precision time.Duration, now time.Time) ([]domain.Series, error) {
+	var series []domain.Series
+	for i, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		decoded, err := decodeLine(line, precision, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		series = append(series, decoded...)
+	}
+	return series, nil
+}
+
+func decodeLine(line string, precision time.Duration, now time.Time) ([]domain.Series, error) {
+	sections := splitUnescaped(line, ' ', true)
+	if len(sections) < 2 || len(sections) > 3 {
+		return nil, ErrMalformed
+	}
+
+	// Measurement and tags
+	key := splitUnescaped(sections[0], ',', false)
+	measurement := unescape(key[0])
+	if measurement == "" {
+		return nil, fmt.Errorf("%w: missing measurement", ErrMalformed)
+	}
+	tags := make(map[string]string, len(key)-1)
+	for _, tag := range key[1:] {
+		kv := splitUnescaped(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%w: invalid tag %q", ErrMalformed, tag)
+		}
+		tags[unescape(kv[0])] = unescape(kv[1])
+	}
+
+	// Timestamp
+	timestamp := now
+	if len(sections) == 3 {
+		ts, err := strconv.ParseInt(sections[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid timestamp %q", ErrMalformed, sections[2])
+		}
+		timestamp = time.Unix(0, ts*int64(precision))
+	}
+
+	// Fields
+	var series []domain.Series
+	for _, field := range splitUnescaped(sections[1], ',', true) {
+		kv := splitUnescaped(field, '=', true)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%w: invalid field %q", ErrMalformed, field)
+		}
+
+		value, ok, err := parseFieldValue(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		name := measurement
+		if fieldName := unescape(kv[0]); fieldName != "value" {
+			name += "_" + fieldName
+		}
+		series = append(series, domain.Series{
+			Name:      name,
+			Labels:    tags,
+			Value:     value,
+			Timestamp: timestamp,
+		})
+	}
+	return series, nil
+}
+
+// parseFieldValue parses a field value; ok is false for string fields
+func parseFieldValue(s string) (float64, bool, error) {
+	switch {
+	case s == "":
+		return 0, false, fmt.Errorf("%w: empty field value", ErrMalformed)
+	case s[0] == '"':
+		return 0, false, nil
+	case s == "t" || s == "T" || s == "true" || s == "True" || s == "TRUE":
+		return 1, true, nil
+	case s == "f" || s == "F" || s == "false" || s == "False" || s == "FALSE":
+		return 0, true, nil
+	case strings.HasSuffix(s, "i"):
+		v, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: invalid integer %q", ErrMalformed, s)
+		}
+		return float64(v), true, nil
+	case strings.HasSuffix(s, "u"):
+		v, err := strconv.ParseUint(s[:len(s)-1], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("%w: invalid unsigned integer %q", ErrMalformed, s)
+		}
+		return float64(v), true, nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: invalid number %q", ErrMalformed, s)
+	}
+	return v, true, nil
+}
+
+// splitUnescaped splits s on sep, skipping backslash-escaped separators and,
+// when quotes is set, separators inside double-quoted strings
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var unescaper = strings.NewReplacer(`\,`, ",", `\ `, " ", `\=`, "=", `\\`, `\`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
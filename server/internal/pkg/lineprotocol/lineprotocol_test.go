@@ -0,0 +1,155 @@
+package lineprotocol
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestDecode(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	at := time.Unix(0, 1760862600123456789)
+
+	tests := []struct {
+		name string
+		body string
+		want []domain.Series
+	}{
+		{
+			name: "fields and tags",
+			body: "cpu,host=web-1,core=0 usage=12.5,idle=87.5 1760862600123456789",
+			want: []domain.Series{
+				{Name: "cpu_usage", Labels: map[string]string{"host": "web-1", "core": "0"}, Value: 12.5, Timestamp: at},
+				{Name: "cpu_idle", Labels: map[string]string{"host": "web-1", "core": "0"}, Value: 87.5, Timestamp: at},
+			},
+		},
+		{
+			name: "value field and no timestamp",
+			body: "queue_depth value=42",
+			want: []domain.Series{{Name: "queue_depth", Labels: map[string]string{}, Value: 42, Timestamp: now}},
+		},
+		{
+			name: "integer, unsigned, boolean and float forms",
+			body: "m a=-7i,b=18446744073709551615u,c=t,d=FALSE,e=True,f=1e3,g=-0.5",
+			want: []domain.Series{
+				{Name: "m_a", Labels: map[string]string{}, Value: -7, Timestamp: now},
+				{Name: "m_b", Labels: map[string]string{}, Value: 18446744073709551615, Timestamp: now},
+				{Name: "m_c", Labels: map[string]string{}, Value: 1, Timestamp: now},
+				{Name: "m_d", Labels: map[string]string{}, Value: 0, Timestamp: now},
+				{Name: "m_e", Labels: map[string]string{}, Value: 1, Timestamp: now},
+				{Name: "m_f", Labels: map[string]string{}, Value: 1000, Timestamp: now},
+				{Name: "m_g", Labels: map[string]string{}, Value: -0.5, Timestamp: now},
+			},
+		},
+		{
+			name: "escaped measurement, tags and field keys",
+			body: `disk\ io\,total,mount\ point=/var\ lib,path=a\=b\\c read\ ops=3i,write\,ops=4i`,
+			want: []domain.Series{
+				{Name: "disk io,total_read ops", Labels: map[string]string{"mount point": "/var lib", "path": `a=b\c`}, Value: 3, Timestamp: now},
+				{Name: "disk io,total_write,ops", Labels: map[string]string{"mount point": "/var lib", "path": `a=b\c`}, Value: 4, Timestamp: now},
+			},
+		},
+		{
+			name: "string fields with commas, spaces, equals and quotes are skipped",
+			body: `job,name=backup status="done, 3 files=ok",msg="said \"hi, there\"",duration=12.5 1760862600123456789`,
+			want: []domain.Series{{Name: "job_duration", Labels: map[string]string{"name": "backup"}, Value: 12.5, Timestamp: at}},
+		},
+		{
+			name: "only string fields",
+			body: `event message="deploy"`,
+		},
+		{
+			name: "several lines, comments and blank lines",
+			body: "# a comment\n\ncpu usage=1\r\n  mem used=2i  \n",
+			want: []domain.Series{
+				{Name: "cpu_usage", Labels: map[string]string{}, Value: 1, Timestamp: now},
+				{Name: "mem_used", Labels: map[string]string{}, Value: 2, Timestamp: now},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.body, time.Nanosecond, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no fields", "cpu"},
+		{"no fields with tags", "cpu,host=a"},
+		{"too many sections", "cpu usage=1 1760862600 extra"},
+		{"missing measurement", ",host=a usage=1"},
+		{"tag without value", "cpu,host usage=1"},
+		{"tag without key", "cpu,=a usage=1"},
+		{"field without value", "cpu usage"},
+		{"field without key", "cpu =1"},
+		{"empty field value", "cpu usage="},
+		{"invalid number", "cpu usage=12a"},
+		{"float with integer suffix", "cpu usage=1.5i"},
+		{"negative unsigned", "cpu usage=-1u"},
+		{"integer out of range", "cpu usage=9223372036854775808i"},
+		{"invalid timestamp", "cpu usage=1 yesterday"},
+		{"bad second line", "cpu usage=1\nmem"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.body, time.Nanosecond, time.Now()); !errors.Is(err, ErrMalformed) {
+				t.Errorf("Decode(%q) error = %v, want ErrMalformed", tt.body, err)
+			}
+		})
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		timestamp string
+		want      time.Time
+	}{
+		{"", "1760862600123456789", time.Unix(0, 1760862600123456789)},
+		{"n", "1760862600123456789", time.Unix(0, 1760862600123456789)},
+		{"ns", "1760862600123456789", time.Unix(0, 1760862600123456789)},
+		{"u", "1760862600123456", time.Unix(0, 1760862600123456000)},
+		{"us", "1760862600123456", time.Unix(0, 1760862600123456000)},
+		{"ms", "1760862600123", time.Unix(0, 1760862600123000000)},
+		{"s", "1760862600", time.Unix(1760862600, 0)},
+		{"m", "29347710", time.Unix(1760862600, 0)},
+		{"h", "489128", time.Unix(1760860800, 0)},
+		{"s", "-60", time.Unix(-60, 0)},
+	}
+	for _, tt := range tests {
+		precision, err := Precision(tt.precision)
+		if err != nil {
+			t.Errorf("Precision(%q): %v", tt.precision, err)
+			continue
+		}
+		series, err := Decode("cpu usage=1 "+tt.timestamp, precision, time.Now())
+		if err != nil {
+			t.Errorf("precision %q: %v", tt.precision, err)
+			continue
+		}
+		if !series[0].Timestamp.Equal(tt.want) {
+			t.Errorf("precision %q: timestamp %s = %s, want %s", tt.precision, tt.timestamp, series[0].Timestamp, tt.want)
+		}
+	}
+
+	for _, precision := range []string{"x", "sec", "NS", "1s"} {
+		if _, err := Precision(precision); err == nil {
+			t.Errorf("Precision(%q) accepted an unknown precision", precision)
+		}
+	}
+}
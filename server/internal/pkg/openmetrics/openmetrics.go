@@ -0,0 +1,206 @@
+package openmetrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the OpenMetrics text exposition content type
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Metric types
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Label is a single name/value pair; order is preserved in the output
+type Label struct {
+	Name  string
+	Value string
+}
+
+type Labels []Label
+
+// With returns a copy of the labels with extra pairs appended
+func (l Labels) With(pairs ...string) Labels {
+	out := make(Labels, len(l), len(l)+len(pairs)/2)
+	copy(out, l)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		out = append(out, Label{Name: pairs[i], Value: pairs[i+1]})
+	}
+	return out
+}
+
+// Has reports whether a label is named name
+func (l Labels) Has(name string) bool {
+	for _, label := range l {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+type sample struct {
+	labels    Labels
+	value     float64
+	timestamp *time.Time
+}
+
+type family struct {
+	name    string
+	typ     string
+	help    string
+	samples []sample
+}
+
+// Exposition collects samples grouped by metric family, as required by the format
+type Exposition struct {
+	families  map[string]*family
+	timestamp *time.Time
+}
+
+func New() *Exposition {
+	return &Exposition{
+		families: make(map[string]*family),
+	}
+}
+
+// SetTimestamp attaches a timestamp to every sample added afterwards; nil clears it
+func (e *Exposition) SetTimestamp(ts *time.Time) {
+	e.timestamp = ts
+}
+
+// Gauge adds a gauge sample
+func (e *Exposition) Gauge(name, help string, value float64, labels Labels) {
+	e.add(name, Gauge, help, value, labels)
+}
+
+// Counter adds a counter sample; name must not include the _total suffix
+func (e *Exposition) Counter(name, help string, value float64, labels Labels) {
+	e.add(name, Counter, help, value, labels)
+}
+
+func (e *Exposition) add(name, typ, help string, value float64, labels Labels) {
+	f, ok := e.families[name]
+	if !ok {
+		f = &family{name: name, typ: typ, help: help}
+		e.families[name] = f
+	}
+	f.samples = append(f.samples, sample{labels: labels, value: value, timestamp: e.timestamp})
+}
+
+// WriteTo writes all families, sorted by name, followed by the # EOF marker
+func (e *Exposition) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(e.families))
+	for name := range e.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, name := range names {
+		f := e.families[name]
+		cw.writeString("# TYPE " + f.name + " " + f.typ + "\n")
+		if f.help != "" {
+			cw.writeString("# HELP " + f.name + " " + escapeHelp(f.help) + "\n")
+		}
+
+		sampleName := f.name
+		if f.typ == Counter {
+			sampleName += "_total"
+		}
+		for _, s := range f.samples {
+			cw.writeString(sampleName)
+			writeLabels(cw, s.labels)
+			cw.writeString(" " + formatFloat(s.value))
+			if s.timestamp != nil {
+				cw.writeString(" " + strconv.FormatFloat(float64(s.timestamp.UnixMilli())/1000, 'f', -1, 64))
+			}
+			cw.writeString("\n")
+		}
+	}
+	cw.writeString("# EOF\n")
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// SanitizeName turns an arbitrary string into a valid metric or label name
+func SanitizeName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func writeLabels(cw *countingWriter, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	cw.writeString("{")
+	for i, l := range labels {
+		if i > 0 {
+			cw.writeString(",")
+		}
+		cw.writeString(l.Name + `="` + escapeLabelValue(l.Value) + `"`)
+	}
+	cw.writeString("}")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) writeString(s string) {
+	if c.err != nil {
+		return
+	}
+	n, err := c.w.WriteString(s)
+	c.n += int64(n)
+	c.err = err
+}
@@ -0,0 +1,128 @@
+package openmetrics
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares an exposition with testdata/name, or rewrites the file with -update
+func checkGolden(t *testing.T, e *Exposition, name string) {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := e.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo() = %d bytes, wrote %d", n, buf.Len())
+	}
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("exposition differs from %s (go test -update to rewrite it):\n%s", path, got)
+	}
+	assertUniqueLabels(t, buf.String())
+}
+
+// assertUniqueLabels fails for a sample that repeats a label name, which Prometheus rejects
+// along with the whole scrape
+func assertUniqueLabels(t *testing.T, exposition string) {
+	t.Helper()
+	for _, line := range strings.Split(exposition, "\n") {
+		start, end := strings.Index(line, "{"), strings.LastIndex(line, "}")
+		if strings.HasPrefix(line, "#") || start < 0 || end < start {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, pair := range strings.Split(line[start+1:end], `",`) {
+			name, _, _ := strings.Cut(pair, "=")
+			if seen[name] {
+				t.Errorf("label %s repeated in %s", name, line)
+			}
+			seen[name] = true
+		}
+	}
+}
+
+func TestExpositionFormat(t *testing.T) {
+	e := New()
+	base := Labels{{Name: "agent", Value: "web-1"}}
+	e.Gauge("queue_depth", "Jobs waiting\nin the \\ queue", 42, base.With("queue", `mail "outbound"`+"\n"+`C:\spool`))
+	e.Gauge("queue_depth", "", math.NaN(), base.With("queue", "empty"))
+	e.Counter("requests", "Requests served", 1.5e10, base)
+	e.Gauge("headroom", "", math.Inf(1), nil)
+	e.Gauge("deficit", "", math.Inf(-1), nil)
+
+	at := time.Date(2026, 10, 19, 2, 0, 0, 123e6, time.UTC)
+	e.SetTimestamp(&at)
+	e.Gauge("temperature_celsius", "Sensor temperature", -3.25, Labels{{Name: "sensor", Value: "outside"}})
+	e.SetTimestamp(nil)
+	e.Gauge("temperature_celsius", "", 21, Labels{{Name: "sensor", Value: "inside"}})
+
+	checkGolden(t, e, "format.txt")
+}
+
+func TestAddSystemMetricsSections(t *testing.T) {
+	e := New()
+	base := Labels{{Name: "agent_id", Value: "agent-1"}, {Name: "agent", Value: "db-1"}}
+	e.addServices([]domain.ServiceStatus{
+		{Unit: "postgresql.service", ActiveState: "active", SubState: "running", Restarts: 2, MemoryBytes: 256 << 20, CPUUsageNanos: 42e9},
+		{Unit: "pgbouncer.service", ActiveState: "failed", SubState: "failed", Restarts: 5},
+	}, base)
+	e.addPing([]domain.PingResult{
+		{Target: "gateway", Method: "icmp", Sent: 5, Received: 4, LossPercent: 20, MinMs: 0.5, AvgMs: 1.25, MaxMs: 2, JitterMs: 0.25},
+		{Target: "offsite", Method: "tcp", Sent: 5, LossPercent: 100},
+	}, base)
+	e.addCustom([]domain.CustomCheck{{
+		Plugin:   "pg_stats",
+		ExitCode: 1,
+		Metrics: []domain.CustomMetric{
+			{Name: "connections", Value: 87, Labels: map[string]string{"database": "orders"}},
+			{Name: "replication lag", Value: 0.5},
+		},
+	}}, base)
+
+	checkGolden(t, e, "sections.txt")
+}
+
+func TestAddCustomLabelCollisions(t *testing.T) {
+	e := New()
+	base := Labels{{Name: "agent_id", Value: "agent-1"}, {Name: "agent", Value: "web-1"}, {Name: "tags", Value: ",web,"}}
+	e.addCustom([]domain.CustomCheck{{
+		Plugin: "queues",
+		Metrics: []domain.CustomMetric{{
+			Name:  "queue_depth",
+			Value: 12,
+			Labels: map[string]string{
+				"plugin":      "rabbitmq",   // the plugin label of every plugin metric
+				"agent":       "broker-2",   // a base label
+				"label_agent": "also taken", // the prefixed name is taken too
+				"queue-name":  "mail",
+				"queue_name":  "mail-retry", // the same name once sanitized
+				"tags":        "a,b",
+				"vhost":       "/",
+			},
+		}},
+	}}, base)
+
+	checkGolden(t, e, "collisions.txt")
+}
@@ -0,0 +1,230 @@
+package openmetrics
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Prefix is prepended to every metric name derived from domain.SystemMetrics
+const Prefix = "monitor_"
+
+// AddSystemMetrics adds every section of a SystemMetrics sample, each labelled with base
+func (e *Exposition) AddSystemMetrics(m *domain.SystemMetrics, base Labels) {
+	e.addCPU(m.CPU, base)
+	e.addMemory(m, base)
+	e.addDisks(m.Disk, base)
+	e.addNetwork(m.Network, base)
+
+	p := m.Process
+	e.Gauge(Prefix+"processes", "Number of processes by state", float64(p.Total), base.With("state", "all"))
+	e.Gauge(Prefix+"processes", "", float64(p.Running), base.With("state", "running"))
+	e.Gauge(Prefix+"processes", "", float64(p.Sleeping), base.With("state", "sleeping"))
+	e.Gauge(Prefix+"processes", "", float64(p.Stopped), base.With("state", "stopped"))
+	e.Gauge(Prefix+"processes", "", float64(p.Zombie), base.With("state", "zombie"))
+	e.Gauge(Prefix+"threads", "Number of threads across all processes", float64(p.Threads), base)
+
+	e.Gauge(Prefix+"load1", "1 minute load average", m.Load.Load1, base)
+	e.Gauge(Prefix+"load5", "5 minute load average", m.Load.Load5, base)
+	e.Gauge(Prefix+"load15", "15 minute load average", m.Load.Load15, base)
+
+	for _, s := range m.Temperature.Sensors {
+		e.Gauge(Prefix+"temperature_celsius", "Thermal sensor temperature", s.Temperature, base.With("sensor", s.Name))
+	}
+
+	e.Gauge(Prefix+"uptime_seconds", "Seconds since boot", float64(m.System.Uptime), base)
+	e.Gauge(Prefix+"boot_time_seconds", "Boot time as a Unix timestamp", float64(m.System.BootTime), base)
+	e.Gauge(Prefix+"info", "Host information", 1, base.With(
+		"hostname", m.System.Hostname,
+		"os", m.System.OS,
+		"platform", m.System.Platform,
+		"platform_version", m.System.PlatformVersion,
+		"kernel_version", m.System.KernelVersion,
+		"arch", m.System.KernelArch,
+	))
+
+	e.addPressure(m.Pressure, base)
+	e.addVMStat(m.VMStat, base)
+	e.addContainers(m.Containers, base)
+	e.addServices(m.Services, base)
+	e.addCustom(m.Custom, base)
+	e.addPing(m.Ping, base)
+}
+
+func (e *Exposition) addCPU(cpu domain.CPUMetrics, base Labels) {
+	e.Gauge(Prefix+"cpu_usage_percent", "Total CPU usage", cpu.UsagePercent, base)
+	for i, v := range cpu.PerCore {
+		e.Gauge(Prefix+"cpu_core_usage_percent", "CPU usage per core", v, base.With("core", strconv.Itoa(i)))
+	}
+	e.Gauge(Prefix+"cpu_cores", "Number of physical cores", float64(cpu.Cores), base)
+	e.Gauge(Prefix+"cpu_threads", "Number of logical CPUs", float64(cpu.Threads), base)
+	e.Gauge(Prefix+"cpu_frequency_mhz", "CPU frequency", cpu.Frequency, base)
+}
+
+func (e *Exposition) addMemory(m *domain.SystemMetrics, base Labels) {
+	mem := m.Memory
+	e.Gauge(Prefix+"memory_total_bytes", "Total memory", float64(mem.Total), base)
+	e.Gauge(Prefix+"memory_available_bytes", "Available memory", float64(mem.Available), base)
+	e.Gauge(Prefix+"memory_used_bytes", "Used memory", float64(mem.Used), base)
+	e.Gauge(Prefix+"memory_free_bytes", "Free memory", float64(mem.Free), base)
+	e.Gauge(Prefix+"memory_cached_bytes", "Page cache", float64(mem.Cached), base)
+	e.Gauge(Prefix+"memory_buffers_bytes", "Buffers", float64(mem.Buffers), base)
+	e.Gauge(Prefix+"memory_used_percent", "Used memory percentage", mem.UsedPercent, base)
+	e.Gauge(Prefix+"swap_total_bytes", "Total swap", float64(mem.Swap.Total), base)
+	e.Gauge(Prefix+"swap_used_bytes", "Used swap", float64(mem.Swap.Used), base)
+	e.Gauge(Prefix+"swap_free_bytes", "Free swap", float64(mem.Swap.Free), base)
+
+	if d := m.MemoryDetail; d != nil {
+		e.Gauge(Prefix+"memory_dirty_bytes", "Memory waiting to be written back to disk", float64(d.Dirty), base)
+		e.Gauge(Prefix+"memory_writeback_bytes", "Memory being written back to disk", float64(d.Writeback), base)
+		e.Gauge(Prefix+"memory_slab_bytes", "Kernel slab memory", float64(d.Slab), base)
+		e.Gauge(Prefix+"memory_shmem_bytes", "Shared memory", float64(d.Shmem), base)
+		e.Gauge(Prefix+"memory_committed_as_bytes", "Memory committed to processes", float64(d.CommittedAS), base)
+		e.Gauge(Prefix+"memory_commit_limit_bytes", "Commit limit", float64(d.CommitLimit), base)
+		e.Gauge(Prefix+"memory_hugepages", "Total huge pages", float64(d.HugePagesTotal), base)
+		e.Gauge(Prefix+"memory_hugepages_free", "Free huge pages", float64(d.HugePagesFree), base)
+	}
+}
+
+func (e *Exposition) addDisks(disks []domain.DiskMetrics, base Labels) {
+	for _, d := range disks {
+		l := base.With("mount", d.MountPoint, "device", d.Device, "fstype", d.FsType)
+		e.Gauge(Prefix+"disk_total_bytes", "Filesystem size", float64(d.Total), l)
+		e.Gauge(Prefix+"disk_used_bytes", "Filesystem used space", float64(d.Used), l)
+		e.Gauge(Prefix+"disk_free_bytes", "Filesystem free space", float64(d.Free), l)
+		e.Gauge(Prefix+"disk_used_percent", "Filesystem used percentage", d.UsedPercent, l)
+		e.Gauge(Prefix+"disk_inodes", "Filesystem inodes", float64(d.InodesTotal), l)
+		e.Gauge(Prefix+"disk_inodes_free", "Filesystem free inodes", float64(d.InodesFree), l)
+	}
+}
+
+func (e *Exposition) addNetwork(n domain.NetworkMetrics, base Labels) {
+	for _, iface := range n.Interfaces {
+		l := base.With("interface", iface.Name)
+		e.Counter(Prefix+"network_transmit_bytes", "Bytes sent per interface", float64(iface.BytesSent), l)
+		e.Counter(Prefix+"network_receive_bytes", "Bytes received per interface", float64(iface.BytesRecv), l)
+		e.Counter(Prefix+"network_transmit_packets", "Packets sent per interface", float64(iface.PacketsSent), l)
+		e.Counter(Prefix+"network_receive_packets", "Packets received per interface", float64(iface.PacketsRecv), l)
+	}
+	e.Counter(Prefix+"network_receive_errors", "Receive errors across all interfaces", float64(n.ErrorsIn), base)
+	e.Counter(Prefix+"network_transmit_errors", "Transmit errors across all interfaces", float64(n.ErrorsOut), base)
+	e.Counter(Prefix+"network_receive_drops", "Dropped inbound packets across all interfaces", float64(n.DropIn), base)
+	e.Counter(Prefix+"network_transmit_drops", "Dropped outbound packets across all interfaces", float64(n.DropOut), base)
+
+	c := n.Connections
+	e.Gauge(Prefix+"network_connections", "Connections by state", float64(c.Established), base.With("state", "established"))
+	e.Gauge(Prefix+"network_connections", "", float64(c.Listen), base.With("state", "listen"))
+	e.Gauge(Prefix+"network_connections", "", float64(c.TimeWait), base.With("state", "time_wait"))
+	e.Gauge(Prefix+"network_connections", "", float64(c.CloseWait), base.With("state", "close_wait"))
+}
+
+func (e *Exposition) addPressure(p *domain.PressureMetrics, base Labels) {
+	if p == nil {
+		return
+	}
+	add := func(resource string, stat *domain.PressureStat) {
+		if stat == nil {
+			return
+		}
+		kinds := []struct {
+			kind string
+			avg  *domain.PressureAvg
+		}{{"some", &stat.Some}, {"full", stat.Full}}
+		for _, k := range kinds {
+			if k.avg == nil {
+				continue
+			}
+			l := base.With("resource", resource, "kind", k.kind)
+			e.Counter(Prefix+"pressure_stalled_seconds", "Total time tasks were stalled on a resource", float64(k.avg.Total)/1e6, l)
+			e.Gauge(Prefix+"pressure_avg_percent", "Share of time stalled over the window", k.avg.Avg10, l.With("window", "10s"))
+			e.Gauge(Prefix+"pressure_avg_percent", "", k.avg.Avg60, l.With("window", "60s"))
+			e.Gauge(Prefix+"pressure_avg_percent", "", k.avg.Avg300, l.With("window", "300s"))
+		}
+	}
+	add("cpu", p.CPU)
+	add("memory", p.Memory)
+	add("io", p.IO)
+}
+
+func (e *Exposition) addVMStat(v *domain.VMStatMetrics, base Labels) {
+	if v == nil {
+		return
+	}
+	e.Counter(Prefix+"vmstat_page_faults", "Page faults", float64(v.PageFaults), base)
+	e.Counter(Prefix+"vmstat_major_page_faults", "Major page faults", float64(v.MajorPageFaults), base)
+	e.Counter(Prefix+"vmstat_swap_in_pages", "Pages swapped in", float64(v.SwapIn), base)
+	e.Counter(Prefix+"vmstat_swap_out_pages", "Pages swapped out", float64(v.SwapOut), base)
+	e.Counter(Prefix+"vmstat_oom_kills", "Processes killed by the OOM killer", float64(v.OOMKills), base)
+}
+
+func (e *Exposition) addContainers(containers []domain.ContainerMetrics, base Labels) {
+	for _, c := range containers {
+		l := base.With("container_id", c.ID, "container", c.Name, "image", c.Image)
+		e.Counter(Prefix+"container_cpu_usage_seconds", "Container CPU time", float64(c.CPU.UsageNanos)/1e9, l)
+		e.Gauge(Prefix+"container_cpu_usage_percent", "Container CPU usage since the previous sample", c.CPU.UsagePercent, l)
+		e.Counter(Prefix+"container_cpu_throttled_periods", "CFS periods the container was throttled", float64(c.CPU.ThrottledPeriods), l)
+		e.Counter(Prefix+"container_cpu_throttled_seconds", "Time the container was throttled", float64(c.CPU.ThrottledNanos)/1e9, l)
+		e.Gauge(Prefix+"container_memory_usage_bytes", "Container memory usage", float64(c.Memory.Usage), l)
+		e.Gauge(Prefix+"container_memory_limit_bytes", "Container memory limit, 0 when unlimited", float64(c.Memory.Limit), l)
+		e.Counter(Prefix+"container_oom_kills", "Container OOM kills", float64(c.Memory.OOMKills), l)
+		e.Counter(Prefix+"container_blkio_read_bytes", "Bytes read by the container", float64(c.BlockIO.ReadBytes), l)
+		e.Counter(Prefix+"container_blkio_write_bytes", "Bytes written by the container", float64(c.BlockIO.WriteBytes), l)
+		e.Gauge(Prefix+"container_pids", "Tasks in the container", float64(c.PIDs.Current), l)
+	}
+}
+
+func (e *Exposition) addServices(services []domain.ServiceStatus, base Labels) {
+	for _, s := range services {
+		l := base.With("unit", s.Unit)
+		up := 0.0
+		if s.ActiveState == "active" {
+			up = 1
+		}
+		e.Gauge(Prefix+"service_up", "Whether the systemd unit is active", up, l)
+		e.Gauge(Prefix+"service_state", "Current systemd unit state", 1, l.With("state", s.ActiveState, "sub_state", s.SubState))
+		e.Counter(Prefix+"service_restarts", "Automatic restarts of the unit", float64(s.Restarts), l)
+		e.Gauge(Prefix+"service_memory_bytes", "Unit memory usage", float64(s.MemoryBytes), l)
+		e.Counter(Prefix+"service_cpu_seconds", "Unit CPU time", float64(s.CPUUsageNanos)/1e9, l)
+	}
+}
+
+func (e *Exposition) addCustom(checks []domain.CustomCheck, base Labels) {
+	for _, c := range checks {
+		l := base.With("plugin", c.Plugin)
+		e.Gauge(Prefix+"custom_check_status", "Plugin exit code (0 ok, 1 warning, 2 critical, 3 unknown)", float64(c.ExitCode), l)
+		for _, m := range c.Metrics {
+			keys := make([]string, 0, len(m.Labels))
+			for k := range m.Labels {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			// A sample must not repeat a label name, so plugin labels named like one already
+			// set, such as plugin or agent, are prefixed with label_
+			ml := l
+			for _, k := range keys {
+				name := SanitizeName(k)
+				for ml.Has(name) {
+					name = "label_" + name
+				}
+				ml = ml.With(name, m.Labels[k])
+			}
+			e.Gauge(Prefix+"custom_"+SanitizeName(m.Name), "Custom plugin metric", m.Value, ml)
+		}
+	}
+}
+
+func (e *Exposition) addPing(results []domain.PingResult, base Labels) {
+	for _, r := range results {
+		l := base.With("target", r.Target, "method", r.Method)
+		e.Gauge(Prefix+"ping_loss_ratio", "Share of echo requests lost in the last ping run", r.LossPercent/100, l)
+		if r.Received == 0 {
+			continue
+		}
+		e.Gauge(Prefix+"ping_rtt_min_seconds", "Lowest round trip of the last ping run", r.MinMs/1000, l)
+		e.Gauge(Prefix+"ping_rtt_avg_seconds", "Average round trip of the last ping run", r.AvgMs/1000, l)
+		e.Gauge(Prefix+"ping_rtt_max_seconds", "Highest round trip of the last ping run", r.MaxMs/1000, l)
+		e.Gauge(Prefix+"ping_jitter_seconds", "Mean difference between consecutive round trips of the last ping run", r.JitterMs/1000, l)
+	}
+}
@@ -0,0 +1,293 @@
+// Package otlp decodes OTLP/HTTP metrics export requests in both the
+// protobuf and JSON encodings. Gauge and Sum data points are supported;
+// histograms and summaries are skipped.
+package otlp
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protowalk"
+)
+
+// Content types defined by the OTLP/HTTP specification
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// HostAttribute is the resource attribute used to identify the source host
+const HostAttribute = "host.name"
+
+// resource is one ResourceMetrics entry reduced to what the server needs
+type resource struct {
+	attributes map[string]string
+	series     []domain.Series
+}
+
+// DecodeProtobuf decodes an ExportMetricsServiceRequest protobuf message
+func DecodeProtobuf(data []byte) ([]domain.SeriesBatch, error) {
+	var resources []resource
+	err := protowalk.Walk(data, func(f protowalk.Field) error {
+		if f.Num != 1 || f.Type != protowire.BytesType { // resource_metrics
+			return nil
+		}
+		r, err := decodeResourceMetrics(f.Bytes)
+		if err != nil {
+			return err
+		}
+		resources = append(resources, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toBatches(resources), nil
+}
+
+func decodeResourceMetrics(data []byte) (resource, error) {
+	r := resource{attributes: make(map[string]string)}
+	err := protowalk.Walk(data, func(f protowalk.Field) error {
+		switch f.Num {
+		case 1: // resource
+			return protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				if f.Num == 1 { // attributes
+					return decodeKeyValue(f.Bytes, r.attributes)
+				}
+				return nil
+			})
+		case 2: // scope_metrics
+			return protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				if f.Num != 2 { // metrics
+					return nil
+				}
+				series, err := decodeMetric(f.Bytes)
+				r.series = append(r.series, series...)
+				return err
+			})
+		}
+		return nil
+	})
+	return r, err
+}
+
+func decodeMetric(data []byte) ([]domain.Series, error) {
+	var name string
+	var points [][]byte
+	err := protowalk.Walk(data, func(f protowalk.Field) error {
+		switch f.Num {
+		case 1:
+			name = string(f.Bytes)
+		case 5, 7: // gauge, sum
+			return protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				if f.Num == 1 { // data_points
+					points = append(points, f.Bytes)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make([]domain.Series, 0, len(points))
+	for _, p := range points {
+		s := domain.Series{Name: name, Labels: make(map[string]string)}
+		err := protowalk.Walk(p, func(f protowalk.Field) error {
+			switch f.Num {
+			case 3: // time_unix_nano
+				s.Timestamp = time.Unix(0, int64(f.Raw))
+			case 4: // as_double
+				s.Value = f.Double()
+			case 6: // as_int
+				s.Value = float64(int64(f.Raw))
+			case 7: // attributes
+				return decodeKeyValue(f.Bytes, s.Labels)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// decodeKeyValue decodes a KeyValue into attrs, stringifying scalar values
+func decodeKeyValue(data []byte, attrs map[string]string) error {
+	var key, value string
+	err := protowalk.Walk(data, func(f protowalk.Field) error {
+		switch f.Num {
+		case 1:
+			key = string(f.Bytes)
+		case 2: // AnyValue
+			return protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				switch f.Num {
+				case 1:
+					value = string(f.Bytes)
+				case 2:
+					value = strconv.FormatBool(f.Raw != 0)
+				case 3:
+					value = strconv.FormatInt(int64(f.Raw), 10)
+				case 4:
+					value = strconv.FormatFloat(math.Float64frombits(f.Raw), 'g', -1, 64)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err == nil && key != "" {
+		attrs[key] = value
+	}
+	return err
+}
+
+// JSON encoding, see https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type jsonRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []jsonKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name  string       `json:"name"`
+				Gauge *jsonNumbers `json:"gauge"`
+				Sum   *jsonNumbers `json:"sum"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+type jsonNumbers struct {
+	DataPoints []struct {
+		Attributes   []jsonKeyValue `json:"attributes"`
+		TimeUnixNano jsonInt64      `json:"timeUnixNano"`
+		AsDouble     *float64       `json:"asDouble"`
+		AsInt        *jsonInt64     `json:"asInt"`
+	} `json:"dataPoints"`
+}
+
+type jsonKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string    `json:"stringValue"`
+		BoolValue   *bool      `json:"boolValue"`
+		IntValue    *jsonInt64 `json:"intValue"`
+		DoubleValue *float64   `json:"doubleValue"`
+	} `json:"value"`
+}
+
+// jsonInt64 accepts int64 values encoded either as JSON numbers or strings
+type jsonInt64 int64
+
+func (i *jsonInt64) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		data = []byte(s)
+	}
+	v, err := strconv.ParseInt(string(data), 10, 64)
+	*i = jsonInt64(v)
+	return err
+}
+
+func (kv jsonKeyValue) String() string {
+	v := kv.Value
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	}
+	return ""
+}
+
+// DecodeJSON decodes an ExportMetricsServiceRequest in the OTLP JSON encoding
+func DecodeJSON(data []byte) ([]domain.SeriesBatch, error) {
+	var req jsonRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+
+	var resources []resource
+	for _, rm := range req.ResourceMetrics {
+		r := resource{attributes: make(map[string]string)}
+		for _, kv := range rm.Resource.Attributes {
+			r.attributes[kv.Key] = kv.String()
+		}
+
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				numbers := m.Gauge
+				if numbers == nil {
+					numbers = m.Sum
+				}
+				if numbers == nil {
+					continue
+				}
+				for _, dp := range numbers.DataPoints {
+					s := domain.Series{
+						Name:   m.Name,
+						Labels: make(map[string]string, len(dp.Attributes)),
+					}
+					if dp.TimeUnixNano != 0 {
+						s.Timestamp = time.Unix(0, int64(dp.TimeUnixNano))
+					}
+					switch {
+					case dp.AsDouble != nil:
+						s.Value = *dp.AsDouble
+					case dp.AsInt != nil:
+						s.Value = float64(*dp.AsInt)
+					}
+					for _, kv := range dp.Attributes {
+						s.Labels[kv.Key] = kv.String()
+					}
+					r.series = append(r.series, s)
+				}
+			}
+		}
+		resources = append(resources, r)
+	}
+
+	return toBatches(resources), nil
+}
+
+// toBatches groups resources by host; resources sharing a host are merged
+func toBatches(resources []resource) []domain.SeriesBatch {
+	index := make(map[string]int)
+	var batches []domain.SeriesBatch
+	for _, r := range resources {
+		source := r.attributes[HostAttribute]
+		if source == "" {
+			source = r.attributes["service.instance.id"]
+		}
+		if source == "" {
+			source = r.attributes["service.name"]
+		}
+
+		i, ok := index[source]
+		if !ok {
+			i = len(batches)
+			index[source] = i
+			batches = append(batches, domain.SeriesBatch{Source: source})
+		}
+		batches[i].Series = append(batches[i].Series, r.series...)
+	}
+	return batches
+}
@@ -0,0 +1,362 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// DefaultPingPort is connected to by the TCP method when a target sets no port
+const DefaultPingPort = 80
+
+// pingSpacing separates the echo requests of a run
+const pingSpacing = time.Second
+
+// pingPayload is the data carried by echo requests
+var pingPayload = []byte("realtime-monitoring-ping")
+
+// nextEchoID gives each raw ICMP socket its own echo identifier, since raw sockets receive
+// the replies to every socket of the host
+var nextEchoID atomic.Uint32
+
+func init() {
+	nextEchoID.Store(uint32(os.Getpid()))
+}
+
+// Ping sends count echo requests to a target, one per second, and reports the loss and
+// round trips. ICMP goes over an unprivileged ICMP socket where the kernel allows one, a raw
+// socket otherwise, and falls back to timing TCP connects when neither can be opened.
+func Ping(ctx context.Context, target domain.PingTarget, count int, timeout time.Duration) domain.PingResult {
+	result := domain.PingResult{Target: target.Label(), Host: target.Host, Method: domain.PingMethodTCP, CheckedAt: time.Now()}
+	if target.Method != domain.PingMethodTCP {
+		result.Method = domain.PingMethodICMP
+	}
+
+	ip, err := resolveIP(ctx, target.Host)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Address = ip.String()
+
+	var echo func(seq int) (time.Duration, error)
+	if target.Method != domain.PingMethodTCP {
+		if conn, err := listenICMP(ip, false); err == nil {
+			defer conn.Close()
+			result.Method = domain.PingMethodICMP
+			echo = func(seq int) (time.Duration, error) {
+				return conn.echo(ctx, seq, timeout)
+			}
+		}
+	}
+	if echo == nil {
+		result.Method = domain.PingMethodTCP
+		port := target.Port
+		if port == 0 {
+			port = DefaultPingPort
+		}
+		addr := net.JoinHostPort(ip.String(), fmt.Sprint(port))
+		echo = func(int) (time.Duration, error) {
+			return connectTime(ctx, addr, timeout)
+		}
+	}
+
+	var rtts []time.Duration
+	start := time.Now()
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			wait := time.NewTimer(time.Until(start.Add(time.Duration(seq) * pingSpacing)))
+			select {
+			case <-wait.C:
+			case <-ctx.Done():
+				wait.Stop()
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		rtt, err := echo(seq)
+		result.Sent++
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		rtts = append(rtts, rtt)
+	}
+
+	pingStats(&result, rtts)
+	return result
+}
+
+// pingStats fills in the loss and round trip statistics; the error of a lost request is
+// only kept when nothing answered
+func pingStats(result *domain.PingResult, rtts []time.Duration) {
+	result.Received = len(rtts)
+	if result.Sent > 0 {
+		result.LossPercent = float64(result.Sent-result.Received) / float64(result.Sent) * 100
+	}
+	if len(rtts) == 0 {
+		return
+	}
+	result.Error = ""
+
+	lowest, highest, sum, jitter := math.Inf(1), 0.0, 0.0, 0.0
+	for i, rtt := range rtts {
+		ms := milliseconds(rtt)
+		lowest = math.Min(lowest, ms)
+		highest = math.Max(highest, ms)
+		sum += ms
+		if i > 0 {
+			jitter += math.Abs(ms - milliseconds(rtts[i-1]))
+		}
+	}
+	result.MinMs = lowest
+	result.MaxMs = highest
+	result.AvgMs = sum / float64(len(rtts))
+	if len(rtts) > 1 {
+		result.JitterMs = jitter / float64(len(rtts)-1)
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// connectTime times a TCP connect. A refused connection still answered, so it counts.
+func connectTime(ctx context.Context, addr string, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	rtt := time.Since(start)
+	if err == nil {
+		conn.Close()
+		return rtt, nil
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return rtt, nil
+	}
+	return 0, err
+}
+
+// resolveIP resolves a host, preferring IPv4
+func resolveIP(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no address for %s", host)
+	}
+	return ips[0], nil
+}
+
+// icmpConn sends echo requests to one address and reads what comes back
+type icmpConn struct {
+	conn  *icmp.PacketConn
+	dst   net.IP
+	addr  net.Addr
+	v6    bool
+	raw   bool // raw sockets keep the echo identifier and receive the replies to other sockets
+	id    int
+	proto int
+	buf   []byte
+}
+
+// icmpReply is an echo reply, or an ICMP error about one of our echo requests
+type icmpReply struct {
+	seq  int
+	peer net.IP
+	at   time.Time
+	echo bool // an echo reply rather than an error
+	// final is set for echo replies and unreachable errors: probes need not go further
+	final bool
+}
+
+// listenICMP opens an ICMP socket for the family of ip: an unprivileged one first unless
+// rawOnly is set, as traceroute needs the ICMP errors only raw sockets receive
+func listenICMP(ip net.IP, rawOnly bool) (*icmpConn, error) {
+	v6 := ip.To4() == nil
+	networks := []string{"udp4", "ip4:icmp"}
+	address, proto := "0.0.0.0", 1
+	if v6 {
+		networks = []string{"udp6", "ip6:ipv6-icmp"}
+		address, proto = "::", 58
+	}
+	if rawOnly {
+		networks = networks[1:]
+	}
+
+	var err error
+	for _, network := range networks {
+		var conn *icmp.PacketConn
+		if conn, err = icmp.ListenPacket(network, address); err != nil {
+			continue
+		}
+		c := &icmpConn{
+			conn:  conn,
+			dst:   ip,
+			v6:    v6,
+			raw:   network != networks[0] || rawOnly,
+			id:    int(nextEchoID.Add(1) & 0xffff),
+			proto: proto,
+			buf:   make([]byte, 1500),
+		}
+		if c.raw {
+			c.addr = &net.IPAddr{IP: ip}
+		} else {
+			c.addr = &net.UDPAddr{IP: ip}
+		}
+		return c, nil
+	}
+	return nil, err
+}
+
+func (c *icmpConn) Close() error {
+	return c.conn.Close()
+}
+
+// setTTL sets the TTL (hop limit) of the following requests
+func (c *icmpConn) setTTL(ttl int) error {
+	if c.v6 {
+		return c.conn.IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return c.conn.IPv4PacketConn().SetTTL(ttl)
+}
+
+// send sends an echo request
+func (c *icmpConn) send(seq int) error {
+	var kind icmp.Type = ipv4.ICMPTypeEcho
+	if c.v6 {
+		kind = ipv6.ICMPTypeEchoRequest
+	}
+	msg := icmp.Message{Type: kind, Body: &icmp.Echo{ID: c.id, Seq: seq, Data: pingPayload}}
+	data, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.WriteTo(data, c.addr)
+	return err
+}
+
+// echo sends an echo request and waits for its reply
+func (c *icmpConn) echo(ctx context.Context, seq int, timeout time.Duration) (time.Duration, error) {
+	sent := time.Now()
+	if err := c.send(seq); err != nil {
+		return 0, err
+	}
+
+	deadline := sent.Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	for {
+		reply, err := c.read(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return 0, fmt.Errorf("no reply within %s", timeout)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if reply.seq == seq && reply.echo && reply.peer.Equal(c.dst) {
+			return reply.at.Sub(sent), nil
+		}
+	}
+}
+
+// read returns the next reply to one of our requests
+func (c *icmpConn) read(deadline time.Time) (icmpReply, error) {
+	if err := c.conn.SetReadDeadline(deadline); err != nil {
+		return icmpReply{}, err
+	}
+
+	for {
+		n, peer, err := c.conn.ReadFrom(c.buf)
+		if err != nil {
+			return icmpReply{}, err
+		}
+		reply := icmpReply{peer: addrIP(peer), at: time.Now()}
+
+		msg, err := icmp.ParseMessage(c.proto, c.buf[:n])
+		if err != nil {
+			continue
+		}
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if msg.Type != ipv4.ICMPTypeEchoReply && msg.Type != ipv6.ICMPTypeEchoReply {
+				continue
+			}
+			if c.raw && body.ID != c.id {
+				continue
+			}
+			reply.seq, reply.echo, reply.final = body.Seq, true, true
+		case *icmp.TimeExceeded:
+			seq, ok := c.quoted(body.Data)
+			if !ok {
+				continue
+			}
+			reply.seq = seq
+		case *icmp.DstUnreach:
+			seq, ok := c.quoted(body.Data)
+			if !ok {
+				continue
+			}
+			reply.seq, reply.final = seq, true
+		default:
+			continue
+		}
+		return reply, nil
+	}
+}
+
+// quoted finds our echo request in the datagram quoted by an ICMP error and returns its sequence
+func (c *icmpConn) quoted(data []byte) (int, bool) {
+	header := 40
+	if !c.v6 {
+		if len(data) < 1 {
+			return 0, false
+		}
+		header = int(data[0]&0x0f) << 2
+	}
+	if len(data) < header+8 {
+		return 0, false
+	}
+	echo := data[header:]
+	id := int(echo[4])<<8 | int(echo[5])
+	if c.raw && id != c.id {
+		return 0, false
+	}
+	return int(echo[6])<<8 | int(echo[7]), true
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	return nil
+}
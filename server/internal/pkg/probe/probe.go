@@ -0,0 +1,288 @@
+// Package probe runs synthetic checks of HTTP, TCP, DNS and TLS endpoints. It is shared by
+// the server and the agents, which run the checks assigned to them.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Defaults applied to options left unset
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultMinDaysRemaining = 14
+)
+
+// maxBody limits the response body read for BodyMatch
+const maxBody = 1 << 20
+
+// Run runs a check once, within its timeout, and reports the outcome
+func Run(ctx context.Context, spec domain.CheckSpec) domain.ProbeResult {
+	timeout := spec.Timeout()
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := domain.ProbeResult{CheckedAt: time.Now()}
+	start := time.Now()
+
+	var err error
+	switch spec.Type {
+	case domain.CheckTypeHTTP:
+		err = runHTTP(ctx, spec, &result)
+	case domain.CheckTypeTCP:
+		err = runTCP(ctx, spec)
+	case domain.CheckTypeDNS:
+		err = runDNS(ctx, spec, &result)
+	case domain.CheckTypeTLS:
+		err = runTLS(ctx, spec, &result)
+	default:
+		err = fmt.Errorf("unknown check type %q", spec.Type)
+	}
+
+	result.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		result.Status = domain.CheckStatusDown
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = domain.CheckStatusUp
+	return result
+}
+
+// ValidateBodyMatch checks a BodyMatch expression compiles
+func ValidateBodyMatch(expr string) error {
+	_, err := regexp.Compile(expr)
+	return err
+}
+
+func runHTTP(ctx context.Context, spec domain.CheckSpec, result *domain.ProbeResult) error {
+	opts := spec.HTTP
+	if opts == nil {
+		opts = &domain.HTTPCheckOptions{}
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if opts.Body != "" {
+		body = strings.NewReader(opts.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, spec.Target, body)
+	if err != nil {
+		return err
+	}
+	for key, value := range opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: opts.SkipTLSVerify},
+			DisableKeepAlives: true,
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBody))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	latency := time.Since(start)
+	result.StatusCode = resp.StatusCode
+
+	if len(opts.ExpectedStatus) > 0 {
+		if !slices.Contains(opts.ExpectedStatus, resp.StatusCode) {
+			return fmt.Errorf("status %d, expected %v", resp.StatusCode, opts.ExpectedStatus)
+		}
+	} else if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if opts.BodyMatch != "" {
+		re, err := regexp.Compile(opts.BodyMatch)
+		if err != nil {
+			return fmt.Errorf("invalid body_match: %w", err)
+		}
+		if !re.Match(content) {
+			return fmt.Errorf("body does not match %q", opts.BodyMatch)
+		}
+	}
+
+	if opts.MaxLatencyMs > 0 && latency > time.Duration(opts.MaxLatencyMs)*time.Millisecond {
+		return fmt.Errorf("latency %dms exceeds %dms", latency.Milliseconds(), opts.MaxLatencyMs)
+	}
+	return nil
+}
+
+func runTCP(ctx context.Context, spec domain.CheckSpec) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", spec.Target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func runDNS(ctx context.Context, spec domain.CheckSpec, result *domain.ProbeResult) error {
+	opts := spec.DNS
+	if opts == nil {
+		opts = &domain.DNSCheckOptions{}
+	}
+
+	resolver := net.DefaultResolver
+	if opts.Resolver != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, opts.Resolver)
+			},
+		}
+	}
+
+	answers, err := lookup(ctx, resolver, opts.RecordType, spec.Target)
+	if err != nil {
+		return err
+	}
+	result.Answers = answers
+	if len(answers) == 0 {
+		return fmt.Errorf("no %s records", recordType(opts.RecordType))
+	}
+
+	for _, expected := range opts.Expected {
+		if !slices.Contains(answers, normalizeAnswer(expected)) {
+			return fmt.Errorf("answer %v lacks %q", answers, expected)
+		}
+	}
+	return nil
+}
+
+// lookup resolves the records of a type, normalized for comparison
+func lookup(ctx context.Context, resolver *net.Resolver, kind, name string) ([]string, error) {
+	var answers []string
+	switch recordType(kind) {
+	case "A", "AAAA":
+		network := "ip4"
+		if kind == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, cname)
+	case "MX":
+		records, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range records {
+			answers = append(answers, mx.Host)
+		}
+	case "TXT":
+		records, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return records, nil
+	case "NS":
+		records, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range records {
+			answers = append(answers, ns.Host)
+		}
+	default:
+		return nil, fmt.Errorf("unknown record type %q", kind)
+	}
+
+	for i := range answers {
+		answers[i] = normalizeAnswer(answers[i])
+	}
+	return answers, nil
+}
+
+func recordType(kind string) string {
+	if kind == "" {
+		return "A"
+	}
+	return kind
+}
+
+// normalizeAnswer lowercases names and drops their trailing dot
+func normalizeAnswer(answer string) string {
+	return strings.TrimSuffix(strings.ToLower(answer), ".")
+}
+
+func runTLS(ctx context.Context, spec domain.CheckSpec, result *domain.ProbeResult) error {
+	opts := spec.TLS
+	if opts == nil {
+		opts = &domain.TLSCheckOptions{}
+	}
+	minDays := opts.MinDaysRemaining
+	if minDays <= 0 {
+		minDays = DefaultMinDaysRemaining
+	}
+
+	serverName := opts.ServerName
+	if serverName == "" {
+		host, _, err := net.SplitHostPort(spec.Target)
+		if err != nil {
+			return err
+		}
+		serverName = host
+	}
+
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: serverName, InsecureSkipVerify: opts.SkipVerify}}
+	conn, err := dialer.DialContext(ctx, "tcp", spec.Target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+	expires := certs[0].NotAfter
+	result.CertExpiresAt = &expires
+
+	remaining := time.Until(expires)
+	if remaining <= 0 {
+		return fmt.Errorf("certificate expired at %s", expires.Format(time.RFC3339))
+	}
+	if remaining < time.Duration(minDays)*24*time.Hour {
+		return fmt.Errorf("certificate expires in %d days, at %s", int(remaining.Hours()/24), expires.Format(time.RFC3339))
+	}
+	return nil
+}
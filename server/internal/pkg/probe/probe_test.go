@@ -0,0 +1,222 @@
+package probe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestRunHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Write([]byte(r.Method + " " + r.Header.Get("X-Token") + " " + string(body)))
+		case "/missing":
+			http.NotFound(w, r)
+		case "/error":
+			http.Error(w, "boom", http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "/echo", http.StatusFound)
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		default:
+			w.Write([]byte(`{"status":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		path       string
+		options    *domain.HTTPCheckOptions
+		err        string // the error of a down result, up when empty
+		statusCode int
+	}{
+		{name: "ok", path: "/", statusCode: 200},
+		{name: "redirect followed", path: "/redirect", statusCode: 200},
+		{name: "client error", path: "/missing", err: "status 404", statusCode: 404},
+		{name: "server error", path: "/error", err: "status 500", statusCode: 500},
+		{name: "expected status", path: "/missing", options: &domain.HTTPCheckOptions{ExpectedStatus: []int{404, 410}}, statusCode: 404},
+		{name: "unexpected status", path: "/", options: &domain.HTTPCheckOptions{ExpectedStatus: []int{204}}, err: "status 200, expected [204]", statusCode: 200},
+		{name: "body match", path: "/", options: &domain.HTTPCheckOptions{BodyMatch: `"status":\s*"ok"`}, statusCode: 200},
+		{name: "body mismatch", path: "/", options: &domain.HTTPCheckOptions{BodyMatch: `"status":\s*"degraded"`}, err: "body does not match", statusCode: 200},
+		{name: "invalid body match", path: "/", options: &domain.HTTPCheckOptions{BodyMatch: `(`}, err: "invalid body_match", statusCode: 200},
+		{name: "method, headers and body", path: "/echo", options: &domain.HTTPCheckOptions{
+			Method: http.MethodPost, Headers: map[string]string{"X-Token": "secret"}, Body: "ping", BodyMatch: "^POST secret ping$",
+		}, statusCode: 200},
+		{name: "too slow", path: "/slow", options: &domain.HTTPCheckOptions{MaxLatencyMs: 10}, err: "exceeds 10ms", statusCode: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeHTTP, Target: server.URL + tt.path, HTTP: tt.options})
+			assertResult(t, result, tt.err)
+			if result.StatusCode != tt.statusCode {
+				t.Errorf("StatusCode = %d, want %d", result.StatusCode, tt.statusCode)
+			}
+		})
+	}
+}
+
+func TestRunHTTPTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	start := time.Now()
+	result := Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeHTTP, Target: server.URL, TimeoutSeconds: 1})
+	assertResult(t, result, "deadline exceeded")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s with a timeout of 1s", elapsed)
+	}
+}
+
+func TestRunHTTPS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The test server's certificate is signed by no trusted authority
+	result := Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeHTTP, Target: server.URL})
+	assertResult(t, result, "certificate")
+
+	result = Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeHTTP, Target: server.URL, HTTP: &domain.HTTPCheckOptions{SkipTLSVerify: true}})
+	assertResult(t, result, "")
+}
+
+func TestRunTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	assertResult(t, Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeTCP, Target: addr}), "")
+
+	// Nothing listens on the port once the listener is closed
+	listener.Close()
+	assertResult(t, Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeTCP, Target: addr}), "refused")
+}
+
+func TestRunTLS(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		notAfter time.Time
+		options  *domain.TLSCheckOptions
+		err      string
+	}{
+		{name: "valid", notAfter: now.Add(90 * 24 * time.Hour), options: &domain.TLSCheckOptions{SkipVerify: true}},
+		{name: "expiring within the default 14 days", notAfter: now.Add(10 * 24 * time.Hour), options: &domain.TLSCheckOptions{SkipVerify: true}, err: "certificate expires in 9 days"},
+		{name: "expiring within min_days_remaining", notAfter: now.Add(40 * 24 * time.Hour), options: &domain.TLSCheckOptions{SkipVerify: true, MinDaysRemaining: 60}, err: "certificate expires in 39 days"},
+		{name: "expired", notAfter: now.Add(-time.Hour), options: &domain.TLSCheckOptions{SkipVerify: true}, err: "certificate expired at"},
+		{name: "untrusted", notAfter: now.Add(90 * 24 * time.Hour), err: "certificate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := serveTLS(t, tt.notAfter)
+			result := Run(context.Background(), domain.CheckSpec{Type: domain.CheckTypeTLS, Target: addr, TLS: tt.options})
+			assertResult(t, result, tt.err)
+			if tt.options == nil {
+				return
+			}
+			if result.CertExpiresAt == nil || !result.CertExpiresAt.Equal(tt.notAfter.Truncate(time.Second)) {
+				t.Errorf("CertExpiresAt = %v, want %s", result.CertExpiresAt, tt.notAfter.Truncate(time.Second))
+			}
+		})
+	}
+}
+
+func TestRunUnknownType(t *testing.T) {
+	assertResult(t, Run(context.Background(), domain.CheckSpec{Type: "smtp", Target: "mail:25"}), `unknown check type "smtp"`)
+}
+
+// assertResult checks a result is down with an error containing err, or up when err is empty
+func assertResult(t *testing.T, result domain.ProbeResult, err string) {
+	t.Helper()
+	if result.CheckedAt.IsZero() || result.LatencyMs < 0 {
+		t.Errorf("CheckedAt = %s, LatencyMs = %v", result.CheckedAt, result.LatencyMs)
+	}
+	if err == "" {
+		if result.Status != domain.CheckStatusUp || result.Error != "" {
+			t.Errorf("Run() = %s (%s), want up", result.Status, result.Error)
+		}
+		return
+	}
+	if result.Status != domain.CheckStatusDown || !strings.Contains(result.Error, err) {
+		t.Errorf("Run() = %s (%s), want down with %q", result.Status, result.Error, err)
+	}
+}
+
+// serveTLS accepts TLS connections on a local port with a self-signed certificate for
+// localhost expiring at notAfter, and returns its address
+func serveTLS(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return net.JoinHostPort("localhost", port)
+}
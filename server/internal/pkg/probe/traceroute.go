@@ -0,0 +1,139 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Defaults of traceroute requests
+const (
+	DefaultMaxHops           = 30
+	DefaultTracerouteProbes  = 3
+	DefaultTracerouteTimeout = time.Second
+)
+
+// tracer sends probes with a TTL and reads the replies to them
+type tracer interface {
+	method() string
+	// probe sends the probe identified by seq with a TTL
+	probe(ttl, seq int) error
+	// read returns the next reply, or os.ErrDeadlineExceeded
+	read(deadline time.Time) (icmpReply, error)
+	Close() error
+}
+
+// Traceroute finds the routers on the way to a host by sending probes with increasing TTLs,
+// until the host answers or the request's max hops. ICMP echo probes go over a raw socket;
+// without the privilege to open one, UDP probes are sent and the ICMP errors they cause are
+// read from the socket's error queue, which Linux allows any user.
+func Traceroute(ctx context.Context, req domain.TracerouteRequest) (*domain.TracerouteResult, error) {
+	maxHops, probes, timeout := req.MaxHops, req.Probes, time.Duration(req.TimeoutMs)*time.Millisecond
+	if maxHops <= 0 {
+		maxHops = DefaultMaxHops
+	}
+	if probes <= 0 {
+		probes = DefaultTracerouteProbes
+	}
+	if timeout <= 0 {
+		timeout = DefaultTracerouteTimeout
+	}
+
+	ip, err := resolveIP(ctx, req.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	var t tracer
+	if conn, err := listenICMP(ip, true); err == nil {
+		t = &icmpTracer{conn}
+	} else if t, err = listenUDPTracer(ip); err != nil {
+		return nil, fmt.Errorf("cannot open a raw ICMP socket or an unprivileged UDP one: %w", err)
+	}
+	defer t.Close()
+
+	result := &domain.TracerouteResult{
+		Target:    req.Target,
+		Address:   ip.String(),
+		Method:    t.method(),
+		Hops:      []domain.TracerouteHop{},
+		StartedAt: time.Now(),
+	}
+	for ttl := 1; ttl <= maxHops && !result.Reached && ctx.Err() == nil; ttl++ {
+		hop, final, err := traceHop(ctx, t, ttl, probes, timeout)
+		if err != nil {
+			return nil, err
+		}
+		result.Hops = append(result.Hops, hop)
+		if final {
+			result.Reached = net.ParseIP(hop.Address).Equal(ip)
+			break
+		}
+	}
+	result.DurationMs = milliseconds(time.Since(result.StartedAt))
+	return result, nil
+}
+
+// traceHop sends the probes of a TTL at once and collects their replies. It reports whether
+// the probes went as far as they can: the host answered or a router found it unreachable.
+func traceHop(ctx context.Context, t tracer, ttl, probes int, timeout time.Duration) (domain.TracerouteHop, bool, error) {
+	hop := domain.TracerouteHop{TTL: ttl, RTTMs: []float64{}}
+
+	// Sequences carry the TTL so late replies to an earlier hop are told apart
+	sent := make(map[int]time.Time, probes)
+	for i := 0; i < probes; i++ {
+		seq := ttl<<4 | i
+		if err := t.probe(ttl, seq); err != nil {
+			return hop, false, err
+		}
+		sent[seq] = time.Now()
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	final := false
+	for len(sent) > 0 {
+		reply, err := t.read(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return hop, false, err
+		}
+		at, ok := sent[reply.seq]
+		if !ok {
+			continue
+		}
+		delete(sent, reply.seq)
+		if hop.Address == "" {
+			hop.Address = reply.peer.String()
+		}
+		hop.RTTMs = append(hop.RTTMs, milliseconds(reply.at.Sub(at)))
+		final = final || reply.final
+	}
+	hop.Lost = len(sent)
+	return hop, final, nil
+}
+
+// icmpTracer traces with ICMP echo requests over a raw socket
+type icmpTracer struct {
+	*icmpConn
+}
+
+func (t *icmpTracer) method() string {
+	return domain.PingMethodICMP
+}
+
+func (t *icmpTracer) probe(ttl, seq int) error {
+	if err := t.setTTL(ttl); err != nil {
+		return err
+	}
+	return t.send(seq)
+}
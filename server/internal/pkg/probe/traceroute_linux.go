@@ -0,0 +1,186 @@
+//go:build linux
+
+package probe
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"golang.org/x/sys/unix"
+)
+
+// tracePortBase is the destination port of the first UDP probe, as in traceroute(8)
+const tracePortBase = 33434
+
+// ICMP types found in the extended errors of the error queue
+const (
+	icmpTimeExceeded   = 11
+	icmpUnreachable    = 3
+	icmp6TimeExceeded  = 3
+	icmp6Unreachable   = 1
+	extendedErrorSize  = 16 // struct sock_extended_err, followed by the offender's address
+	soEEOriginICMP     = 2
+	soEEOriginICMP6    = 3
+	sockaddrFamilySize = 2
+)
+
+// udpTracer traces with UDP probes to unlikely ports. The socket has IP_RECVERR set, so the
+// ICMP errors the probes cause are queued on it with the address of the router that sent them.
+type udpTracer struct {
+	conn *net.UDPConn
+	raw  syscall.RawConn
+	dst  net.IP
+	v6   bool
+	buf  []byte
+	oob  []byte
+}
+
+func listenUDPTracer(ip net.IP) (tracer, error) {
+	v6 := ip.To4() == nil
+	network := "udp4"
+	if v6 {
+		network = "udp6"
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	t := &udpTracer{conn: conn, raw: raw, dst: ip, v6: v6, buf: make([]byte, 1500), oob: make([]byte, 512)}
+	if err := t.setsockopt(unix.IP_RECVERR, unix.IPV6_RECVERR, 1); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *udpTracer) method() string {
+	return domain.PingMethodUDP
+}
+
+func (t *udpTracer) Close() error {
+	return t.conn.Close()
+}
+
+// setsockopt sets the IPv4 or the IPv6 option, depending on the family of the socket
+func (t *udpTracer) setsockopt(opt4, opt6, value int) error {
+	var err error
+	ctrlErr := t.raw.Control(func(fd uintptr) {
+		if t.v6 {
+			err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, opt6, value)
+		} else {
+			err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, opt4, value)
+		}
+	})
+	if ctrlErr != nil {
+		return ctrlErr
+	}
+	return err
+}
+
+// probe sends a datagram whose destination port identifies it
+func (t *udpTracer) probe(ttl, seq int) error {
+	if err := t.setsockopt(unix.IP_TTL, unix.IPV6_UNICAST_HOPS, ttl); err != nil {
+		return err
+	}
+	_, err := t.conn.WriteToUDP(pingPayload, &net.UDPAddr{IP: t.dst, Port: tracePortBase + seq})
+	// An error queued by an earlier probe may be reported on send; the queue still has it
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) {
+		return nil
+	}
+	return err
+}
+
+// read returns the next ICMP error from the socket's error queue
+func (t *udpTracer) read(deadline time.Time) (icmpReply, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return icmpReply{}, err
+	}
+
+	for {
+		var oobn int
+		var from unix.Sockaddr
+		var recvErr error
+		err := t.raw.Read(func(fd uintptr) bool {
+			_, oobn, _, from, recvErr = unix.Recvmsg(int(fd), t.buf, t.oob, unix.MSG_ERRQUEUE)
+			return recvErr != unix.EAGAIN
+		})
+		if err != nil {
+			return icmpReply{}, err
+		}
+		if recvErr != nil {
+			return icmpReply{}, recvErr
+		}
+		at := time.Now()
+
+		port := 0
+		switch sa := from.(type) {
+		case *unix.SockaddrInet4:
+			port = sa.Port
+		case *unix.SockaddrInet6:
+			port = sa.Port
+		}
+		if port < tracePortBase {
+			continue
+		}
+
+		reply, ok := t.parseError(t.oob[:oobn])
+		if !ok {
+			continue
+		}
+		reply.seq, reply.at = port-tracePortBase, at
+		return reply, nil
+	}
+}
+
+// parseError finds the extended error in control messages and returns the router that sent it
+func (t *udpTracer) parseError(oob []byte) (icmpReply, bool) {
+	messages, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return icmpReply{}, false
+	}
+
+	for _, m := range messages {
+		v4 := m.Header.Level == unix.IPPROTO_IP && m.Header.Type == unix.IP_RECVERR
+		v6 := m.Header.Level == unix.IPPROTO_IPV6 && m.Header.Type == unix.IPV6_RECVERR
+		if (!v4 && !v6) || len(m.Data) < extendedErrorSize+sockaddrFamilySize {
+			continue
+		}
+
+		origin, kind := m.Data[4], m.Data[5]
+		var reply icmpReply
+		switch {
+		case origin == soEEOriginICMP && kind == icmpTimeExceeded, origin == soEEOriginICMP6 && kind == icmp6TimeExceeded:
+		case origin == soEEOriginICMP && kind == icmpUnreachable, origin == soEEOriginICMP6 && kind == icmp6Unreachable:
+			reply.final = true
+		default:
+			continue
+		}
+
+		offender := m.Data[extendedErrorSize:]
+		switch binary.NativeEndian.Uint16(offender) {
+		case unix.AF_INET:
+			if len(offender) >= 8 {
+				reply.peer = net.IP(append([]byte(nil), offender[4:8]...))
+			}
+		case unix.AF_INET6:
+			if len(offender) >= 24 {
+				reply.peer = net.IP(append([]byte(nil), offender[8:24]...))
+			}
+		}
+		if reply.peer == nil {
+			continue
+		}
+		return reply, true
+	}
+	return icmpReply{}, false
+}
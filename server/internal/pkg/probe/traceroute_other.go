@@ -0,0 +1,14 @@
+//go:build !linux
+
+package probe
+
+import (
+	"errors"
+	"net"
+)
+
+// listenUDPTracer is unavailable: reading the ICMP errors of UDP probes without privileges
+// relies on the Linux socket error queue
+func listenUDPTracer(ip net.IP) (tracer, error) {
+	return nil, errors.New("unprivileged traceroute is only supported on Linux")
+}
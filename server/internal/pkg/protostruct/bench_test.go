@@ -0,0 +1,95 @@
+package protostruct_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
+)
+
+// Benchmarks of the metrics encodings, reporting the payload size as bytes/sample. They
+// encode testdata/metrics.json, a busy 32-core host, or a sample of a real agent:
+//
+//	go test -run '^$' -bench . -benchmem ./internal/pkg/protostruct ./internal/pkg/compression
+//	curl -s http://agent:9090/metrics > /tmp/sample.json
+//	METRICS_SAMPLE=/tmp/sample.json go test -run '^$' -bench . -benchmem ./internal/pkg/protostruct
+
+// loadSample reads $METRICS_SAMPLE, or the synthetic sample. The file is a /metrics response
+// or bare SystemMetrics JSON.
+func loadSample(b *testing.B, synthetic string) *domain.SystemMetrics {
+	b.Helper()
+	path := os.Getenv("METRICS_SAMPLE")
+	if path == "" {
+		path = synthetic
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var envelope struct {
+		Data *domain.SystemMetrics `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err == nil && envelope.Data != nil {
+		return envelope.Data
+	}
+	var metrics domain.SystemMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		b.Fatalf("%s: %v", path, err)
+	}
+	return &metrics
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	metrics := loadSample(b, "testdata/metrics.json")
+	b.Run("json", func(b *testing.B) {
+		benchmarkEncode(b, func() ([]byte, error) { return json.Marshal(metrics) })
+	})
+	b.Run("protobuf", func(b *testing.B) {
+		benchmarkEncode(b, func() ([]byte, error) { return protostruct.Marshal(metrics) })
+	})
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	metrics := loadSample(b, "testdata/metrics.json")
+	b.Run("json", func(b *testing.B) {
+		data, err := json.Marshal(metrics)
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchmarkDecode(b, data, func(out *domain.SystemMetrics) error { return json.Unmarshal(data, out) })
+	})
+	b.Run("protobuf", func(b *testing.B) {
+		data, err := protostruct.Marshal(metrics)
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchmarkDecode(b, data, func(out *domain.SystemMetrics) error { return protostruct.Unmarshal(data, out) })
+	})
+}
+
+func benchmarkEncode(b *testing.B, encode func() ([]byte, error)) {
+	b.ReportAllocs()
+	var size int
+	for i := 0; i < b.N; i++ {
+		data, err := encode()
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "bytes/sample")
+}
+
+func benchmarkDecode(b *testing.B, data []byte, decode func(*domain.SystemMetrics) error) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var out domain.SystemMetrics
+		if err := decode(&out); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(len(data)), "bytes/sample")
+}
@@ -0,0 +1,402 @@
+// Package protostruct encodes Go structs in protobuf wire format without generated code.
+//
+// Every exported field carries its field number in a proto tag, e.g. `proto:"3"`, which
+// must never change for encoded data to stay readable. Unexported fields and fields tagged
+// json:"-" or proto:"-" are not encoded. Types map as follows:
+//
+//   - bool, uint*: varint; int*: zigzag varint (sint64)
+//   - float64: fixed64; float32: fixed32
+//   - string, []byte: length-delimited
+//   - time.Time: zigzag varint of Unix nanoseconds, 0 for the zero time
+//   - structs: embedded messages; pointers: the pointed-to value, present when non-nil
+//   - slices: repeated fields, packed for numeric and bool elements
+//   - maps: repeated entry messages with the key as field 1 and the value as field 2
+//
+// As in proto3, zero scalars are omitted, and unknown fields are skipped when decoding.
+package protostruct
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protowalk"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ContentType is the media type of protobuf encoded bodies
+const ContentType = "application/x-protobuf"
+
+// ErrMalformed is returned when data is not a valid encoding of the target type
+var ErrMalformed = protowalk.ErrMalformed
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// plan lists the encoded fields of a struct type and their numbers
+type plan struct {
+	fields []planField
+	byNum  map[protowire.Number]int // field index by number
+	err    error
+}
+
+type planField struct {
+	index int
+	num   protowire.Number
+}
+
+var plans sync.Map // reflect.Type -> *plan
+
+func planOf(t reflect.Type) (*plan, error) {
+	if cached, ok := plans.Load(t); ok {
+		p := cached.(*plan)
+		return p, p.err
+	}
+
+	p := &plan{byNum: make(map[protowire.Number]int)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("proto")
+		if !f.IsExported() || f.Tag.Get("json") == "-" || tag == "-" {
+			continue
+		}
+		if !tagged {
+			p.err = fmt.Errorf("protostruct: field %s.%s has no proto tag", t, f.Name)
+			break
+		}
+		n, err := strconv.ParseInt(tag, 10, 32)
+		num := protowire.Number(n)
+		if err != nil || !num.IsValid() {
+			p.err = fmt.Errorf("protostruct: field %s.%s has an invalid proto tag %q", t, f.Name, tag)
+			break
+		}
+		if _, ok := p.byNum[num]; ok {
+			p.err = fmt.Errorf("protostruct: field number %d is used twice in %s", num, t)
+			break
+		}
+		p.fields = append(p.fields, planField{index: i, num: num})
+		p.byNum[num] = i
+	}
+	plans.Store(t, p)
+	return p, p.err
+}
+
+// Marshal encodes v, which must be a struct or a pointer to one
+func Marshal(v interface{}) ([]byte, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("protostruct: cannot marshal %s", rv.Type())
+	}
+	return appendMessage(nil, rv)
+}
+
+// Unmarshal decodes data into v, which must be a pointer to a struct. Decoded fields are
+// merged into v: scalars are overwritten and repeated fields appended to.
+func Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("protostruct: cannot unmarshal into %T", v)
+	}
+	return decodeMessage(data, rv.Elem())
+}
+
+func appendMessage(b []byte, v reflect.Value) ([]byte, error) {
+	p, err := planOf(v.Type())
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range p.fields {
+		if b, err = appendField(b, f.num, v.Field(f.index), false); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendField encodes a single field. Zero scalars are skipped unless present is set,
+// which is the case for pointed-to values, repeated elements and map entries.
+func appendField(b []byte, num protowire.Number, v reflect.Value, present bool) ([]byte, error) {
+	if v.Type() == timeType {
+		t := v.Interface().(time.Time)
+		if t.IsZero() && !present {
+			return b, nil
+		}
+		var nanos int64
+		if !t.IsZero() {
+			nanos = t.UnixNano()
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(nanos)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if v.IsZero() && !present {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, wireType(v.Kind()))
+		return appendScalar(b, v), nil
+
+	case reflect.String:
+		if v.Len() == 0 && !present {
+			return b, nil
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, v.String()), nil
+
+	case reflect.Struct:
+		msg, err := appendMessage(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, msg), nil
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return b, nil
+		}
+		return appendField(b, num, v.Elem(), true)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Len() == 0 && !present {
+				return b, nil
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			return protowire.AppendBytes(b, v.Bytes()), nil
+		}
+		if v.Len() == 0 {
+			return b, nil
+		}
+		if packable(v.Type().Elem()) {
+			var packed []byte
+			for i := 0; i < v.Len(); i++ {
+				packed = appendScalar(packed, v.Index(i))
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			return protowire.AppendBytes(b, packed), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if b, err = appendField(b, num, v.Index(i), true); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+
+	case reflect.Map:
+		// Entries are written in key order so equal maps encode identically
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			entry, err := appendField(nil, 1, key, true)
+			if err != nil {
+				return nil, err
+			}
+			if entry, err = appendField(entry, 2, v.MapIndex(key), true); err != nil {
+				return nil, err
+			}
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+		return b, nil
+
+	default:
+		return nil, fmt.Errorf("protostruct: unsupported type %s", v.Type())
+	}
+}
+
+// appendScalar appends the value of a numeric or bool field without its tag
+func appendScalar(b []byte, v reflect.Value) []byte {
+	switch v.Kind() {
+	case reflect.Bool:
+		return protowire.AppendVarint(b, protowire.EncodeBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return protowire.AppendVarint(b, protowire.EncodeZigZag(v.Int()))
+	case reflect.Float32:
+		return protowire.AppendFixed32(b, math.Float32bits(float32(v.Float())))
+	case reflect.Float64:
+		return protowire.AppendFixed64(b, math.Float64bits(v.Float()))
+	default:
+		return protowire.AppendVarint(b, v.Uint())
+	}
+}
+
+func wireType(kind reflect.Kind) protowire.Type {
+	switch kind {
+	case reflect.Float32:
+		return protowire.Fixed32Type
+	case reflect.Float64:
+		return protowire.Fixed64Type
+	default:
+		return protowire.VarintType
+	}
+}
+
+func packable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func decodeMessage(data []byte, v reflect.Value) error {
+	p, err := planOf(v.Type())
+	if err != nil {
+		return err
+	}
+	return protowalk.Walk(data, func(f protowalk.Field) error {
+		i, ok := p.byNum[f.Num]
+		if !ok {
+			return nil
+		}
+		return decodeField(f, v.Field(i))
+	})
+}
+
+// decodeField decodes one occurrence of a field into v
+func decodeField(f protowalk.Field, v reflect.Value) error {
+	if v.Type() == timeType {
+		if f.Type != protowire.VarintType {
+			return ErrMalformed
+		}
+		var t time.Time
+		if nanos := protowire.DecodeZigZag(f.Raw); nanos != 0 {
+			t = time.Unix(0, nanos).UTC()
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		if f.Type != wireType(v.Kind()) {
+			return ErrMalformed
+		}
+		setScalar(v, f.Raw)
+		return nil
+
+	case reflect.String:
+		if f.Type != protowire.BytesType {
+			return ErrMalformed
+		}
+		v.SetString(string(f.Bytes))
+		return nil
+
+	case reflect.Struct:
+		if f.Type != protowire.BytesType {
+			return ErrMalformed
+		}
+		return decodeMessage(f.Bytes, v)
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return decodeField(f, v.Elem())
+
+	case reflect.Slice:
+		elemType := v.Type().Elem()
+		if elemType.Kind() == reflect.Uint8 {
+			if f.Type != protowire.BytesType {
+				return ErrMalformed
+			}
+			v.SetBytes(append([]byte(nil), f.Bytes...))
+			return nil
+		}
+		if packable(elemType) && f.Type == protowire.BytesType {
+			return decodePacked(f.Bytes, v)
+		}
+		elem := reflect.New(elemType).Elem()
+		if err := decodeField(f, elem); err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+		return nil
+
+	case reflect.Map:
+		if f.Type != protowire.BytesType {
+			return ErrMalformed
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		value := reflect.New(v.Type().Elem()).Elem()
+		err := protowalk.Walk(f.Bytes, func(entry protowalk.Field) error {
+			switch entry.Num {
+			case 1:
+				return decodeField(entry, key)
+			case 2:
+				return decodeField(entry, value)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		v.SetMapIndex(key, value)
+		return nil
+
+	default:
+		return fmt.Errorf("protostruct: unsupported type %s", v.Type())
+	}
+}
+
+// decodePacked appends every element of a packed repeated field to the slice v
+func decodePacked(data []byte, v reflect.Value) error {
+	elemType := v.Type().Elem()
+	for len(data) > 0 {
+		var raw uint64
+		var n int
+		switch wireType(elemType.Kind()) {
+		case protowire.Fixed32Type:
+			var u uint32
+			u, n = protowire.ConsumeFixed32(data)
+			raw = uint64(u)
+		case protowire.Fixed64Type:
+			raw, n = protowire.ConsumeFixed64(data)
+		default:
+			raw, n = protowire.ConsumeVarint(data)
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+
+		elem := reflect.New(elemType).Elem()
+		setScalar(elem, raw)
+		v.Set(reflect.Append(v, elem))
+	}
+	return nil
+}
+
+// setScalar stores a varint or fixed-size wire value in a numeric or bool field
+func setScalar(v reflect.Value, raw uint64) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(protowire.DecodeBool(raw))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(protowire.DecodeZigZag(raw))
+	case reflect.Float32:
+		v.SetFloat(float64(math.Float32frombits(uint32(raw))))
+	case reflect.Float64:
+		v.SetFloat(math.Float64frombits(raw))
+	default:
+		v.SetUint(raw)
+	}
+}
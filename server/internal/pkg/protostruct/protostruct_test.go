@@ -0,0 +1,82 @@
+package protostruct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type reordered struct {
+	Skipped string `json:"-"`
+	Second  int    `json:"second" proto:"2"`
+	First   string `json:"first" proto:"1"`
+	Ignored bool   `json:"ignored" proto:"-"`
+	hidden  int
+}
+
+type reorderedOld struct {
+	First  string `json:"first" proto:"1"`
+	Second int    `json:"second" proto:"2"`
+}
+
+func TestFieldNumbersFromTags(t *testing.T) {
+	data, err := Marshal(&reordered{Skipped: "x", Second: -3, First: "a", Ignored: true, hidden: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var old reorderedOld
+	if err := Unmarshal(data, &old); err != nil {
+		t.Fatal(err)
+	}
+	if want := (reorderedOld{First: "a", Second: -3}); old != want {
+		t.Fatalf("decoded %+v, want %+v", old, want)
+	}
+
+	var back reordered
+	if err := Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if want := (reordered{Second: -3, First: "a"}); !reflect.DeepEqual(back, want) {
+		t.Fatalf("decoded %+v, want %+v", back, want)
+	}
+}
+
+func TestInvalidTags(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		data []byte
+		err  string
+	}{
+		{"missing", &struct {
+			A int `json:"a"`
+		}{}, []byte{0x08, 0x01}, "has no proto tag"},
+		{"invalid", &struct {
+			A int `json:"a" proto:"one"`
+		}{}, []byte{0x08, 0x01}, "invalid proto tag"},
+		{"zero", &struct {
+			A int `json:"a" proto:"0"`
+		}{}, []byte{0x08, 0x01}, "invalid proto tag"},
+		{"duplicate", &struct {
+			A int `json:"a" proto:"1"`
+			B int `json:"b" proto:"1"`
+		}{}, []byte{0x08, 0x01}, "used twice"},
+		{"nested", &struct {
+			A []struct {
+				B int `json:"b"`
+			} `json:"a" proto:"1"`
+		}{A: make([]struct {
+			B int `json:"b"`
+		}, 1)}, []byte{0x0a, 0x02, 0x08, 0x01}, "has no proto tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Marshal(tt.v); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Marshal() error = %v, want %q", err, tt.err)
+			}
+			if err := Unmarshal(tt.data, tt.v); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Unmarshal() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
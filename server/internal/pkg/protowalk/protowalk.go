@@ -0,0 +1,68 @@
+// Package protowalk iterates over the fields of an encoded protobuf message
+// without generated code, for decoding small well-known wire formats.
+package protowalk
+
+import (
+	"errors"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ErrMalformed is returned when the data is not a valid protobuf message
+var ErrMalformed = errors.New("malformed protobuf message")
+
+// Field is a single decoded field. Bytes is set for length-delimited fields,
+// Raw for varint, fixed32 and fixed64 fields.
+type Field struct {
+	Num   protowire.Number
+	Type  protowire.Type
+	Bytes []byte
+	Raw   uint64
+}
+
+// Double interprets a fixed64 field as a float64
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.Raw)
+}
+
+// Walk calls fn for every field of the message; groups are skipped
+func Walk(data []byte, fn func(f Field) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+
+		f := Field{Num: num, Type: typ}
+		switch typ {
+		case protowire.BytesType:
+			f.Bytes, n = protowire.ConsumeBytes(data)
+		case protowire.VarintType:
+			f.Raw, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.Raw, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(data)
+			f.Raw = uint64(v)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return ErrMalformed
+			}
+			data = data[n:]
+			continue
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		data = data[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
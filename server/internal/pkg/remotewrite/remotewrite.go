@@ -0,0 +1,91 @@
+// Package remotewrite decodes Prometheus remote-write 1.0 requests
+// (snappy compressed prometheus.WriteRequest protobuf messages).
+package remotewrite
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protowalk"
+)
+
+// Decode decompresses and decodes a remote-write body into series.
+// Every sample of a time series becomes its own Series, the __name__ label becomes its name.
+func Decode(body []byte) ([]domain.Series, error) {
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress snappy payload: %w", err)
+	}
+
+	var series []domain.Series
+	err = protowalk.Walk(data, func(f protowalk.Field) error {
+		// WriteRequest.timeseries = 1
+		if f.Num != 1 || f.Type != protowire.BytesType {
+			return nil
+		}
+		decoded, err := decodeTimeSeries(f.Bytes)
+		if err != nil {
+			return err
+		}
+		series = append(series, decoded...)
+		return nil
+	})
+	return series, err
+}
+
+func decodeTimeSeries(data []byte) ([]domain.Series, error) {
+	labels := make(map[string]string)
+	var samples []domain.Series
+
+	err := protowalk.Walk(data, func(f protowalk.Field) error {
+		switch f.Num {
+		case 1: // TimeSeries.labels
+			var name, value string
+			err := protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				switch f.Num {
+				case 1:
+					name = string(f.Bytes)
+				case 2:
+					value = string(f.Bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			labels[name] = value
+		case 2: // TimeSeries.samples
+			var s domain.Series
+			err := protowalk.Walk(f.Bytes, func(f protowalk.Field) error {
+				switch f.Num {
+				case 1:
+					s.Value = f.Double()
+				case 2:
+					s.Timestamp = time.UnixMilli(int64(f.Raw))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			samples = append(samples, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	name := labels["__name__"]
+	delete(labels, "__name__")
+
+	for i := range samples {
+		samples[i].Name = name
+		samples[i].Labels = labels
+	}
+	return samples, nil
+}
@@ -0,0 +1,282 @@
+// Package statsd parses StatsD lines (with DogStatsD style tags) and aggregates
+// them per flush interval into series.
+//
+//	<name>:<value>|<type>[|@<sample rate>][|#<tag>[:<value>],...]
+//
+// Supported types are counters (c), gauges (g, with +/- deltas), timers (ms),
+// histograms (h), distributions (d) and sets (s).
+package statsd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Metric types
+const (
+	Counter      = "c"
+	Gauge        = "g"
+	Timer        = "ms"
+	Histogram    = "h"
+	Distribution = "d"
+	Set          = "s"
+)
+
+// ErrMalformed is returned for lines that are not valid StatsD
+var ErrMalformed = errors.New("malformed statsd line")
+
+// Metric is one parsed StatsD sample
+type Metric struct {
+	Name       string
+	Type       string
+	Value      float64
+	Raw        string // unparsed value, used for sets
+	Delta      bool   // gauge value prefixed with + or -
+	SampleRate float64
+	Tags       map[string]string
+}
+
+// Parse parses a single StatsD line
+func Parse(line string) (Metric, error) {
+	m := Metric{SampleRate: 1}
+
+	colon := strings.IndexByte(line, ':')
+	if colon <= 0 {
+		return m, fmt.Errorf("%w: %q", ErrMalformed, line)
+	}
+	m.Name = line[:colon]
+
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 {
+		return m, fmt.Errorf("%w: %q", ErrMalformed, line)
+	}
+	m.Raw = parts[0]
+	m.Type = parts[1]
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return m, fmt.Errorf("%w: invalid sample rate in %q", ErrMalformed, line)
+			}
+			m.SampleRate = rate
+		case strings.HasPrefix(part, "#"):
+			m.Tags = parseTags(part[1:])
+		}
+	}
+
+	switch m.Type {
+	case Set:
+		return m, nil
+	case Counter, Gauge, Timer, Histogram, Distribution:
+	default:
+		return m, fmt.Errorf("%w: unknown type %q", ErrMalformed, m.Type)
+	}
+
+	value, err := strconv.ParseFloat(m.Raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return m, fmt.Errorf("%w: invalid value in %q", ErrMalformed, line)
+	}
+	m.Value = value
+	m.Delta = m.Type == Gauge && (m.Raw[0] == '+' || m.Raw[0] == '-')
+	return m, nil
+}
+
+func parseTags(s string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(s, ",") {
+		if tag == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(tag, ":")
+		tags[key] = value
+	}
+	return tags
+}
+
+// key identifies an aggregated metric
+type key struct {
+	source string
+	name   string
+	typ    string
+	tags   string
+}
+
+// gaugeIdleFlushes is how many flushes a gauge not updated is still reported for
+const gaugeIdleFlushes = 5
+
+type aggregate struct {
+	tags    map[string]string
+	count   float64
+	value   float64
+	samples []float64
+	set     map[string]struct{}
+}
+
+// gauge is the value of a gauge kept across flushes
+type gauge struct {
+	tags  map[string]string
+	value float64
+	idle  int // flushes since it was last updated
+}
+
+// Aggregator accumulates metrics between flushes. It is not safe for concurrent use.
+type Aggregator struct {
+	percentiles []float64
+	metrics     map[key]*aggregate
+	gauges      map[key]*gauge // reported every flush until idle for gaugeIdleFlushes
+}
+
+// NewAggregator creates an aggregator reporting the given percentiles (0-100) for timers
+func NewAggregator(percentiles []float64) *Aggregator {
+	return &Aggregator{
+		percentiles: percentiles,
+		metrics:     make(map[key]*aggregate),
+		gauges:      make(map[key]*gauge),
+	}
+}
+
+// Add records a metric received from source
+func (a *Aggregator) Add(source string, m Metric) {
+	k := key{source: source, name: m.Name, typ: m.Type, tags: tagString(m.Tags)}
+	if m.Type == Histogram || m.Type == Distribution {
+		k.typ = Timer
+	}
+
+	if k.typ == Gauge {
+		g, ok := a.gauges[k]
+		if !ok {
+			g = &gauge{tags: m.Tags}
+			a.gauges[k] = g
+		}
+		if m.Delta {
+			g.value += m.Value
+		} else {
+			g.value = m.Value
+		}
+		g.idle = 0
+		return
+	}
+
+	agg, ok := a.metrics[k]
+	if !ok {
+		agg = &aggregate{tags: m.Tags}
+		a.metrics[k] = agg
+	}
+
+	switch k.typ {
+	case Counter:
+		agg.value += m.Value / m.SampleRate
+	case Timer:
+		agg.count += 1 / m.SampleRate
+		agg.samples = append(agg.samples, m.Value)
+	case Set:
+		if agg.set == nil {
+			agg.set = make(map[string]struct{})
+		}
+		agg.set[m.Raw] = struct{}{}
+	}
+}
+
+// Flush returns the aggregated series grouped by source and resets the aggregator.
+// Gauges are reported with their last value for gaugeIdleFlushes flushes after their last update.
+func (a *Aggregator) Flush(at time.Time) []domain.SeriesBatch {
+	bySource := make(map[string][]domain.Series)
+	for k, agg := range a.metrics {
+		series := func(name string, value float64, unit string) {
+			bySource[k.source] = append(bySource[k.source], domain.Series{
+				Name:      name,
+				Labels:    agg.tags,
+				Value:     value,
+				Unit:      unit,
+				Timestamp: at,
+			})
+		}
+
+		switch k.typ {
+		case Counter:
+			series(k.name, agg.value, "")
+		case Set:
+			series(k.name, float64(len(agg.set)), "")
+		case Timer:
+			sort.Float64s(agg.samples)
+			var sum float64
+			for _, v := range agg.samples {
+				sum += v
+			}
+			n := len(agg.samples)
+			series(k.name+".count", agg.count, "")
+			series(k.name+".mean", sum/float64(n), "ms")
+			series(k.name+".min", agg.samples[0], "ms")
+			series(k.name+".max", agg.samples[n-1], "ms")
+			for _, p := range a.percentiles {
+				series(k.name+".p"+strconv.FormatFloat(p, 'f', -1, 64), percentile(agg.samples, p), "ms")
+			}
+		}
+	}
+	a.metrics = make(map[key]*aggregate)
+
+	// A gauge whose series is no longer sent, such as one tagged with a request ID, expires
+	for k, g := range a.gauges {
+		if g.idle > gaugeIdleFlushes {
+			delete(a.gauges, k)
+			continue
+		}
+		g.idle++
+		bySource[k.source] = append(bySource[k.source], domain.Series{
+			Name:      k.name,
+			Labels:    g.tags,
+			Value:     g.value,
+			Timestamp: at,
+		})
+	}
+
+	sources := make([]string, 0, len(bySource))
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	batches := make([]domain.SeriesBatch, 0, len(sources))
+	for _, source := range sources {
+		batches = append(batches, domain.SeriesBatch{Source: source, Series: bySource[source]})
+	}
+	return batches
+}
+
+// percentile returns the nearest-rank percentile of sorted samples
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p * float64(len(sorted)) / 100)) // p*n first, as p/100 is inexact: p7 of 100 would rank 8
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func tagString(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + ":" + tags[k] + ",")
+	}
+	return b.String()
+}
@@ -0,0 +1,214 @@
+package statsd
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		line string
+		want Metric
+	}{
+		{"requests:1|c", Metric{Name: "requests", Type: Counter, Value: 1, Raw: "1", SampleRate: 1}},
+		{"requests:3|c|@0.1", Metric{Name: "requests", Type: Counter, Value: 3, Raw: "3", SampleRate: 0.1}},
+		{"temp:21.5|g", Metric{Name: "temp", Type: Gauge, Value: 21.5, Raw: "21.5", SampleRate: 1}},
+		{"temp:+2|g", Metric{Name: "temp", Type: Gauge, Value: 2, Raw: "+2", Delta: true, SampleRate: 1}},
+		{"temp:-1.5|g", Metric{Name: "temp", Type: Gauge, Value: -1.5, Raw: "-1.5", Delta: true, SampleRate: 1}},
+		{"offset:-3|c", Metric{Name: "offset", Type: Counter, Value: -3, Raw: "-3", SampleRate: 1}},
+		{"latency:320|ms|@0.5|#env:prod,region:eu", Metric{Name: "latency", Type: Timer, Value: 320, Raw: "320", SampleRate: 0.5, Tags: map[string]string{"env": "prod", "region": "eu"}}},
+		{"size:1024|h|#canary", Metric{Name: "size", Type: Histogram, Value: 1024, Raw: "1024", SampleRate: 1, Tags: map[string]string{"canary": ""}}},
+		{"size:1024|d|#url:http://a:8080/x", Metric{Name: "size", Type: Distribution, Value: 1024, Raw: "1024", SampleRate: 1, Tags: map[string]string{"url": "http://a:8080/x"}}},
+		{"users:alice|s", Metric{Name: "users", Type: Set, Raw: "alice", SampleRate: 1}},
+		{"app.db.queries:1|c|#", Metric{Name: "app.db.queries", Type: Counter, Value: 1, Raw: "1", SampleRate: 1, Tags: map[string]string{}}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.line)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+
+	for _, line := range []string{
+		"", "requests", ":1|c", "requests:1", "requests:1|x", "requests:abc|c", "requests:|g",
+		"requests:NaN|g", "requests:Inf|ms", "requests:1|c|@0", "requests:1|c|@1.5", "requests:1|c|@-0.5", "requests:1|c|@x",
+	} {
+		if _, err := Parse(line); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Parse(%q) error = %v, want ErrMalformed", line, err)
+		}
+	}
+}
+
+// flushValues flushes the aggregator and returns the series values by source and name
+func flushValues(t *testing.T, a *Aggregator) map[string]map[string]float64 {
+	t.Helper()
+	values := make(map[string]map[string]float64)
+	for _, batch := range a.Flush(time.Now()) {
+		values[batch.Source] = make(map[string]float64)
+		for _, s := range batch.Series {
+			values[batch.Source][s.Name] = s.Value
+		}
+	}
+	return values
+}
+
+func add(t *testing.T, a *Aggregator, source string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		m, err := Parse(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Add(source, m)
+	}
+}
+
+func TestAggregatorSampleRates(t *testing.T) {
+	a := NewAggregator(nil)
+	add(t, a, "web-1",
+		"requests:1|c|@0.1", // 10 requests
+		"requests:2|c|@0.5", // 4 requests
+		"requests:1|c",
+		"latency:100|ms|@0.25",
+		"latency:300|ms|@0.25",
+	)
+
+	got := flushValues(t, a)["web-1"]
+	want := map[string]float64{
+		"requests":      15,
+		"latency.count": 8,
+		"latency.mean":  200,
+		"latency.min":   100,
+		"latency.max":   300,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush() = %v, want %v", got, want)
+	}
+}
+
+func TestAggregatorGauges(t *testing.T) {
+	a := NewAggregator(nil)
+	add(t, a, "web-1", "temp:+5|g", "temp:20|g", "temp:+2.5|g", "temp:-1|g")
+	add(t, a, "web-2", "temp:-3|g")
+	if got := flushValues(t, a); got["web-1"]["temp"] != 21.5 || got["web-2"]["temp"] != -3 {
+		t.Fatalf("first flush = %v, want web-1 21.5 and web-2 -3", got)
+	}
+
+	// Deltas apply to the value kept from the previous flush
+	add(t, a, "web-1", "temp:+1|g")
+	if got := flushValues(t, a); got["web-1"]["temp"] != 22.5 {
+		t.Fatalf("second flush = %v, want web-1 22.5", got)
+	}
+	add(t, a, "web-1", "temp:4|g")
+	if got := flushValues(t, a); got["web-1"]["temp"] != 4 || got["web-2"]["temp"] != -3 {
+		t.Fatalf("third flush = %v, want web-1 4 and web-2 still -3", got)
+	}
+}
+
+func TestAggregatorGaugesExpire(t *testing.T) {
+	a := NewAggregator(nil)
+	add(t, a, "web-1", "queue:3|g|#pod:api-1", "queue:5|g|#pod:api-2")
+	flushValues(t, a)
+
+	// A gauge not sent again is reported with its last value, then expires
+	for i := 1; i <= gaugeIdleFlushes; i++ {
+		add(t, a, "web-1", "queue:7|g|#pod:api-2")
+		batches := a.Flush(time.Now())
+		if len(batches) != 1 || len(batches[0].Series) != 2 {
+			t.Fatalf("idle flush %d = %+v, want both gauges", i, batches)
+		}
+	}
+	add(t, a, "web-1", "queue:7|g|#pod:api-2")
+	batches := a.Flush(time.Now())
+	if len(batches) != 1 || len(batches[0].Series) != 1 || batches[0].Series[0].Labels["pod"] != "api-2" {
+		t.Fatalf("flush after %d idle ones = %+v, want only the gauge of api-2", gaugeIdleFlushes, batches)
+	}
+	if len(a.gauges) != 1 {
+		t.Errorf("%d gauges kept, want 1", len(a.gauges))
+	}
+
+	// An expired gauge starts again from its next value
+	add(t, a, "web-1", "queue:+1|g|#pod:api-1")
+	for _, series := range a.Flush(time.Now())[0].Series {
+		if series.Labels["pod"] == "api-1" && series.Value != 1 {
+			t.Errorf("api-1 gauge = %g after expiring, want 1", series.Value)
+		}
+	}
+}
+
+func TestAggregatorPercentiles(t *testing.T) {
+	tests := []struct {
+		samples     int // 1 to samples, added in reverse
+		percentiles []float64
+		want        []float64
+	}{
+		{1, []float64{1, 50, 99, 100}, []float64{1, 1, 1, 1}},
+		{4, []float64{25, 50, 75, 100}, []float64{1, 2, 3, 4}},
+		{4, []float64{1, 26, 51, 76}, []float64{1, 2, 3, 4}},
+		{10, []float64{90, 95, 99}, []float64{9, 10, 10}},
+		{100, []float64{7, 50, 57, 90, 99}, []float64{7, 50, 57, 90, 99}},
+		{1000, []float64{99.9, 99.5, 12.5}, []float64{999, 995, 125}},
+	}
+	for _, tt := range tests {
+		a := NewAggregator(tt.percentiles)
+		for v := tt.samples; v >= 1; v-- {
+			a.Add("web-1", Metric{Name: "latency", Type: Timer, Value: float64(v), SampleRate: 1})
+		}
+		got := flushValues(t, a)["web-1"]
+		for i, p := range tt.percentiles {
+			name := "latency.p" + strconv.FormatFloat(p, 'f', -1, 64)
+			if got[name] != tt.want[i] {
+				t.Errorf("%d samples: %s = %g, want %g", tt.samples, name, got[name], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestAggregatorFlush(t *testing.T) {
+	a := NewAggregator([]float64{50})
+	add(t, a, "web-2", "users:alice|s", "users:bob|s", "users:alice|s", "size:10|h", "size:30|d")
+	add(t, a, "web-1", "requests:1|c|#env:prod", "requests:2|c|#env:prod", "requests:5|c|#env:dev")
+
+	at := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	batches := a.Flush(at)
+	for _, batch := range batches {
+		sort.Slice(batch.Series, func(i, j int) bool {
+			if batch.Series[i].Name != batch.Series[j].Name {
+				return batch.Series[i].Name < batch.Series[j].Name
+			}
+			return batch.Series[i].Value < batch.Series[j].Value
+		})
+	}
+	prod, dev := map[string]string{"env": "prod"}, map[string]string{"env": "dev"}
+	want := []domain.SeriesBatch{
+		{Source: "web-1", Series: []domain.Series{
+			{Name: "requests", Labels: prod, Value: 3, Timestamp: at},
+			{Name: "requests", Labels: dev, Value: 5, Timestamp: at},
+		}},
+		{Source: "web-2", Series: []domain.Series{
+			{Name: "size.count", Value: 2, Timestamp: at},
+			{Name: "size.max", Value: 30, Unit: "ms", Timestamp: at},
+			{Name: "size.mean", Value: 20, Unit: "ms", Timestamp: at},
+			{Name: "size.min", Value: 10, Unit: "ms", Timestamp: at},
+			{Name: "size.p50", Value: 10, Unit: "ms", Timestamp: at},
+			{Name: "users", Value: 2, Timestamp: at},
+		}},
+	}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("Flush() =\n%+v\nwant\n%+v", batches, want)
+	}
+
+	if batches := a.Flush(at); len(batches) != 0 {
+		t.Errorf("Flush() without new metrics = %+v, want nothing", batches)
+	}
+}
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type AgentAlertRepository struct {
+	db *sql.DB
+}
+
+func NewAgentAlertRepository(db *sql.DB) *AgentAlertRepository {
+	return &AgentAlertRepository{
+		db: db,
+	}
+}
+
+// SaveAlert inserts a new alert or updates the value, status and resolution of an existing one
+func (r *AgentAlertRepository) SaveAlert(ctx context.Context, alert *domain.AgentAlert) error {
+	if alert.ID != 0 {
+		_, err := r.db.ExecContext(ctx,
+			`UPDATE agent_alerts SET value = ?, status = ?, resolved_at = ? WHERE id = ?`,
+			alert.Value, alert.Status, alert.ResolvedAt, alert.ID,
+		)
+		return err
+	}
+
+	query := `
+		INSERT INTO agent_alerts (group_id, rule_name, agent_id, agent_name, metric, condition, threshold, value, status, started_at, fired_at, resolved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		alert.GroupID,
+		alert.RuleName,
+		alert.AgentID,
+		alert.AgentName,
+		alert.Metric,
+		alert.Condition,
+		alert.Threshold,
+		alert.Value,
+		alert.Status,
+		alert.StartedAt,
+		alert.FiredAt,
+		alert.ResolvedAt,
+	).Scan(&alert.ID)
+}
+
+func (r *AgentAlertRepository) GetAlerts(ctx context.Context, q domain.AgentAlertQuery) ([]domain.AgentAlert, error) {
+	var conditions []string
+	var args []interface{}
+
+	if q.GroupID != 0 {
+		conditions = append(conditions, "group_id = ?")
+		args = append(args, q.GroupID)
+	}
+	if q.AgentID != "" {
+		conditions = append(conditions, "agent_id = ?")
+		args = append(args, q.AgentID)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, group_id, rule_name, agent_id, agent_name, metric, condition, threshold, value, status, started_at, fired_at, resolved_at
+		FROM agent_alerts
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY fired_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []domain.AgentAlert{}
+	for rows.Next() {
+		var alert domain.AgentAlert
+		var resolvedAt sql.NullTime
+		err := rows.Scan(
+			&alert.ID,
+			&alert.GroupID,
+			&alert.RuleName,
+			&alert.AgentID,
+			&alert.AgentName,
+			&alert.Metric,
+			&alert.Condition,
+			&alert.Threshold,
+			&alert.Value,
+			&alert.Status,
+			&alert.StartedAt,
+			&alert.FiredAt,
+			&resolvedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type AgentAuditRepository struct {
+	db *sql.DB
+}
+
+func NewAgentAuditRepository(db *sql.DB) *AgentAuditRepository {
+	return &AgentAuditRepository{
+		db: db,
+	}
+}
+
+func (r *AgentAuditRepository) Record(ctx context.Context, entry *domain.AgentAuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO agent_audit (agent_id, action, actor, changes, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		entry.AgentID,
+		entry.Action,
+		entry.Actor,
+		string(changes),
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *AgentAuditRepository) GetEntries(ctx context.Context, agentID string, limit int) ([]domain.AgentAuditEntry, error) {
+	query := `
+		SELECT id, agent_id, action, COALESCE(actor, ''), changes, created_at
+		FROM agent_audit
+		WHERE agent_id = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []domain.AgentAuditEntry{}
+	for rows.Next() {
+		var entry domain.AgentAuditEntry
+		var changes string
+		err := rows.Scan(
+			&entry.ID,
+			&entry.AgentID,
+			&entry.Action,
+			&entry.Actor,
+			&changes,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
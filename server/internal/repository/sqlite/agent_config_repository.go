@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type AgentConfigRepository struct {
+	db *sql.DB
+}
+
+func NewAgentConfigRepository(db *sql.DB) *AgentConfigRepository {
+	return &AgentConfigRepository{
+		db: db,
+	}
+}
+
+func (r *AgentConfigRepository) GetAll(ctx context.Context) ([]domain.AgentConfigDocument, error) {
+	query := `SELECT scope, target, settings, updated_at FROM agent_configs ORDER BY scope, target`
+	return r.query(ctx, query)
+}
+
+func (r *AgentConfigRepository) Get(ctx context.Context, scope, target string) (*domain.AgentConfigDocument, error) {
+	query := `SELECT scope, target, settings, updated_at FROM agent_configs WHERE scope = ? AND target = ?`
+
+	doc, err := scanAgentConfig(r.db.QueryRowContext(ctx, query, scope, target))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return doc, err
+}
+
+// GetForAgent returns the documents of the agent's tags, in the order of tags, then those of
+// its groups, in the order of groups, followed by its own
+func (r *AgentConfigRepository) GetForAgent(ctx context.Context, agentID string, tags []string, groups []string) ([]domain.AgentConfigDocument, error) {
+	scopes := []struct {
+		scope   string
+		targets []string
+	}{
+		{domain.AgentConfigScopeTag, tags},
+		{domain.AgentConfigScopeGroup, groups},
+	}
+
+	args := []interface{}{domain.AgentConfigScopeAgent, agentID}
+	query := `SELECT scope, target, settings, updated_at FROM agent_configs WHERE (scope = ? AND target = ?)`
+	for _, s := range scopes {
+		if len(s.targets) == 0 {
+			continue
+		}
+		query += ` OR (scope = ? AND target IN (?` + strings.Repeat(", ?", len(s.targets)-1) + `))`
+		args = append(args, s.scope)
+		for _, target := range s.targets {
+			args = append(args, target)
+		}
+	}
+
+	docs, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	byTarget := make(map[string]domain.AgentConfigDocument)
+	var own *domain.AgentConfigDocument
+	for i := range docs {
+		if docs[i].Scope == domain.AgentConfigScopeAgent {
+			own = &docs[i]
+		} else {
+			byTarget[docs[i].Scope+":"+docs[i].Target] = docs[i]
+		}
+	}
+
+	var ordered []domain.AgentConfigDocument
+	for _, s := range scopes {
+		for _, target := range s.targets {
+			if doc, ok := byTarget[s.scope+":"+target]; ok {
+				ordered = append(ordered, doc)
+				delete(byTarget, s.scope+":"+target)
+			}
+		}
+	}
+	if own != nil {
+		ordered = append(ordered, *own)
+	}
+	return ordered, nil
+}
+
+// Save creates or replaces the document of a scope and target
+func (r *AgentConfigRepository) Save(ctx context.Context, doc *domain.AgentConfigDocument) error {
+	settings, err := json.Marshal(doc.Settings)
+	if err != nil {
+		return err
+	}
+	doc.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO agent_configs (scope, target, settings, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (scope, target) DO UPDATE SET settings = excluded.settings, updated_at = excluded.updated_at
+	`
+
+	_, err = r.db.ExecContext(ctx, query, doc.Scope, doc.Target, string(settings), doc.UpdatedAt)
+	return err
+}
+
+func (r *AgentConfigRepository) Delete(ctx context.Context, scope, target string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM agent_configs WHERE scope = ? AND target = ?`, scope, target)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *AgentConfigRepository) query(ctx context.Context, query string, args ...interface{}) ([]domain.AgentConfigDocument, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []domain.AgentConfigDocument
+	for rows.Next() {
+		doc, err := scanAgentConfig(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+
+	return docs, rows.Err()
+}
+
+func scanAgentConfig(row rowScanner) (*domain.AgentConfigDocument, error) {
+	var doc domain.AgentConfigDocument
+	var settings string
+
+	if err := row.Scan(&doc.Scope, &doc.Target, &settings, &doc.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(settings), &doc.Settings); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
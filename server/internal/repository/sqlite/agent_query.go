@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// agentSortColumns maps the sort fields to their columns
+var agentSortColumns = map[string]string{
+	domain.AgentSortName:      "name",
+	domain.AgentSortHostname:  "hostname",
+	domain.AgentSortStatus:    "status",
+	domain.AgentSortVersion:   "version",
+	domain.AgentSortLastSeen:  "last_seen",
+	domain.AgentSortCreatedAt: "created_at",
+}
+
+// agentCursor is the position after the last agent of a page: its sort key and ID. The sort
+// order is kept so that a cursor is not used with another one.
+type agentCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Key        string `json:"k"`
+	ID         string `json:"id"`
+}
+
+func (c agentCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeAgentCursor(value string) (agentCursor, error) {
+	var cursor agentCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	if err != nil {
+		return cursor, fmt.Errorf("%w: malformed cursor", domain.ErrInvalidInput)
+	}
+	return cursor, nil
+}
+
+// keyedRow scans the sort key selected after the agent columns
+type keyedRow struct {
+	row rowScanner
+	key *string
+}
+
+func (k keyedRow) Scan(dest ...interface{}) error {
+	return k.row.Scan(append(dest, k.key)...)
+}
+
+// Query filters agents in SQL, tags through agent_tags, and pages them by keyset on the
+// sort column and the ID, so pages stay consistent while agents are added
+func (r *AgentRepository) Query(ctx context.Context, q domain.AgentQuery) ([]domain.Agent, string, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = domain.AgentSortCreatedAt
+	}
+	column, ok := agentSortColumns[sort]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: unknown sort field %q", domain.ErrInvalidInput, sort)
+	}
+	sortKey := "COALESCE(" + column + ", '')"
+
+	var conditions []string
+	var args []interface{}
+
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, q.Type)
+	}
+	if q.Version != "" {
+		conditions = append(conditions, "version = ?")
+		args = append(args, q.Version)
+	}
+	if q.Search != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Search) + "%"
+		conditions = append(conditions, `(name LIKE ? ESCAPE '\' OR hostname LIKE ? ESCAPE '\' OR ip_address LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern, pattern)
+	}
+	if !q.SeenAfter.IsZero() {
+		conditions = append(conditions, "last_seen >= ?")
+		args = append(args, q.SeenAfter.UTC())
+	}
+	if !q.SeenBefore.IsZero() {
+		conditions = append(conditions, "last_seen < ?")
+		args = append(args, q.SeenBefore.UTC())
+	}
+
+	if tags := slices.Compact(slices.Sorted(slices.Values(q.Tags))); len(tags) > 0 {
+		tagCondition := `id IN (SELECT agent_id FROM agent_tags WHERE tag IN (?` + strings.Repeat(", ?", len(tags)-1) + `)`
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		if q.TagMatch == domain.AgentTagMatchAll {
+			tagCondition += ` GROUP BY agent_id HAVING COUNT(*) = ?`
+			args = append(args, len(tags))
+		}
+		conditions = append(conditions, tagCondition+")")
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeAgentCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if cursor.Sort != sort || cursor.Descending != q.Descending {
+			return nil, "", fmt.Errorf("%w: cursor belongs to another sort order", domain.ErrInvalidInput)
+		}
+		op := ">"
+		if q.Descending {
+			op = "<"
+		}
+		conditions = append(conditions, "("+sortKey+" "+op+" ? OR ("+sortKey+" = ? AND id "+op+" ?))")
+		args = append(args, cursor.Key, cursor.Key, cursor.ID)
+	}
+
+	query := `SELECT ` + agentColumns + `, ` + sortKey + ` FROM agents`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	direction := "ASC"
+	if q.Descending {
+		direction = "DESC"
+	}
+	query += " ORDER BY " + sortKey + " " + direction + ", id " + direction
+	if q.Limit > 0 {
+		// One more than the page tells whether there is a next page
+		query += " LIMIT ?"
+		args = append(args, q.Limit+1)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	agents := []domain.Agent{}
+	var keys []string
+	for rows.Next() {
+		var key string
+		agent, err := scanAgent(keyedRow{row: rows, key: &key})
+		if err != nil {
+			return nil, "", err
+		}
+		agents = append(agents, *agent)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	if q.Limit <= 0 || len(agents) <= q.Limit {
+		return agents, "", nil
+	}
+	agents = agents[:q.Limit]
+	last := agents[q.Limit-1]
+	next := agentCursor{Sort: sort, Descending: q.Descending, Key: keys[q.Limit-1], ID: last.ID}
+	return agents, next.encode(), nil
+}
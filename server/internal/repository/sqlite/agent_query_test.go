@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestQueryAgentsSeenInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	repo := NewAgentRepository(openTestDB(t))
+	jakarta := time.FixedZone("WIB", 7*3600)
+	newYork := time.FixedZone("EST", -5*3600)
+	at := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	// A server outside UTC records when agents were last seen in its own zone
+	for id, lastSeen := range map[string]time.Time{
+		"agent-1": at.Add(-10 * time.Minute).In(jakarta),
+		"agent-2": at.Add(5 * time.Minute).In(newYork),
+		"agent-3": at.Add(20 * time.Minute).In(jakarta),
+	} {
+		agent := &domain.Agent{ID: id, Name: id, Host: "127.0.0.1", Status: "online", LastSeen: lastSeen}
+		if err := repo.Register(ctx, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.UpdateStatus(ctx, "agent-3", "online", at.Add(30*time.Minute).In(newYork)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query domain.AgentQuery
+		want  []string
+	}{
+		{"seen after, west of the stored times", domain.AgentQuery{SeenAfter: at.In(newYork)}, []string{"agent-2", "agent-3"}},
+		{"seen after, east of the stored times", domain.AgentQuery{SeenAfter: at.In(jakarta)}, []string{"agent-2", "agent-3"}},
+		{"seen before", domain.AgentQuery{SeenBefore: at.Add(25 * time.Minute).In(jakarta)}, []string{"agent-1", "agent-2"}},
+		{"window", domain.AgentQuery{SeenAfter: at.In(jakarta), SeenBefore: at.Add(10 * time.Minute).In(newYork)}, []string{"agent-2"}},
+		{"sorted by last seen", domain.AgentQuery{Sort: domain.AgentSortLastSeen, Descending: true}, []string{"agent-3", "agent-2", "agent-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agents, _, err := repo.Query(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, agent := range agents {
+				ids = append(ids, agent.ID)
+			}
+			if tt.query.Sort == "" {
+				slices.Sort(ids)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Query() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitDBNormalizesLastSeen(t *testing.T) {
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+	if _, err := db.Exec(`UPDATE agents SET last_seen = '2026-10-19 09:10:00+07:00' WHERE id = 'agent-1'`); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var lastSeen string
+	if err := db.QueryRow(`SELECT CAST(last_seen AS TEXT) FROM agents WHERE id = 'agent-1'`).Scan(&lastSeen); err != nil {
+		t.Fatal(err)
+	}
+	if lastSeen != "2026-10-19 02:10:00+00:00" {
+		t.Errorf("last_seen = %s, want 2026-10-19 02:10:00+00:00", lastSeen)
+	}
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"time"
 
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/compression"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/protostruct"
 )
 
+// maxPulledMetrics limits the size of a decompressed protobuf metrics response
+const maxPulledMetrics = 32 << 20
+
 type AgentRepository struct {
 	db *sql.DB
 }
@@ -27,42 +35,77 @@ func (r *AgentRepository) Register(ctx context.Context, agent *domain.Agent) err
 		return err
 	}
 
+	if agent.Type == "" {
+		agent.Type = domain.AgentTypeAgent
+	}
+
 	query := `
-		INSERT INTO agents (id, name, host, hostname, ip_address, status, last_seen, version, tags, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO agents (id, name, type, host, hostname, ip_address, status, last_seen, version, tags, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err = r.db.ExecContext(ctx, query,
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, query,
 		agent.ID,
 		agent.Name,
+		agent.Type,
 		agent.Host,
 		agent.Hostname,
 		agent.IPAddress,
 		agent.Status,
-		agent.LastSeen,
+		agent.LastSeen.UTC(), // compared as text with the UTC bounds of the agent queries
 		agent.Version,
 		string(tagsJSON),
 		agent.Description,
-		agent.CreatedAt,
-		agent.UpdatedAt,
+		agent.CreatedAt.UTC(),
+		agent.UpdatedAt.UTC(),
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	if err := saveTags(ctx, tx, agent.ID, agent.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
-	query := `
-		SELECT id, name, host, hostname, ip_address, status, last_seen, version, tags, description, created_at, updated_at
-		FROM agents
-		WHERE id = ?
-	`
+// saveTags replaces the normalized tags of an agent
+func saveTags(ctx context.Context, tx *sql.Tx, agentID string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM agent_tags WHERE agent_id = ?`, agentID); err != nil {
+		return err
+	}
+	for i, tag := range tags {
+		_, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO agent_tags (agent_id, tag, position) VALUES (?, ?, ?)`, agentID, tag, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// agentColumns is the column list matching scanAgent
+const agentColumns = `id, name, type, host, hostname, ip_address, status, last_seen, version, tags, description, created_at, updated_at, config_version`
 
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAgent(row rowScanner) (*domain.Agent, error) {
 	var agent domain.Agent
 	var tagsJSON string
+	var configVersion sql.NullString
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := row.Scan(
 		&agent.ID,
 		&agent.Name,
+		&agent.Type,
 		&agent.Host,
 		&agent.Hostname,
 		&agent.IPAddress,
@@ -73,14 +116,12 @@ func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent
 		&agent.Description,
 		&agent.CreatedAt,
 		&agent.UpdatedAt,
+		&configVersion,
 	)
-
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
 	if err != nil {
 		return nil, err
 	}
+	agent.ConfigVersion = configVersion.String
 
 	if tagsJSON != "" && tagsJSON != "null" {
 		if err := json.Unmarshal([]byte(tagsJSON), &agent.Tags); err != nil {
@@ -91,13 +132,81 @@ func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent
 	return &agent, nil
 }
 
-func (r *AgentRepository) GetAll(ctx context.Context) ([]domain.Agent, error) {
+// Update replaces the registration details of an agent
+func (r *AgentRepository) Update(ctx context.Context, agent *domain.Agent) error {
+	tagsJSON, err := json.Marshal(agent.Tags)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		SELECT id, name, host, hostname, ip_address, status, last_seen, version, tags, description, created_at, updated_at
-		FROM agents
-		ORDER BY created_at DESC
+		UPDATE agents
+		SET name = ?, host = ?, hostname = ?, ip_address = ?, status = ?, last_seen = ?,
+			version = ?, tags = ?, description = ?, updated_at = ?
+		WHERE id = ?
 	`
 
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query,
+		agent.Name,
+		agent.Host,
+		agent.Hostname,
+		agent.IPAddress,
+		agent.Status,
+		agent.LastSeen.UTC(),
+		agent.Version,
+		string(tagsJSON),
+		agent.Description,
+		agent.UpdatedAt.UTC(),
+		agent.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+
+	if err := saveTags(ctx, tx, agent.ID, agent.Tags); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
+	query := `SELECT ` + agentColumns + ` FROM agents WHERE id = ?`
+
+	agent, err := scanAgent(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return agent, err
+}
+
+// GetByHost finds an agent by type and host, used to look up virtual agents
+func (r *AgentRepository) GetByHost(ctx context.Context, agentType, host string) (*domain.Agent, error) {
+	query := `SELECT ` + agentColumns + ` FROM agents WHERE type = ? AND host = ? LIMIT 1`
+
+	agent, err := scanAgent(r.db.QueryRowContext(ctx, query, agentType, host))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return agent, err
+}
+
+func (r *AgentRepository) GetAll(ctx context.Context) ([]domain.Agent, error) {
+	query := `SELECT ` + agentColumns + ` FROM agents ORDER BY created_at DESC`
+
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
@@ -106,34 +215,11 @@ func (r *AgentRepository) GetAll(ctx context.Context) ([]domain.Agent, error) {
 
 	var agents []domain.Agent
 	for rows.Next() {
-		var agent domain.Agent
-		var tagsJSON string
-
-		err := rows.Scan(
-			&agent.ID,
-			&agent.Name,
-			&agent.Host,
-			&agent.Hostname,
-			&agent.IPAddress,
-			&agent.Status,
-			&agent.LastSeen,
-			&agent.Version,
-			&tagsJSON,
-			&agent.Description,
-			&agent.CreatedAt,
-			&agent.UpdatedAt,
-		)
+		agent, err := scanAgent(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if tagsJSON != "" && tagsJSON != "null" {
-			if err := json.Unmarshal([]byte(tagsJSON), &agent.Tags); err != nil {
-				return nil, err
-			}
-		}
-
-		agents = append(agents, agent)
+		agents = append(agents, *agent)
 	}
 
 	return agents, rows.Err()
@@ -146,7 +232,14 @@ func (r *AgentRepository) UpdateStatus(ctx context.Context, id string, status st
 		WHERE id = ?
 	`
 
-	_, err := r.db.ExecContext(ctx, query, status, lastSeen, time.Now(), id)
+	_, err := r.db.ExecContext(ctx, query, status, lastSeen.UTC(), time.Now().UTC(), id)
+	return err
+}
+
+// UpdateConfigVersion records the version of the server-managed config an agent reported running
+func (r *AgentRepository) UpdateConfigVersion(ctx context.Context, id string, version string) error {
+	query := `UPDATE agents SET config_version = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, version, id)
 	return err
 }
 
@@ -162,19 +255,37 @@ func (r *AgentRepository) SaveMetrics(ctx context.Context, metrics *domain.Agent
 		return err
 	}
 
+	// Samples without a timestamp are never treated as duplicates (NULLs are distinct)
+	var sampledAt interface{}
+	if !metrics.Metrics.Timestamp.IsZero() {
+		sampledAt = metrics.Metrics.Timestamp
+	}
+
 	query := `
-		INSERT INTO agent_metrics (agent_id, agent_name, metrics, received_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO agent_metrics (agent_id, agent_name, metrics, received_at, sampled_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (agent_id, sampled_at) DO NOTHING
 	`
 
-	_, err = r.db.ExecContext(ctx, query,
+	result, err := r.db.ExecContext(ctx, query,
 		metrics.AgentID,
 		metrics.AgentName,
 		string(metricsJSON),
 		metrics.ReceivedAt,
+		sampledAt,
 	)
+	if err != nil {
+		return err
+	}
 
-	return err
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrDuplicate
+	}
+	return nil
 }
 
 func (r *AgentRepository) GetLatestMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
@@ -182,7 +293,7 @@ func (r *AgentRepository) GetLatestMetrics(ctx context.Context, agentID string)
 		SELECT id, agent_id, agent_name, metrics, received_at
 		FROM agent_metrics
 		WHERE agent_id = ?
-		ORDER BY received_at DESC
+		ORDER BY COALESCE(sampled_at, received_at) DESC, id DESC
 		LIMIT 1
 	`
 
@@ -212,7 +323,8 @@ func (r *AgentRepository) GetLatestMetrics(ctx context.Context, agentID string)
 	return &metrics, nil
 }
 
-// PullMetrics pulls metrics from the agent's HTTP endpoint
+// PullMetrics pulls metrics from the agent's HTTP endpoint. The request is bounded by ctx;
+// the outcome is recorded even when ctx expires.
 func (r *AgentRepository) PullMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
 	// 1. Get agent from database
 	agent, err := r.GetByID(ctx, agentID)
@@ -229,29 +341,44 @@ func (r *AgentRepository) PullMetrics(ctx context.Context, agentID string) (*dom
 	if err != nil {
 		return nil, err
 	}
+	// Agents without protobuf or compression support ignore these and answer plain JSON
+	req.Header.Set("Accept", protostruct.ContentType+", application/json")
+	req.Header.Set("Accept-Encoding", compression.AcceptEncoding)
 
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	resp, err := client.Do(req)
+	store := context.WithoutCancel(ctx)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		// Agent is offline, update status
-		r.UpdateStatus(ctx, agentID, "offline", time.Now())
+		r.UpdateStatus(store, agentID, "offline", time.Now())
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		r.UpdateStatus(ctx, agentID, "error", time.Now())
+		r.UpdateStatus(store, agentID, "error", time.Now())
 		return nil, fmt.Errorf("agent returned status %d", resp.StatusCode)
 	}
 
-	// 3. Parse JSON response
+	// 3. Parse the protobuf or JSON response
+	body, err := compression.NewReader(resp.Header.Get("Content-Encoding"), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
 	var metricsResponse struct {
 		Success bool                 `json:"success"`
 		Data    domain.SystemMetrics `json:"data"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&metricsResponse); err != nil {
+	if contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); contentType == protostruct.ContentType {
+		data, err := io.ReadAll(io.LimitReader(body, maxPulledMetrics))
+		if err != nil {
+			return nil, err
+		}
+		if err := protostruct.Unmarshal(data, &metricsResponse.Data); err != nil {
+			return nil, err
+		}
+	} else if err := json.NewDecoder(body).Decode(&metricsResponse); err != nil {
 		return nil, err
 	}
 
@@ -264,12 +391,12 @@ func (r *AgentRepository) PullMetrics(ctx context.Context, agentID string) (*dom
 	}
 
 	// 5. Save metrics to database
-	if err := r.SaveMetrics(ctx, agentMetrics); err != nil {
+	if err := r.SaveMetrics(store, agentMetrics); err != nil && !errors.Is(err, domain.ErrDuplicate) {
 		return nil, err
 	}
 
 	// 6. Update agent status to online
-	if err := r.UpdateStatus(ctx, agentID, "online", time.Now()); err != nil {
+	if err := r.UpdateStatus(store, agentID, "online", time.Now()); err != nil {
 		return nil, err
 	}
 
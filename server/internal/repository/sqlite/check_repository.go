@@ -0,0 +1,519 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type CheckRepository struct {
+	db *sql.DB
+}
+
+func NewCheckRepository(db *sql.DB) *CheckRepository {
+	return &CheckRepository{
+		db: db,
+	}
+}
+
+// checkColumns is the column list matching scanCheck
+const checkColumns = `id, name, spec, interval_seconds, locations, failure_threshold, notifications, paused, created_at, updated_at`
+
+// checkResultColumns is the column list matching scanCheckResult
+const checkResultColumns = `id, check_id, location, status, latency_ms, COALESCE(error, ''), COALESCE(status_code, 0), cert_expires_at, answers, checked_at`
+
+func (r *CheckRepository) GetAll(ctx context.Context) ([]domain.Check, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+checkColumns+` FROM checks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []domain.Check{}
+	for rows.Next() {
+		check, err := scanCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *check)
+	}
+
+	return checks, rows.Err()
+}
+
+func (r *CheckRepository) Get(ctx context.Context, id int64) (*domain.Check, error) {
+	check, err := scanCheck(r.db.QueryRowContext(ctx, `SELECT `+checkColumns+` FROM checks WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return check, err
+}
+
+func (r *CheckRepository) Create(ctx context.Context, check *domain.Check) error {
+	spec, locations, routes, err := encodeCheck(check)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	check.CreatedAt = now
+	check.UpdatedAt = now
+
+	query := `
+		INSERT INTO checks (name, spec, interval_seconds, locations, failure_threshold, notifications, paused, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		check.Name,
+		spec,
+		check.IntervalSeconds,
+		locations,
+		check.FailureThreshold,
+		routes,
+		check.Paused,
+		check.CreatedAt,
+		check.UpdatedAt,
+	).Scan(&check.ID)
+}
+
+func (r *CheckRepository) Update(ctx context.Context, check *domain.Check) error {
+	spec, locations, routes, err := encodeCheck(check)
+	if err != nil {
+		return err
+	}
+
+	check.UpdatedAt = time.Now().UTC()
+
+	query := `
+		UPDATE checks
+		SET name = ?, spec = ?, interval_seconds = ?, locations = ?, failure_threshold = ?, notifications = ?, paused = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		check.Name,
+		spec,
+		check.IntervalSeconds,
+		locations,
+		check.FailureThreshold,
+		routes,
+		check.Paused,
+		check.UpdatedAt,
+		check.ID,
+	).Scan(&check.CreatedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+func (r *CheckRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM checks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *CheckRepository) SaveResult(ctx context.Context, result *domain.CheckResult) error {
+	var answers interface{}
+	if len(result.Answers) > 0 {
+		data, err := json.Marshal(result.Answers)
+		if err != nil {
+			return err
+		}
+		answers = string(data)
+	}
+
+	var statusCode interface{}
+	if result.StatusCode != 0 {
+		statusCode = result.StatusCode
+	}
+
+	query := `
+		INSERT INTO check_results (check_id, location, status, latency_ms, error, status_code, cert_expires_at, answers, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		result.CheckID,
+		result.Location,
+		result.Status,
+		result.LatencyMs,
+		result.Error,
+		statusCode,
+		utcOrNull(result.CertExpiresAt),
+		answers,
+		result.CheckedAt.UTC(), // agents report probe results in their own zone
+	).Scan(&result.ID)
+}
+
+func (r *CheckRepository) GetResults(ctx context.Context, q domain.CheckResultQuery) ([]domain.CheckResult, error) {
+	conditions := []string{"check_id = ?"}
+	args := []interface{}{q.CheckID}
+
+	if q.Location != "" {
+		conditions = append(conditions, "location = ?")
+		args = append(args, q.Location)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "checked_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `SELECT ` + checkResultColumns + ` FROM check_results WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY checked_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	return r.queryResults(ctx, query, args...)
+}
+
+func (r *CheckRepository) GetLatestResults(ctx context.Context) ([]domain.CheckResult, error) {
+	query := `
+		SELECT ` + checkResultColumns + ` FROM check_results
+		WHERE id IN (SELECT MAX(id) FROM check_results GROUP BY check_id, location)
+		ORDER BY check_id, location
+	`
+	return r.queryResults(ctx, query)
+}
+
+func (r *CheckRepository) GetUptime(ctx context.Context, checkID int64, since time.Time) ([]domain.CheckUptime, error) {
+	query := `
+		SELECT location, COUNT(*), SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), AVG(latency_ms)
+		FROM check_results
+		WHERE check_id = ? AND checked_at >= ?
+		GROUP BY location
+		ORDER BY location
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, domain.CheckStatusUp, checkID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uptimes := []domain.CheckUptime{}
+	for rows.Next() {
+		var uptime domain.CheckUptime
+		if err := rows.Scan(&uptime.Location, &uptime.Results, &uptime.Up, &uptime.AvgLatencyMs); err != nil {
+			return nil, err
+		}
+		uptimes = append(uptimes, uptime)
+	}
+
+	return uptimes, rows.Err()
+}
+
+func (r *CheckRepository) PruneResults(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM check_results WHERE checked_at < ?`, before.UTC()); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `DELETE FROM heartbeat_pings WHERE received_at < ?`, before.UTC())
+	return err
+}
+
+// SaveAlert inserts a new alert or updates the status and resolution of an existing one
+func (r *CheckRepository) SaveAlert(ctx context.Context, alert *domain.CheckAlert) error {
+	if alert.ID != 0 {
+		_, err := r.db.ExecContext(ctx,
+			`UPDATE check_alerts SET status = ?, resolved_at = ? WHERE id = ?`,
+			alert.Status, utcOrNull(alert.ResolvedAt), alert.ID,
+		)
+		return err
+	}
+
+	query := `
+		INSERT INTO check_alerts (check_id, check_name, location, status, error, started_at, fired_at, resolved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		alert.CheckID,
+		alert.CheckName,
+		alert.Location,
+		alert.Status,
+		alert.Error,
+		alert.StartedAt.UTC(),
+		alert.FiredAt.UTC(),
+		utcOrNull(alert.ResolvedAt),
+	).Scan(&alert.ID)
+}
+
+func (r *CheckRepository) GetAlerts(ctx context.Context, q domain.CheckAlertQuery) ([]domain.CheckAlert, error) {
+	var conditions []string
+	var args []interface{}
+
+	if q.CheckID != 0 {
+		conditions = append(conditions, "check_id = ?")
+		args = append(args, q.CheckID)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, check_id, check_name, location, status, COALESCE(error, ''), started_at, fired_at, resolved_at
+		FROM check_alerts
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY fired_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []domain.CheckAlert{}
+	for rows.Next() {
+		var alert domain.CheckAlert
+		var resolvedAt sql.NullTime
+		err := rows.Scan(
+			&alert.ID,
+			&alert.CheckID,
+			&alert.CheckName,
+			&alert.Location,
+			&alert.Status,
+			&alert.Error,
+			&alert.StartedAt,
+			&alert.FiredAt,
+			&resolvedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (r *CheckRepository) SavePing(ctx context.Context, ping *domain.HeartbeatPing) error {
+	query := `
+		INSERT INTO heartbeat_pings (check_id, kind, exit_code, duration_ms, message, source, user_agent, received_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		ping.CheckID,
+		ping.Kind,
+		ping.ExitCode,
+		ping.DurationMs,
+		ping.Message,
+		ping.Source,
+		ping.UserAgent,
+		ping.ReceivedAt.UTC(),
+	).Scan(&ping.ID)
+}
+
+func (r *CheckRepository) GetPings(ctx context.Context, q domain.HeartbeatPingQuery) ([]domain.HeartbeatPing, error) {
+	conditions := []string{"check_id = ?"}
+	args := []interface{}{q.CheckID}
+
+	if q.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, q.Kind)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "received_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, check_id, kind, exit_code, duration_ms, COALESCE(message, ''), source, COALESCE(user_agent, ''), received_at
+		FROM heartbeat_pings
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY received_at DESC, id DESC LIMIT ?
+	`
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pings := []domain.HeartbeatPing{}
+	for rows.Next() {
+		var ping domain.HeartbeatPing
+		var exitCode sql.NullInt64
+		var durationMs sql.NullFloat64
+		err := rows.Scan(
+			&ping.ID,
+			&ping.CheckID,
+			&ping.Kind,
+			&exitCode,
+			&durationMs,
+			&ping.Message,
+			&ping.Source,
+			&ping.UserAgent,
+			&ping.ReceivedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			ping.ExitCode = &code
+		}
+		if durationMs.Valid {
+			ping.DurationMs = &durationMs.Float64
+		}
+		pings = append(pings, ping)
+	}
+
+	return pings, rows.Err()
+}
+
+func (r *CheckRepository) queryResults(ctx context.Context, query string, args ...interface{}) ([]domain.CheckResult, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []domain.CheckResult{}
+	for rows.Next() {
+		result, err := scanCheckResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+
+	return results, rows.Err()
+}
+
+// utcOrNull returns an optional time in UTC, as times are compared as text
+func utcOrNull(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func encodeCheck(check *domain.Check) (spec, locations, routes string, err error) {
+	data, err := json.Marshal(check.CheckSpec)
+	if err != nil {
+		return "", "", "", err
+	}
+	spec = string(data)
+
+	if data, err = json.Marshal(check.Locations); err != nil {
+		return "", "", "", err
+	}
+	locations = string(data)
+
+	notifications := check.Notifications
+	if notifications == nil {
+		notifications = []domain.NotificationRoute{}
+	}
+	if data, err = json.Marshal(notifications); err != nil {
+		return "", "", "", err
+	}
+	return spec, locations, string(data), nil
+}
+
+func scanCheck(row rowScanner) (*domain.Check, error) {
+	var check domain.Check
+	var spec, locations, routes string
+
+	err := row.Scan(
+		&check.ID,
+		&check.Name,
+		&spec,
+		&check.IntervalSeconds,
+		&locations,
+		&check.FailureThreshold,
+		&routes,
+		&check.Paused,
+		&check.CreatedAt,
+		&check.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(spec), &check.CheckSpec); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(locations), &check.Locations); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(routes), &check.Notifications); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+func scanCheckResult(row rowScanner) (*domain.CheckResult, error) {
+	var result domain.CheckResult
+	var certExpiresAt sql.NullTime
+	var answers sql.NullString
+
+	err := row.Scan(
+		&result.ID,
+		&result.CheckID,
+		&result.Location,
+		&result.Status,
+		&result.LatencyMs,
+		&result.Error,
+		&result.StatusCode,
+		&certExpiresAt,
+		&answers,
+		&result.CheckedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if certExpiresAt.Valid {
+		result.CertExpiresAt = &certExpiresAt.Time
+	}
+	if answers.Valid {
+		if err := json.Unmarshal([]byte(answers.String), &result.Answers); err != nil {
+			return nil, err
+		}
+	}
+	return &result, nil
+}
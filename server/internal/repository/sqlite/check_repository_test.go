@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestCheckResultsInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	repo := NewCheckRepository(openTestDB(t))
+	jakarta := time.FixedZone("WIB", 7*3600)
+	newYork := time.FixedZone("EST", -5*3600)
+
+	check := &domain.Check{Name: "api", CheckSpec: domain.CheckSpec{Type: domain.CheckTypeTCP, Target: "api:443"}}
+	if err := repo.Create(ctx, check); err != nil {
+		t.Fatal(err)
+	}
+
+	// Agents report the results of their probes in their own zone
+	for _, result := range []domain.CheckResult{
+		{Location: "agent-jakarta", ProbeResult: domain.ProbeResult{Status: domain.CheckStatusDown, CheckedAt: time.Date(2026, 10, 19, 8, 50, 0, 0, jakarta)}},  // 01:50Z
+		{Location: "agent-jakarta", ProbeResult: domain.ProbeResult{Status: domain.CheckStatusUp, CheckedAt: time.Date(2026, 10, 19, 9, 10, 0, 0, jakarta)}},    // 02:10Z
+		{Location: "agent-new-york", ProbeResult: domain.ProbeResult{Status: domain.CheckStatusUp, CheckedAt: time.Date(2026, 10, 18, 20, 55, 0, 0, newYork)}},  // 01:55Z
+		{Location: "agent-new-york", ProbeResult: domain.ProbeResult{Status: domain.CheckStatusDown, CheckedAt: time.Date(2026, 10, 18, 21, 5, 0, 0, newYork)}}, // 02:05Z
+		{Location: "agent-new-york", ProbeResult: domain.ProbeResult{Status: domain.CheckStatusUp, CheckedAt: time.Date(2026, 10, 18, 21, 15, 0, 0, newYork)}},  // 02:15Z
+	} {
+		result.CheckID = check.ID
+		if err := repo.SaveResult(ctx, &result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	since := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	uptimes, err := repo.GetUptime(ctx, check.ID, since.In(jakarta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][2]int)
+	for _, uptime := range uptimes {
+		got[uptime.Location] = [2]int{int(uptime.Up), int(uptime.Results)}
+	}
+	if len(got) != 2 || got["agent-jakarta"] != [2]int{1, 1} || got["agent-new-york"] != [2]int{1, 2} {
+		t.Errorf("GetUptime(%s) = %v up of results, want agent-jakarta [1 1], agent-new-york [1 2]", since, got)
+	}
+
+	results, err := repo.GetResults(ctx, domain.CheckResultQuery{CheckID: check.ID, Since: since.In(newYork)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var times []time.Time
+	for _, result := range results {
+		times = append(times, result.CheckedAt)
+	}
+	if len(times) != 3 || !times[0].Equal(since.Add(15*time.Minute)) || !times[1].Equal(since.Add(10*time.Minute)) || !times[2].Equal(since.Add(5*time.Minute)) {
+		t.Errorf("GetResults(since %s) = %v, want the 3 results after it, newest first", since, times)
+	}
+
+	if err := repo.PruneResults(ctx, since.In(jakarta)); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := repo.GetResults(ctx, domain.CheckResultQuery{CheckID: check.ID}); err != nil || len(results) != 3 {
+		t.Errorf("GetResults() after pruning before %s = %d results, %v, want 3", since, len(results), err)
+	}
+}
+
+func TestHeartbeatPingsInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	repo := NewCheckRepository(openTestDB(t))
+	newYork := time.FixedZone("EST", -5*3600)
+
+	check := &domain.Check{Name: "backup", CheckSpec: domain.CheckSpec{Type: domain.CheckTypeHeartbeat, Target: "token"}}
+	if err := repo.Create(ctx, check); err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{since.Add(-5 * time.Minute).In(newYork), since.Add(5 * time.Minute).In(newYork)} {
+		ping := &domain.HeartbeatPing{CheckID: check.ID, Kind: domain.HeartbeatPingSuccess, Source: "10.0.0.9", ReceivedAt: at}
+		if err := repo.SavePing(ctx, ping); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pings, err := repo.GetPings(ctx, domain.HeartbeatPingQuery{CheckID: check.ID, Since: since})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pings) != 1 || !pings[0].ReceivedAt.Equal(since.Add(5*time.Minute)) {
+		t.Errorf("GetPings(since %s) = %+v, want the ping at 02:05Z", since, pings)
+	}
+}
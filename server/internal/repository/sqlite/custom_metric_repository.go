@@ -0,0 +1,218 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type CustomMetricRepository struct {
+	db *sql.DB
+}
+
+func NewCustomMetricRepository(db *sql.DB) *CustomMetricRepository {
+	return &CustomMetricRepository{
+		db: db,
+	}
+}
+
+// SaveChecks replaces the latest result of each plugin, keeping a newer one already stored
+func (r *CustomMetricRepository) SaveChecks(ctx context.Context, agentID string, checks []domain.CustomCheck) error {
+	query := `
+		INSERT INTO custom_checks (agent_id, plugin, status, exit_code, output, duration_ms, metrics, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (agent_id, plugin) DO UPDATE SET
+			status = excluded.status,
+			exit_code = excluded.exit_code,
+			output = excluded.output,
+			duration_ms = excluded.duration_ms,
+			metrics = excluded.metrics,
+			checked_at = excluded.checked_at
+		WHERE excluded.checked_at >= custom_checks.checked_at
+	`
+
+	for _, check := range checks {
+		metricsJSON, err := json.Marshal(check.Metrics)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.db.ExecContext(ctx, query,
+			agentID,
+			check.Plugin,
+			check.Status,
+			check.ExitCode,
+			check.Output,
+			check.DurationMs,
+			string(metricsJSON),
+			check.CheckedAt.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *CustomMetricRepository) GetChecks(ctx context.Context, agentID string) ([]domain.CustomCheck, error) {
+	query := `
+		SELECT plugin, status, exit_code, output, duration_ms, metrics, checked_at
+		FROM custom_checks
+		WHERE agent_id = ?
+		ORDER BY plugin
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []domain.CustomCheck
+	for rows.Next() {
+		var check domain.CustomCheck
+		var metricsJSON string
+
+		err := rows.Scan(
+			&check.Plugin,
+			&check.Status,
+			&check.ExitCode,
+			&check.Output,
+			&check.DurationMs,
+			&metricsJSON,
+			&check.CheckedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if metricsJSON != "" && metricsJSON != "null" {
+			if err := json.Unmarshal([]byte(metricsJSON), &check.Metrics); err != nil {
+				return nil, err
+			}
+		}
+
+		checks = append(checks, check)
+	}
+
+	return checks, rows.Err()
+}
+
+// SavePoints stores metric points, ignoring points already stored for the same series and time
+func (r *CustomMetricRepository) SavePoints(ctx context.Context, points []domain.CustomMetricPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO custom_metrics (agent_id, source, name, value, unit, labels, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, point := range points {
+		labelsJSON, err := json.Marshal(point.Labels)
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.ExecContext(ctx,
+			point.AgentID,
+			point.Source,
+			point.Name,
+			point.Value,
+			point.Unit,
+			string(labelsJSON),
+			point.RecordedAt.UTC(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *CustomMetricRepository) QueryPoints(ctx context.Context, q domain.CustomMetricQuery) ([]domain.CustomMetricPoint, error) {
+	conditions := []string{"agent_id = ?"}
+	args := []interface{}{q.AgentID}
+
+	if q.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, q.Source)
+	}
+	if q.Name != "" {
+		conditions = append(conditions, "name = ?")
+		args = append(args, q.Name)
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "recorded_at >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "recorded_at <= ?")
+		args = append(args, q.To.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT agent_id, source, name, value, unit, labels, recorded_at
+		FROM custom_metrics
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY recorded_at DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []domain.CustomMetricPoint
+	for rows.Next() {
+		var point domain.CustomMetricPoint
+		var labelsJSON string
+
+		err := rows.Scan(
+			&point.AgentID,
+			&point.Source,
+			&point.Name,
+			&point.Value,
+			&point.Unit,
+			&labelsJSON,
+			&point.RecordedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if labelsJSON != "" && labelsJSON != "null" {
+			if err := json.Unmarshal([]byte(labelsJSON), &point.Labels); err != nil {
+				return nil, err
+			}
+		}
+
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
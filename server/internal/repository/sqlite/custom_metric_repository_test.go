@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// registerAgent registers an agent for the rows that reference it
+func registerAgent(t *testing.T, db *sql.DB, id string) {
+	t.Helper()
+	agent := &domain.Agent{ID: id, Name: id, Host: "127.0.0.1", Status: "online", LastSeen: time.Now()}
+	if err := NewAgentRepository(db).Register(context.Background(), agent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitDBRemovesDuplicatePoints(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+
+	// Points stored before the unique index existed
+	if _, err := db.Exec(`DROP INDEX idx_custom_metrics_point`); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 10, 19, 1, 30, 0, 0, time.UTC)
+	for i, value := range []float64{1, 1, 2, 1} {
+		recordedAt := at
+		if i == 2 {
+			recordedAt = at.Add(time.Minute)
+		}
+		_, err := db.Exec(`INSERT INTO custom_metrics (agent_id, source, name, value, unit, labels, recorded_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			"agent-1", "check_queue", "queue_depth", value, "", `{"queue":"mail"}`, recordedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewCustomMetricRepository(db)
+	points, err := repo.QueryPoints(ctx, domain.CustomMetricQuery{AgentID: "agent-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("%d points after the migration, want 2: %+v", len(points), points)
+	}
+
+	// A point saved again is ignored
+	point := domain.CustomMetricPoint{AgentID: "agent-1", Source: "check_queue", Name: "queue_depth", Value: 1, Labels: map[string]string{"queue": "mail"}, RecordedAt: at}
+	if err := repo.SavePoints(ctx, []domain.CustomMetricPoint{point}); err != nil {
+		t.Fatal(err)
+	}
+	if points, err := repo.QueryPoints(ctx, domain.CustomMetricQuery{AgentID: "agent-1"}); err != nil || len(points) != 2 {
+		t.Fatalf("%d points after saving one again, %v, want 2", len(points), err)
+	}
+}
+
+func TestSaveChecksKeepsNewerResult(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+	repo := NewCustomMetricRepository(db)
+	at := time.Date(2026, 10, 19, 1, 30, 0, 0, time.UTC)
+
+	// A replayed sample carries an older result of the plugin
+	for _, check := range []domain.CustomCheck{
+		{Plugin: "backup", Status: "ok", CheckedAt: at.Add(time.Minute)},
+		{Plugin: "backup", Status: "critical", CheckedAt: at},
+	} {
+		if err := repo.SaveChecks(ctx, "agent-1", []domain.CustomCheck{check}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checks, err := repo.GetChecks(ctx, "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 1 || checks[0].Status != "ok" || !checks[0].CheckedAt.Equal(at.Add(time.Minute)) {
+		t.Errorf("GetChecks() = %+v, want the newer ok result", checks)
+	}
+}
+
+func TestQueryPointsInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+	repo := NewCustomMetricRepository(db)
+	jakarta := time.FixedZone("WIB", 7*3600)
+	newYork := time.FixedZone("EST", -5*3600)
+	at := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	// Pushed points carry the offset of the app that sent them
+	var points []domain.CustomMetricPoint
+	for i, recordedAt := range []time.Time{
+		at.Add(-10 * time.Minute).In(jakarta),
+		at.Add(5 * time.Minute).In(newYork),
+		at.Add(15 * time.Minute).In(jakarta),
+	} {
+		points = append(points, domain.CustomMetricPoint{AgentID: "agent-1", Source: "app", Name: "queue_depth", Value: float64(i), RecordedAt: recordedAt})
+	}
+	if err := repo.SavePoints(ctx, points); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query domain.CustomMetricQuery
+		want  []float64
+	}{
+		{"from, west of the stored times", domain.CustomMetricQuery{From: at.In(newYork)}, []float64{1, 2}},
+		{"from, east of the stored times", domain.CustomMetricQuery{From: at.In(jakarta)}, []float64{1, 2}},
+		{"to", domain.CustomMetricQuery{To: at.Add(10 * time.Minute).In(jakarta)}, []float64{0, 1}},
+		{"window", domain.CustomMetricQuery{From: at.In(jakarta), To: at.Add(10 * time.Minute).In(newYork)}, []float64{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.AgentID = "agent-1"
+			points, err := repo.QueryPoints(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []float64
+			for _, point := range points {
+				values = append(values, point.Value)
+			}
+			slices.Sort(values)
+			if !slices.Equal(values, tt.want) {
+				t.Errorf("QueryPoints() = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
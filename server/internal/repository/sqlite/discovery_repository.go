@@ -0,0 +1,141 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type DiscoveryRepository struct {
+	db *sql.DB
+}
+
+func NewDiscoveryRepository(db *sql.DB) *DiscoveryRepository {
+	return &DiscoveryRepository{
+		db: db,
+	}
+}
+
+// discoveredColumns is the column list matching scanDiscovered
+const discoveredColumns = `id, host, source, name, COALESCE(hostname, ''), COALESCE(ip_address, ''), COALESCE(version, ''), tags, status, first_seen, last_seen`
+
+func (r *DiscoveryRepository) Save(ctx context.Context, agent *domain.DiscoveredAgent) error {
+	tags, err := json.Marshal(agent.Tags)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO discovered_agents (host, source, name, hostname, ip_address, version, tags, status, first_seen, last_seen)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (host) DO UPDATE SET
+			source = excluded.source,
+			name = excluded.name,
+			hostname = excluded.hostname,
+			ip_address = excluded.ip_address,
+			version = excluded.version,
+			tags = excluded.tags,
+			last_seen = excluded.last_seen
+		RETURNING id, status, first_seen
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		agent.Host,
+		agent.Source,
+		agent.Name,
+		agent.Hostname,
+		agent.IPAddress,
+		agent.Version,
+		string(tags),
+		agent.Status,
+		agent.LastSeen,
+		agent.LastSeen,
+	).Scan(&agent.ID, &agent.Status, &agent.FirstSeen)
+}
+
+func (r *DiscoveryRepository) Get(ctx context.Context, id int64) (*domain.DiscoveredAgent, error) {
+	query := `SELECT ` + discoveredColumns + ` FROM discovered_agents WHERE id = ?`
+
+	agent, err := scanDiscovered(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return agent, err
+}
+
+func (r *DiscoveryRepository) GetAll(ctx context.Context, status string) ([]domain.DiscoveredAgent, error) {
+	query := `SELECT ` + discoveredColumns + ` FROM discovered_agents`
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE status = ?`
+		args = append(args, status)
+	}
+	query += ` ORDER BY first_seen, id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agents := []domain.DiscoveredAgent{}
+	for rows.Next() {
+		agent, err := scanDiscovered(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *agent)
+	}
+
+	return agents, rows.Err()
+}
+
+func (r *DiscoveryRepository) SetStatus(ctx context.Context, id int64, status string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE discovered_agents SET status = ? WHERE id = ?`, status, id)
+	return err
+}
+
+func (r *DiscoveryRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM discovered_agents WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func scanDiscovered(row rowScanner) (*domain.DiscoveredAgent, error) {
+	var agent domain.DiscoveredAgent
+	var tags string
+
+	err := row.Scan(
+		&agent.ID,
+		&agent.Host,
+		&agent.Source,
+		&agent.Name,
+		&agent.Hostname,
+		&agent.IPAddress,
+		&agent.Version,
+		&tags,
+		&agent.Status,
+		&agent.FirstSeen,
+		&agent.LastSeen,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &agent.Tags); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
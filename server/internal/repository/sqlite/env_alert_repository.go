@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type EnvAlertRepository struct {
+	db *sql.DB
+}
+
+func NewEnvAlertRepository(db *sql.DB) *EnvAlertRepository {
+	return &EnvAlertRepository{
+		db: db,
+	}
+}
+
+func (r *EnvAlertRepository) GetRules(ctx context.Context) ([]domain.EnvAlertRule, error) {
+	query := `
+		SELECT id, name, sensor_id, condition, threshold, duration_seconds, enabled, created_at, updated_at
+		FROM env_alert_rules
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []domain.EnvAlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (r *EnvAlertRepository) GetRule(ctx context.Context, id int64) (*domain.EnvAlertRule, error) {
+	query := `
+		SELECT id, name, sensor_id, condition, threshold, duration_seconds, enabled, created_at, updated_at
+		FROM env_alert_rules
+		WHERE id = ?
+	`
+
+	rule, err := scanAlertRule(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rule, err
+}
+
+func (r *EnvAlertRepository) CreateRule(ctx context.Context, rule *domain.EnvAlertRule) error {
+	now := time.Now()
+	rule.CreatedAt = now
+	rule.UpdatedAt = now
+
+	query := `
+		INSERT INTO env_alert_rules (name, sensor_id, condition, threshold, duration_seconds, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		rule.Name,
+		rule.SensorID,
+		rule.Condition,
+		rule.Threshold,
+		rule.DurationSeconds,
+		rule.Enabled,
+		rule.CreatedAt,
+		rule.UpdatedAt,
+	).Scan(&rule.ID)
+}
+
+func (r *EnvAlertRepository) UpdateRule(ctx context.Context, rule *domain.EnvAlertRule) error {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE env_alert_rules
+		SET name = ?, sensor_id = ?, condition = ?, threshold = ?, duration_seconds = ?, enabled = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at
+	`
+
+	err := r.db.QueryRowContext(ctx, query,
+		rule.Name,
+		rule.SensorID,
+		rule.Condition,
+		rule.Threshold,
+		rule.DurationSeconds,
+		rule.Enabled,
+		rule.UpdatedAt,
+		rule.ID,
+	).Scan(&rule.CreatedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+func (r *EnvAlertRepository) DeleteRule(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM env_alert_rules WHERE id = ?`, id)
+	return err
+}
+
+// SaveAlert inserts a new alert or updates the value, status and resolution of an existing one
+func (r *EnvAlertRepository) SaveAlert(ctx context.Context, alert *domain.EnvAlert) error {
+	if alert.ID != 0 {
+		_, err := r.db.ExecContext(ctx,
+			`UPDATE env_alerts SET value = ?, status = ?, resolved_at = ? WHERE id = ?`,
+			alert.Value, alert.Status, alert.ResolvedAt, alert.ID,
+		)
+		return err
+	}
+
+	query := `
+		INSERT INTO env_alerts (rule_id, rule_name, sensor_id, condition, threshold, value, status, started_at, fired_at, resolved_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		alert.RuleID,
+		alert.RuleName,
+		alert.SensorID,
+		alert.Condition,
+		alert.Threshold,
+		alert.Value,
+		alert.Status,
+		alert.StartedAt,
+		alert.FiredAt,
+		alert.ResolvedAt,
+	).Scan(&alert.ID)
+}
+
+func (r *EnvAlertRepository) GetAlerts(ctx context.Context, q domain.EnvAlertQuery) ([]domain.EnvAlert, error) {
+	var conditions []string
+	var args []interface{}
+
+	if q.RuleID != 0 {
+		conditions = append(conditions, "rule_id = ?")
+		args = append(args, q.RuleID)
+	}
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `
+		SELECT id, rule_id, rule_name, sensor_id, condition, threshold, value, status, started_at, fired_at, resolved_at
+		FROM env_alerts
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY fired_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []domain.EnvAlert
+	for rows.Next() {
+		var alert domain.EnvAlert
+		var resolvedAt sql.NullTime
+		err := rows.Scan(
+			&alert.ID,
+			&alert.RuleID,
+			&alert.RuleName,
+			&alert.SensorID,
+			&alert.Condition,
+			&alert.Threshold,
+			&alert.Value,
+			&alert.Status,
+			&alert.StartedAt,
+			&alert.FiredAt,
+			&resolvedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func scanAlertRule(row rowScanner) (*domain.EnvAlertRule, error) {
+	var rule domain.EnvAlertRule
+	err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&rule.SensorID,
+		&rule.Condition,
+		&rule.Threshold,
+		&rule.DurationSeconds,
+		&rule.Enabled,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
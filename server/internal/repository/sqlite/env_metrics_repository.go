@@ -3,6 +3,8 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"strings"
+	"time"
 
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
 )
@@ -17,6 +19,7 @@ func NewEnvMetricsRepository(db *sql.DB) *EnvMetricsRepository {
 	}
 }
 
+// GetLatest returns the legacy view: the latest env_metrics row overlaid with newer sensor readings
 func (r *EnvMetricsRepository) GetLatest(ctx context.Context) (*domain.EnvMetrics, error) {
 	query := `
 		SELECT id, first_temperature, first_humidity, second_temperature, second_humidity, created_at
@@ -35,14 +38,20 @@ func (r *EnvMetricsRepository) GetLatest(ctx context.Context) (*domain.EnvMetric
 		&metrics.CreatedAt,
 	)
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+	var legacy *domain.EnvMetrics
+	switch {
+	case err == nil:
+		legacy = &metrics
+	case err != sql.ErrNoRows:
+		return nil, err
 	}
+
+	readings, err := r.GetLatestReadings(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return &metrics, nil
+	return domain.NewEnvironment(legacy, readings), nil
 }
 
 func (r *EnvMetricsRepository) Create(ctx context.Context, metrics *domain.EnvMetrics) error {
@@ -57,7 +66,7 @@ func (r *EnvMetricsRepository) Create(ctx context.Context, metrics *domain.EnvMe
 		metrics.FirstHumidity,
 		metrics.SecondTemperature,
 		metrics.SecondHumidity,
-		metrics.CreatedAt,
+		metrics.CreatedAt.UTC(),
 	).Scan(
 		&metrics.ID,
 		&metrics.FirstTemperature,
@@ -69,3 +78,258 @@ func (r *EnvMetricsRepository) Create(ctx context.Context, metrics *domain.EnvMe
 
 	return err
 }
+
+func (r *EnvMetricsRepository) GetSensors(ctx context.Context) ([]domain.Sensor, error) {
+	query := `
+		SELECT id, name, location, kind, unit, agent_id, created_at, updated_at
+		FROM sensors
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sensors []domain.Sensor
+	for rows.Next() {
+		sensor, err := scanSensor(rows)
+		if err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, *sensor)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	readings, err := r.GetLatestReadings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(map[string]domain.SensorReading, len(readings))
+	for _, reading := range readings {
+		latest[reading.SensorID] = reading
+	}
+	for i := range sensors {
+		if reading, ok := latest[sensors[i].ID]; ok {
+			sensors[i].LatestReading = &reading
+		}
+	}
+
+	return sensors, nil
+}
+
+func (r *EnvMetricsRepository) GetSensor(ctx context.Context, id string) (*domain.Sensor, error) {
+	query := `
+		SELECT id, name, location, kind, unit, agent_id, created_at, updated_at
+		FROM sensors
+		WHERE id = ?
+	`
+
+	sensor, err := scanSensor(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reading domain.SensorReading
+	err = r.db.QueryRowContext(ctx, `
+		SELECT id, sensor_id, value, recorded_at
+		FROM sensor_readings
+		WHERE sensor_id = ?
+		ORDER BY recorded_at DESC, id DESC
+		LIMIT 1
+	`, id).Scan(&reading.ID, &reading.SensorID, &reading.Value, &reading.RecordedAt)
+	if err == nil {
+		sensor.LatestReading = &reading
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return sensor, nil
+}
+
+func (r *EnvMetricsRepository) SaveSensor(ctx context.Context, sensor *domain.Sensor) error {
+	now := time.Now()
+	if sensor.CreatedAt.IsZero() {
+		sensor.CreatedAt = now
+	}
+	sensor.UpdatedAt = now
+
+	query := `
+		INSERT INTO sensors (id, name, location, kind, unit, agent_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name,
+			location = excluded.location,
+			kind = excluded.kind,
+			unit = excluded.unit,
+			agent_id = excluded.agent_id,
+			updated_at = excluded.updated_at
+		RETURNING created_at
+	`
+
+	var agentID sql.NullString
+	if sensor.AgentID != "" {
+		agentID = sql.NullString{String: sensor.AgentID, Valid: true}
+	}
+
+	return r.db.QueryRowContext(ctx, query,
+		sensor.ID,
+		sensor.Name,
+		sensor.Location,
+		sensor.Kind,
+		sensor.Unit,
+		agentID,
+		sensor.CreatedAt,
+		sensor.UpdatedAt,
+	).Scan(&sensor.CreatedAt)
+}
+
+func (r *EnvMetricsRepository) DeleteSensor(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM sensors WHERE id = ?`, id)
+	return err
+}
+
+func (r *EnvMetricsRepository) SaveReadings(ctx context.Context, readings []domain.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO sensor_readings (sensor_id, value, recorded_at)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := range readings {
+		reading := &readings[i]
+		if reading.RecordedAt.IsZero() {
+			reading.RecordedAt = time.Now()
+		}
+		// Stored in UTC so that readings sent with any offset compare and sort as text
+		if err := stmt.QueryRowContext(ctx, reading.SensorID, reading.Value, reading.RecordedAt.UTC()).Scan(&reading.ID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLatestReadings returns the most recent reading of every sensor
+func (r *EnvMetricsRepository) GetLatestReadings(ctx context.Context) ([]domain.SensorReading, error) {
+	query := `
+		SELECT r.id, r.sensor_id, r.value, r.recorded_at
+		FROM sensors s
+		JOIN sensor_readings r ON r.id = (
+			SELECT id FROM sensor_readings
+			WHERE sensor_id = s.id
+			ORDER BY recorded_at DESC, id DESC
+			LIMIT 1
+		)
+		ORDER BY s.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []domain.SensorReading
+	for rows.Next() {
+		var reading domain.SensorReading
+		if err := rows.Scan(&reading.ID, &reading.SensorID, &reading.Value, &reading.RecordedAt); err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rows.Err()
+}
+
+// GetReadings returns readings in a time range, ordered by sensor and time
+func (r *EnvMetricsRepository) GetReadings(ctx context.Context, q domain.ReadingQuery) ([]domain.SensorReading, error) {
+	var conditions []string
+	var args []interface{}
+
+	if len(q.SensorIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(q.SensorIDs)), ", ")
+		conditions = append(conditions, "sensor_id IN ("+placeholders+")")
+		for _, id := range q.SensorIDs {
+			args = append(args, id)
+		}
+	}
+	if !q.From.IsZero() {
+		conditions = append(conditions, "recorded_at >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		conditions = append(conditions, "recorded_at <= ?")
+		args = append(args, q.To.UTC())
+	}
+
+	query := `SELECT id, sensor_id, value, recorded_at FROM sensor_readings`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY sensor_id, recorded_at, id"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []domain.SensorReading
+	for rows.Next() {
+		var reading domain.SensorReading
+		if err := rows.Scan(&reading.ID, &reading.SensorID, &reading.Value, &reading.RecordedAt); err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rows.Err()
+}
+
+func scanSensor(row rowScanner) (*domain.Sensor, error) {
+	var sensor domain.Sensor
+	var location, unit, agentID sql.NullString
+	err := row.Scan(
+		&sensor.ID,
+		&sensor.Name,
+		&location,
+		&sensor.Kind,
+		&unit,
+		&agentID,
+		&sensor.CreatedAt,
+		&sensor.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sensor.Location = location.String
+	sensor.Unit = unit.String
+	sensor.AgentID = agentID.String
+	return &sensor, nil
+}
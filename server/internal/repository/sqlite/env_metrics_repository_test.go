@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// openTestDB opens an initialized database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestReadingsInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	repo := NewEnvMetricsRepository(openTestDB(t))
+	sensor := domain.LegacySensors[0].ID
+	jakarta := time.FixedZone("WIB", 7*3600)
+
+	err := repo.SaveReadings(ctx, []domain.SensorReading{
+		{SensorID: sensor, Value: 1, RecordedAt: time.Date(2026, 10, 19, 8, 0, 0, 0, jakarta)}, // 01:00Z
+		{SensorID: sensor, Value: 3, RecordedAt: time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)},
+		{SensorID: sensor, Value: 2, RecordedAt: time.Date(2026, 10, 19, 9, 30, 0, 0, jakarta)}, // 02:30Z
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readings, err := repo.GetReadings(ctx, domain.ReadingQuery{
+		SensorIDs: []string{sensor},
+		From:      time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC),
+		To:        time.Date(2026, 10, 19, 10, 0, 0, 0, jakarta), // 03:00Z
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 2 || readings[0].Value != 2 || readings[1].Value != 3 {
+		t.Fatalf("GetReadings() = %+v, want the readings of 02:30Z and 03:00Z in order", readings)
+	}
+
+	latest, err := repo.GetLatestReadings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].Value != 3 {
+		t.Fatalf("GetLatestReadings() = %+v, want the reading of 03:00Z", latest)
+	}
+}
+
+func TestInitDBNormalizesStoredOffsets(t *testing.T) {
+	db := openTestDB(t)
+	sensor := domain.LegacySensors[0].ID
+
+	// Rows written before times were stored in UTC
+	for _, recordedAt := range []string{"2026-10-19 08:00:00+07:00", "2026-10-19 03:00:00+00:00", "2026-10-19 02:00:00"} {
+		if _, err := db.Exec(`INSERT INTO sensor_readings (sensor_id, value, recorded_at) VALUES (?, 0, ?)`, sensor, recordedAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT CAST(recorded_at AS TEXT) FROM sensor_readings ORDER BY recorded_at`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var recordedAt string
+		if err := rows.Scan(&recordedAt); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, recordedAt)
+	}
+	want := []string{"2026-10-19 01:00:00+00:00", "2026-10-19 02:00:00", "2026-10-19 03:00:00+00:00"}
+	if len(got) != len(want) {
+		t.Fatalf("recorded_at = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("recorded_at = %v, want %v", got, want)
+		}
+	}
+}
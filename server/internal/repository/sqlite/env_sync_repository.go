@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// EnvSyncRepository reads local environmental rows in insertion order for mirroring
+type EnvSyncRepository struct {
+	db *sql.DB
+}
+
+func NewEnvSyncRepository(db *sql.DB) *EnvSyncRepository {
+	return &EnvSyncRepository{
+		db: db,
+	}
+}
+
+func (r *EnvSyncRepository) GetCursor(ctx context.Context, name string) (int64, error) {
+	var position int64
+	err := r.db.QueryRowContext(ctx, `SELECT position FROM sync_cursors WHERE name = ?`, name).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return position, err
+}
+
+func (r *EnvSyncRepository) SaveCursor(ctx context.Context, name string, position int64) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sync_cursors (name, position, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET
+			position = excluded.position,
+			updated_at = excluded.updated_at
+	`, name, position, time.Now())
+	return err
+}
+
+func (r *EnvSyncRepository) GetEnvMetricsAfter(ctx context.Context, id int64, limit int) ([]domain.EnvMetrics, error) {
+	query := `
+		SELECT id, first_temperature, first_humidity, second_temperature, second_humidity, created_at
+		FROM env_metrics
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []domain.EnvMetrics
+	for rows.Next() {
+		var m domain.EnvMetrics
+		err := rows.Scan(
+			&m.ID,
+			&m.FirstTemperature,
+			&m.FirstHumidity,
+			&m.SecondTemperature,
+			&m.SecondHumidity,
+			&m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, rows.Err()
+}
+
+func (r *EnvSyncRepository) GetReadingsAfter(ctx context.Context, id int64, limit int) ([]domain.SensorReading, error) {
+	query := `
+		SELECT id, sensor_id, value, recorded_at
+		FROM sensor_readings
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var readings []domain.SensorReading
+	for rows.Next() {
+		var reading domain.SensorReading
+		if err := rows.Scan(&reading.ID, &reading.SensorID, &reading.Value, &reading.RecordedAt); err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	return readings, rows.Err()
+}
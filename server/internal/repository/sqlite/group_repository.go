@@ -0,0 +1,240 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type GroupRepository struct {
+	db *sql.DB
+}
+
+func NewGroupRepository(db *sql.DB) *GroupRepository {
+	return &GroupRepository{
+		db: db,
+	}
+}
+
+// groupColumns is the column list matching scanGroup
+const groupColumns = `id, name, kind, parent_id, COALESCE(description, ''), selector, alert_rules, notifications, created_at, updated_at`
+
+func (r *GroupRepository) GetAll(ctx context.Context) ([]domain.Group, error) {
+	query := `SELECT ` + groupColumns + ` FROM agent_groups ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []domain.Group{}
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+
+	return groups, rows.Err()
+}
+
+func (r *GroupRepository) Get(ctx context.Context, id int64) (*domain.Group, error) {
+	query := `SELECT ` + groupColumns + ` FROM agent_groups WHERE id = ?`
+
+	group, err := scanGroup(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return group, err
+}
+
+func (r *GroupRepository) Create(ctx context.Context, group *domain.Group) error {
+	selector, rules, routes, err := encodeGroup(group)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	group.CreatedAt = now
+	group.UpdatedAt = now
+
+	query := `
+		INSERT INTO agent_groups (name, kind, parent_id, description, selector, alert_rules, notifications, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`
+
+	return r.db.QueryRowContext(ctx, query,
+		group.Name,
+		group.Kind,
+		group.ParentID,
+		group.Description,
+		selector,
+		rules,
+		routes,
+		group.CreatedAt,
+		group.UpdatedAt,
+	).Scan(&group.ID)
+}
+
+func (r *GroupRepository) Update(ctx context.Context, group *domain.Group) error {
+	selector, rules, routes, err := encodeGroup(group)
+	if err != nil {
+		return err
+	}
+	group.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE agent_groups
+		SET name = ?, kind = ?, parent_id = ?, description = ?, selector = ?, alert_rules = ?, notifications = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		group.Name,
+		group.Kind,
+		group.ParentID,
+		group.Description,
+		selector,
+		rules,
+		routes,
+		group.UpdatedAt,
+		group.ID,
+	).Scan(&group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+func (r *GroupRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM agent_groups WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *GroupRepository) GetMembers(ctx context.Context) (map[int64][]string, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT group_id, agent_id FROM agent_group_members ORDER BY group_id, agent_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make(map[int64][]string)
+	for rows.Next() {
+		var groupID int64
+		var agentID string
+		if err := rows.Scan(&groupID, &agentID); err != nil {
+			return nil, err
+		}
+		members[groupID] = append(members[groupID], agentID)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *GroupRepository) AddMember(ctx context.Context, groupID int64, agentID string) error {
+	_, err := r.db.ExecContext(ctx, `INSERT OR IGNORE INTO agent_group_members (group_id, agent_id) VALUES (?, ?)`, groupID, agentID)
+	return err
+}
+
+func (r *GroupRepository) RemoveMember(ctx context.Context, groupID int64, agentID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM agent_group_members WHERE group_id = ? AND agent_id = ?`, groupID, agentID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// encodeGroup returns the JSON columns of a group; the selector is NULL for a static group
+func encodeGroup(group *domain.Group) (interface{}, string, string, error) {
+	var selector interface{}
+	if group.Selector != nil {
+		data, err := json.Marshal(group.Selector)
+		if err != nil {
+			return nil, "", "", err
+		}
+		selector = string(data)
+	}
+
+	if group.AlertRules == nil {
+		group.AlertRules = []domain.AgentAlertRule{}
+	}
+	rules, err := json.Marshal(group.AlertRules)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if group.Notifications == nil {
+		group.Notifications = []domain.NotificationRoute{}
+	}
+	routes, err := json.Marshal(group.Notifications)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return selector, string(rules), string(routes), nil
+}
+
+func scanGroup(row rowScanner) (*domain.Group, error) {
+	var group domain.Group
+	var parentID sql.NullInt64
+	var selector sql.NullString
+	var rules, routes string
+
+	err := row.Scan(
+		&group.ID,
+		&group.Name,
+		&group.Kind,
+		&parentID,
+		&group.Description,
+		&selector,
+		&rules,
+		&routes,
+		&group.CreatedAt,
+		&group.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		group.ParentID = &parentID.Int64
+	}
+	if selector.Valid {
+		if err := json.Unmarshal([]byte(selector.String), &group.Selector); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal([]byte(rules), &group.AlertRules); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(routes), &group.Notifications); err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
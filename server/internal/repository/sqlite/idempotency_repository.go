@@ -0,0 +1,44 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// idempotencyRetention is how long a claimed key blocks duplicates
+const idempotencyRetention = 24 * time.Hour
+
+type IdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		db: db,
+	}
+}
+
+func (r *IdempotencyRepository) Claim(ctx context.Context, scope, key string) (bool, error) {
+	// Expired keys can be claimed again
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE created_at < ?`, time.Now().Add(-idempotencyRetention))
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO idempotency_keys (scope, key, created_at) VALUES (?, ?, ?)`,
+		scope, key, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+func (r *IdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = ? AND key = ?`, scope, key)
+	return err
+}
@@ -3,10 +3,13 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
 )
 
-// InitDB initializes the SQLite database with schema for agents only
-// Note: env_metrics is stored in Supabase
+// InitDB initializes the SQLite database schema.
+// env_metrics and sensor readings are used when Supabase is not configured, and mirrored to it once it is.
 func InitDB(db *sql.DB) error {
 	// Define all table schemas
 	schemas := []struct {
@@ -19,6 +22,7 @@ func InitDB(db *sql.DB) error {
 				CREATE TABLE IF NOT EXISTS agents (
 					id TEXT PRIMARY KEY,
 					name TEXT NOT NULL,
+					type TEXT NOT NULL DEFAULT 'agent',
 					host TEXT NOT NULL,
 					hostname TEXT,
 					ip_address TEXT,
@@ -34,6 +38,20 @@ func InitDB(db *sql.DB) error {
 				CREATE INDEX IF NOT EXISTS idx_agents_last_seen ON agents(last_seen);
 			`,
 		},
+		{
+			// Tags of each agent, in their order, indexed for filtering; agents.tags keeps a JSON copy
+			name: "agent_tags",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_tags (
+					agent_id TEXT NOT NULL,
+					tag TEXT NOT NULL,
+					position INTEGER NOT NULL,
+					PRIMARY KEY (agent_id, tag),
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_tags_tag ON agent_tags(tag, agent_id);
+			`,
+		},
 		{
 			name: "agent_metrics",
 			schema: `
@@ -49,6 +67,409 @@ func InitDB(db *sql.DB) error {
 				CREATE INDEX IF NOT EXISTS idx_agent_metrics_received_at ON agent_metrics(received_at);
 			`,
 		},
+		{
+			name: "service_states",
+			schema: `
+				CREATE TABLE IF NOT EXISTS service_states (
+					agent_id TEXT NOT NULL,
+					unit TEXT NOT NULL,
+					load_state TEXT,
+					active_state TEXT NOT NULL,
+					sub_state TEXT,
+					restarts INTEGER NOT NULL DEFAULT 0,
+					main_pid INTEGER NOT NULL DEFAULT 0,
+					memory_bytes INTEGER NOT NULL DEFAULT 0,
+					cpu_usage_ns INTEGER NOT NULL DEFAULT 0,
+					cpu_percent REAL NOT NULL DEFAULT 0,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (agent_id, unit),
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+			`,
+		},
+		{
+			name: "service_events",
+			schema: `
+				CREATE TABLE IF NOT EXISTS service_events (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					agent_id TEXT NOT NULL,
+					unit TEXT NOT NULL,
+					from_state TEXT,
+					to_state TEXT NOT NULL,
+					sub_state TEXT,
+					occurred_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_service_events_agent_id ON service_events(agent_id, occurred_at);
+				CREATE INDEX IF NOT EXISTS idx_service_events_to_state ON service_events(to_state);
+			`,
+		},
+		{
+			name: "custom_checks",
+			schema: `
+				CREATE TABLE IF NOT EXISTS custom_checks (
+					agent_id TEXT NOT NULL,
+					plugin TEXT NOT NULL,
+					status TEXT NOT NULL,
+					exit_code INTEGER NOT NULL DEFAULT 0,
+					output TEXT,
+					duration_ms INTEGER NOT NULL DEFAULT 0,
+					metrics TEXT,
+					checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (agent_id, plugin),
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+			`,
+		},
+		{
+			name: "custom_metrics",
+			schema: `
+				CREATE TABLE IF NOT EXISTS custom_metrics (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					agent_id TEXT NOT NULL,
+					source TEXT NOT NULL,
+					name TEXT NOT NULL,
+					value REAL NOT NULL,
+					unit TEXT,
+					labels TEXT,
+					recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_custom_metrics_agent_name ON custom_metrics(agent_id, name, recorded_at);
+				CREATE INDEX IF NOT EXISTS idx_custom_metrics_recorded_at ON custom_metrics(recorded_at);
+			`,
+		},
+		{
+			name: "env_metrics",
+			schema: `
+				CREATE TABLE IF NOT EXISTS env_metrics (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					first_temperature REAL,
+					first_humidity REAL,
+					second_temperature REAL,
+					second_humidity REAL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				);
+				CREATE INDEX IF NOT EXISTS idx_env_metrics_created_at ON env_metrics(created_at);
+			`,
+		},
+		{
+			name: "sensors",
+			schema: `
+				CREATE TABLE IF NOT EXISTS sensors (
+					id TEXT PRIMARY KEY,
+					name TEXT NOT NULL DEFAULT '',
+					location TEXT,
+					kind TEXT NOT NULL,
+					unit TEXT,
+					agent_id TEXT,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE SET NULL
+				);
+			`,
+		},
+		{
+			name: "sensor_readings",
+			schema: `
+				CREATE TABLE IF NOT EXISTS sensor_readings (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					sensor_id TEXT NOT NULL,
+					value REAL NOT NULL,
+					recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (sensor_id) REFERENCES sensors(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_sensor_readings_sensor ON sensor_readings(sensor_id, recorded_at);
+			`,
+		},
+		{
+			name: "idempotency_keys",
+			schema: `
+				CREATE TABLE IF NOT EXISTS idempotency_keys (
+					scope TEXT NOT NULL,
+					key TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (scope, key)
+				);
+				CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+			`,
+		},
+		{
+			name: "env_alert_rules",
+			schema: `
+				CREATE TABLE IF NOT EXISTS env_alert_rules (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					sensor_id TEXT NOT NULL,
+					condition TEXT NOT NULL,
+					threshold REAL NOT NULL,
+					duration_seconds INTEGER NOT NULL DEFAULT 0,
+					enabled INTEGER NOT NULL DEFAULT 1,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			name: "env_alerts",
+			schema: `
+				CREATE TABLE IF NOT EXISTS env_alerts (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					rule_id INTEGER NOT NULL,
+					rule_name TEXT NOT NULL,
+					sensor_id TEXT NOT NULL,
+					condition TEXT NOT NULL,
+					threshold REAL NOT NULL,
+					value REAL NOT NULL,
+					status TEXT NOT NULL,
+					started_at DATETIME NOT NULL,
+					fired_at DATETIME NOT NULL,
+					resolved_at DATETIME,
+					FOREIGN KEY (rule_id) REFERENCES env_alert_rules(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_env_alerts_rule ON env_alerts(rule_id, fired_at);
+				CREATE INDEX IF NOT EXISTS idx_env_alerts_status ON env_alerts(status);
+			`,
+		},
+		{
+			name: "sync_cursors",
+			schema: `
+				CREATE TABLE IF NOT EXISTS sync_cursors (
+					name TEXT PRIMARY KEY,
+					position INTEGER NOT NULL DEFAULT 0,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			// Selector, alert rules and notification routes are JSON
+			name: "agent_groups",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_groups (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					kind TEXT NOT NULL,
+					parent_id INTEGER,
+					description TEXT,
+					selector TEXT,
+					alert_rules TEXT NOT NULL DEFAULT '[]',
+					notifications TEXT NOT NULL DEFAULT '[]',
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					FOREIGN KEY (parent_id) REFERENCES agent_groups(id)
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_groups_parent ON agent_groups(parent_id);
+			`,
+		},
+		{
+			name: "agent_group_members",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_group_members (
+					group_id INTEGER NOT NULL,
+					agent_id TEXT NOT NULL,
+					PRIMARY KEY (group_id, agent_id),
+					FOREIGN KEY (group_id) REFERENCES agent_groups(id) ON DELETE CASCADE,
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_group_members_agent ON agent_group_members(agent_id);
+			`,
+		},
+		{
+			name: "agent_alerts",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_alerts (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					group_id INTEGER NOT NULL,
+					rule_name TEXT NOT NULL,
+					agent_id TEXT NOT NULL,
+					agent_name TEXT NOT NULL,
+					metric TEXT NOT NULL,
+					condition TEXT NOT NULL,
+					threshold REAL NOT NULL,
+					value REAL NOT NULL,
+					status TEXT NOT NULL,
+					started_at DATETIME NOT NULL,
+					fired_at DATETIME NOT NULL,
+					resolved_at DATETIME,
+					FOREIGN KEY (group_id) REFERENCES agent_groups(id) ON DELETE CASCADE,
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_alerts_group ON agent_alerts(group_id, fired_at);
+				CREATE INDEX IF NOT EXISTS idx_agent_alerts_agent ON agent_alerts(agent_id, fired_at);
+				CREATE INDEX IF NOT EXISTS idx_agent_alerts_status ON agent_alerts(status);
+			`,
+		},
+		{
+			// Kept after an agent is deleted, so there is no foreign key
+			name: "agent_audit",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_audit (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					agent_id TEXT NOT NULL,
+					action TEXT NOT NULL,
+					actor TEXT,
+					changes TEXT NOT NULL,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_audit_agent ON agent_audit(agent_id, created_at);
+			`,
+		},
+		{
+			// Agents found by discovery awaiting approval, and the rejected ones; tags are JSON
+			name: "discovered_agents",
+			schema: `
+				CREATE TABLE IF NOT EXISTS discovered_agents (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					host TEXT NOT NULL UNIQUE,
+					source TEXT NOT NULL,
+					name TEXT NOT NULL,
+					hostname TEXT,
+					ip_address TEXT,
+					version TEXT,
+					tags TEXT NOT NULL DEFAULT '[]',
+					status TEXT NOT NULL,
+					first_seen DATETIME NOT NULL,
+					last_seen DATETIME NOT NULL
+				);
+				CREATE INDEX IF NOT EXISTS idx_discovered_agents_status ON discovered_agents(status);
+			`,
+		},
+		{
+			// The probe spec, locations and notification routes are JSON
+			name: "checks",
+			schema: `
+				CREATE TABLE IF NOT EXISTS checks (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					name TEXT NOT NULL,
+					spec TEXT NOT NULL,
+					interval_seconds INTEGER NOT NULL,
+					locations TEXT NOT NULL DEFAULT '[]',
+					failure_threshold INTEGER NOT NULL DEFAULT 1,
+					notifications TEXT NOT NULL DEFAULT '[]',
+					paused BOOLEAN NOT NULL DEFAULT 0,
+					created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+				);
+			`,
+		},
+		{
+			name: "check_results",
+			schema: `
+				CREATE TABLE IF NOT EXISTS check_results (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					check_id INTEGER NOT NULL,
+					location TEXT NOT NULL,
+					status TEXT NOT NULL,
+					latency_ms REAL NOT NULL,
+					error TEXT,
+					status_code INTEGER,
+					cert_expires_at DATETIME,
+					answers TEXT,
+					checked_at DATETIME NOT NULL,
+					FOREIGN KEY (check_id) REFERENCES checks(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_check_results_check ON check_results(check_id, checked_at);
+				CREATE INDEX IF NOT EXISTS idx_check_results_location ON check_results(check_id, location, id);
+				CREATE INDEX IF NOT EXISTS idx_check_results_checked ON check_results(checked_at);
+			`,
+		},
+		{
+			name: "check_alerts",
+			schema: `
+				CREATE TABLE IF NOT EXISTS check_alerts (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					check_id INTEGER NOT NULL,
+					check_name TEXT NOT NULL,
+					location TEXT NOT NULL,
+					status TEXT NOT NULL,
+					error TEXT,
+					started_at DATETIME NOT NULL,
+					fired_at DATETIME NOT NULL,
+					resolved_at DATETIME,
+					FOREIGN KEY (check_id) REFERENCES checks(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_check_alerts_check ON check_alerts(check_id, fired_at);
+				CREATE INDEX IF NOT EXISTS idx_check_alerts_status ON check_alerts(status);
+			`,
+		},
+		{
+			name: "heartbeat_pings",
+			schema: `
+				CREATE TABLE IF NOT EXISTS heartbeat_pings (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					check_id INTEGER NOT NULL,
+					kind TEXT NOT NULL,
+					exit_code INTEGER,
+					duration_ms REAL,
+					message TEXT,
+					source TEXT NOT NULL,
+					user_agent TEXT,
+					received_at DATETIME NOT NULL,
+					FOREIGN KEY (check_id) REFERENCES checks(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_heartbeat_pings_check ON heartbeat_pings(check_id, received_at);
+				CREATE INDEX IF NOT EXISTS idx_heartbeat_pings_received ON heartbeat_pings(received_at);
+			`,
+		},
+		{
+			// Agents send the latest result of each target in every sample, so a result is
+			// stored once per agent, target and check time
+			name: "ping_results",
+			schema: `
+				CREATE TABLE IF NOT EXISTS ping_results (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					agent_id TEXT NOT NULL,
+					target TEXT NOT NULL,
+					host TEXT NOT NULL,
+					address TEXT,
+					method TEXT NOT NULL,
+					sent INTEGER NOT NULL,
+					received INTEGER NOT NULL,
+					loss_percent REAL NOT NULL,
+					min_ms REAL NOT NULL,
+					avg_ms REAL NOT NULL,
+					max_ms REAL NOT NULL,
+					jitter_ms REAL NOT NULL,
+					error TEXT,
+					checked_at DATETIME NOT NULL,
+					UNIQUE (agent_id, target, checked_at),
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_ping_results_checked ON ping_results(checked_at);
+			`,
+		},
+		{
+			// A line shipped again after a lost response is stored once per agent, source and cursor
+			name: "agent_logs",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_logs (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					agent_id TEXT NOT NULL,
+					source TEXT NOT NULL,
+					unit TEXT,
+					level TEXT,
+					message TEXT NOT NULL,
+					timestamp DATETIME NOT NULL,
+					cursor TEXT,
+					UNIQUE (agent_id, source, cursor),
+					FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+				);
+				CREATE INDEX IF NOT EXISTS idx_agent_logs_agent ON agent_logs(agent_id, timestamp);
+				CREATE INDEX IF NOT EXISTS idx_agent_logs_timestamp ON agent_logs(timestamp);
+			`,
+		},
+		{
+			name: "agent_configs",
+			schema: `
+				CREATE TABLE IF NOT EXISTS agent_configs (
+					scope TEXT NOT NULL,
+					target TEXT NOT NULL,
+					settings TEXT NOT NULL,
+					updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+					PRIMARY KEY (scope, target)
+				);
+			`,
+		},
 	}
 
 	// Execute each schema
@@ -59,7 +480,174 @@ func InitDB(db *sql.DB) error {
 		fmt.Printf("✓ Table ready: %s\n", table.name)
 	}
 
-	fmt.Println("✓ Database initialization completed (SQLite for agents)")
-	fmt.Println("  Note: env_metrics is stored in Supabase")
+	// Add columns introduced after a table was first created
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"agents", "type", "TEXT NOT NULL DEFAULT 'agent'"},
+		{"agent_metrics", "sampled_at", "DATETIME"},
+		{"agents", "config_version", "TEXT"},
+	}
+
+	for _, c := range columns {
+		if err := ensureColumn(db, c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_agents_type_host ON agents(type, host)`); err != nil {
+		return fmt.Errorf("failed to create index idx_agents_type_host: %w", err)
+	}
+
+	// Normalize the tags of agents registered before agent_tags existed
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO agent_tags (agent_id, tag, position)
+		SELECT agents.id, tags.value, tags.key
+		FROM agents, json_each(agents.tags) AS tags
+		WHERE json_valid(agents.tags) AND json_type(agents.tags) = 'array' AND tags.type = 'text'
+			AND NOT EXISTS (SELECT 1 FROM agent_tags WHERE agent_tags.agent_id = agents.id)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to migrate agent tags: %w", err)
+	}
+
+	// Replayed agent samples are deduplicated by agent and sample time
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_agent_metrics_sample ON agent_metrics(agent_id, sampled_at)`); err != nil {
+		return fmt.Errorf("failed to create index idx_agent_metrics_sample: %w", err)
+	}
+
+	// Plugin results are reported again with every sample until the plugin runs next, and
+	// retried remote writes resend their samples: a point is stored once
+	if err := uniqueIndex(db, "idx_custom_metrics_point", "custom_metrics", "agent_id, source, name, labels, recorded_at"); err != nil {
+		return fmt.Errorf("failed to create index idx_custom_metrics_point: %w", err)
+	}
+
+	// Readings, probe and plugin results were once stored with the offset they were sent with, and
+	// agents were last seen in the server's zone; times compare as text in UTC
+	for _, c := range []struct{ table, column string }{
+		{"sensor_readings", "recorded_at"},
+		{"env_metrics", "created_at"},
+		{"ping_results", "checked_at"},
+		{"check_results", "checked_at"},
+		{"check_alerts", "fired_at"},
+		{"heartbeat_pings", "received_at"},
+		{"agents", "last_seen"},
+		{"agents", "created_at"},
+		{"custom_metrics", "recorded_at"},
+		{"custom_checks", "checked_at"},
+	} {
+		if err := normalizeTimes(db, c.table, c.column); err != nil {
+			return fmt.Errorf("failed to normalize %s.%s to UTC: %w", c.table, c.column, err)
+		}
+	}
+
+	// Register the legacy two-probe sensors
+	for _, sensor := range domain.LegacySensors {
+		_, err := db.Exec(`INSERT OR IGNORE INTO sensors (id, name, kind, unit) VALUES (?, ?, ?, ?)`,
+			sensor.ID, sensor.Name, sensor.Kind, sensor.Unit)
+		if err != nil {
+			return fmt.Errorf("failed to register sensor %s: %w", sensor.ID, err)
+		}
+	}
+
+	fmt.Println("✓ Database initialization completed (SQLite)")
+	return nil
+}
+
+// ensureColumn adds a column to an existing table when it is missing
+func ensureColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// uniqueIndex creates a unique index over columns, first deleting all but the oldest row of
+// each duplicate stored before the index existed
+func uniqueIndex(db *sql.DB, name, table, columns string) error {
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?`, name).Scan(&exists); err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM %[1]s WHERE id NOT IN (SELECT MIN(id) FROM %[1]s GROUP BY %[2]s)", table, columns))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s(%s)", name, table, columns)); err != nil {
+		return err
+	}
+	if removed, _ := result.RowsAffected(); removed > 0 {
+		fmt.Printf("✓ Removed %d duplicate rows from %s\n", removed, table)
+	}
+	return tx.Commit()
+}
+
+// normalizeTimes rewrites the times of a column stored with a non-UTC offset in UTC
+func normalizeTimes(db *sql.DB, table, column string) error {
+	rows, err := db.Query(fmt.Sprintf(
+		"SELECT rowid, %[1]s FROM %[2]s WHERE %[1]s GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND %[1]s NOT GLOB '*+00:00'",
+		column, table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	times := make(map[int64]time.Time)
+	for rows.Next() {
+		var id int64
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			return err
+		}
+		times[id] = t
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// A row that becomes a duplicate of one already stored in UTC replaces it
+	for id, t := range times {
+		if _, err := db.Exec(fmt.Sprintf("UPDATE OR REPLACE %s SET %s = ? WHERE rowid = ?", table, column), t.UTC(), id); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,197 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// maxLogScan bounds the lines read to find the matches of a regular expression, which
+// SQLite cannot evaluate
+const maxLogScan = 100000
+
+type LogRepository struct {
+	db *sql.DB
+}
+
+func NewLogRepository(db *sql.DB) *LogRepository {
+	return &LogRepository{
+		db: db,
+	}
+}
+
+func (r *LogRepository) SaveLines(ctx context.Context, agentID string, lines []domain.LogLine) ([]domain.LogLine, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO agent_logs (agent_id, source, unit, level, message, timestamp, cursor)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	saved := make([]domain.LogLine, 0, len(lines))
+	for _, line := range lines {
+		// Timestamps are compared as text, so they are all stored in UTC
+		line.AgentID = agentID
+		line.Timestamp = line.Timestamp.UTC()
+
+		err := stmt.QueryRowContext(ctx,
+			agentID,
+			line.Source,
+			line.Unit,
+			line.Level,
+			line.Message,
+			line.Timestamp,
+			sql.NullString{String: line.Cursor, Valid: line.Cursor != ""},
+		).Scan(&line.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue // already stored
+		}
+		if err != nil {
+			return nil, err
+		}
+		saved = append(saved, line)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (r *LogRepository) Query(ctx context.Context, q domain.LogQuery) ([]domain.LogLine, error) {
+	conditions := []string{"agent_id = ?"}
+	args := []interface{}{q.AgentID}
+
+	if q.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, q.Source)
+	}
+	if q.Unit != "" {
+		conditions = append(conditions, "unit = ?")
+		args = append(args, q.Unit)
+	}
+	if q.Level != "" {
+		conditions = append(conditions, "level = ?")
+		args = append(args, q.Level)
+	}
+	if q.Contains != "" {
+		conditions = append(conditions, "instr(LOWER(message), ?) > 0")
+		args = append(args, strings.ToLower(q.Contains))
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, q.Until.UTC())
+	}
+	if q.BeforeID > 0 {
+		conditions = append(conditions, "id < ?")
+		args = append(args, q.BeforeID)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	// A regular expression is matched here on at most maxLogScan lines
+	scan := limit
+	if q.Pattern != nil {
+		scan = maxLogScan
+	}
+	args = append(args, scan)
+
+	query := `
+		SELECT id, agent_id, source, COALESCE(unit, ''), COALESCE(level, ''), message, timestamp, COALESCE(cursor, '')
+		FROM agent_logs
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lines := []domain.LogLine{}
+	for rows.Next() && len(lines) < limit {
+		var line domain.LogLine
+		err := rows.Scan(
+			&line.ID,
+			&line.AgentID,
+			&line.Source,
+			&line.Unit,
+			&line.Level,
+			&line.Message,
+			&line.Timestamp,
+			&line.Cursor,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if q.Pattern != nil && !q.Pattern.MatchString(line.Message) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, rows.Err()
+}
+
+func (r *LogRepository) Prune(ctx context.Context, before time.Time, maxPerAgent int) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM agent_logs WHERE timestamp < ?`, before.UTC()); err != nil {
+		return err
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT agent_id FROM agent_logs GROUP BY agent_id HAVING COUNT(*) > ?`, maxPerAgent)
+	if err != nil {
+		return err
+	}
+	var agentIDs []string
+	for rows.Next() {
+		var agentID string
+		if err := rows.Scan(&agentID); err != nil {
+			rows.Close()
+			return err
+		}
+		agentIDs = append(agentIDs, agentID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Keep the newest maxPerAgent lines of each agent over the limit
+	query := `
+		DELETE FROM agent_logs WHERE agent_id = ? AND id <= (
+			SELECT id FROM agent_logs WHERE agent_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)
+	`
+	for _, agentID := range agentIDs {
+		if _, err := r.db.ExecContext(ctx, query, agentID, agentID, maxPerAgent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
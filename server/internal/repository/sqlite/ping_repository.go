@@ -0,0 +1,146 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type PingRepository struct {
+	db *sql.DB
+}
+
+func NewPingRepository(db *sql.DB) *PingRepository {
+	return &PingRepository{
+		db: db,
+	}
+}
+
+// pingResultColumns is the column list matching pingResultFields
+const pingResultColumns = `target, host, COALESCE(address, ''), method, sent, received, loss_percent, min_ms, avg_ms, max_ms, jitter_ms, COALESCE(error, ''), checked_at`
+
+func (r *PingRepository) SaveResults(ctx context.Context, agentID string, results []domain.PingResult) error {
+	query := `
+		INSERT OR IGNORE INTO ping_results (agent_id, target, host, address, method, sent, received, loss_percent, min_ms, avg_ms, max_ms, jitter_ms, error, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	for _, result := range results {
+		_, err := r.db.ExecContext(ctx, query,
+			agentID,
+			result.Target,
+			result.Host,
+			result.Address,
+			result.Method,
+			result.Sent,
+			result.Received,
+			result.LossPercent,
+			result.MinMs,
+			result.AvgMs,
+			result.MaxMs,
+			result.JitterMs,
+			result.Error,
+			result.CheckedAt.UTC(), // compared as text with the UTC bounds of the queries
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *PingRepository) GetResults(ctx context.Context, q domain.PingResultQuery) ([]domain.PingResult, error) {
+	conditions := []string{"agent_id = ?"}
+	args := []interface{}{q.AgentID}
+
+	if q.Target != "" {
+		conditions = append(conditions, "target = ?")
+		args = append(args, q.Target)
+	}
+	if !q.Since.IsZero() {
+		conditions = append(conditions, "checked_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	query := `SELECT ` + pingResultColumns + ` FROM ping_results WHERE ` + strings.Join(conditions, " AND ") +
+		` ORDER BY checked_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []domain.PingResult{}
+	for rows.Next() {
+		result, err := scanPingResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+
+	return results, rows.Err()
+}
+
+func (r *PingRepository) GetLatest(ctx context.Context, since time.Time) ([]domain.AgentPingResult, error) {
+	query := `
+		SELECT agent_id, ` + pingResultColumns + ` FROM ping_results
+		WHERE id IN (SELECT MAX(id) FROM ping_results WHERE checked_at >= ? GROUP BY agent_id, target)
+		ORDER BY agent_id, target
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []domain.AgentPingResult{}
+	for rows.Next() {
+		var result domain.AgentPingResult
+		if err := rows.Scan(append([]interface{}{&result.AgentID}, pingResultFields(&result.PingResult)...)...); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func scanPingResult(row rowScanner) (*domain.PingResult, error) {
+	var result domain.PingResult
+	if err := row.Scan(pingResultFields(&result)...); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// pingResultFields returns the scan destinations of pingResultColumns
+func pingResultFields(result *domain.PingResult) []interface{} {
+	return []interface{}{
+		&result.Target,
+		&result.Host,
+		&result.Address,
+		&result.Method,
+		&result.Sent,
+		&result.Received,
+		&result.LossPercent,
+		&result.MinMs,
+		&result.AvgMs,
+		&result.MaxMs,
+		&result.JitterMs,
+		&result.Error,
+		&result.CheckedAt,
+	}
+}
@@ -0,0 +1,102 @@
+package sqlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+func TestPingResultsInMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	repo := NewPingRepository(db)
+	jakarta := time.FixedZone("WIB", 7*3600)
+	newYork := time.FixedZone("EST", -5*3600)
+
+	// Each agent reports its results in its own zone
+	registerAgent(t, db, "agent-jakarta")
+	registerAgent(t, db, "agent-new-york")
+	save := func(agentID string, results ...domain.PingResult) {
+		t.Helper()
+		if err := repo.SaveResults(ctx, agentID, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("agent-jakarta",
+		domain.PingResult{Target: "db", Host: "10.0.0.5", Method: "icmp", AvgMs: 1, CheckedAt: time.Date(2026, 10, 19, 8, 50, 0, 0, jakarta)}, // 01:50Z
+		domain.PingResult{Target: "db", Host: "10.0.0.5", Method: "icmp", AvgMs: 2, CheckedAt: time.Date(2026, 10, 19, 9, 10, 0, 0, jakarta)}, // 02:10Z
+	)
+	save("agent-new-york",
+		domain.PingResult{Target: "db", Host: "10.0.0.5", Method: "icmp", AvgMs: 3, CheckedAt: time.Date(2026, 10, 18, 21, 5, 0, 0, newYork)},  // 02:05Z
+		domain.PingResult{Target: "db", Host: "10.0.0.5", Method: "icmp", AvgMs: 4, CheckedAt: time.Date(2026, 10, 18, 20, 55, 0, 0, newYork)}, // 01:55Z
+		domain.PingResult{Target: "web", Host: "10.0.0.6", Method: "tcp", AvgMs: 5, CheckedAt: time.Date(2026, 10, 18, 20, 30, 0, 0, newYork)}, // 01:30Z
+	)
+	since := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	latest, err := repo.GetLatest(ctx, since.In(jakarta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, result := range latest {
+		got[result.AgentID+"/"+result.Target] = result.AvgMs
+	}
+	want := map[string]float64{"agent-jakarta/db": 2, "agent-new-york/db": 3}
+	if len(got) != len(want) || got["agent-jakarta/db"] != 2 || got["agent-new-york/db"] != 3 {
+		t.Errorf("GetLatest(%s) = %v, want %v", since, got, want)
+	}
+
+	results, err := repo.GetResults(ctx, domain.PingResultQuery{AgentID: "agent-new-york", Since: since.Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []float64
+	for _, result := range results {
+		values = append(values, result.AvgMs)
+	}
+	if len(values) != 3 || values[0] != 3 || values[1] != 4 || values[2] != 5 {
+		t.Errorf("GetResults() = %v, want the newest first: [3 4 5]", values)
+	}
+
+	// A result replayed by the agent is stored once
+	save("agent-jakarta", domain.PingResult{Target: "db", Host: "10.0.0.5", Method: "icmp", AvgMs: 2, CheckedAt: time.Date(2026, 10, 19, 9, 10, 0, 0, jakarta)})
+	if results, err := repo.GetResults(ctx, domain.PingResultQuery{AgentID: "agent-jakarta"}); err != nil || len(results) != 2 {
+		t.Errorf("GetResults() after a replay = %d results, %v, want 2", len(results), err)
+	}
+}
+
+func TestInitDBNormalizesPingResults(t *testing.T) {
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+
+	// The same result stored with an offset and, after an upgrade, replayed in UTC
+	for _, checkedAt := range []string{"2026-10-19 08:50:00+07:00", "2026-10-19 01:50:00+00:00", "2026-10-18 21:05:00-05:00"} {
+		_, err := db.Exec(`INSERT INTO ping_results (agent_id, target, host, method, sent, received, loss_percent, min_ms, avg_ms, max_ms, jitter_ms, checked_at)
+			VALUES ('agent-1', 'db', '10.0.0.5', 'icmp', 1, 1, 0, 1, 1, 1, 0, ?)`, checkedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.Query(`SELECT CAST(checked_at AS TEXT) FROM ping_results ORDER BY checked_at`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var checkedAt string
+		if err := rows.Scan(&checkedAt); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, checkedAt)
+	}
+	if len(got) != 2 || got[0] != "2026-10-19 01:50:00+00:00" || got[1] != "2026-10-19 02:05:00+00:00" {
+		t.Errorf("checked_at = %v, want [2026-10-19 01:50:00+00:00 2026-10-19 02:05:00+00:00]", got)
+	}
+}
@@ -0,0 +1,148 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+type ServiceRepository struct {
+	db *sql.DB
+}
+
+func NewServiceRepository(db *sql.DB) *ServiceRepository {
+	return &ServiceRepository{
+		db: db,
+	}
+}
+
+func (r *ServiceRepository) GetStates(ctx context.Context, agentID string) ([]domain.ServiceState, error) {
+	query := `
+		SELECT agent_id, unit, load_state, active_state, sub_state, restarts, main_pid, memory_bytes, cpu_usage_ns, cpu_percent, updated_at
+		FROM service_states
+		WHERE agent_id = ?
+		ORDER BY unit
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []domain.ServiceState
+	for rows.Next() {
+		var state domain.ServiceState
+		err := rows.Scan(
+			&state.AgentID,
+			&state.Unit,
+			&state.LoadState,
+			&state.ActiveState,
+			&state.SubState,
+			&state.Restarts,
+			&state.MainPID,
+			&state.MemoryBytes,
+			&state.CPUUsageNanos,
+			&state.CPUPercent,
+			&state.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	return states, rows.Err()
+}
+
+func (r *ServiceRepository) SaveState(ctx context.Context, state *domain.ServiceState) error {
+	query := `
+		INSERT INTO service_states (agent_id, unit, load_state, active_state, sub_state, restarts, main_pid, memory_bytes, cpu_usage_ns, cpu_percent, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (agent_id, unit) DO UPDATE SET
+			load_state = excluded.load_state,
+			active_state = excluded.active_state,
+			sub_state = excluded.sub_state,
+			restarts = excluded.restarts,
+			main_pid = excluded.main_pid,
+			memory_bytes = excluded.memory_bytes,
+			cpu_usage_ns = excluded.cpu_usage_ns,
+			cpu_percent = excluded.cpu_percent,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		state.AgentID,
+		state.Unit,
+		state.LoadState,
+		state.ActiveState,
+		state.SubState,
+		state.Restarts,
+		state.MainPID,
+		state.MemoryBytes,
+		state.CPUUsageNanos,
+		state.CPUPercent,
+		state.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ServiceRepository) RecordEvent(ctx context.Context, event *domain.ServiceEvent) error {
+	query := `
+		INSERT INTO service_events (agent_id, unit, from_state, to_state, sub_state, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		event.AgentID,
+		event.Unit,
+		event.FromState,
+		event.ToState,
+		event.SubState,
+		event.OccurredAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	event.ID, err = result.LastInsertId()
+	return err
+}
+
+func (r *ServiceRepository) GetEvents(ctx context.Context, agentID string, limit int) ([]domain.ServiceEvent, error) {
+	query := `
+		SELECT id, agent_id, unit, from_state, to_state, sub_state, occurred_at
+		FROM service_events
+		WHERE agent_id = ?
+		ORDER BY occurred_at DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, agentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []domain.ServiceEvent
+	for rows.Next() {
+		var event domain.ServiceEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.AgentID,
+			&event.Unit,
+			&event.FromState,
+			&event.ToState,
+			&event.SubState,
+			&event.OccurredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -2,6 +2,9 @@ package supabase
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
@@ -31,6 +34,35 @@ func (r *AgentRepository) Register(ctx context.Context, agent *domain.Agent) err
 	return nil
 }
 
+// Update replaces the registration details of an agent
+func (r *AgentRepository) Update(ctx context.Context, agent *domain.Agent) error {
+	updates := map[string]interface{}{
+		"name":        agent.Name,
+		"host":        agent.Host,
+		"hostname":    agent.Hostname,
+		"ip_address":  agent.IPAddress,
+		"status":      agent.Status,
+		"last_seen":   agent.LastSeen,
+		"version":     agent.Version,
+		"tags":        agent.Tags,
+		"description": agent.Description,
+		"updated_at":  agent.UpdatedAt,
+	}
+
+	var results []domain.Agent
+	_, err := r.client.From("agents").
+		Update(updates, "", "").
+		Eq("id", agent.ID).
+		ExecuteTo(&results)
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
 func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent, error) {
 	var agents []domain.Agent
 	_, err := r.client.From("agents").
@@ -50,6 +82,26 @@ func (r *AgentRepository) GetByID(ctx context.Context, id string) (*domain.Agent
 	return &agents[0], nil
 }
 
+func (r *AgentRepository) GetByHost(ctx context.Context, agentType, host string) (*domain.Agent, error) {
+	var agents []domain.Agent
+	_, err := r.client.From("agents").
+		Select("*", "", false).
+		Eq("type", agentType).
+		Eq("host", host).
+		Limit(1, "").
+		ExecuteTo(&agents)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(agents) == 0 {
+		return nil, nil
+	}
+
+	return &agents[0], nil
+}
+
 func (r *AgentRepository) GetAll(ctx context.Context) ([]domain.Agent, error) {
 	var agents []domain.Agent
 	_, err := r.client.From("agents").
@@ -64,6 +116,74 @@ func (r *AgentRepository) GetAll(ctx context.Context) ([]domain.Agent, error) {
 	return agents, nil
 }
 
+// Query filters and sorts agents through PostgREST. Pages are addressed by offset, so the
+// cursor is the offset of the next page.
+func (r *AgentRepository) Query(ctx context.Context, q domain.AgentQuery) ([]domain.Agent, string, error) {
+	sort := q.Sort
+	if sort == "" {
+		sort = domain.AgentSortCreatedAt
+	}
+	switch sort {
+	case domain.AgentSortName, domain.AgentSortHostname, domain.AgentSortStatus, domain.AgentSortVersion, domain.AgentSortLastSeen, domain.AgentSortCreatedAt:
+	default:
+		return nil, "", fmt.Errorf("%w: unknown sort field %q", domain.ErrInvalidInput, sort)
+	}
+
+	offset := 0
+	if q.Cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(q.Cursor); err != nil || offset < 0 {
+			return nil, "", fmt.Errorf("%w: malformed cursor", domain.ErrInvalidInput)
+		}
+	}
+
+	builder := r.client.From("agents").Select("*", "", false)
+	if q.Status != "" {
+		builder = builder.Eq("status", q.Status)
+	}
+	if q.Type != "" {
+		builder = builder.Eq("type", q.Type)
+	}
+	if q.Version != "" {
+		builder = builder.Eq("version", q.Version)
+	}
+	if q.Search != "" {
+		// PostgREST reserves commas and parentheses in or=() filters
+		term := strings.NewReplacer(",", " ", "(", " ", ")", " ").Replace(q.Search)
+		builder = builder.Or(fmt.Sprintf("name.ilike.*%[1]s*,hostname.ilike.*%[1]s*,ip_address.ilike.*%[1]s*", term), "")
+	}
+	if !q.SeenAfter.IsZero() {
+		builder = builder.Gte("last_seen", q.SeenAfter.Format(time.RFC3339Nano))
+	}
+	if !q.SeenBefore.IsZero() {
+		builder = builder.Lt("last_seen", q.SeenBefore.Format(time.RFC3339Nano))
+	}
+	if len(q.Tags) > 0 {
+		if q.TagMatch == domain.AgentTagMatchAll {
+			builder = builder.Contains("tags", q.Tags)
+		} else {
+			builder = builder.Overlaps("tags", q.Tags)
+		}
+	}
+
+	builder = builder.
+		Order(sort, &postgrest.OrderOpts{Ascending: !q.Descending}).
+		Order("id", &postgrest.OrderOpts{Ascending: !q.Descending})
+	if q.Limit > 0 {
+		builder = builder.Range(offset, offset+q.Limit, "")
+	}
+
+	agents := []domain.Agent{}
+	if _, err := builder.ExecuteTo(&agents); err != nil {
+		return nil, "", err
+	}
+
+	if q.Limit <= 0 || len(agents) <= q.Limit {
+		return agents, "", nil
+	}
+	return agents[:q.Limit], strconv.Itoa(offset + q.Limit), nil
+}
+
 func (r *AgentRepository) UpdateStatus(ctx context.Context, id string, status string, lastSeen time.Time) error {
 	updates := map[string]interface{}{
 		"status":     status,
@@ -80,6 +200,20 @@ func (r *AgentRepository) UpdateStatus(ctx context.Context, id string, status st
 	return err
 }
 
+func (r *AgentRepository) UpdateConfigVersion(ctx context.Context, id string, version string) error {
+	updates := map[string]interface{}{
+		"config_version": version,
+	}
+
+	var results []domain.Agent
+	_, err := r.client.From("agents").
+		Update(updates, "", "").
+		Eq("id", id).
+		ExecuteTo(&results)
+
+	return err
+}
+
 func (r *AgentRepository) Delete(ctx context.Context, id string) error {
 	var results []domain.Agent
 	_, err := r.client.From("agents").
@@ -3,6 +3,7 @@ package supabase
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
 	"github.com/supabase-community/postgrest-go"
@@ -10,17 +11,22 @@ import (
 )
 
 type EnvMetricsRepository struct {
-	client *supabase.Client
-	table  string
+	client        *supabase.Client
+	table         string
+	sensorTable   string
+	readingsTable string
 }
 
 func NewEnvMetricsRepository(client *supabase.Client) *EnvMetricsRepository {
 	return &EnvMetricsRepository{
-		client: client,
-		table:  "env_metrics",
+		client:        client,
+		table:         "env_metrics",
+		sensorTable:   "sensors",
+		readingsTable: "sensor_readings",
 	}
 }
 
+// GetLatest returns the legacy view: the latest env_metrics row overlaid with newer sensor readings
 func (r *EnvMetricsRepository) GetLatest(ctx context.Context) (*domain.EnvMetrics, error) {
 	var result []domain.EnvMetrics
 
@@ -33,16 +39,33 @@ func (r *EnvMetricsRepository) GetLatest(ctx context.Context) (*domain.EnvMetric
 		return nil, fmt.Errorf("failed to get latest env metrics: %w", err)
 	}
 
-	if len(result) == 0 {
-		return nil, nil // Return nil if no data
+	var legacy *domain.EnvMetrics
+	if len(result) > 0 {
+		legacy = &result[0]
 	}
 
-	return &result[0], nil
+	readings, err := r.GetLatestReadings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return domain.NewEnvironment(legacy, readings), nil
 }
 
 func (r *EnvMetricsRepository) Create(ctx context.Context, metrics *domain.EnvMetrics) error {
+	// id is left to the identity column, and created_at to its default when unset
+	row := map[string]interface{}{
+		"first_temperature":  metrics.FirstTemperature,
+		"first_humidity":     metrics.FirstHumidity,
+		"second_temperature": metrics.SecondTemperature,
+		"second_humidity":    metrics.SecondHumidity,
+	}
+	if !metrics.CreatedAt.IsZero() {
+		row["created_at"] = metrics.CreatedAt
+	}
+
 	var result []domain.EnvMetrics
-	_, err := r.client.From(r.table).Insert(metrics, false, "", "*", "").ExecuteTo(&result)
+	_, err := r.client.From(r.table).Insert(row, false, "", "*", "").ExecuteTo(&result)
 	if err != nil {
 		return fmt.Errorf("failed to create env metrics: %w", err)
 	}
@@ -53,3 +76,185 @@ func (r *EnvMetricsRepository) Create(ctx context.Context, metrics *domain.EnvMe
 
 	return nil
 }
+
+func (r *EnvMetricsRepository) GetSensors(ctx context.Context) ([]domain.Sensor, error) {
+	var sensors []domain.Sensor
+	_, err := r.client.From(r.sensorTable).Select("*", "", false).
+		Order("id", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&sensors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors: %w", err)
+	}
+
+	for i := range sensors {
+		reading, err := r.latestReading(sensors[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		sensors[i].LatestReading = reading
+	}
+
+	return sensors, nil
+}
+
+func (r *EnvMetricsRepository) GetSensor(ctx context.Context, id string) (*domain.Sensor, error) {
+	var sensors []domain.Sensor
+	_, err := r.client.From(r.sensorTable).Select("*", "", false).
+		Eq("id", id).
+		ExecuteTo(&sensors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor: %w", err)
+	}
+
+	if len(sensors) == 0 {
+		return nil, nil
+	}
+
+	sensor := &sensors[0]
+	if sensor.LatestReading, err = r.latestReading(id); err != nil {
+		return nil, err
+	}
+	return sensor, nil
+}
+
+func (r *EnvMetricsRepository) SaveSensor(ctx context.Context, sensor *domain.Sensor) error {
+	// created_at is left to the column default so an upsert keeps the original value
+	row := map[string]interface{}{
+		"id":         sensor.ID,
+		"name":       sensor.Name,
+		"location":   sensor.Location,
+		"kind":       sensor.Kind,
+		"unit":       sensor.Unit,
+		"agent_id":   nil,
+		"updated_at": time.Now(),
+	}
+	if sensor.AgentID != "" {
+		row["agent_id"] = sensor.AgentID
+	}
+
+	var result []domain.Sensor
+	_, err := r.client.From(r.sensorTable).Insert(row, true, "id", "*", "").ExecuteTo(&result)
+	if err != nil {
+		return fmt.Errorf("failed to save sensor: %w", err)
+	}
+
+	if len(result) > 0 {
+		*sensor = result[0]
+	}
+	return nil
+}
+
+func (r *EnvMetricsRepository) DeleteSensor(ctx context.Context, id string) error {
+	var result []domain.Sensor
+	_, err := r.client.From(r.sensorTable).Delete("", "").Eq("id", id).ExecuteTo(&result)
+	if err != nil {
+		return fmt.Errorf("failed to delete sensor: %w", err)
+	}
+	return nil
+}
+
+func (r *EnvMetricsRepository) SaveReadings(ctx context.Context, readings []domain.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	for i := range readings {
+		if readings[i].RecordedAt.IsZero() {
+			readings[i].RecordedAt = time.Now()
+		}
+	}
+
+	var result []domain.SensorReading
+	_, err := r.client.From(r.readingsTable).Insert(readings, false, "", "*", "").ExecuteTo(&result)
+	if err != nil {
+		return fmt.Errorf("failed to save sensor readings: %w", err)
+	}
+
+	if len(result) == len(readings) {
+		copy(readings, result)
+	}
+	return nil
+}
+
+// GetLatestReadings returns the most recent reading of every sensor
+func (r *EnvMetricsRepository) GetLatestReadings(ctx context.Context) ([]domain.SensorReading, error) {
+	var sensors []domain.Sensor
+	_, err := r.client.From(r.sensorTable).Select("id", "", false).
+		Order("id", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&sensors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors: %w", err)
+	}
+
+	var readings []domain.SensorReading
+	for _, sensor := range sensors {
+		reading, err := r.latestReading(sensor.ID)
+		if err != nil {
+			return nil, err
+		}
+		if reading != nil {
+			readings = append(readings, *reading)
+		}
+	}
+	return readings, nil
+}
+
+func (r *EnvMetricsRepository) latestReading(sensorID string) (*domain.SensorReading, error) {
+	var result []domain.SensorReading
+	_, err := r.client.From(r.readingsTable).Select("*", "", false).
+		Eq("sensor_id", sensorID).
+		Order("recorded_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(1, "").
+		ExecuteTo(&result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest reading of sensor %s: %w", sensorID, err)
+	}
+
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return &result[0], nil
+}
+
+// supabasePageSize stays within the default PostgREST max-rows setting
+const supabasePageSize = 1000
+
+// GetReadings returns readings in a time range, ordered by sensor and time, paging through the API
+func (r *EnvMetricsRepository) GetReadings(ctx context.Context, query domain.ReadingQuery) ([]domain.SensorReading, error) {
+	var readings []domain.SensorReading
+	for offset := 0; query.Limit <= 0 || offset < query.Limit; offset += supabasePageSize {
+		size := supabasePageSize
+		if query.Limit > 0 && query.Limit-offset < size {
+			size = query.Limit - offset
+		}
+
+		builder := r.client.From(r.readingsTable).Select("*", "", false)
+		if len(query.SensorIDs) > 0 {
+			builder = builder.In("sensor_id", query.SensorIDs)
+		}
+		if !query.From.IsZero() {
+			builder = builder.Gte("recorded_at", query.From.Format(time.RFC3339Nano))
+		}
+		if !query.To.IsZero() {
+			builder = builder.Lte("recorded_at", query.To.Format(time.RFC3339Nano))
+		}
+
+		var page []domain.SensorReading
+		_, err := builder.
+			Order("sensor_id", &postgrest.OrderOpts{Ascending: true}).
+			Order("recorded_at", &postgrest.OrderOpts{Ascending: true}).
+			Order("id", &postgrest.OrderOpts{Ascending: true}).
+			Range(offset, offset+size-1, "").
+			ExecuteTo(&page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sensor readings: %w", err)
+		}
+
+		readings = append(readings, page...)
+		if len(page) < size {
+			break
+		}
+	}
+
+	return readings, nil
+}
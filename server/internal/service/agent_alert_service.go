@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// AgentAlertService periodically evaluates the alert rules of groups against the latest
+// metrics of their agents, and sends the alerts it fires and resolves to the groups'
+// notification routes
+type AgentAlertService struct {
+	groups    *GroupService
+	agentRepo domain.AgentRepository
+	alertRepo domain.AgentAlertRepository
+	notifier  *Notifier
+	interval  time.Duration
+
+	mu sync.Mutex
+	// breaches holds when each rule was first seen breached on an agent; a restart
+	// starts the durations over
+	breaches map[string]time.Time
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+func NewAgentAlertService(groups *GroupService, agentRepo domain.AgentRepository, alertRepo domain.AgentAlertRepository, notifier *Notifier, interval time.Duration) *AgentAlertService {
+	return &AgentAlertService{
+		groups:    groups,
+		agentRepo: agentRepo,
+		alertRepo: alertRepo,
+		notifier:  notifier,
+		interval:  interval,
+		breaches:  make(map[string]time.Time),
+		stopChan:  make(chan bool),
+	}
+}
+
+// Start begins the evaluation loop
+func (s *AgentAlertService) Start() {
+	log.Printf("🚨 Agent alert evaluator started (interval: %s)", s.interval)
+	s.wg.Add(1)
+	go s.evaluateLoop()
+}
+
+// Stop gracefully stops the evaluation loop
+func (s *AgentAlertService) Stop() {
+	log.Println("⏸️  Stopping agent alert evaluator...")
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Agent alert evaluator stopped")
+}
+
+func (s *AgentAlertService) evaluateLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.Evaluate(context.Background())
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Evaluate(context.Background())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Evaluate checks every rule of every group once against each agent in the group, firing
+// new alerts and resolving recovered ones. Alerts whose rule, group or agent membership is
+// gone are resolved too.
+func (s *AgentAlertService) Evaluate(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tree, err := s.groups.tree(ctx)
+	if err != nil {
+		log.Printf("❌ Failed to get groups: %v", err)
+		return
+	}
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		log.Printf("❌ Failed to get agents: %v", err)
+		return
+	}
+	firing, err := s.alertRepo.GetAlerts(ctx, domain.AgentAlertQuery{Status: domain.AlertStatusFiring, Limit: maxFiringAlerts})
+	if err != nil {
+		log.Printf("❌ Failed to get firing agent alerts: %v", err)
+		return
+	}
+
+	open := make(map[string]*domain.AgentAlert, len(firing))
+	for i := range firing {
+		open[alertKey(firing[i].GroupID, firing[i].RuleName, firing[i].AgentID)] = &firing[i]
+	}
+
+	latest := make(map[string]*domain.AgentMetrics)
+	seen := make(map[string]bool)
+	now := time.Now()
+
+	for _, group := range tree.sorted() {
+		if len(group.AlertRules) == 0 {
+			continue
+		}
+		members := tree.members(group, agents)
+
+		for i := range group.AlertRules {
+			rule := &group.AlertRules[i]
+			for j := range members {
+				agent := &members[j]
+				key := alertKey(group.ID, rule.Name, agent.ID)
+				seen[key] = true
+
+				value, ok := s.metricValue(ctx, rule.Metric, agent, latest)
+				if !ok {
+					continue
+				}
+
+				alert := open[key]
+				if !rule.Breached(value) {
+					delete(s.breaches, key)
+					if alert != nil {
+						s.resolve(ctx, tree, alert, value, now)
+					}
+					continue
+				}
+
+				since, ok := s.breaches[key]
+				if !ok {
+					since = now
+					s.breaches[key] = now
+				}
+
+				switch {
+				case alert == nil && now.Sub(since) >= rule.Duration():
+					alert = &domain.AgentAlert{
+						GroupID:   group.ID,
+						RuleName:  rule.Name,
+						AgentID:   agent.ID,
+						AgentName: agent.Name,
+						Metric:    rule.Metric,
+						Condition: rule.Condition,
+						Threshold: rule.Threshold,
+						Value:     value,
+						Status:    domain.AlertStatusFiring,
+						StartedAt: since,
+						FiredAt:   now,
+					}
+					if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+						log.Printf("❌ Failed to save alert %q for agent %s: %v", rule.Name, agent.Name, err)
+						continue
+					}
+					log.Printf("🚨 Alert %q firing: %s of %s is %g, %s %g since %s", rule.Name, rule.Metric, agent.Name, value, rule.Condition, rule.Threshold, since.Format(time.RFC3339))
+					s.notify(tree, alert)
+
+				case alert != nil && alert.Value != value:
+					alert.Value = value
+					if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+						log.Printf("❌ Failed to update alert %q for agent %s: %v", rule.Name, agent.Name, err)
+					}
+				}
+			}
+		}
+	}
+
+	for key, alert := range open {
+		if !seen[key] {
+			s.resolve(ctx, tree, alert, alert.Value, now)
+		}
+	}
+	for key := range s.breaches {
+		if !seen[key] {
+			delete(s.breaches, key)
+		}
+	}
+}
+
+// metricValue returns the value of a rule's metric for an agent. Only the offline metric
+// is evaluated for agents that are not online, whose last sample is stale.
+func (s *AgentAlertService) metricValue(ctx context.Context, metric string, agent *domain.Agent, latest map[string]*domain.AgentMetrics) (float64, bool) {
+	if metric == domain.AgentAlertMetricOffline {
+		if agent.Status == "online" {
+			return 0, true
+		}
+		return 1, true
+	}
+	if agent.Status != "online" {
+		return 0, false
+	}
+
+	metrics, ok := latest[agent.ID]
+	if !ok {
+		var err error
+		if metrics, err = s.agentRepo.GetLatestMetrics(ctx, agent.ID); err != nil {
+			log.Printf("⚠️  Agent %s (%s): Failed to get metrics - %v", agent.Name, agent.ID, err)
+		}
+		latest[agent.ID] = metrics
+	}
+	if metrics == nil {
+		return 0, false
+	}
+
+	switch metric {
+	case domain.AgentAlertMetricCPU:
+		return metrics.Metrics.CPU.UsagePercent, true
+	case domain.AgentAlertMetricMemory:
+		return metrics.Metrics.Memory.UsedPercent, true
+	case domain.AgentAlertMetricDisk:
+		if disk := fullestDisk(metrics.Metrics); disk != nil {
+			return disk.UsedPercent, true
+		}
+	case domain.AgentAlertMetricLoad:
+		return metrics.Metrics.Load.Load1, true
+	case domain.AgentAlertMetricFailedUnits:
+		// Agents monitoring no units have nothing to evaluate
+		if len(metrics.Metrics.Services) == 0 {
+			return 0, false
+		}
+		failed := 0
+		for _, service := range metrics.Metrics.Services {
+			if service.ActiveState == domain.ServiceStateFailed {
+				failed++
+			}
+		}
+		return float64(failed), true
+	}
+	return 0, false
+}
+
+func (s *AgentAlertService) resolve(ctx context.Context, tree *groupTree, alert *domain.AgentAlert, value float64, at time.Time) {
+	alert.Value = value
+	alert.Status = domain.AlertStatusResolved
+	alert.ResolvedAt = &at
+	if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+		log.Printf("❌ Failed to resolve alert %q for agent %s: %v", alert.RuleName, alert.AgentName, err)
+		return
+	}
+	log.Printf("✅ Alert %q resolved: %s of %s is %g", alert.RuleName, alert.Metric, alert.AgentName, value)
+	s.notify(tree, alert)
+}
+
+// notify sends an alert to the routes of its group, or of the nearest group above it with routes
+func (s *AgentAlertService) notify(tree *groupTree, alert *domain.AgentAlert) {
+	routes := tree.routes(alert.GroupID)
+	if len(routes) == 0 {
+		return
+	}
+
+	groupName := ""
+	if group := tree.groups[alert.GroupID]; group != nil {
+		groupName = group.Name
+	}
+
+	s.notifier.Send(routes, domain.Notification{
+		Kind:   domain.NotificationKindAgentAlert,
+		Status: alert.Status,
+		Text: fmt.Sprintf("[%s] %s: %s of %s is %g (%s %g)",
+			strings.ToUpper(alert.Status), alert.RuleName, alert.Metric, alert.AgentName, alert.Value, alert.Condition, alert.Threshold),
+		Group:  groupName,
+		Alert:  *alert,
+		SentAt: time.Now(),
+	})
+}
+
+func alertKey(groupID int64, ruleName, agentID string) string {
+	return fmt.Sprintf("%d/%s/%s", groupID, ruleName, agentID)
+}
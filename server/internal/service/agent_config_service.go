@@ -0,0 +1,247 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// AgentConfigService manages the config documents stored for agents, groups and tags and merges
+// them into the effective config each agent fetches
+type AgentConfigService struct {
+	repo      domain.AgentConfigRepository
+	agentRepo domain.AgentRepository
+	groups    *GroupService
+	sessions  *AgentSessions
+}
+
+func NewAgentConfigService(repo domain.AgentConfigRepository, agentRepo domain.AgentRepository, groups *GroupService, sessions *AgentSessions) *AgentConfigService {
+	return &AgentConfigService{
+		repo:      repo,
+		agentRepo: agentRepo,
+		groups:    groups,
+		sessions:  sessions,
+	}
+}
+
+// Save validates and stores a document, then asks the connected agents it applies to
+// to fetch their config again. Validation failures wrap domain.ErrInvalidInput.
+func (s *AgentConfigService) Save(ctx context.Context, doc *domain.AgentConfigDocument) error {
+	if err := s.checkTarget(ctx, doc.Scope, doc.Target); err != nil {
+		return err
+	}
+	if err := ValidateAgentSettings(doc.Settings); err != nil {
+		return err
+	}
+	if err := s.repo.Save(ctx, doc); err != nil {
+		return err
+	}
+
+	go s.notify(doc.Scope, doc.Target)
+	return nil
+}
+
+// Delete removes a document and notifies the agents it applied to
+func (s *AgentConfigService) Delete(ctx context.Context, scope, target string) error {
+	if err := s.repo.Delete(ctx, scope, target); err != nil {
+		return err
+	}
+
+	go s.notify(scope, target)
+	return nil
+}
+
+// NotifyAgent asks an agent to fetch its config again, after a change of its tags
+func (s *AgentConfigService) NotifyAgent(agentID string) {
+	go s.notify(domain.AgentConfigScopeAgent, agentID)
+}
+
+// Effective merges the documents of an agent's tags, of its groups and its own document,
+// later documents overriding the keys they set, and reports whether the agent runs the result
+func (s *AgentConfigService) Effective(ctx context.Context, agentID string) (*domain.AgentConfig, error) {
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	groups, err := s.groups.GroupsOf(ctx, agent)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs := make([]string, len(groups))
+	for i, group := range groups {
+		groupIDs[i] = strconv.FormatInt(group.ID, 10)
+	}
+
+	docs, err := s.repo.GetForAgent(ctx, agent.ID, agent.Tags, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make(map[string]interface{})
+	sources := []string{}
+	for _, doc := range docs {
+		mergeSettings(settings, doc.Settings)
+		if doc.Scope == domain.AgentConfigScopeAgent {
+			sources = append(sources, doc.Scope)
+		} else {
+			sources = append(sources, doc.Scope+":"+doc.Target)
+		}
+	}
+
+	version, err := settingsVersion(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.AgentConfig{
+		AgentID:        agent.ID,
+		Version:        version,
+		Settings:       settings,
+		Sources:        sources,
+		AppliedVersion: agent.ConfigVersion,
+		InSync:         agent.ConfigVersion == version,
+	}, nil
+}
+
+// ValidateAgentSettings checks a document only sets known agent settings, with valid values.
+// Failures wrap domain.ErrInvalidInput.
+func ValidateAgentSettings(settings map[string]interface{}) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return invalidInput("settings: %v", err)
+	}
+
+	merged := domain.DefaultAgentSettings()
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&merged); err != nil {
+		return invalidInput("settings: %v", err)
+	}
+	if err := merged.Validate(); err != nil {
+		return invalidInput("settings: %v", err)
+	}
+	return nil
+}
+
+func (s *AgentConfigService) checkTarget(ctx context.Context, scope, target string) error {
+	if target == "" {
+		return invalidInput("target is required")
+	}
+
+	switch scope {
+	case domain.AgentConfigScopeTag:
+		return nil
+	case domain.AgentConfigScopeGroup:
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return invalidInput("group target must be a group ID")
+		}
+		_, err = s.groups.Get(ctx, id)
+		return err
+	case domain.AgentConfigScopeAgent:
+		agent, err := s.agentRepo.GetByID(ctx, target)
+		if err != nil {
+			return err
+		}
+		if agent == nil {
+			return domain.ErrNotFound
+		}
+		return nil
+	default:
+		return invalidInput("scope must be %q, %q or %q", domain.AgentConfigScopeTag, domain.AgentConfigScopeGroup, domain.AgentConfigScopeAgent)
+	}
+}
+
+// notify sends reload-config to the connected agents a changed document applies to;
+// agents that are not connected pick the change up on their next config poll
+func (s *AgentConfigService) notify(scope, target string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var agentIDs []string
+	switch scope {
+	case domain.AgentConfigScopeAgent:
+		agentIDs = []string{target}
+	case domain.AgentConfigScopeGroup:
+		id, err := strconv.ParseInt(target, 10, 64)
+		if err != nil {
+			return
+		}
+		if agentIDs, err = s.groups.MemberIDs(ctx, id); err != nil && !errors.Is(err, domain.ErrNotFound) {
+			log.Printf("⚠️  Failed to list group members for config notification: %v", err)
+			return
+		}
+	default:
+		agents, err := s.agentRepo.GetAll(ctx)
+		if err != nil {
+			log.Printf("⚠️  Failed to list agents for config notification: %v", err)
+			return
+		}
+		for _, agent := range agents {
+			for _, tag := range agent.Tags {
+				if tag == target {
+					agentIDs = append(agentIDs, agent.ID)
+					break
+				}
+			}
+		}
+	}
+
+	sendReloadConfig(s.sessions, agentIDs)
+}
+
+// sendReloadConfig sends reload-config to each connected agent of agentIDs
+func sendReloadConfig(sessions *AgentSessions, agentIDs []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, id := range slices.Compact(slices.Sorted(slices.Values(agentIDs))) {
+		_, err := sessions.Send(ctx, id, domain.AgentCommand{Name: domain.AgentCommandReloadConfig})
+		if err != nil && !errors.Is(err, ErrAgentNotConnected) {
+			log.Printf("⚠️  Agent %s: Failed to send reload-config - %v", id, err)
+		}
+	}
+}
+
+// mergeSettings merges src into dst, recursing into nested objects; other values,
+// lists included, replace those of dst
+func mergeSettings(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				mergeSettings(existing, nested)
+				continue
+			}
+			copied := make(map[string]interface{})
+			mergeSettings(copied, nested)
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// settingsVersion identifies merged settings by a hash of their JSON form, in which
+// encoding/json sorts object keys
+func settingsVersion(settings map[string]interface{}) (string, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode settings: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -12,26 +12,59 @@ import (
 
 type AgentPoller struct {
 	repo     domain.AgentRepository
-	interval time.Duration
-	stopChan chan bool
-	wg       sync.WaitGroup
+	metrics  *MetricsProcessor
+	sessions *AgentSessions
+
+	mu          sync.RWMutex
+	interval    time.Duration
+	timeout     time.Duration // bounds a single pull
+	rescheduled chan struct{}
+	stopChan    chan bool
+	wg          sync.WaitGroup
 }
 
-func NewAgentPoller(repo domain.AgentRepository, interval time.Duration) *AgentPoller {
+func NewAgentPoller(repo domain.AgentRepository, metrics *MetricsProcessor, sessions *AgentSessions, interval, timeout time.Duration) *AgentPoller {
 	return &AgentPoller{
-		repo:     repo,
-		interval: interval,
-		stopChan: make(chan bool),
+		repo:        repo,
+		metrics:     metrics,
+		sessions:    sessions,
+		interval:    interval,
+		timeout:     timeout,
+		rescheduled: make(chan struct{}, 1),
+		stopChan:    make(chan bool),
 	}
 }
 
 // Start begins the polling loop
 func (p *AgentPoller) Start() {
-	log.Printf("🔄 Agent poller started (interval: %s)", p.interval)
+	interval, _ := p.schedule()
+	log.Printf("🔄 Agent poller started (interval: %s)", interval)
 	p.wg.Add(1)
 	go p.pollLoop()
 }
 
+// SetSchedule changes the poll interval and the pull timeout, taking effect after the current poll
+func (p *AgentPoller) SetSchedule(interval, timeout time.Duration) {
+	p.mu.Lock()
+	changed := p.interval != interval
+	p.interval, p.timeout = interval, timeout
+	p.mu.Unlock()
+
+	if changed {
+		select {
+		case p.rescheduled <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// schedule returns the current poll interval and pull timeout
+func (p *AgentPoller) schedule() (time.Duration, time.Duration) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.interval, p.timeout
+}
+
 // Stop gracefully stops the polling loop
 func (p *AgentPoller) Stop() {
 	log.Println("⏸️  Stopping agent poller...")
@@ -44,7 +77,8 @@ func (p *AgentPoller) Stop() {
 func (p *AgentPoller) pollLoop() {
 	defer p.wg.Done()
 
-	ticker := time.NewTicker(p.interval)
+	interval, _ := p.schedule()
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	// Do initial poll immediately
@@ -54,6 +88,10 @@ func (p *AgentPoller) pollLoop() {
 		select {
 		case <-ticker.C:
 			p.pollAllAgents()
+		case <-p.rescheduled:
+			interval, _ = p.schedule()
+			ticker.Reset(interval)
+			log.Printf("🔄 Agent poll interval changed to %s", interval)
 		case <-p.stopChan:
 			return
 		}
@@ -81,6 +119,16 @@ func (p *AgentPoller) pollAllAgents() {
 	// Poll each agent concurrently
 	var wg sync.WaitGroup
 	for _, agent := range agents {
+		if agent.IsVirtual() {
+			p.checkVirtualAgent(ctx, agent)
+			continue
+		}
+
+		// Agents streaming over gRPC push their own samples
+		if p.sessions.Streaming(agent.ID) {
+			continue
+		}
+
 		wg.Add(1)
 		go func(agentID, agentName string) {
 			defer wg.Done()
@@ -93,7 +141,10 @@ func (p *AgentPoller) pollAllAgents() {
 
 // pollAgent polls a single agent
 func (p *AgentPoller) pollAgent(ctx context.Context, agentID, agentName string) {
-	metrics, err := p.repo.PullMetrics(ctx, agentID)
+	_, timeout := p.schedule()
+	pullCtx, cancel := context.WithTimeout(ctx, timeout)
+	metrics, err := p.repo.PullMetrics(pullCtx, agentID)
+	cancel()
 	if err != nil {
 		log.Printf("⚠️  Agent %s (%s): Failed to pull metrics - %v", agentName, agentID, err)
 		return
@@ -106,7 +157,25 @@ func (p *AgentPoller) pollAgent(ctx context.Context, agentID, agentName string)
 			metrics.Metrics.Memory.UsedPercent,
 			getDiskUsagePercent(metrics.Metrics),
 		)
+
+		if err := p.metrics.Process(ctx, metrics); err != nil {
+			log.Printf("⚠️  Agent %s (%s): Failed to process metrics - %v", agentName, agentID, err)
+		}
+	}
+}
+
+// checkVirtualAgent marks a virtual agent offline once its ingest source stops pushing
+func (p *AgentPoller) checkVirtualAgent(ctx context.Context, agent domain.Agent) {
+	interval, _ := p.schedule()
+	if agent.Status != "online" || time.Since(agent.LastSeen) < 3*interval {
+		return
+	}
+
+	if err := p.repo.UpdateStatus(ctx, agent.ID, "offline", agent.LastSeen); err != nil {
+		log.Printf("⚠️  Agent %s (%s): Failed to update status - %v", agent.Name, agent.ID, err)
+		return
 	}
+	log.Printf("⚠️  Agent %s (%s): No %s data since %s, marked offline", agent.Name, agent.ID, agent.Type, agent.LastSeen.Format(time.RFC3339))
 }
 
 // getDiskUsagePercent returns the first disk usage percentage
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// ErrProbeFailed is returned when an agent's /info cannot be fetched
+var ErrProbeFailed = errors.New("agent probe failed")
+
+// AgentService edits registered agents and records every change of their details in
+// the audit trail
+type AgentService struct {
+	repo          domain.AgentRepository
+	auditRepo     domain.AgentAuditRepository
+	configService *AgentConfigService
+}
+
+func NewAgentService(repo domain.AgentRepository, auditRepo domain.AgentAuditRepository, configService *AgentConfigService) *AgentService {
+	return &AgentService{
+		repo:          repo,
+		auditRepo:     auditRepo,
+		configService: configService,
+	}
+}
+
+// Update applies an already validated AgentUpdate. Virtual agents are found by their
+// ingest source, which is their host, so it cannot change. Agents whose tags change are
+// asked to fetch their config again, as tag documents may now apply to them.
+func (s *AgentService) Update(ctx context.Context, id string, update domain.AgentUpdate, actor string) (*domain.Agent, error) {
+	before, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := *before
+	if update.Name != nil {
+		if agent.Name = strings.TrimSpace(*update.Name); agent.Name == "" {
+			return nil, invalidInput("name must not be blank")
+		}
+	}
+	if update.Host != nil && *update.Host != before.Host {
+		if before.IsVirtual() {
+			return nil, invalidInput("the host of a %s agent is its ingest source and cannot change", before.Type)
+		}
+		agent.Host = *update.Host
+	}
+	if update.Tags != nil {
+		agent.Tags = normalizeTags(*update.Tags)
+	}
+	if update.Description != nil {
+		agent.Description = strings.TrimSpace(*update.Description)
+	}
+
+	changes := agentChanges(before, &agent)
+	if len(changes) == 0 {
+		return before, nil
+	}
+
+	agent.UpdatedAt = time.Now()
+	if err := s.repo.Update(ctx, &agent); err != nil {
+		return nil, err
+	}
+	s.record(ctx, agent.ID, domain.AgentAuditUpdate, actor, changes)
+
+	if _, ok := changes["tags"]; ok {
+		s.configService.NotifyAgent(agent.ID)
+	}
+	return &agent, nil
+}
+
+// Reprobe fetches the agent's /info again to refresh its hostname, IP address and version.
+// The agent answering also marks it online.
+func (s *AgentService) Reprobe(ctx context.Context, id, actor string) (*domain.Agent, error) {
+	before, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if before.IsVirtual() {
+		return nil, invalidInput("%s agents have no /info to probe", before.Type)
+	}
+
+	info, err := ProbeAgent(ctx, before.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	agent := *before
+	agent.Hostname = info.Hostname
+	agent.IPAddress = info.IPAddress
+	agent.Version = info.Version
+	agent.Status = "online"
+	agent.LastSeen = time.Now()
+	agent.UpdatedAt = agent.LastSeen
+
+	if err := s.repo.Update(ctx, &agent); err != nil {
+		return nil, err
+	}
+	s.record(ctx, agent.ID, domain.AgentAuditReprobe, actor, agentChanges(before, &agent))
+	return &agent, nil
+}
+
+// Record adds the changes between two versions of an agent to its audit trail; before is
+// nil for a registration and after is nil for a deletion. Nothing is recorded when no
+// audited field changed.
+func (s *AgentService) Record(ctx context.Context, action, actor string, before, after *domain.Agent) {
+	id := ""
+	if after != nil {
+		id = after.ID
+	} else if before != nil {
+		id = before.ID
+	}
+	s.record(ctx, id, action, actor, agentChanges(before, after))
+}
+
+// History returns the latest audit entries of an agent, newest first, also after it was deleted
+func (s *AgentService) History(ctx context.Context, agentID string, limit int) ([]domain.AgentAuditEntry, error) {
+	return s.auditRepo.GetEntries(ctx, agentID, limit)
+}
+
+func (s *AgentService) get(ctx context.Context, id string) (*domain.Agent, error) {
+	agent, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, domain.ErrNotFound
+	}
+	return agent, nil
+}
+
+// record stores an audit entry. The change itself is already saved, so a failure is only logged.
+func (s *AgentService) record(ctx context.Context, agentID, action, actor string, changes map[string]domain.AgentChange) {
+	if len(changes) == 0 {
+		return
+	}
+
+	entry := &domain.AgentAuditEntry{
+		AgentID:   agentID,
+		Action:    action,
+		Actor:     actor,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}
+	if err := s.auditRepo.Record(context.WithoutCancel(ctx), entry); err != nil {
+		log.Printf("⚠️  Agent %s: Failed to record %s in audit trail - %v", agentID, action, err)
+	}
+}
+
+// agentChanges compares the audited fields of two versions of an agent, either of which may be nil
+func agentChanges(before, after *domain.Agent) map[string]domain.AgentChange {
+	var old, cur domain.Agent
+	if before != nil {
+		old = *before
+	}
+	if after != nil {
+		cur = *after
+	}
+
+	fields := []struct {
+		name     string
+		old, cur interface{}
+		changed  bool
+	}{
+		{"name", old.Name, cur.Name, old.Name != cur.Name},
+		{"host", old.Host, cur.Host, old.Host != cur.Host},
+		{"hostname", old.Hostname, cur.Hostname, old.Hostname != cur.Hostname},
+		{"ip_address", old.IPAddress, cur.IPAddress, old.IPAddress != cur.IPAddress},
+		{"version", old.Version, cur.Version, old.Version != cur.Version},
+		{"tags", old.Tags, cur.Tags, !slices.Equal(old.Tags, cur.Tags)},
+		{"description", old.Description, cur.Description, old.Description != cur.Description},
+	}
+
+	changes := make(map[string]domain.AgentChange)
+	for _, field := range fields {
+		if !field.changed {
+			continue
+		}
+		change := domain.AgentChange{Old: field.old, New: field.cur}
+		if before == nil {
+			change.Old = nil
+		}
+		if after == nil {
+			change.New = nil
+		}
+		changes[field.name] = change
+	}
+	return changes
+}
+
+// normalizeTags trims tags and drops blank and repeated ones, keeping their order
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// ProbeAgent fetches the info an agent reports about itself on GET /info, within ctx's
+// deadline. Failures wrap ErrProbeFailed.
+func ProbeAgent(ctx context.Context, host string) (*domain.AgentInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/info", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProbeFailed, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot connect to agent at %s: %v", ErrProbeFailed, host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: agent returned status %d", ErrProbeFailed, resp.StatusCode)
+	}
+
+	var infoResp struct {
+		Success bool             `json:"success"`
+		Data    domain.AgentInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&infoResp); err != nil {
+		return nil, fmt.Errorf("%w: invalid agent info response: %v", ErrProbeFailed, err)
+	}
+	return &infoResp.Data, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// ErrAgentNotConnected is returned when a command is sent to an agent without an open command channel
+var ErrAgentNotConnected = errors.New("agent is not connected")
+
+// AgentSessions tracks agents connected over gRPC: their open metrics streams, so the poller
+// does not pull from agents that push, and their command channels
+type AgentSessions struct {
+	mu       sync.Mutex
+	streams  map[string]int // open metrics streams per agent ID
+	channels map[string]*commandChannel
+}
+
+type commandChannel struct {
+	commands chan domain.AgentCommand
+	pending  map[string]chan domain.AgentCommandResult // guarded by AgentSessions.mu
+	done     chan struct{}
+}
+
+func NewAgentSessions() *AgentSessions {
+	return &AgentSessions{
+		streams:  make(map[string]int),
+		channels: make(map[string]*commandChannel),
+	}
+}
+
+// StreamOpened records an open metrics stream; the returned function must be called when it ends
+func (s *AgentSessions) StreamOpened(agentID string) func() {
+	s.mu.Lock()
+	s.streams[agentID]++
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.streams[agentID]--; s.streams[agentID] <= 0 {
+			delete(s.streams, agentID)
+		}
+	}
+}
+
+// Streaming reports whether an agent has an open metrics stream
+func (s *AgentSessions) Streaming(agentID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[agentID] > 0
+}
+
+// OpenCommandChannel registers the command channel of an agent, replacing an older one, and
+// returns the commands to deliver and a function to call when the channel closes
+func (s *AgentSessions) OpenCommandChannel(agentID string) (<-chan domain.AgentCommand, func()) {
+	channel := &commandChannel{
+		commands: make(chan domain.AgentCommand, 16),
+		pending:  make(map[string]chan domain.AgentCommandResult),
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	if old := s.channels[agentID]; old != nil {
+		close(old.done)
+	}
+	s.channels[agentID] = channel
+	s.mu.Unlock()
+
+	return channel.commands, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.channels[agentID] == channel {
+			delete(s.channels, agentID)
+			close(channel.done)
+		}
+	}
+}
+
+// Complete hands the result of a command to the caller waiting for it
+func (s *AgentSessions) Complete(agentID string, result domain.AgentCommandResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	channel := s.channels[agentID]
+	if channel == nil {
+		return
+	}
+	if waiting, ok := channel.pending[result.CommandID]; ok {
+		delete(channel.pending, result.CommandID)
+		waiting <- result
+	}
+}
+
+// Send delivers a command to a connected agent and waits for its result until ctx is done
+func (s *AgentSessions) Send(ctx context.Context, agentID string, command domain.AgentCommand) (*domain.AgentCommandResult, error) {
+	command.ID = uuid.New().String()
+	command.IssuedAt = time.Now()
+	result := make(chan domain.AgentCommandResult, 1)
+
+	s.mu.Lock()
+	channel := s.channels[agentID]
+	if channel == nil {
+		s.mu.Unlock()
+		return nil, ErrAgentNotConnected
+	}
+	channel.pending[command.ID] = result
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(channel.pending, command.ID)
+		s.mu.Unlock()
+	}()
+
+	select {
+	case channel.commands <- command:
+	case <-channel.done:
+		return nil, ErrAgentNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case r := <-result:
+		return &r, nil
+	case <-channel.done:
+		return nil, ErrAgentNotConnected
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
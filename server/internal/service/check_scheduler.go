@@ -0,0 +1,674 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/cron"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/probe"
+)
+
+// checkResultRetention is how long check results and heartbeat pings are kept
+const checkResultRetention = 30 * 24 * time.Hour
+
+// heartbeatPingPath is where the ping URLs of heartbeat checks are routed
+const heartbeatPingPath = "/api/v1/heartbeats/"
+
+// CheckScheduler runs every synthetic check on its interval, from the server or from the
+// agents it is assigned to, stores the results and fires an alert for each location where
+// a check keeps failing. Heartbeat checks are not run: their jobs ping them, and a run
+// whose ping is overdue is stored as a failure.
+type CheckScheduler struct {
+	repo     domain.CheckRepository
+	sessions *AgentSessions
+	notifier *Notifier
+
+	mu     sync.Mutex
+	checks map[int64]*domain.Check
+	next   map[int64]time.Time
+	// running holds the checks being run, so a slow check does not overlap itself
+	running map[int64]bool
+	// failing holds when each check started failing at each location, and the failures since
+	failing map[string]*checkFailure
+	firing  map[string]*domain.CheckAlert
+	// unreachable holds why an agent could not run a check at its last run
+	unreachable map[string]string
+	// heartbeats holds the jobs of heartbeat checks by check ID, tokens their IDs by ping token
+	heartbeats map[int64]*heartbeatJob
+	tokens     map[string]int64
+
+	reload   chan struct{}
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+type checkFailure struct {
+	since time.Time
+	count int
+}
+
+// heartbeatJob tracks the job of a heartbeat check
+type heartbeatJob struct {
+	// since is when the last run finished or was missed; the next run is the first one
+	// scheduled after it
+	since        time.Time
+	lastPing     *time.Time
+	runningSince *time.Time
+	// dueBy is when the ping of the next run is overdue, zero when the schedule never runs
+	nextRun, dueBy time.Time
+}
+
+// advance waits for the first run scheduled after since
+func (j *heartbeatJob) advance(options *domain.HeartbeatCheckOptions, since time.Time) {
+	j.since = since
+	j.nextRun, j.dueBy = nextHeartbeat(options, since)
+}
+
+func NewCheckScheduler(repo domain.CheckRepository, sessions *AgentSessions, notifier *Notifier) *CheckScheduler {
+	return &CheckScheduler{
+		repo:        repo,
+		sessions:    sessions,
+		notifier:    notifier,
+		checks:      make(map[int64]*domain.Check),
+		next:        make(map[int64]time.Time),
+		running:     make(map[int64]bool),
+		failing:     make(map[string]*checkFailure),
+		firing:      make(map[string]*domain.CheckAlert),
+		unreachable: make(map[string]string),
+		heartbeats:  make(map[int64]*heartbeatJob),
+		tokens:      make(map[string]int64),
+		reload:      make(chan struct{}, 1),
+		stopChan:    make(chan bool),
+	}
+}
+
+// Start loads the checks and their firing alerts and begins the scheduling loop
+func (s *CheckScheduler) Start() error {
+	ctx := context.Background()
+
+	firing, err := s.repo.GetAlerts(ctx, domain.CheckAlertQuery{Status: domain.AlertStatusFiring, Limit: maxFiringAlerts})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	for i := range firing {
+		s.firing[checkKey(firing[i].CheckID, firing[i].Location)] = &firing[i]
+	}
+	s.mu.Unlock()
+
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	log.Printf("🩺 Check scheduler started (%d checks)", len(s.checks))
+	s.wg.Add(1)
+	go s.scheduleLoop()
+	return nil
+}
+
+// Stop gracefully stops the scheduling loop
+func (s *CheckScheduler) Stop() {
+	log.Println("⏸️  Stopping check scheduler...")
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Check scheduler stopped")
+}
+
+// Reload makes the scheduler read the checks again, after one was created, changed or deleted
+func (s *CheckScheduler) Reload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (s *CheckScheduler) scheduleLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+
+	s.prune()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			s.runDue(now)
+		case <-s.reload:
+			if err := s.load(context.Background()); err != nil {
+				log.Printf("❌ Failed to reload checks: %v", err)
+			}
+		case <-prune.C:
+			s.prune()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// load reads the checks, scheduling new ones right away and resolving the alerts of
+// checks and locations that are gone or paused
+func (s *CheckScheduler) load(ctx context.Context) error {
+	checks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	restored, err := s.restoreHeartbeats(ctx, checks)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[string]bool)
+	loaded := make(map[int64]*domain.Check, len(checks))
+	for i := range checks {
+		check := &checks[i]
+		loaded[check.ID] = check
+		if _, ok := s.next[check.ID]; !ok {
+			s.next[check.ID] = time.Now()
+		}
+		if check.Paused {
+			continue
+		}
+		for _, location := range check.Locations {
+			active[checkKey(check.ID, location)] = true
+		}
+	}
+	for id := range s.next {
+		if loaded[id] == nil {
+			delete(s.next, id)
+		}
+	}
+	s.checks = loaded
+
+	// Jobs keep their state across reloads; a changed schedule applies from their last run
+	heartbeats := make(map[int64]*heartbeatJob)
+	tokens := make(map[string]int64)
+	for id, check := range loaded {
+		if check.Type != domain.CheckTypeHeartbeat || check.Heartbeat == nil {
+			continue
+		}
+		job := s.heartbeats[id]
+		if job == nil {
+			job = restored[id]
+		}
+		job.advance(check.Heartbeat, job.since)
+		heartbeats[id] = job
+		tokens[check.Target] = id
+	}
+	s.heartbeats = heartbeats
+	s.tokens = tokens
+
+	for key, alert := range s.firing {
+		if !active[key] {
+			s.resolve(ctx, alert, time.Now())
+		}
+	}
+	for key := range s.failing {
+		if !active[key] {
+			delete(s.failing, key)
+		}
+	}
+	for key := range s.unreachable {
+		if !active[key] {
+			delete(s.unreachable, key)
+		}
+	}
+	return nil
+}
+
+// restoreHeartbeats rebuilds the jobs of heartbeat checks not loaded yet from their latest
+// result and ping
+func (s *CheckScheduler) restoreHeartbeats(ctx context.Context, checks []domain.Check) (map[int64]*heartbeatJob, error) {
+	s.mu.Lock()
+	var missing []*domain.Check
+	for i := range checks {
+		if checks[i].Type == domain.CheckTypeHeartbeat && s.heartbeats[checks[i].ID] == nil {
+			missing = append(missing, &checks[i])
+		}
+	}
+	s.mu.Unlock()
+
+	restored := make(map[int64]*heartbeatJob, len(missing))
+	if len(missing) == 0 {
+		return restored, nil
+	}
+
+	latest, err := s.repo.GetLatestResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+	finished := make(map[int64]time.Time)
+	for _, result := range latest {
+		if result.Location == domain.CheckLocationServer {
+			finished[result.CheckID] = result.CheckedAt
+		}
+	}
+
+	for _, check := range missing {
+		job := &heartbeatJob{since: check.CreatedAt}
+		if at, ok := finished[check.ID]; ok && at.After(job.since) {
+			job.since = at
+		}
+
+		pings, err := s.repo.GetPings(ctx, domain.HeartbeatPingQuery{CheckID: check.ID, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(pings) > 0 {
+			at := pings[0].ReceivedAt
+			job.lastPing = &at
+			if pings[0].Kind == domain.HeartbeatPingStart && at.After(job.since) {
+				job.runningSince = &at
+			}
+		}
+		restored[check.ID] = job
+	}
+	return restored, nil
+}
+
+// runDue starts the checks whose time has come and records the heartbeat runs missed
+func (s *CheckScheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, check := range s.checks {
+		if check.Type == domain.CheckTypeHeartbeat {
+			s.heartbeatDue(now, check)
+			continue
+		}
+		if check.Paused || s.running[id] || now.Before(s.next[id]) {
+			continue
+		}
+		s.next[id] = now.Add(check.Interval())
+		s.running[id] = true
+
+		go func(check domain.Check) {
+			s.Run(context.Background(), &check)
+			s.mu.Lock()
+			delete(s.running, check.ID)
+			s.mu.Unlock()
+		}(*check)
+	}
+}
+
+// Run runs a check once at each of its locations, stores the results and fires or
+// resolves its alerts. Locations whose agent could not run the check have no result.
+func (s *CheckScheduler) Run(ctx context.Context, check *domain.Check) []domain.CheckResult {
+	results := make([]*domain.CheckResult, len(check.Locations))
+	var wg sync.WaitGroup
+	for i, location := range check.Locations {
+		wg.Add(1)
+		go func(i int, location string) {
+			defer wg.Done()
+			results[i] = s.probe(ctx, check, location)
+		}(i, location)
+	}
+	wg.Wait()
+
+	stored := []domain.CheckResult{}
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		s.record(ctx, check, result)
+		stored = append(stored, *result)
+	}
+	return stored
+}
+
+// record stores a result and fires or resolves the alert of its location
+func (s *CheckScheduler) record(ctx context.Context, check *domain.Check, result *domain.CheckResult) {
+	if err := s.repo.SaveResult(ctx, result); err != nil {
+		log.Printf("❌ Failed to save result of check %q at %s: %v", check.Name, result.Location, err)
+	}
+	s.track(ctx, check, result)
+}
+
+// heartbeatDue records a missed run of a heartbeat check once its ping is overdue. Runs
+// missed while the check is paused are skipped. s.mu must be held.
+func (s *CheckScheduler) heartbeatDue(now time.Time, check *domain.Check) {
+	job := s.heartbeats[check.ID]
+	if job == nil || job.dueBy.IsZero() || now.Before(job.dueBy) || s.running[check.ID] {
+		return
+	}
+
+	reason := fmt.Sprintf("no ping for the run at %s by %s", job.nextRun.Format(time.RFC3339), job.dueBy.Format(time.RFC3339))
+	if job.runningSince != nil {
+		reason = fmt.Sprintf("started at %s but did not finish by %s", job.runningSince.Format(time.RFC3339), job.dueBy.Format(time.RFC3339))
+	}
+	job.runningSince = nil
+	job.advance(check.Heartbeat, now)
+	if check.Paused {
+		return
+	}
+
+	result := &domain.CheckResult{
+		CheckID:  check.ID,
+		Location: domain.CheckLocationServer,
+		ProbeResult: domain.ProbeResult{
+			Status:    domain.CheckStatusDown,
+			Error:     reason,
+			CheckedAt: now,
+		},
+	}
+	s.running[check.ID] = true
+
+	go func(check domain.Check) {
+		s.record(context.Background(), &check, result)
+		s.mu.Lock()
+		delete(s.running, check.ID)
+		s.mu.Unlock()
+	}(*check)
+}
+
+// Ping records a call of the ping URL of a heartbeat check by its job. A finished run is
+// stored as a result of the check, failed or not, which fires or resolves its alert; the
+// pings of a paused check are only recorded. It returns ErrNotFound for an unknown token.
+func (s *CheckScheduler) Ping(ctx context.Context, token string, ping *domain.HeartbeatPing) error {
+	s.mu.Lock()
+	id, ok := s.tokens[token]
+	job := s.heartbeats[id]
+	if !ok || job == nil {
+		s.mu.Unlock()
+		return domain.ErrNotFound
+	}
+	check := *s.checks[id]
+
+	ping.CheckID = id
+	at := ping.ReceivedAt
+	if ping.Kind != domain.HeartbeatPingStart && ping.DurationMs == nil && job.runningSince != nil {
+		ms := float64(at.Sub(*job.runningSince).Microseconds()) / 1000
+		ping.DurationMs = &ms
+	}
+	job.lastPing = &at
+	if ping.Kind == domain.HeartbeatPingStart {
+		job.runningSince = &at
+	} else {
+		job.runningSince = nil
+		job.advance(check.Heartbeat, at)
+	}
+	s.mu.Unlock()
+
+	if err := s.repo.SavePing(ctx, ping); err != nil {
+		return err
+	}
+	if ping.Kind == domain.HeartbeatPingStart || check.Paused {
+		return nil
+	}
+
+	result := &domain.CheckResult{
+		CheckID:     id,
+		Location:    domain.CheckLocationServer,
+		ProbeResult: domain.ProbeResult{Status: domain.CheckStatusUp, CheckedAt: at},
+	}
+	if ping.DurationMs != nil {
+		result.LatencyMs = *ping.DurationMs
+	}
+	if ping.Kind == domain.HeartbeatPingFail {
+		result.Status = domain.CheckStatusDown
+		result.Error = "job failed"
+		if ping.ExitCode != nil {
+			result.Error = fmt.Sprintf("job failed with exit code %d", *ping.ExitCode)
+		}
+	}
+	s.record(ctx, &check, result)
+	return nil
+}
+
+// probe runs a check from the server, or asks the agent at location to run it
+func (s *CheckScheduler) probe(ctx context.Context, check *domain.Check, location string) *domain.CheckResult {
+	key := checkKey(check.ID, location)
+	result := &domain.CheckResult{CheckID: check.ID, Location: location}
+
+	if location == domain.CheckLocationServer {
+		result.ProbeResult = probe.Run(ctx, check.CheckSpec)
+		return result
+	}
+
+	probeResult, err := s.probeFromAgent(ctx, check, location)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if _, ok := s.unreachable[key]; !ok {
+			log.Printf("⚠️  Check %q: Agent %s could not run it - %v", check.Name, location, err)
+		}
+		s.unreachable[key] = err.Error()
+		return nil
+	}
+	delete(s.unreachable, key)
+	result.ProbeResult = *probeResult
+	return result
+}
+
+func (s *CheckScheduler) probeFromAgent(ctx context.Context, check *domain.Check, agentID string) (*domain.ProbeResult, error) {
+	spec, err := json.Marshal(check.CheckSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Give the agent time to answer after the probe's own timeout
+	timeout := check.Timeout()
+	if timeout <= 0 {
+		timeout = probe.DefaultTimeout
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, timeout+5*time.Second)
+	defer cancel()
+
+	reply, err := s.sessions.Send(sendCtx, agentID, domain.AgentCommand{
+		Name: domain.AgentCommandProbe,
+		Args: map[string]string{"check": string(spec)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !reply.Success {
+		return nil, errors.New(reply.Error)
+	}
+
+	var result domain.ProbeResult
+	if err := json.Unmarshal([]byte(reply.Output), &result); err != nil {
+		return nil, fmt.Errorf("invalid probe result: %w", err)
+	}
+	return &result, nil
+}
+
+// track counts the consecutive failures of a check at a location, firing an alert once they
+// reach the check's threshold and resolving it at the next success
+func (s *CheckScheduler) track(ctx context.Context, check *domain.Check, result *domain.CheckResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := checkKey(check.ID, result.Location)
+	alert := s.firing[key]
+
+	if result.Status == domain.CheckStatusUp {
+		delete(s.failing, key)
+		if alert != nil {
+			s.resolve(ctx, alert, result.CheckedAt)
+		}
+		return
+	}
+
+	failure := s.failing[key]
+	if failure == nil {
+		failure = &checkFailure{since: result.CheckedAt}
+		s.failing[key] = failure
+	}
+	failure.count++
+	if alert != nil || failure.count < check.FailureThreshold {
+		return
+	}
+
+	alert = &domain.CheckAlert{
+		CheckID:   check.ID,
+		CheckName: check.Name,
+		Location:  result.Location,
+		Status:    domain.AlertStatusFiring,
+		Error:     result.Error,
+		StartedAt: failure.since,
+		FiredAt:   result.CheckedAt,
+	}
+	if err := s.repo.SaveAlert(ctx, alert); err != nil {
+		log.Printf("❌ Failed to save alert of check %q at %s: %v", check.Name, result.Location, err)
+		return
+	}
+	s.firing[key] = alert
+	log.Printf("🚨 Check %q down at %s since %s: %s", check.Name, result.Location, failure.since.Format(time.RFC3339), result.Error)
+	s.notify(check.Notifications, alert)
+}
+
+// resolve resolves a firing alert; s.mu must be held
+func (s *CheckScheduler) resolve(ctx context.Context, alert *domain.CheckAlert, at time.Time) {
+	alert.Status = domain.AlertStatusResolved
+	alert.ResolvedAt = &at
+	if err := s.repo.SaveAlert(ctx, alert); err != nil {
+		log.Printf("❌ Failed to resolve alert of check %q at %s: %v", alert.CheckName, alert.Location, err)
+		return
+	}
+	delete(s.firing, checkKey(alert.CheckID, alert.Location))
+	log.Printf("✅ Check %q no longer down at %s", alert.CheckName, alert.Location)
+
+	var routes []domain.NotificationRoute
+	if check := s.checks[alert.CheckID]; check != nil {
+		routes = check.Notifications
+	}
+	s.notify(routes, alert)
+}
+
+func (s *CheckScheduler) notify(routes []domain.NotificationRoute, alert *domain.CheckAlert) {
+	if len(routes) == 0 {
+		return
+	}
+
+	text := fmt.Sprintf("[%s] check %s is down at %s: %s", strings.ToUpper(alert.Status), alert.CheckName, alert.Location, alert.Error)
+	if alert.Status == domain.AlertStatusResolved {
+		text = fmt.Sprintf("[%s] check %s is no longer down at %s", strings.ToUpper(alert.Status), alert.CheckName, alert.Location)
+	}
+
+	s.notifier.Send(routes, domain.Notification{
+		Kind:   domain.NotificationKindCheckAlert,
+		Status: alert.Status,
+		Text:   text,
+		Alert:  *alert,
+		SentAt: time.Now(),
+	})
+}
+
+// Annotate sets the status of checks at each of their locations from their latest results
+func (s *CheckScheduler) Annotate(checks []domain.Check, latest []domain.CheckResult) {
+	results := make(map[string]*domain.CheckResult, len(latest))
+	for i := range latest {
+		results[checkKey(latest[i].CheckID, latest[i].Location)] = &latest[i]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range checks {
+		check := &checks[i]
+		check.Status = domain.CheckStatusUnknown
+		check.LocationStatus = make([]domain.CheckLocationStatus, 0, len(check.Locations))
+
+		for _, location := range check.Locations {
+			key := checkKey(check.ID, location)
+			status := domain.CheckLocationStatus{
+				Location: location,
+				Status:   domain.CheckStatusUnknown,
+				Firing:   s.firing[key] != nil,
+			}
+			if result := results[key]; result != nil {
+				checkedAt := result.CheckedAt
+				status.Status = result.Status
+				status.LastCheckedAt = &checkedAt
+				status.LatencyMs = result.LatencyMs
+				status.Error = result.Error
+				if check.Status == domain.CheckStatusUnknown {
+					check.Status = domain.CheckStatusUp
+				}
+			}
+			if reason, ok := s.unreachable[key]; ok {
+				status.Status = domain.CheckStatusUnknown
+				status.Error = reason
+			}
+			check.LocationStatus = append(check.LocationStatus, status)
+		}
+
+		for _, status := range check.LocationStatus {
+			if status.Firing {
+				check.Status = domain.CheckStatusDown
+			}
+		}
+		if check.Type == domain.CheckTypeHeartbeat && check.Heartbeat != nil {
+			check.HeartbeatState = s.heartbeatState(check)
+		}
+	}
+}
+
+// heartbeatState reports the job of a heartbeat check; s.mu must be held
+func (s *CheckScheduler) heartbeatState(check *domain.Check) *domain.HeartbeatState {
+	state := &domain.HeartbeatState{PingURL: heartbeatPingPath + check.Target}
+
+	// A check created or changed just now may not be loaded yet
+	since := check.CreatedAt
+	if job := s.heartbeats[check.ID]; job != nil {
+		since = job.since
+		state.LastPingAt = job.lastPing
+		state.RunningSince = job.runningSince
+	}
+	if nextRun, dueBy := nextHeartbeat(check.Heartbeat, since); !nextRun.IsZero() {
+		state.NextRunAt = &nextRun
+		state.DueBy = &dueBy
+	}
+	return state
+}
+
+// heartbeatSchedule parses the schedule of a heartbeat check and loads its timezone
+func heartbeatSchedule(options *domain.HeartbeatCheckOptions) (*cron.Schedule, *time.Location, error) {
+	schedule, err := cron.Parse(options.Schedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	location := time.UTC
+	if options.Timezone != "" {
+		if location, err = time.LoadLocation(options.Timezone); err != nil {
+			return nil, nil, err
+		}
+	}
+	return schedule, location, nil
+}
+
+// nextHeartbeat returns the first run of a heartbeat check scheduled after since and when
+// its ping is overdue; both are zero when the schedule never runs
+func nextHeartbeat(options *domain.HeartbeatCheckOptions, since time.Time) (nextRun, dueBy time.Time) {
+	schedule, location, err := heartbeatSchedule(options)
+	if err != nil {
+		return time.Time{}, time.Time{}
+	}
+	if nextRun = schedule.Next(since.In(location)); nextRun.IsZero() {
+		return time.Time{}, time.Time{}
+	}
+	return nextRun, nextRun.Add(options.Grace())
+}
+
+func (s *CheckScheduler) prune() {
+	if err := s.repo.PruneResults(context.Background(), time.Now().Add(-checkResultRetention)); err != nil {
+		log.Printf("⚠️  Failed to prune check results: %v", err)
+	}
+}
+
+func checkKey(checkID int64, location string) string {
+	return fmt.Sprintf("%d/%s", checkID, location)
+}
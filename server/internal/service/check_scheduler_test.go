@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+// webhook receives notifications, sending each to the returned channel
+func webhook(t *testing.T) (string, <-chan domain.Notification) {
+	t.Helper()
+	received := make(chan domain.Notification, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification domain.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Error(err)
+		}
+		received <- notification
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, received
+}
+
+func expectNotification(t *testing.T, received <-chan domain.Notification, kind, status string) {
+	t.Helper()
+	select {
+	case notification := <-received:
+		if notification.Kind != kind || notification.Status != status {
+			t.Errorf("notification = %s %s (%s), want %s %s", notification.Kind, notification.Status, notification.Text, kind, status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s notification", status)
+	}
+}
+
+func TestCheckSchedulerAlerts(t *testing.T) {
+	ctx := context.Background()
+	repo := sqlite.NewCheckRepository(openTestDB(t))
+
+	var status atomic.Int32
+	status.Store(http.StatusOK)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer target.Close()
+	url, received := webhook(t)
+
+	check := &domain.Check{
+		Name:             "api",
+		CheckSpec:        domain.CheckSpec{Type: domain.CheckTypeHTTP, Target: target.URL},
+		IntervalSeconds:  60,
+		Locations:        []string{domain.CheckLocationServer},
+		FailureThreshold: 2,
+		Notifications:    []domain.NotificationRoute{{Name: "ops", URL: url}},
+	}
+	if err := repo.Create(ctx, check); err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewCheckScheduler(repo, nil, NewNotifier(time.Second))
+	if err := scheduler.load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(want string) {
+		t.Helper()
+		results := scheduler.Run(ctx, check)
+		if len(results) != 1 || results[0].Status != want {
+			t.Fatalf("Run() = %+v, want one %s result", results, want)
+		}
+	}
+	alerts := func(status string) []domain.CheckAlert {
+		t.Helper()
+		alerts, err := repo.GetAlerts(ctx, domain.CheckAlertQuery{CheckID: check.ID, Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return alerts
+	}
+
+	run(domain.CheckStatusUp)
+	status.Store(http.StatusServiceUnavailable)
+
+	// One failure stays below the threshold
+	run(domain.CheckStatusDown)
+	if firing := alerts(domain.AlertStatusFiring); len(firing) != 0 {
+		t.Fatalf("%d alerts firing after one failure, want none below the threshold of 2", len(firing))
+	}
+
+	run(domain.CheckStatusDown)
+	firing := alerts(domain.AlertStatusFiring)
+	if len(firing) != 1 || firing[0].Location != domain.CheckLocationServer || firing[0].Error != "status 503" || firing[0].StartedAt.After(firing[0].FiredAt) {
+		t.Fatalf("firing alerts = %+v, want one since the first failure", firing)
+	}
+	expectNotification(t, received, domain.NotificationKindCheckAlert, domain.AlertStatusFiring)
+
+	// Further failures do not fire it again
+	run(domain.CheckStatusDown)
+	if firing := alerts(domain.AlertStatusFiring); len(firing) != 1 {
+		t.Fatalf("%d alerts firing after another failure, want 1", len(firing))
+	}
+
+	checks := []domain.Check{*check}
+	scheduler.Annotate(checks, nil)
+	if checks[0].Status != domain.CheckStatusDown {
+		t.Errorf("Annotate() status = %s while the alert fires, want down", checks[0].Status)
+	}
+
+	status.Store(http.StatusOK)
+	run(domain.CheckStatusUp)
+	if firing := alerts(domain.AlertStatusFiring); len(firing) != 0 {
+		t.Fatalf("%d alerts firing after a success, want none", len(firing))
+	}
+	resolved := alerts(domain.AlertStatusResolved)
+	if len(resolved) != 1 || resolved[0].ResolvedAt == nil {
+		t.Fatalf("resolved alerts = %+v, want the alert resolved", resolved)
+	}
+	expectNotification(t, received, domain.NotificationKindCheckAlert, domain.AlertStatusResolved)
+
+	// A failure after the success counts from one again
+	status.Store(http.StatusServiceUnavailable)
+	run(domain.CheckStatusDown)
+	if firing := alerts(domain.AlertStatusFiring); len(firing) != 0 {
+		t.Fatalf("%d alerts firing after one new failure, want none", len(firing))
+	}
+	select {
+	case notification := <-received:
+		t.Errorf("unexpected notification %s", notification.Text)
+	default:
+	}
+}
+
+func TestCheckSchedulerResolvesPausedChecks(t *testing.T) {
+	ctx := context.Background()
+	repo := sqlite.NewCheckRepository(openTestDB(t))
+
+	check := &domain.Check{
+		Name:            "db",
+		CheckSpec:       domain.CheckSpec{Type: domain.CheckTypeTCP, Target: "127.0.0.1:1"},
+		IntervalSeconds: 60,
+		Locations:       []string{domain.CheckLocationServer},
+	}
+	if err := repo.Create(ctx, check); err != nil {
+		t.Fatal(err)
+	}
+	scheduler := NewCheckScheduler(repo, nil, NewNotifier(time.Second))
+	if err := scheduler.load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// With the default threshold, the first failure fires
+	if results := scheduler.Run(ctx, check); len(results) != 1 || results[0].Status != domain.CheckStatusDown {
+		t.Fatalf("Run() = %+v, want one down result", results)
+	}
+	if firing, err := repo.GetAlerts(ctx, domain.CheckAlertQuery{Status: domain.AlertStatusFiring}); err != nil || len(firing) != 1 {
+		t.Fatalf("firing alerts = %d, %v, want 1", len(firing), err)
+	}
+
+	// Pausing the check resolves its alert
+	check.Paused = true
+	if err := repo.Update(ctx, check); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if firing, err := repo.GetAlerts(ctx, domain.CheckAlertQuery{Status: domain.AlertStatusFiring}); err != nil || len(firing) != 0 {
+		t.Errorf("firing alerts after pausing = %d, %v, want none", len(firing), err)
+	}
+}
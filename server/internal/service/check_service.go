@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/probe"
+)
+
+// Defaults of the checks fields left unset
+const (
+	defaultCheckInterval  = 60
+	defaultCheckTimeout   = 10
+	defaultHeartbeatGrace = 300
+)
+
+// CheckService manages synthetic checks and reports their status and uptime
+type CheckService struct {
+	repo      domain.CheckRepository
+	agentRepo domain.AgentRepository
+	scheduler *CheckScheduler
+}
+
+func NewCheckService(repo domain.CheckRepository, agentRepo domain.AgentRepository, scheduler *CheckScheduler) *CheckService {
+	return &CheckService{
+		repo:      repo,
+		agentRepo: agentRepo,
+		scheduler: scheduler,
+	}
+}
+
+// GetAll lists the checks with their status
+func (s *CheckService) GetAll(ctx context.Context) ([]domain.Check, error) {
+	checks, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.repo.GetLatestResults(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.scheduler.Annotate(checks, latest)
+	return checks, nil
+}
+
+// Get returns a check with its status
+func (s *CheckService) Get(ctx context.Context, id int64) (*domain.Check, error) {
+	check, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if check == nil {
+		return nil, domain.ErrNotFound
+	}
+	if err := s.annotate(ctx, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// Create validates and stores a check, which runs right away. Validation failures wrap
+// domain.ErrInvalidInput.
+func (s *CheckService) Create(ctx context.Context, check *domain.Check) error {
+	if err := issueHeartbeatToken(check, nil); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, check); err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, check); err != nil {
+		return err
+	}
+
+	s.scheduler.Reload()
+	return s.annotate(ctx, check)
+}
+
+// Update replaces a check. Validation failures wrap domain.ErrInvalidInput.
+func (s *CheckService) Update(ctx context.Context, check *domain.Check) error {
+	current, err := s.repo.Get(ctx, check.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return domain.ErrNotFound
+	}
+	if err := issueHeartbeatToken(check, current); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, check); err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, check); err != nil {
+		return err
+	}
+
+	s.scheduler.Reload()
+	return s.annotate(ctx, check)
+}
+
+// Delete removes a check with its results and alerts
+func (s *CheckService) Delete(ctx context.Context, id int64) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.scheduler.Reload()
+	return nil
+}
+
+// Run runs a check now at each of its locations, paused or not
+func (s *CheckService) Run(ctx context.Context, id int64) ([]domain.CheckResult, error) {
+	check, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if check == nil {
+		return nil, domain.ErrNotFound
+	}
+	if check.Type == domain.CheckTypeHeartbeat {
+		return nil, invalidInput("heartbeat checks run when their job pings them")
+	}
+	return s.scheduler.Run(ctx, check), nil
+}
+
+// Ping records a call of the ping URL of a heartbeat check by its job. A run that exited
+// with a code other than 0 failed. It returns ErrNotFound for an unknown token.
+func (s *CheckService) Ping(ctx context.Context, token string, ping *domain.HeartbeatPing) error {
+	if ping.Kind == domain.HeartbeatPingSuccess && ping.ExitCode != nil && *ping.ExitCode != 0 {
+		ping.Kind = domain.HeartbeatPingFail
+	}
+	return s.scheduler.Ping(ctx, token, ping)
+}
+
+// issueHeartbeatToken sets the target of a heartbeat check to its ping token: the current
+// one of a heartbeat check being updated, or a new one
+func issueHeartbeatToken(check, current *domain.Check) error {
+	if check.Type != domain.CheckTypeHeartbeat {
+		return nil
+	}
+
+	token := ""
+	if current != nil && current.Type == domain.CheckTypeHeartbeat {
+		token = current.Target
+	}
+	if check.Target != "" && check.Target != token {
+		return invalidInput("target of heartbeat checks is issued by the server")
+	}
+	if token == "" {
+		token = rand.Text()
+	}
+	check.Target = token
+	return nil
+}
+
+// annotate sets the status of a single check
+func (s *CheckService) annotate(ctx context.Context, check *domain.Check) error {
+	latest, err := s.repo.GetLatestResults(ctx)
+	if err != nil {
+		return err
+	}
+
+	checks := []domain.Check{*check}
+	s.scheduler.Annotate(checks, latest)
+	*check = checks[0]
+	return nil
+}
+
+// Uptime reports the share of successful results of a check over the window before now
+func (s *CheckService) Uptime(ctx context.Context, id int64, window time.Duration) (*domain.CheckUptimeReport, error) {
+	check, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if check == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	since := time.Now().Add(-window)
+	locations, err := s.repo.GetUptime(ctx, id, since)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.CheckUptimeReport{CheckID: id, Window: window.String(), Since: since, Locations: locations}
+	latency := 0.0
+	for i := range locations {
+		location := &locations[i]
+		location.UptimePercent = uptimePercent(location.Up, location.Results)
+		report.Overall.Results += location.Results
+		report.Overall.Up += location.Up
+		latency += location.AvgLatencyMs * float64(location.Results)
+	}
+	report.Overall.UptimePercent = uptimePercent(report.Overall.Up, report.Overall.Results)
+	if report.Overall.Results > 0 {
+		report.Overall.AvgLatencyMs = latency / float64(report.Overall.Results)
+	}
+	return report, nil
+}
+
+func uptimePercent(up, results int) float64 {
+	if results == 0 {
+		return 0
+	}
+	return float64(up) / float64(results) * 100
+}
+
+// validate fills in the defaults of a check and checks its target, options and locations
+func (s *CheckService) validate(ctx context.Context, check *domain.Check) error {
+	if check.IntervalSeconds == 0 {
+		check.IntervalSeconds = defaultCheckInterval
+	}
+	if check.TimeoutSeconds == 0 {
+		check.TimeoutSeconds = defaultCheckTimeout
+	}
+	if check.FailureThreshold == 0 {
+		check.FailureThreshold = 1
+	}
+	if check.TimeoutSeconds > check.IntervalSeconds {
+		return invalidInput("timeout_seconds must not exceed interval_seconds")
+	}
+
+	if err := validateCheckSpec(&check.CheckSpec); err != nil {
+		return err
+	}
+
+	// Heartbeat checks are not run anywhere; the server watches for their pings
+	if check.Type == domain.CheckTypeHeartbeat {
+		for _, location := range check.Locations {
+			if strings.TrimSpace(location) != domain.CheckLocationServer {
+				return invalidInput("heartbeat checks can only have the %q location", domain.CheckLocationServer)
+			}
+		}
+	}
+
+	locations := []string{}
+	for _, location := range check.Locations {
+		if location = strings.TrimSpace(location); !slices.Contains(locations, location) {
+			locations = append(locations, location)
+		}
+	}
+	if len(locations) == 0 {
+		locations = []string{domain.CheckLocationServer}
+	}
+	for _, location := range locations {
+		if location == domain.CheckLocationServer {
+			continue
+		}
+		agent, err := s.agentRepo.GetByID(ctx, location)
+		if err != nil {
+			return err
+		}
+		if agent == nil {
+			return invalidInput("location %q is neither %q nor an agent ID", location, domain.CheckLocationServer)
+		}
+		if agent.IsVirtual() {
+			return invalidInput("location %q is a %s agent, which cannot run checks", location, agent.Type)
+		}
+	}
+	check.Locations = locations
+	if check.Notifications == nil {
+		check.Notifications = []domain.NotificationRoute{}
+	}
+	return nil
+}
+
+// validateCheckSpec checks the target suits the check type and only that type's options are set
+func validateCheckSpec(spec *domain.CheckSpec) error {
+	options := map[string]bool{
+		domain.CheckTypeHTTP:      spec.HTTP != nil,
+		domain.CheckTypeDNS:       spec.DNS != nil,
+		domain.CheckTypeTLS:       spec.TLS != nil,
+		domain.CheckTypeHeartbeat: spec.Heartbeat != nil,
+	}
+	for kind, set := range options {
+		if set && kind != spec.Type {
+			return invalidInput("%s options are only allowed on %s checks", kind, kind)
+		}
+	}
+	if spec.Target == "" {
+		return invalidInput("target is required")
+	}
+
+	switch spec.Type {
+	case domain.CheckTypeHTTP:
+		u, err := url.Parse(spec.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return invalidInput("target of http checks must be an http or https URL, got %q", spec.Target)
+		}
+		if spec.HTTP != nil && spec.HTTP.BodyMatch != "" {
+			if err := probe.ValidateBodyMatch(spec.HTTP.BodyMatch); err != nil {
+				return invalidInput("body_match: %v", err)
+			}
+		}
+	case domain.CheckTypeTCP, domain.CheckTypeTLS:
+		if host, port, err := net.SplitHostPort(spec.Target); err != nil || host == "" || port == "" {
+			return invalidInput("target of %s checks must be host:port, got %q", spec.Type, spec.Target)
+		}
+	case domain.CheckTypeDNS:
+		if strings.ContainsAny(spec.Target, " /:") {
+			return invalidInput("target of dns checks must be a name, got %q", spec.Target)
+		}
+	case domain.CheckTypeHeartbeat:
+		if spec.Heartbeat == nil {
+			return invalidInput("heartbeat checks need heartbeat options with a schedule")
+		}
+		if spec.Heartbeat.GraceSeconds == 0 {
+			spec.Heartbeat.GraceSeconds = defaultHeartbeatGrace
+		}
+		schedule, location, err := heartbeatSchedule(spec.Heartbeat)
+		if err != nil {
+			return invalidInput("heartbeat: %v", err)
+		}
+		if schedule.Next(time.Now().In(location)).IsZero() {
+			return invalidInput("schedule %q never runs", spec.Heartbeat.Schedule)
+		}
+	}
+	return nil
+}
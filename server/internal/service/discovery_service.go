@@ -0,0 +1,580 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// DiscoveryOptions configures a DiscoveryService
+type DiscoveryOptions struct {
+	Interval    time.Duration
+	Timeout     time.Duration
+	Concurrency int
+	// Approval queues found agents instead of registering them
+	Approval    bool
+	DefaultTags []string
+	CIDRs       []string
+	Ports       []int
+	Files       []string
+	FileRefresh time.Duration
+	SRV         []string
+}
+
+// DiscoveryService finds agents by scanning networks, reading file_sd target lists and
+// looking up DNS SRV names, and checks each target's /info. Agents that answer are
+// registered with the default tags, or queued for approval.
+type DiscoveryService struct {
+	opts         DiscoveryOptions
+	agentRepo    domain.AgentRepository
+	repo         domain.DiscoveryRepository
+	agentService *AgentService
+
+	// running is held for the duration of a run, so runs do not overlap
+	running sync.Mutex
+	mu      sync.Mutex
+	lastRun *domain.DiscoveryRun
+	// files holds the modification time and size of each target file at its last read
+	files map[string]string
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+// discoveryTarget is an address an agent may listen on, with the tags its provider gives it
+type discoveryTarget struct {
+	host   string
+	source string
+	tags   []string
+}
+
+// fileSDGroup is an entry of a Prometheus file_sd target list
+type fileSDGroup struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+func NewDiscoveryService(opts DiscoveryOptions, agentRepo domain.AgentRepository, repo domain.DiscoveryRepository, agentService *AgentService) *DiscoveryService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &DiscoveryService{
+		opts:         opts,
+		agentRepo:    agentRepo,
+		repo:         repo,
+		agentService: agentService,
+		files:        make(map[string]string),
+		ctx:          ctx,
+		cancel:       cancel,
+		stopChan:     make(chan bool),
+	}
+}
+
+// Enabled reports whether any provider is configured
+func (s *DiscoveryService) Enabled() bool {
+	return len(s.sources()) > 0
+}
+
+// Start begins discovering agents: every provider on each interval, and the target files
+// again whenever they change
+func (s *DiscoveryService) Start() {
+	log.Printf("🔎 Agent discovery started (sources: %s, interval: %s)", strings.Join(s.sources(), ", "), s.opts.Interval)
+	s.wg.Add(1)
+	go s.discoverLoop()
+}
+
+// Stop gracefully stops discovery, abandoning a run in progress
+func (s *DiscoveryService) Stop() {
+	log.Println("⏸️  Stopping agent discovery...")
+	s.cancel()
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Agent discovery stopped")
+}
+
+func (s *DiscoveryService) discoverLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	var refresh <-chan time.Time
+	if len(s.opts.Files) > 0 {
+		fileTicker := time.NewTicker(s.opts.FileRefresh)
+		defer fileTicker.Stop()
+		refresh = fileTicker.C
+	}
+
+	s.Run(s.ctx, nil)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Run(s.ctx, nil)
+		case <-refresh:
+			if s.filesChanged() {
+				s.Run(s.ctx, []string{domain.DiscoverySourceFile})
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Status reports the configuration and the last run
+func (s *DiscoveryService) Status() *domain.DiscoveryStatus {
+	running := !s.running.TryLock()
+	if !running {
+		s.running.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &domain.DiscoveryStatus{
+		Enabled:  s.Enabled(),
+		Approval: s.opts.Approval,
+		Sources:  s.sources(),
+		Running:  running,
+		LastRun:  s.lastRun,
+	}
+}
+
+// Trigger starts a run of every provider in the background, reporting false when a run
+// is already in progress
+func (s *DiscoveryService) Trigger() bool {
+	if !s.running.TryLock() {
+		return false
+	}
+	go func() {
+		defer s.running.Unlock()
+		s.run(s.ctx, nil)
+	}()
+	return true
+}
+
+// Run checks the targets of the given providers, every configured one when sources is nil,
+// waiting for a run in progress to finish first
+func (s *DiscoveryService) Run(ctx context.Context, sources []string) *domain.DiscoveryRun {
+	s.running.Lock()
+	defer s.running.Unlock()
+	return s.run(ctx, sources)
+}
+
+func (s *DiscoveryService) run(ctx context.Context, sources []string) *domain.DiscoveryRun {
+	if sources == nil {
+		sources = s.sources()
+	}
+	run := &domain.DiscoveryRun{Sources: sources, StartedAt: time.Now()}
+
+	var targets []discoveryTarget
+	for _, source := range sources {
+		switch source {
+		case domain.DiscoverySourceScan:
+			targets = append(targets, s.scanTargets()...)
+		case domain.DiscoverySourceFile:
+			targets = append(targets, s.fileTargets(run)...)
+		case domain.DiscoverySourceSRV:
+			targets = append(targets, s.srvTargets(ctx, run)...)
+		}
+	}
+
+	if err := s.check(ctx, targets, run); err != nil {
+		run.Errors = append(run.Errors, err.Error())
+	}
+	run.FinishedAt = time.Now()
+
+	if run.Registered > 0 || run.Queued > 0 || len(run.Errors) > 0 {
+		log.Printf("🔎 Discovery (%s): %d targets, %d agents found, %d registered, %d queued for approval, %d errors",
+			strings.Join(sources, ", "), run.Targets, run.Found, run.Registered, run.Queued, len(run.Errors))
+	}
+
+	s.mu.Lock()
+	s.lastRun = run
+	s.mu.Unlock()
+	return run
+}
+
+// Approve registers a queued agent after checking its /info again. A failed check wraps
+// ErrProbeFailed.
+func (s *DiscoveryService) Approve(ctx context.Context, id int64, actor string) (*domain.Agent, error) {
+	discovered, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if discovered == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	existing, err := s.agentRepo.GetByHost(ctx, domain.AgentTypeAgent, discovered.Host)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, invalidInput("an agent is already registered at %s", discovered.Host)
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	info, err := ProbeAgent(probeCtx, discovered.Host)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := s.register(ctx, discovered.Host, info, discovered.Tags, actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		log.Printf("⚠️  Failed to remove approved agent %s from the discovery queue: %v", discovered.Host, err)
+	}
+	return agent, nil
+}
+
+// Reject keeps a queued agent from being registered or queued again until it is forgotten
+func (s *DiscoveryService) Reject(ctx context.Context, id int64) error {
+	discovered, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if discovered == nil {
+		return domain.ErrNotFound
+	}
+	return s.repo.SetStatus(ctx, id, domain.DiscoveredRejected)
+}
+
+// sources lists the configured providers. Listed targets come before scanned ones, so an
+// agent found by both keeps the tags of its target file.
+func (s *DiscoveryService) sources() []string {
+	var sources []string
+	if len(s.opts.Files) > 0 {
+		sources = append(sources, domain.DiscoverySourceFile)
+	}
+	if len(s.opts.SRV) > 0 {
+		sources = append(sources, domain.DiscoverySourceSRV)
+	}
+	if len(s.opts.CIDRs) > 0 && len(s.opts.Ports) > 0 {
+		sources = append(sources, domain.DiscoverySourceScan)
+	}
+	return sources
+}
+
+// scanTargets lists every port of every address of the CIDRs, leaving out the network and
+// broadcast addresses of IPv4 networks
+func (s *DiscoveryService) scanTargets() []discoveryTarget {
+	var targets []discoveryTarget
+	for _, cidr := range s.opts.CIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			continue
+		}
+		prefix = prefix.Masked()
+
+		var addrs []netip.Addr
+		for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+			addrs = append(addrs, addr)
+		}
+		if prefix.Addr().Is4() && prefix.Bits() < 31 && len(addrs) > 2 {
+			addrs = addrs[1 : len(addrs)-1]
+		}
+
+		for _, addr := range addrs {
+			for _, port := range s.opts.Ports {
+				targets = append(targets, discoveryTarget{
+					host:   netip.AddrPortFrom(addr, uint16(port)).String(),
+					source: domain.DiscoverySourceScan,
+				})
+			}
+		}
+	}
+	return targets
+}
+
+// fileTargets reads the target files, turning the labels of each entry into key=value tags
+func (s *DiscoveryService) fileTargets(run *domain.DiscoveryRun) []discoveryTarget {
+	stamps := make(map[string]string)
+	defer func() {
+		s.mu.Lock()
+		s.files = stamps
+		s.mu.Unlock()
+	}()
+
+	var targets []discoveryTarget
+	for _, path := range s.targetFiles(run) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			run.Errors = append(run.Errors, err.Error())
+			continue
+		}
+		stamps[path] = fileStamp(path)
+
+		// JSON is valid YAML, so one decoder reads both formats
+		var groups []fileSDGroup
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := yaml.Unmarshal(data, &groups); err != nil {
+				run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", path, err))
+				continue
+			}
+		}
+
+		for _, group := range groups {
+			tags := make([]string, 0, len(group.Labels))
+			for key, value := range group.Labels {
+				tags = append(tags, key+"="+value)
+			}
+			sort.Strings(tags)
+
+			for _, host := range group.Targets {
+				if _, _, err := net.SplitHostPort(host); err != nil {
+					run.Errors = append(run.Errors, fmt.Sprintf("%s: target %q must be host:port", path, host))
+					continue
+				}
+				targets = append(targets, discoveryTarget{host: host, source: domain.DiscoverySourceFile, tags: tags})
+			}
+		}
+	}
+	return targets
+}
+
+// targetFiles expands the globs of the configured files
+func (s *DiscoveryService) targetFiles(run *domain.DiscoveryRun) []string {
+	var paths []string
+	for _, pattern := range s.opts.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			if run != nil {
+				run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", pattern, err))
+			}
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	return slices.Compact(slices.Sorted(slices.Values(paths)))
+}
+
+// filesChanged reports whether a target file was added, removed or modified since the last read
+func (s *DiscoveryService) filesChanged() bool {
+	paths := s.targetFiles(nil)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(paths) != len(s.files) {
+		return true
+	}
+	for _, path := range paths {
+		if stamp, ok := s.files[path]; !ok || stamp != fileStamp(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return info.ModTime().String() + "/" + strconv.FormatInt(info.Size(), 10)
+}
+
+// srvTargets looks up the SRV names
+func (s *DiscoveryService) srvTargets(ctx context.Context, run *domain.DiscoveryRun) []discoveryTarget {
+	var targets []discoveryTarget
+	for _, name := range s.opts.SRV {
+		lookupCtx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+		_, records, err := net.DefaultResolver.LookupSRV(lookupCtx, "", "", name)
+		cancel()
+		if err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		for _, record := range records {
+			host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+			targets = append(targets, discoveryTarget{host: host, source: domain.DiscoverySourceSRV})
+		}
+	}
+	return targets
+}
+
+// check probes the targets that are neither registered nor rejected, then registers or
+// queues the agents that answer. An agent reachable on several addresses, such as a scanned
+// host with two interfaces, is taken once: agents are told apart by hostname and port.
+func (s *DiscoveryService) check(ctx context.Context, targets []discoveryTarget, run *domain.DiscoveryRun) error {
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get agents: %w", err)
+	}
+	discovered, err := s.repo.GetAll(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to get discovered agents: %w", err)
+	}
+
+	skip := make(map[string]bool)
+	queued := make(map[string]bool)
+	identities := make(map[string]bool)
+	for _, agent := range agents {
+		if agent.IsVirtual() {
+			continue
+		}
+		skip[agent.Host] = true
+		identities[agentIdentity(agent.Hostname, agent.Host)] = true
+	}
+	for _, agent := range discovered {
+		if agent.Status == domain.DiscoveredRejected {
+			skip[agent.Host] = true
+		}
+		queued[agent.Host] = true
+	}
+
+	var pending []discoveryTarget
+	for _, target := range targets {
+		if !skip[target.host] {
+			skip[target.host] = true
+			pending = append(pending, target)
+		}
+	}
+	run.Targets = len(pending)
+
+	type result struct {
+		target discoveryTarget
+		info   *domain.AgentInfo
+	}
+	var mu sync.Mutex
+	var found []result
+
+	sem := make(chan struct{}, s.opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, target := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(target discoveryTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			probeCtx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+			info, err := ProbeAgent(probeCtx, target.host)
+			cancel()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				// Most scanned addresses run no agent; listed targets are expected to
+				if target.source != domain.DiscoverySourceScan {
+					run.Errors = append(run.Errors, err.Error())
+				}
+				return
+			}
+			found = append(found, result{target: target, info: info})
+		}(target)
+	}
+	wg.Wait()
+
+	// Register in target order, so the first address of a host is the one kept
+	order := make(map[string]int, len(pending))
+	for i, target := range pending {
+		order[target.host] = i
+	}
+	sort.Slice(found, func(i, j int) bool { return order[found[i].target.host] < order[found[j].target.host] })
+
+	run.Found = len(found)
+	for _, r := range found {
+		identity := agentIdentity(r.info.Hostname, r.target.host)
+		if r.info.Hostname != "" && identities[identity] {
+			continue
+		}
+		identities[identity] = true
+
+		tags := normalizeTags(append(slices.Clone(s.opts.DefaultTags), r.target.tags...))
+
+		if !s.opts.Approval {
+			if _, err := s.register(ctx, r.target.host, r.info, tags, "discovery"); err != nil {
+				run.Errors = append(run.Errors, fmt.Sprintf("failed to register agent at %s: %v", r.target.host, err))
+				continue
+			}
+			run.Registered++
+			continue
+		}
+
+		entry := &domain.DiscoveredAgent{
+			Host:      r.target.host,
+			Source:    r.target.source,
+			Name:      agentName(r.info, r.target.host),
+			Hostname:  r.info.Hostname,
+			IPAddress: r.info.IPAddress,
+			Version:   r.info.Version,
+			Tags:      tags,
+			Status:    domain.DiscoveredPending,
+			LastSeen:  time.Now(),
+		}
+		if err := s.repo.Save(ctx, entry); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("failed to queue agent at %s: %v", r.target.host, err))
+			continue
+		}
+		if !queued[entry.Host] {
+			log.Printf("🔎 Agent %s found at %s, awaiting approval", entry.Name, entry.Host)
+			run.Queued++
+		}
+	}
+	return nil
+}
+
+// register registers a found agent and records it in the audit trail
+func (s *DiscoveryService) register(ctx context.Context, host string, info *domain.AgentInfo, tags []string, actor string) (*domain.Agent, error) {
+	now := time.Now()
+	agent := &domain.Agent{
+		ID:        uuid.New().String(),
+		Name:      agentName(info, host),
+		Host:      host,
+		Hostname:  info.Hostname,
+		IPAddress: info.IPAddress,
+		Status:    "online",
+		LastSeen:  now,
+		Version:   info.Version,
+		Tags:      tags,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if err := s.agentRepo.Register(ctx, agent); err != nil {
+		return nil, err
+	}
+	s.agentService.Record(ctx, domain.AgentAuditRegister, actor, nil, agent)
+	log.Printf("✅ Agent %s registered at %s by %s", agent.Name, agent.Host, actor)
+	return agent, nil
+}
+
+// agentName is the name an agent reports, or its hostname or address when it reports none
+func agentName(info *domain.AgentInfo, host string) string {
+	switch {
+	case info.Name != "":
+		return info.Name
+	case info.Hostname != "":
+		return info.Hostname
+	default:
+		return host
+	}
+}
+
+// agentIdentity tells apart the agents of a machine by the port they listen on
+func agentIdentity(hostname, host string) string {
+	_, port, _ := net.SplitHostPort(host)
+	return hostname + ":" + port
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// alertLookback is how far before a rule's duration readings are loaded to find where a breach began
+const alertLookback = time.Hour
+
+// EnvAlertService periodically evaluates environmental alert rules against stored readings
+type EnvAlertService struct {
+	envRepo   domain.EnvMetricsRepository
+	alertRepo domain.EnvAlertRepository
+	interval  time.Duration
+	stopChan  chan bool
+	wg        sync.WaitGroup
+}
+
+func NewEnvAlertService(envRepo domain.EnvMetricsRepository, alertRepo domain.EnvAlertRepository, interval time.Duration) *EnvAlertService {
+	return &EnvAlertService{
+		envRepo:   envRepo,
+		alertRepo: alertRepo,
+		interval:  interval,
+		stopChan:  make(chan bool),
+	}
+}
+
+// Start begins the evaluation loop
+func (s *EnvAlertService) Start() {
+	log.Printf("🌡️  Environmental alert evaluator started (interval: %s)", s.interval)
+	s.wg.Add(1)
+	go s.evaluateLoop()
+}
+
+// Stop gracefully stops the evaluation loop
+func (s *EnvAlertService) Stop() {
+	log.Println("⏸️  Stopping environmental alert evaluator...")
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Environmental alert evaluator stopped")
+}
+
+func (s *EnvAlertService) evaluateLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.Evaluate(context.Background())
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Evaluate(context.Background())
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// Evaluate checks every rule once, firing new alerts and resolving recovered ones
+func (s *EnvAlertService) Evaluate(ctx context.Context) {
+	rules, err := s.alertRepo.GetRules(ctx)
+	if err != nil {
+		log.Printf("❌ Failed to get alert rules: %v", err)
+		return
+	}
+
+	firing, err := s.alertRepo.GetAlerts(ctx, domain.EnvAlertQuery{Status: domain.AlertStatusFiring, Limit: len(rules) + 1})
+	if err != nil {
+		log.Printf("❌ Failed to get firing alerts: %v", err)
+		return
+	}
+	open := make(map[int64]*domain.EnvAlert, len(firing))
+	for i := range firing {
+		open[firing[i].RuleID] = &firing[i]
+	}
+
+	now := time.Now()
+	for i := range rules {
+		rule := &rules[i]
+		alert := open[rule.ID]
+
+		if !rule.Enabled {
+			if alert != nil {
+				s.resolve(ctx, alert, alert.Value, now)
+			}
+			continue
+		}
+
+		readings, err := s.envRepo.GetReadings(ctx, domain.ReadingQuery{
+			SensorIDs: []string{rule.SensorID},
+			From:      now.Add(-rule.Duration() - alertLookback),
+		})
+		if err != nil {
+			log.Printf("❌ Failed to get readings for alert rule %q: %v", rule.Name, err)
+			continue
+		}
+		if len(readings) == 0 {
+			continue
+		}
+
+		latest := readings[len(readings)-1]
+		since, breached := breachStart(rule, readings)
+
+		switch {
+		case alert == nil && breached && latest.RecordedAt.Sub(since) >= rule.Duration():
+			alert = &domain.EnvAlert{
+				RuleID:    rule.ID,
+				RuleName:  rule.Name,
+				SensorID:  rule.SensorID,
+				Condition: rule.Condition,
+				Threshold: rule.Threshold,
+				Value:     latest.Value,
+				Status:    domain.AlertStatusFiring,
+				StartedAt: since,
+				FiredAt:   now,
+			}
+			if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+				log.Printf("❌ Failed to save alert for rule %q: %v", rule.Name, err)
+				continue
+			}
+			log.Printf("🚨 Alert %q firing: %s is %g, %s %g since %s", rule.Name, rule.SensorID, latest.Value, rule.Condition, rule.Threshold, since.Format(time.RFC3339))
+
+		case alert != nil && breached && alert.Value != latest.Value:
+			alert.Value = latest.Value
+			if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+				log.Printf("❌ Failed to update alert for rule %q: %v", rule.Name, err)
+			}
+
+		case alert != nil && !breached:
+			s.resolve(ctx, alert, latest.Value, now)
+		}
+	}
+}
+
+func (s *EnvAlertService) resolve(ctx context.Context, alert *domain.EnvAlert, value float64, at time.Time) {
+	alert.Value = value
+	alert.Status = domain.AlertStatusResolved
+	alert.ResolvedAt = &at
+	if err := s.alertRepo.SaveAlert(ctx, alert); err != nil {
+		log.Printf("❌ Failed to resolve alert %q: %v", alert.RuleName, err)
+		return
+	}
+	log.Printf("✅ Alert %q resolved: %s is %g", alert.RuleName, alert.SensorID, value)
+}
+
+// breachStart reports whether the latest reading breaches the rule and, if so,
+// the time of the first reading of the uninterrupted breach leading up to it
+func breachStart(rule *domain.EnvAlertRule, readings []domain.SensorReading) (time.Time, bool) {
+	last := len(readings) - 1
+	if !rule.Breached(readings[last].Value) {
+		return time.Time{}, false
+	}
+
+	since := readings[last].RecordedAt
+	for i := last - 1; i >= 0 && rule.Breached(readings[i].Value); i-- {
+		since = readings[i].RecordedAt
+	}
+	return since, true
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+func TestEvaluateMixedTimeZones(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	envRepo := sqlite.NewEnvMetricsRepository(db)
+	alertRepo := sqlite.NewEnvAlertRepository(db)
+	sensor := domain.LegacySensors[0].ID
+
+	rule := &domain.EnvAlertRule{
+		Name:            "hot",
+		SensorID:        sensor,
+		Condition:       "above",
+		Threshold:       30,
+		DurationSeconds: 900,
+		Enabled:         true,
+	}
+	if err := alertRepo.CreateRule(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	breachStarted := now.Add(-30 * time.Minute)
+	saveReadings(t, envRepo, sensor, map[time.Time]float64{
+		now.Add(-40 * time.Minute).UTC():                          20,
+		breachStarted.In(jakarta):                                 35,
+		now.Add(-20 * time.Minute).UTC():                          36,
+		now.Add(-5 * time.Minute).In(time.FixedZone("", -5*3600)): 37,
+	})
+
+	NewEnvAlertService(envRepo, alertRepo, time.Minute).Evaluate(ctx)
+
+	alerts, err := alertRepo.GetAlerts(ctx, domain.EnvAlertQuery{Status: domain.AlertStatusFiring, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("firing alerts = %+v, want one", alerts)
+	}
+	if !alerts[0].StartedAt.Equal(breachStarted) || alerts[0].Value != 37 {
+		t.Fatalf("alert started at %s with %g, want %s with 37", alerts[0].StartedAt, alerts[0].Value, breachStarted)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+const (
+	// maxHistoryReadings bounds the raw readings loaded for one history or summary request
+	maxHistoryReadings = 200000
+
+	// maxHistoryBuckets bounds the points of one history series
+	maxHistoryBuckets = 10000
+)
+
+// EnvHistoryService aggregates stored sensor readings into buckets and daily summaries
+type EnvHistoryService struct {
+	envRepo domain.EnvMetricsRepository
+}
+
+func NewEnvHistoryService(envRepo domain.EnvMetricsRepository) *EnvHistoryService {
+	return &EnvHistoryService{
+		envRepo: envRepo,
+	}
+}
+
+// History returns one bucketed series per sensor with readings in the range. With q.Derived,
+// dew point and heat index series are added for every temperature and humidity pair in the result.
+// Validation failures wrap domain.ErrInvalidInput.
+func (s *EnvHistoryService) History(ctx context.Context, q domain.EnvHistoryQuery) ([]domain.EnvSeries, error) {
+	switch q.Aggregate {
+	case domain.AggregateAvg, domain.AggregateMin, domain.AggregateMax, domain.AggregateLast, domain.AggregateCount:
+	default:
+		return nil, invalidInput("unknown aggregation %q", q.Aggregate)
+	}
+	if q.Bucket < time.Second {
+		return nil, invalidInput("bucket must be at least 1s")
+	}
+	if !q.To.After(q.From) {
+		return nil, invalidInput("to must be after from")
+	}
+	if q.To.Sub(q.From)/q.Bucket > maxHistoryBuckets {
+		return nil, invalidInput("range spans more than %d buckets, use a larger bucket", maxHistoryBuckets)
+	}
+
+	sensors, err := s.sensors(ctx, q.SensorIDs)
+	if err != nil {
+		return nil, err
+	}
+	readings, err := s.readings(ctx, q.SensorIDs, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	var series []domain.EnvSeries
+	index := make(map[string]int)
+	for start := 0; start < len(readings); {
+		end := start
+		for end < len(readings) && readings[end].SensorID == readings[start].SensorID {
+			end++
+		}
+
+		sensor := sensors[readings[start].SensorID]
+		index[sensor.ID] = len(series)
+		series = append(series, domain.EnvSeries{
+			SensorID: sensor.ID,
+			Kind:     sensor.Kind,
+			Unit:     sensor.Unit,
+			Points:   bucketReadings(readings[start:end], q.Bucket, q.Aggregate),
+		})
+		start = end
+	}
+
+	if q.Derived && q.Aggregate != domain.AggregateCount {
+		all := make([]domain.Sensor, 0, len(sensors))
+		for _, sensor := range sensors {
+			all = append(all, sensor)
+		}
+		for _, pair := range domain.SensorPairs(all) {
+			t, okT := index[pair.Temperature]
+			h, okH := index[pair.Humidity]
+			if okT && okH {
+				series = append(series, derivedSeries(pair.Temperature, series[t].Points, series[h].Points)...)
+			}
+		}
+	}
+
+	return series, nil
+}
+
+// DailySummaries returns the min, max and average of every sensor for each day in loc
+func (s *EnvHistoryService) DailySummaries(ctx context.Context, sensorIDs []string, from, to time.Time, loc *time.Location) ([]domain.EnvDailySummary, error) {
+	if !to.After(from) {
+		return nil, invalidInput("to must be after from")
+	}
+	if _, err := s.sensors(ctx, sensorIDs); err != nil {
+		return nil, err
+	}
+	readings, err := s.readings(ctx, sensorIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []domain.EnvDailySummary
+	var sum float64
+	for i, r := range readings {
+		date := r.RecordedAt.In(loc).Format("2006-01-02")
+
+		last := len(summaries) - 1
+		if last < 0 || summaries[last].SensorID != r.SensorID || summaries[last].Date != date {
+			summaries = append(summaries, domain.EnvDailySummary{SensorID: r.SensorID, Date: date, Min: r.Value, Max: r.Value})
+			last++
+			sum = 0
+		}
+
+		day := &summaries[last]
+		day.Min = math.Min(day.Min, r.Value)
+		day.Max = math.Max(day.Max, r.Value)
+		day.Count++
+		sum += r.Value
+
+		if i == len(readings)-1 || readings[i+1].SensorID != r.SensorID || readings[i+1].RecordedAt.In(loc).Format("2006-01-02") != date {
+			day.Avg = round2(sum / float64(day.Count))
+		}
+	}
+
+	return summaries, nil
+}
+
+// sensors returns the sensor registry keyed by ID, rejecting unknown requested IDs
+func (s *EnvHistoryService) sensors(ctx context.Context, ids []string) (map[string]domain.Sensor, error) {
+	list, err := s.envRepo.GetSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors: %w", err)
+	}
+
+	sensors := make(map[string]domain.Sensor, len(list))
+	for _, sensor := range list {
+		sensors[sensor.ID] = sensor
+	}
+	for _, id := range ids {
+		if _, ok := sensors[id]; !ok {
+			return nil, invalidInput("unknown sensor %q", id)
+		}
+	}
+
+	return sensors, nil
+}
+
+func (s *EnvHistoryService) readings(ctx context.Context, ids []string, from, to time.Time) ([]domain.SensorReading, error) {
+	readings, err := s.envRepo.GetReadings(ctx, domain.ReadingQuery{
+		SensorIDs: ids,
+		From:      from,
+		To:        to,
+		Limit:     maxHistoryReadings + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor readings: %w", err)
+	}
+	if len(readings) > maxHistoryReadings {
+		return nil, invalidInput("range holds more than %d readings, narrow it or select fewer sensors", maxHistoryReadings)
+	}
+	return readings, nil
+}
+
+// bucketReadings aggregates time-ordered readings of one sensor into buckets aligned to the Unix epoch
+func bucketReadings(readings []domain.SensorReading, bucket time.Duration, aggregate string) []domain.EnvPoint {
+	var points []domain.EnvPoint
+	var sum float64
+
+	for i, r := range readings {
+		start := r.RecordedAt.Truncate(bucket).UTC()
+
+		last := len(points) - 1
+		if last < 0 || !points[last].Time.Equal(start) {
+			points = append(points, domain.EnvPoint{Time: start, Value: r.Value})
+			last++
+			sum = 0
+		}
+
+		p := &points[last]
+		p.Count++
+		sum += r.Value
+		switch aggregate {
+		case domain.AggregateMin:
+			p.Value = math.Min(p.Value, r.Value)
+		case domain.AggregateMax:
+			p.Value = math.Max(p.Value, r.Value)
+		case domain.AggregateLast:
+			p.Value = r.Value
+		}
+
+		if i == len(readings)-1 || !readings[i+1].RecordedAt.Truncate(bucket).UTC().Equal(start) {
+			switch aggregate {
+			case domain.AggregateAvg:
+				p.Value = round2(sum / float64(p.Count))
+			case domain.AggregateCount:
+				p.Value = float64(p.Count)
+			}
+		}
+	}
+
+	return points
+}
+
+// derivedSeries computes dew point and heat index for buckets present in both series
+func derivedSeries(temperatureID string, temperature, humidity []domain.EnvPoint) []domain.EnvSeries {
+	dewPoint := domain.EnvSeries{SensorID: domain.DerivedSensorID(temperatureID, domain.DerivedDewPoint), Kind: domain.DerivedDewPoint, Unit: "°C", Points: []domain.EnvPoint{}}
+	heatIndex := domain.EnvSeries{SensorID: domain.DerivedSensorID(temperatureID, domain.DerivedHeatIndex), Kind: domain.DerivedHeatIndex, Unit: "°C", Points: []domain.EnvPoint{}}
+
+	for i, j := 0, 0; i < len(temperature) && j < len(humidity); {
+		t, h := temperature[i], humidity[j]
+		switch {
+		case t.Time.Before(h.Time):
+			i++
+			continue
+		case h.Time.Before(t.Time):
+			j++
+			continue
+		}
+
+		count := t.Count
+		if h.Count < count {
+			count = h.Count
+		}
+		if v := domain.DewPoint(t.Value, h.Value); !math.IsNaN(v) {
+			dewPoint.Points = append(dewPoint.Points, domain.EnvPoint{Time: t.Time, Value: round2(v), Count: count})
+		}
+		heatIndex.Points = append(heatIndex.Points, domain.EnvPoint{Time: t.Time, Value: round2(domain.HeatIndex(t.Value, h.Value)), Count: count})
+		i++
+		j++
+	}
+
+	return []domain.EnvSeries{dewPoint, heatIndex}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
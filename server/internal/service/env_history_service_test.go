@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+// jakarta is the offset ESP32 boards in the field post their readings with
+var jakarta = time.FixedZone("WIB", 7*3600)
+
+// openTestDB opens an initialized database in a temporary directory
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.InitDB(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// saveReadings stores values of a sensor recorded at the given times
+func saveReadings(t *testing.T, repo domain.EnvMetricsRepository, sensorID string, values map[time.Time]float64) {
+	t.Helper()
+	var readings []domain.SensorReading
+	for at, value := range values {
+		readings = append(readings, domain.SensorReading{SensorID: sensorID, Value: value, RecordedAt: at})
+	}
+	if err := repo.SaveReadings(context.Background(), readings); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistoryMixedTimeZones(t *testing.T) {
+	repo := sqlite.NewEnvMetricsRepository(openTestDB(t))
+	sensor := domain.LegacySensors[0].ID
+	saveReadings(t, repo, sensor, map[time.Time]float64{
+		time.Date(2026, 10, 19, 7, 10, 0, 0, jakarta):  10, // 00:10Z
+		time.Date(2026, 10, 19, 0, 50, 0, 0, time.UTC): 20,
+		time.Date(2026, 10, 19, 8, 20, 0, 0, jakarta):  30, // 01:20Z
+		time.Date(2026, 10, 19, 2, 10, 0, 0, time.UTC): 40,
+	})
+
+	series, err := NewEnvHistoryService(repo).History(context.Background(), domain.EnvHistoryQuery{
+		SensorIDs: []string{sensor},
+		From:      time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2026, 10, 19, 9, 0, 0, 0, jakarta), // 02:00Z
+		Bucket:    time.Hour,
+		Aggregate: domain.AggregateAvg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []domain.EnvPoint{
+		{Time: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), Value: 15, Count: 2},
+		{Time: time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC), Value: 30, Count: 1},
+	}
+	if len(series) != 1 || len(series[0].Points) != len(want) {
+		t.Fatalf("History() = %+v, want one series with %d points", series, len(want))
+	}
+	for i, p := range series[0].Points {
+		if !p.Time.Equal(want[i].Time) || p.Value != want[i].Value || p.Count != want[i].Count {
+			t.Errorf("point %d = %+v, want %+v", i, p, want[i])
+		}
+	}
+}
+
+func TestDailySummariesMixedTimeZones(t *testing.T) {
+	repo := sqlite.NewEnvMetricsRepository(openTestDB(t))
+	sensor := domain.LegacySensors[0].ID
+	saveReadings(t, repo, sensor, map[time.Time]float64{
+		time.Date(2026, 10, 19, 23, 30, 0, 0, time.UTC): 10,
+		time.Date(2026, 10, 20, 6, 0, 0, 0, jakarta):    20, // 2026-10-19 23:00Z
+		time.Date(2026, 10, 20, 1, 0, 0, 0, time.UTC):   30,
+		time.Date(2026, 10, 21, 6, 0, 0, 0, jakarta):    40, // 2026-10-20 23:00Z, past to
+	})
+
+	summaries, err := NewEnvHistoryService(repo).DailySummaries(context.Background(), []string{sensor},
+		time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 20, 22, 0, 0, 0, time.UTC), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []domain.EnvDailySummary{
+		{SensorID: sensor, Date: "2026-10-19", Min: 10, Max: 20, Avg: 15, Count: 2},
+		{SensorID: sensor, Date: "2026-10-20", Min: 30, Max: 30, Avg: 30, Count: 1},
+	}
+	if len(summaries) != len(want) {
+		t.Fatalf("DailySummaries() = %+v, want %+v", summaries, want)
+	}
+	for i := range want {
+		if summaries[i] != want[i] {
+			t.Errorf("summary %d = %+v, want %+v", i, summaries[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+const (
+	// envIdempotencyScope namespaces env submission keys in the idempotency store
+	envIdempotencyScope = "env-metrics"
+
+	// maxClockSkew is how far in the future a reading timestamp may be
+	maxClockSkew = 5 * time.Minute
+
+	// sensorCacheTTL is how long the sensor registry is cached between lookups
+	sensorCacheTTL = time.Minute
+)
+
+// EnvIngestService validates and stores sensor readings submitted over HTTP or MQTT
+type EnvIngestService struct {
+	envRepo     domain.EnvMetricsRepository
+	idempotency domain.IdempotencyRepository
+
+	mu        sync.Mutex
+	sensors   map[string]domain.Sensor
+	fetchedAt time.Time
+}
+
+func NewEnvIngestService(envRepo domain.EnvMetricsRepository, idempotency domain.IdempotencyRepository) *EnvIngestService {
+	return &EnvIngestService{
+		envRepo:     envRepo,
+		idempotency: idempotency,
+	}
+}
+
+// Submit validates every submission first and stores nothing when any is invalid.
+// Submissions whose idempotency key was already processed are reported as duplicates.
+// Validation failures wrap domain.ErrInvalidInput.
+func (s *EnvIngestService) Submit(ctx context.Context, submissions []domain.EnvSubmission) ([]domain.EnvSubmissionResult, error) {
+	if len(submissions) == 0 {
+		return nil, fmt.Errorf("%w: no submissions", domain.ErrInvalidInput)
+	}
+
+	batches := make([][]domain.SensorReading, len(submissions))
+	for i := range submissions {
+		readings, err := s.readings(ctx, &submissions[i])
+		if err != nil {
+			return nil, fmt.Errorf("submission %d: %w", i, err)
+		}
+		batches[i] = readings
+	}
+
+	results := make([]domain.EnvSubmissionResult, len(submissions))
+	for i, sub := range submissions {
+		results[i] = domain.EnvSubmissionResult{IdempotencyKey: sub.IdempotencyKey, Status: domain.SubmissionStored}
+
+		if sub.IdempotencyKey != "" {
+			claimed, err := s.idempotency.Claim(ctx, envIdempotencyScope, sub.IdempotencyKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+			}
+			if !claimed {
+				results[i].Status = domain.SubmissionDuplicate
+				continue
+			}
+		}
+
+		if err := s.envRepo.SaveReadings(ctx, batches[i]); err != nil {
+			if sub.IdempotencyKey != "" {
+				s.idempotency.Release(ctx, envIdempotencyScope, sub.IdempotencyKey)
+			}
+			return nil, fmt.Errorf("failed to save readings: %w", err)
+		}
+		results[i].Readings = len(batches[i])
+	}
+
+	return results, nil
+}
+
+// DecodeEnvSubmissions decodes a single submission object or an array of them,
+// reporting whether the body was an array. Unknown fields are rejected.
+func DecodeEnvSubmissions(body []byte) ([]domain.EnvSubmission, bool, error) {
+	body = bytes.TrimSpace(body)
+	bulk := len(body) > 0 && body[0] == '['
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if bulk {
+		var submissions []domain.EnvSubmission
+		err := decoder.Decode(&submissions)
+		return submissions, true, err
+	}
+
+	var submission domain.EnvSubmission
+	if err := decoder.Decode(&submission); err != nil {
+		return nil, false, err
+	}
+	return []domain.EnvSubmission{submission}, false, nil
+}
+
+// readings flattens and validates the readings of one submission
+func (s *EnvIngestService) readings(ctx context.Context, sub *domain.EnvSubmission) ([]domain.SensorReading, error) {
+	if len(sub.IdempotencyKey) > 128 {
+		return nil, invalidInput("idempotency_key longer than 128 characters")
+	}
+
+	now := time.Now()
+	if sub.RecordedAt.IsZero() {
+		sub.RecordedAt = now
+	}
+
+	readings := append(sub.LegacyReadings(), sub.Readings...)
+	if len(readings) == 0 {
+		return nil, invalidInput("no readings")
+	}
+
+	for i := range readings {
+		r := &readings[i]
+		if r.RecordedAt.IsZero() {
+			r.RecordedAt = sub.RecordedAt
+		}
+		if r.RecordedAt.After(now.Add(maxClockSkew)) {
+			return nil, invalidInput("sensor %q: recorded_at is in the future", r.SensorID)
+		}
+
+		sensor, err := s.sensor(ctx, r.SensorID)
+		if err != nil {
+			return nil, err
+		}
+		if sensor == nil {
+			return nil, invalidInput("unknown sensor %q", r.SensorID)
+		}
+		if err := domain.ValidateReading(sensor.Kind, r.Value); err != nil {
+			return nil, invalidInput("sensor %q: %v", r.SensorID, err)
+		}
+		r.ID = 0
+	}
+
+	return readings, nil
+}
+
+// sensor looks a sensor up in the cached registry, refreshing it when stale or on a miss
+func (s *EnvIngestService) sensor(ctx context.Context, id string) (*domain.Sensor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sensor, ok := s.sensors[id]
+	if ok && time.Since(s.fetchedAt) < sensorCacheTTL {
+		return &sensor, nil
+	}
+	if !ok && s.sensors != nil && time.Since(s.fetchedAt) < time.Second {
+		return nil, nil
+	}
+
+	sensors, err := s.envRepo.GetSensors(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensors: %w", err)
+	}
+	s.sensors = make(map[string]domain.Sensor, len(sensors))
+	for _, sensor := range sensors {
+		s.sensors[sensor.ID] = sensor
+	}
+	s.fetchedAt = time.Now()
+
+	sensor, ok = s.sensors[id]
+	if !ok {
+		return nil, nil
+	}
+	return &sensor, nil
+}
+
+// invalidInput formats a validation error wrapping domain.ErrInvalidInput
+func invalidInput(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", domain.ErrInvalidInput, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+const (
+	// envSyncBatch is the number of rows mirrored per request
+	envSyncBatch = 500
+
+	// Cursor names in the sync repository
+	cursorSensors    = "supabase.sensors" // UnixNano of the last mirrored sensor update
+	cursorEnvMetrics = "supabase.env_metrics"
+	cursorReadings   = "supabase.sensor_readings"
+)
+
+// EnvSyncService mirrors environmental data stored locally in SQLite to a remote repository
+// (Supabase). Every run resumes from the saved cursors, so data recorded while the remote was
+// unconfigured or unreachable is copied once it becomes available. Rows are mirrored at least once.
+type EnvSyncService struct {
+	local    domain.EnvMetricsRepository
+	source   domain.EnvSyncRepository
+	remote   domain.EnvMetricsRepository
+	interval time.Duration
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+func NewEnvSyncService(local domain.EnvMetricsRepository, source domain.EnvSyncRepository, remote domain.EnvMetricsRepository, interval time.Duration) *EnvSyncService {
+	return &EnvSyncService{
+		local:    local,
+		source:   source,
+		remote:   remote,
+		interval: interval,
+		stopChan: make(chan bool),
+	}
+}
+
+// Start begins the sync loop
+func (s *EnvSyncService) Start() {
+	log.Printf("🔁 Environmental metrics sync started (interval: %s)", s.interval)
+	s.wg.Add(1)
+	go s.syncLoop()
+}
+
+// Stop gracefully stops the sync loop
+func (s *EnvSyncService) Stop() {
+	log.Println("⏸️  Stopping environmental metrics sync...")
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Environmental metrics sync stopped")
+}
+
+func (s *EnvSyncService) syncLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.run()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.run()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *EnvSyncService) run() {
+	ctx := context.Background()
+	if err := s.Sync(ctx); err != nil {
+		log.Printf("⚠️  Environmental metrics sync failed, retrying in %s: %v", s.interval, err)
+	}
+}
+
+// Sync mirrors sensors first, so readings never reference a sensor missing remotely,
+// then legacy env_metrics rows and sensor readings
+func (s *EnvSyncService) Sync(ctx context.Context) error {
+	if err := s.syncSensors(ctx); err != nil {
+		return err
+	}
+
+	envMetrics, err := s.syncEnvMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	readings, err := s.syncReadings(ctx)
+	if err != nil {
+		return err
+	}
+
+	if envMetrics+readings > 0 {
+		log.Printf("🔁 Mirrored %d env_metrics row(s) and %d sensor reading(s)", envMetrics, readings)
+	}
+	return nil
+}
+
+// syncSensors upserts local sensors updated since the last run, unless the remote copy is newer
+func (s *EnvSyncService) syncSensors(ctx context.Context) error {
+	cursor, err := s.source.GetCursor(ctx, cursorSensors)
+	if err != nil {
+		return fmt.Errorf("failed to get sensors cursor: %w", err)
+	}
+
+	sensors, err := s.local.GetSensors(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get local sensors: %w", err)
+	}
+
+	position := cursor
+	for _, sensor := range sensors {
+		updated := sensor.UpdatedAt.UnixNano()
+		if updated <= cursor {
+			continue
+		}
+
+		remote, err := s.remote.GetSensor(ctx, sensor.ID)
+		if err != nil {
+			return err
+		}
+		if remote == nil || remote.UpdatedAt.Before(sensor.UpdatedAt) {
+			sensor.LatestReading = nil
+			if err := s.remote.SaveSensor(ctx, &sensor); err != nil {
+				return err
+			}
+		}
+		if updated > position {
+			position = updated
+		}
+	}
+
+	if position == cursor {
+		return nil
+	}
+	return s.source.SaveCursor(ctx, cursorSensors, position)
+}
+
+func (s *EnvSyncService) syncEnvMetrics(ctx context.Context) (int, error) {
+	cursor, err := s.source.GetCursor(ctx, cursorEnvMetrics)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get env_metrics cursor: %w", err)
+	}
+
+	total := 0
+	for {
+		rows, err := s.source.GetEnvMetricsAfter(ctx, cursor, envSyncBatch)
+		if err != nil {
+			return total, fmt.Errorf("failed to get local env_metrics: %w", err)
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		for _, row := range rows {
+			id := row.ID
+			row.ID = 0
+			if err := s.remote.Create(ctx, &row); err != nil {
+				return total, err
+			}
+			if err := s.source.SaveCursor(ctx, cursorEnvMetrics, id); err != nil {
+				return total, fmt.Errorf("failed to save env_metrics cursor: %w", err)
+			}
+			cursor = id
+			total++
+		}
+	}
+}
+
+func (s *EnvSyncService) syncReadings(ctx context.Context) (int, error) {
+	cursor, err := s.source.GetCursor(ctx, cursorReadings)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get sensor readings cursor: %w", err)
+	}
+
+	total := 0
+	for {
+		readings, err := s.source.GetReadingsAfter(ctx, cursor, envSyncBatch)
+		if err != nil {
+			return total, fmt.Errorf("failed to get local sensor readings: %w", err)
+		}
+		if len(readings) == 0 {
+			return total, nil
+		}
+
+		last := readings[len(readings)-1].ID
+		for i := range readings {
+			readings[i].ID = 0
+		}
+		if err := s.remote.SaveReadings(ctx, readings); err != nil {
+			return total, err
+		}
+		if err := s.source.SaveCursor(ctx, cursorReadings, last); err != nil {
+			return total, fmt.Errorf("failed to save sensor readings cursor: %w", err)
+		}
+		cursor = last
+		total += len(readings)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// fleetTopHosts is the number of hosts ranked per resource in the fleet summary
+const fleetTopHosts = 5
+
+// FleetCache wraps an AgentRepository and keeps the latest sample of every agent in memory,
+// updated on every SaveMetrics and PullMetrics, so the fleet summary and GetLatestMetrics
+// need no query. An agent's sample is loaded from the repository the first time it is asked
+// for, and again until it has one.
+type FleetCache struct {
+	domain.AgentRepository
+
+	mu sync.RWMutex
+	// latest holds the latest sample of each agent looked up or saved
+	latest map[string]*domain.AgentMetrics
+}
+
+func NewFleetCache(repo domain.AgentRepository) *FleetCache {
+	return &FleetCache{
+		AgentRepository: repo,
+		latest:          make(map[string]*domain.AgentMetrics),
+	}
+}
+
+// SaveMetrics saves a sample and caches it when it is newer than the cached one
+func (c *FleetCache) SaveMetrics(ctx context.Context, metrics *domain.AgentMetrics) error {
+	if err := c.AgentRepository.SaveMetrics(ctx, metrics); err != nil {
+		return err
+	}
+
+	c.store(metrics)
+	return nil
+}
+
+// PullMetrics pulls a sample from an agent, which the repository saves, and caches it
+func (c *FleetCache) PullMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
+	metrics, err := c.AgentRepository.PullMetrics(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	c.store(metrics)
+	return metrics, nil
+}
+
+// GetLatestMetrics returns a copy of the cached sample of an agent
+func (c *FleetCache) GetLatestMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
+	latest, err := c.get(ctx, agentID)
+	if err != nil || latest == nil {
+		return nil, err
+	}
+	sample := *latest
+	return &sample, nil
+}
+
+// Delete deletes an agent and forgets its sample
+func (c *FleetCache) Delete(ctx context.Context, id string) error {
+	if err := c.AgentRepository.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.latest, id)
+	return nil
+}
+
+// Summary returns every agent with its latest key metrics, and the totals, percentiles and
+// top hosts of the fleet. Only online agents count for the metric aggregates.
+func (c *FleetCache) Summary(ctx context.Context) (*domain.FleetSummary, error) {
+	agents, err := c.AgentRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &domain.FleetSummary{
+		Stats:       make(map[string]domain.FleetStats),
+		Top:         make(map[string][]domain.FleetTopHost),
+		Agents:      make([]domain.FleetAgent, 0, len(agents)),
+		GeneratedAt: time.Now(),
+	}
+	values := make(map[string][]domain.FleetTopHost)
+
+	for _, agent := range agents {
+		summary.Totals.Agents++
+		switch agent.Status {
+		case "online":
+			summary.Totals.Online++
+		case "offline":
+			summary.Totals.Offline++
+		default:
+			summary.Totals.Error++
+		}
+
+		latest, err := c.get(ctx, agent.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		entry := domain.FleetAgent{
+			ID:       agent.ID,
+			Name:     agent.Name,
+			Type:     agent.Type,
+			Status:   agent.Status,
+			LastSeen: agent.LastSeen,
+			Tags:     agent.Tags,
+		}
+		if latest != nil {
+			entry.Metrics = keyMetrics(latest)
+		}
+		summary.Agents = append(summary.Agents, entry)
+
+		if latest == nil || agent.Status != "online" {
+			continue
+		}
+
+		m := latest.Metrics
+		summary.Totals.Reporting++
+		summary.Totals.CPUCores += m.CPU.Cores
+		summary.Totals.MemoryTotal += m.Memory.Total
+		summary.Totals.MemoryUsed += m.Memory.Used
+		for _, disk := range m.Disk {
+			summary.Totals.DiskTotal += disk.Total
+			summary.Totals.DiskUsed += disk.Used
+		}
+
+		host := domain.FleetTopHost{AgentID: agent.ID, AgentName: agent.Name}
+		add := func(resource string, value float64, mountPoint string) {
+			host.Value = value
+			host.MountPoint = mountPoint
+			values[resource] = append(values[resource], host)
+		}
+		add(domain.FleetResourceCPU, m.CPU.UsagePercent, "")
+		add(domain.FleetResourceMemory, m.Memory.UsedPercent, "")
+		add(domain.FleetResourceLoad, m.Load.Load1, "")
+		if disk := fullestDisk(m); disk != nil {
+			add(domain.FleetResourceDisk, disk.UsedPercent, disk.MountPoint)
+		}
+	}
+
+	for _, resource := range []string{domain.FleetResourceCPU, domain.FleetResourceMemory, domain.FleetResourceDisk, domain.FleetResourceLoad} {
+		hosts := values[resource]
+		sort.SliceStable(hosts, func(i, j int) bool { return hosts[i].Value > hosts[j].Value })
+		summary.Stats[resource] = fleetStats(hosts)
+		summary.Top[resource] = hosts[:min(len(hosts), fleetTopHosts)]
+	}
+
+	return summary, nil
+}
+
+// get returns the cached sample of an agent, loading it on the first lookup
+func (c *FleetCache) get(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
+	c.mu.RLock()
+	latest, ok := c.latest[agentID]
+	c.mu.RUnlock()
+	if ok {
+		return latest, nil
+	}
+
+	latest, err := c.AgentRepository.GetLatestMetrics(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest == nil {
+		return nil, nil // not cached, to look again until the agent has a sample
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// A sample saved while loading is newer
+	if cached, ok := c.latest[agentID]; ok {
+		return cached, nil
+	}
+	c.latest[agentID] = latest
+	return latest, nil
+}
+
+// store caches a copy of a sample when it is newer than the cached one
+func (c *FleetCache) store(metrics *domain.AgentMetrics) {
+	sample := *metrics
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached := c.latest[metrics.AgentID]; cached == nil || !sampleTime(&sample).Before(sampleTime(cached)) {
+		c.latest[metrics.AgentID] = &sample
+	}
+}
+
+// sampleTime orders samples the way the repository does: by sample timestamp, or by
+// reception for samples without one
+func sampleTime(m *domain.AgentMetrics) time.Time {
+	if !m.Metrics.Timestamp.IsZero() {
+		return m.Metrics.Timestamp
+	}
+	return m.ReceivedAt
+}
+
+func keyMetrics(latest *domain.AgentMetrics) *domain.FleetAgentMetrics {
+	m := latest.Metrics
+	metrics := &domain.FleetAgentMetrics{
+		CPUPercent:    m.CPU.UsagePercent,
+		MemoryPercent: m.Memory.UsedPercent,
+		Load1:         m.Load.Load1,
+		Load5:         m.Load.Load5,
+		Load15:        m.Load.Load15,
+		Uptime:        m.System.Uptime,
+		ReceivedAt:    latest.ReceivedAt,
+	}
+	if disk := fullestDisk(m); disk != nil {
+		metrics.Disk = &domain.FleetDiskUsage{MountPoint: disk.MountPoint, UsedPercent: disk.UsedPercent}
+	}
+	return metrics
+}
+
+// fleetStats describes the values of hosts sorted in descending order
+func fleetStats(hosts []domain.FleetTopHost) domain.FleetStats {
+	if len(hosts) == 0 {
+		return domain.FleetStats{}
+	}
+
+	sorted := make([]float64, len(hosts))
+	sum := 0.0
+	for i, host := range hosts {
+		sorted[len(hosts)-1-i] = host.Value
+		sum += host.Value
+	}
+
+	return domain.FleetStats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Avg:   sum / float64(len(sorted)),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+		P99:   percentile(sorted, 99),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
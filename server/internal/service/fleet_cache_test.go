@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// pullRepository saves pulled samples itself, as the sqlite repository does, without going
+// through the cache's SaveMetrics
+type pullRepository struct {
+	domain.AgentRepository
+	latest *domain.AgentMetrics
+}
+
+func (r *pullRepository) GetLatestMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
+	return r.latest, nil
+}
+
+func (r *pullRepository) PullMetrics(ctx context.Context, agentID string) (*domain.AgentMetrics, error) {
+	r.latest = &domain.AgentMetrics{AgentID: agentID, ReceivedAt: time.Now()}
+	return r.latest, nil
+}
+
+func TestFleetCachePulledSamples(t *testing.T) {
+	ctx := context.Background()
+	repo := &pullRepository{}
+	cache := NewFleetCache(repo)
+
+	// Looked up before the agent has a sample
+	if latest, err := cache.GetLatestMetrics(ctx, "a1"); err != nil || latest != nil {
+		t.Fatalf("GetLatestMetrics() = %v, %v; want nil, nil", latest, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		pulled, err := cache.PullMetrics(ctx, "a1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		latest, err := cache.GetLatestMetrics(ctx, "a1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if latest == nil || !latest.ReceivedAt.Equal(pulled.ReceivedAt) {
+			t.Fatalf("pull %d: GetLatestMetrics() = %v, want the pulled sample", i, latest)
+		}
+	}
+}
+
+func TestFleetCacheLoadsSampleSavedPastIt(t *testing.T) {
+	ctx := context.Background()
+	repo := &pullRepository{}
+	cache := NewFleetCache(repo)
+
+	if latest, _ := cache.GetLatestMetrics(ctx, "a1"); latest != nil {
+		t.Fatalf("GetLatestMetrics() = %v, want nil", latest)
+	}
+	repo.latest = &domain.AgentMetrics{AgentID: "a1", ReceivedAt: time.Now()}
+	if latest, _ := cache.GetLatestMetrics(ctx, "a1"); latest == nil {
+		t.Fatal("GetLatestMetrics() = nil after the agent got a sample")
+	}
+}
@@ -0,0 +1,492 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// maxFiringAlerts bounds the firing alerts loaded at once
+const maxFiringAlerts = 10000
+
+// GroupService manages agent groups: their hierarchy, their static and dynamic members and
+// the health of the agents they contain. Agents whose groups change are asked to fetch their
+// config again, as group documents may apply to them.
+type GroupService struct {
+	repo       domain.GroupRepository
+	agentRepo  domain.AgentRepository
+	alertRepo  domain.AgentAlertRepository
+	configRepo domain.AgentConfigRepository
+	sessions   *AgentSessions
+}
+
+func NewGroupService(repo domain.GroupRepository, agentRepo domain.AgentRepository, alertRepo domain.AgentAlertRepository, configRepo domain.AgentConfigRepository, sessions *AgentSessions) *GroupService {
+	return &GroupService{
+		repo:       repo,
+		agentRepo:  agentRepo,
+		alertRepo:  alertRepo,
+		configRepo: configRepo,
+		sessions:   sessions,
+	}
+}
+
+// Get returns a group, or domain.ErrNotFound
+func (s *GroupService) Get(ctx context.Context, id int64) (*domain.Group, error) {
+	group, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if group == nil {
+		return nil, domain.ErrNotFound
+	}
+	return group, nil
+}
+
+// Create checks a validated group fits in the hierarchy and stores it. Failures of the
+// checks wrap domain.ErrInvalidInput.
+func (s *GroupService) Create(ctx context.Context, group *domain.Group) error {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return err
+	}
+	if err := tree.check(group); err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, group); err != nil {
+		return err
+	}
+
+	if group.IsDynamic() {
+		agents, err := s.agentRepo.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		go s.reload(agentIDs(tree.members(group, agents)))
+	}
+	return nil
+}
+
+// Update replaces a group. A group with child groups keeps its kind, and a group only gets
+// a selector once the members added by hand are removed.
+func (s *GroupService) Update(ctx context.Context, group *domain.Group) error {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return err
+	}
+	existing := tree.groups[group.ID]
+	if existing == nil {
+		return domain.ErrNotFound
+	}
+	if err := tree.check(group); err != nil {
+		return err
+	}
+	if group.Kind != existing.Kind && len(tree.children[group.ID]) > 0 {
+		return invalidInput("a group with child groups cannot change kind")
+	}
+	if group.IsDynamic() && len(tree.static[group.ID]) > 0 {
+		return invalidInput("remove the %d members added by hand before giving the group a selector", len(tree.static[group.ID]))
+	}
+
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	before := tree.members(existing, agents)
+
+	if err := s.repo.Update(ctx, group); err != nil {
+		return err
+	}
+
+	tree.groups[group.ID] = group
+	go s.reload(append(agentIDs(before), agentIDs(tree.members(group, agents))...))
+	return nil
+}
+
+// Delete removes a group without child groups, with its config document and its alerts
+func (s *GroupService) Delete(ctx context.Context, id int64) error {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return err
+	}
+	group := tree.groups[id]
+	if group == nil {
+		return domain.ErrNotFound
+	}
+	if n := len(tree.children[id]); n > 0 {
+		return invalidInput("group %q has %d child groups", group.Name, n)
+	}
+
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	members := tree.members(group, agents)
+
+	err = s.configRepo.Delete(ctx, domain.AgentConfigScopeGroup, strconv.FormatInt(id, 10))
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	go s.reload(agentIDs(members))
+	return nil
+}
+
+// AddMember adds an agent to a static group
+func (s *GroupService) AddMember(ctx context.Context, groupID int64, agentID string) error {
+	group, err := s.Get(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if group.IsDynamic() {
+		return invalidInput("the members of group %q come from its selector", group.Name)
+	}
+
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return err
+	}
+	if agent == nil {
+		return domain.ErrNotFound
+	}
+
+	if err := s.repo.AddMember(ctx, groupID, agentID); err != nil {
+		return err
+	}
+	go s.reload([]string{agentID})
+	return nil
+}
+
+// RemoveMember removes an agent from a static group
+func (s *GroupService) RemoveMember(ctx context.Context, groupID int64, agentID string) error {
+	group, err := s.Get(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if group.IsDynamic() {
+		return invalidInput("the members of group %q come from its selector", group.Name)
+	}
+
+	if err := s.repo.RemoveMember(ctx, groupID, agentID); err != nil {
+		return err
+	}
+	go s.reload([]string{agentID})
+	return nil
+}
+
+// Members returns the agents in a group, with those of the groups under it unless direct is set
+func (s *GroupService) Members(ctx context.Context, id int64, direct bool) ([]domain.Agent, error) {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	group := tree.groups[id]
+	if group == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if direct {
+		members := []domain.Agent{}
+		for _, agent := range agents {
+			if tree.contains(group, &agent) {
+				members = append(members, agent)
+			}
+		}
+		return members, nil
+	}
+	return tree.members(group, agents), nil
+}
+
+// GroupsOf returns the groups containing an agent, directly or through a group under them,
+// outermost first
+func (s *GroupService) GroupsOf(ctx context.Context, agent *domain.Agent) ([]domain.Group, error) {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []domain.Group{}
+	for _, group := range tree.groupsOf(agent) {
+		groups = append(groups, *group)
+	}
+	return groups, nil
+}
+
+// Health counts the agents of a group by status and finds its busiest CPU and fullest disk.
+// Only online agents count for CPU, as the last sample of an offline agent is stale.
+func (s *GroupService) Health(ctx context.Context, id int64) (*domain.GroupHealth, error) {
+	tree, err := s.tree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	group := tree.groups[id]
+	if group == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	health := &domain.GroupHealth{GroupID: id}
+	for _, agent := range tree.members(group, agents) {
+		health.Agents++
+		switch agent.Status {
+		case "online":
+			health.Online++
+		case "offline":
+			health.Offline++
+		default:
+			health.Error++
+		}
+
+		latest, err := s.agentRepo.GetLatestMetrics(ctx, agent.ID)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			continue
+		}
+
+		cpu := latest.Metrics.CPU.UsagePercent
+		if agent.Status == "online" && (health.WorstCPU == nil || cpu > health.WorstCPU.Percent) {
+			health.WorstCPU = &domain.AgentResourceUsage{AgentID: agent.ID, AgentName: agent.Name, Percent: cpu}
+		}
+		if disk := fullestDisk(latest.Metrics); disk != nil && (health.WorstDisk == nil || disk.UsedPercent > health.WorstDisk.Percent) {
+			health.WorstDisk = &domain.AgentResourceUsage{AgentID: agent.ID, AgentName: agent.Name, Percent: disk.UsedPercent, MountPoint: disk.MountPoint}
+		}
+	}
+
+	firing, err := s.alertRepo.GetAlerts(ctx, domain.AgentAlertQuery{Status: domain.AlertStatusFiring, Limit: maxFiringAlerts})
+	if err != nil {
+		return nil, err
+	}
+	subtree := tree.subtree(id)
+	for _, alert := range firing {
+		if slices.Contains(subtree, alert.GroupID) {
+			health.FiringAlerts++
+		}
+	}
+	return health, nil
+}
+
+// MemberIDs returns the IDs of the agents in a group or any group under it
+func (s *GroupService) MemberIDs(ctx context.Context, id int64) ([]string, error) {
+	members, err := s.Members(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+	return agentIDs(members), nil
+}
+
+// reload asks agents to fetch their config again
+func (s *GroupService) reload(agentIDs []string) {
+	sendReloadConfig(s.sessions, agentIDs)
+}
+
+// groupTree is a snapshot of the groups, their hierarchy and their static members
+type groupTree struct {
+	groups   map[int64]*domain.Group
+	children map[int64][]int64
+	static   map[int64][]string
+}
+
+func (s *GroupService) tree(ctx context.Context) (*groupTree, error) {
+	groups, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	static, err := s.repo.GetMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &groupTree{
+		groups:   make(map[int64]*domain.Group, len(groups)),
+		children: make(map[int64][]int64),
+		static:   static,
+	}
+	for i := range groups {
+		group := &groups[i]
+		tree.groups[group.ID] = group
+		if group.ParentID != nil {
+			tree.children[*group.ParentID] = append(tree.children[*group.ParentID], group.ID)
+		}
+	}
+	return tree, nil
+}
+
+// check validates the place of a group in the hierarchy, the uniqueness of its name among
+// its siblings and of its alert rule names
+func (t *groupTree) check(group *domain.Group) error {
+	parentKind, nested := domain.GroupParentKinds[group.Kind]
+	switch {
+	case !nested && group.ParentID != nil:
+		return invalidInput("%s groups are at the top of the hierarchy and have no parent", group.Kind)
+	case nested && group.ParentID == nil:
+		return invalidInput("%s groups need a parent %s group", group.Kind, parentKind)
+	case nested:
+		parent := t.groups[*group.ParentID]
+		if parent == nil {
+			return invalidInput("parent group %d not found", *group.ParentID)
+		}
+		if parent.Kind != parentKind {
+			return invalidInput("%s groups go under %s groups, and group %q is of kind %s", group.Kind, parentKind, parent.Name, parent.Kind)
+		}
+	}
+
+	for _, other := range t.groups {
+		if other.ID != group.ID && sameParent(other, group) && strings.EqualFold(other.Name, group.Name) {
+			return invalidInput("a group named %q already exists at this level", other.Name)
+		}
+	}
+
+	names := make(map[string]bool, len(group.AlertRules))
+	for _, rule := range group.AlertRules {
+		if names[rule.Name] {
+			return invalidInput("alert rule %q is defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// sorted returns the groups by ID
+func (t *groupTree) sorted() []*domain.Group {
+	groups := make([]*domain.Group, 0, len(t.groups))
+	for _, group := range t.groups {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	return groups
+}
+
+// contains reports whether an agent is a direct member of a group
+func (t *groupTree) contains(group *domain.Group, agent *domain.Agent) bool {
+	if group.Selector != nil {
+		return group.Selector.Matches(agent.Tags)
+	}
+	return slices.Contains(t.static[group.ID], agent.ID)
+}
+
+// subtree returns the ID of a group and those of every group under it
+func (t *groupTree) subtree(id int64) []int64 {
+	ids := []int64{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+// members returns the agents in a group or any group under it
+func (t *groupTree) members(group *domain.Group, agents []domain.Agent) []domain.Agent {
+	subtree := t.subtree(group.ID)
+	members := []domain.Agent{}
+	for _, agent := range agents {
+		for _, id := range subtree {
+			member := t.groups[id]
+			if id == group.ID {
+				member = group
+			}
+			if member != nil && t.contains(member, &agent) {
+				members = append(members, agent)
+				break
+			}
+		}
+	}
+	return members
+}
+
+// ancestry returns a group and the groups above it, outermost first
+func (t *groupTree) ancestry(id int64) []*domain.Group {
+	var chain []*domain.Group
+	for group := t.groups[id]; group != nil && len(chain) <= len(t.groups); {
+		chain = append([]*domain.Group{group}, chain...)
+		if group.ParentID == nil {
+			break
+		}
+		group = t.groups[*group.ParentID]
+	}
+	return chain
+}
+
+// groupsOf returns the groups containing an agent, directly or through a group under them,
+// outermost first
+func (t *groupTree) groupsOf(agent *domain.Agent) []*domain.Group {
+	seen := make(map[int64]bool)
+	var groups []*domain.Group
+	for _, group := range t.sorted() {
+		if !t.contains(group, agent) {
+			continue
+		}
+		for _, ancestor := range t.ancestry(group.ID) {
+			if !seen[ancestor.ID] {
+				seen[ancestor.ID] = true
+				groups = append(groups, ancestor)
+			}
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groupLevel(groups[i].Kind) < groupLevel(groups[j].Kind)
+	})
+	return groups
+}
+
+// routes returns the notification routes of a group, or of its nearest ancestor with routes
+func (t *groupTree) routes(id int64) []domain.NotificationRoute {
+	chain := t.ancestry(id)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if len(chain[i].Notifications) > 0 {
+			return chain[i].Notifications
+		}
+	}
+	return nil
+}
+
+// groupLevel is the depth of a kind of group in the hierarchy, 0 for environments
+func groupLevel(kind string) int {
+	level := 0
+	for k := kind; domain.GroupParentKinds[k] != ""; k = domain.GroupParentKinds[k] {
+		level++
+	}
+	return level
+}
+
+func sameParent(a, b *domain.Group) bool {
+	if a.ParentID == nil || b.ParentID == nil {
+		return a.ParentID == nil && b.ParentID == nil
+	}
+	return *a.ParentID == *b.ParentID
+}
+
+func agentIDs(agents []domain.Agent) []string {
+	ids := make([]string, len(agents))
+	for i, agent := range agents {
+		ids[i] = agent.ID
+	}
+	return ids
+}
+
+// fullestDisk returns the disk with the highest used percent, nil without disks
+func fullestDisk(metrics domain.SystemMetrics) *domain.DiskMetrics {
+	var fullest *domain.DiskMetrics
+	for i := range metrics.Disk {
+		if fullest == nil || metrics.Disk[i].UsedPercent > fullest.UsedPercent {
+			fullest = &metrics.Disk[i]
+		}
+	}
+	return fullest
+}
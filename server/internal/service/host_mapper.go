@@ -0,0 +1,503 @@
+package service
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// cpuTimes holds cumulative idle and total CPU seconds, used to turn counters into percentages
+type cpuTimes struct {
+	idle  float64
+	total float64
+}
+
+// hostSnapshot is the latest value of every series received for one virtual agent
+type hostSnapshot struct {
+	source string
+	series map[string]domain.Series
+}
+
+func seriesKey(s domain.Series) string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.Name)
+	for _, k := range keys {
+		b.WriteString("|" + k + "=" + s.Labels[k])
+	}
+	return b.String()
+}
+
+// each calls fn for every series with the given name
+func (h *hostSnapshot) each(name string, fn func(s domain.Series)) {
+	for _, s := range h.series {
+		if s.Name == name {
+			fn(s)
+		}
+	}
+}
+
+// value returns the value of the first series named name whose labels include match
+func (h *hostSnapshot) value(name string, match ...string) float64 {
+	var v float64
+	h.each(name, func(s domain.Series) {
+		for i := 0; i+1 < len(match); i += 2 {
+			if s.Labels[match[i]] != match[i+1] {
+				return
+			}
+		}
+		v = s.Value
+	})
+	return v
+}
+
+// sum adds up every series named name whose labels include match
+func (h *hostSnapshot) sum(name string, match ...string) float64 {
+	var total float64
+	h.each(name, func(s domain.Series) {
+		for i := 0; i+1 < len(match); i += 2 {
+			if s.Labels[match[i]] != match[i+1] {
+				return
+			}
+		}
+		total += s.Value
+	})
+	return total
+}
+
+// has reports whether any series named name was received
+func (h *hostSnapshot) has(name string) bool {
+	found := false
+	h.each(name, func(domain.Series) { found = true })
+	return found
+}
+
+// cpuUsage turns per-core cumulative times into usage percentages against the previous times
+func cpuUsage(current, previous map[string]cpuTimes) (float64, []float64) {
+	cores := make([]string, 0, len(current))
+	for cpu := range current {
+		cores = append(cores, cpu)
+	}
+	sort.Slice(cores, func(i, j int) bool {
+		a, _ := strconv.Atoi(cores[i])
+		b, _ := strconv.Atoi(cores[j])
+		return a < b
+	})
+
+	var idleDelta, totalDelta float64
+	perCore := make([]float64, 0, len(cores))
+	for _, cpu := range cores {
+		cur := current[cpu]
+		prev, ok := previous[cpu]
+		if !ok || cur.total <= prev.total {
+			perCore = append(perCore, 0)
+			continue
+		}
+		di := cur.idle - prev.idle
+		dt := cur.total - prev.total
+		idleDelta += di
+		totalDelta += dt
+		perCore = append(perCore, (1-di/dt)*100)
+	}
+
+	if totalDelta == 0 {
+		return 0, perCore
+	}
+	return (1 - idleDelta/totalDelta) * 100, perCore
+}
+
+func percent(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return part / total * 100
+}
+
+// prometheusNames are the node_exporter series mapped onto SystemMetrics
+var prometheusNames = map[string]bool{
+	"node_load1": true, "node_load5": true, "node_load15": true,
+	"node_memory_MemTotal_bytes": true, "node_memory_MemAvailable_bytes": true, "node_memory_MemFree_bytes": true,
+	"node_memory_Cached_bytes": true, "node_memory_Buffers_bytes": true,
+	"node_memory_SwapTotal_bytes": true, "node_memory_SwapFree_bytes": true,
+	"node_memory_Dirty_bytes": true, "node_memory_Writeback_bytes": true, "node_memory_Slab_bytes": true,
+	"node_memory_Shmem_bytes": true, "node_memory_Committed_AS_bytes": true, "node_memory_CommitLimit_bytes": true,
+	"node_filesystem_size_bytes": true, "node_filesystem_free_bytes": true, "node_filesystem_avail_bytes": true,
+	"node_filesystem_files": true, "node_filesystem_files_free": true,
+	"node_network_receive_bytes_total": true, "node_network_transmit_bytes_total": true,
+	"node_network_receive_packets_total": true, "node_network_transmit_packets_total": true,
+	"node_network_receive_errs_total": true, "node_network_transmit_errs_total": true,
+	"node_network_receive_drop_total": true, "node_network_transmit_drop_total": true,
+	"node_network_mtu_bytes": true, "node_netstat_Tcp_CurrEstab": true,
+	"node_cpu_seconds_total": true, "node_boot_time_seconds": true, "node_time_seconds": true,
+	"node_procs_running": true, "node_procs_blocked": true, "node_processes_threads": true,
+	"node_uname_info": true, "node_os_info": true, "node_hwmon_temp_celsius": true,
+	"node_pressure_cpu_waiting_seconds_total": true, "node_pressure_memory_waiting_seconds_total": true,
+	"node_pressure_memory_stalled_seconds_total": true, "node_pressure_io_waiting_seconds_total": true,
+	"node_pressure_io_stalled_seconds_total": true, "node_vmstat_pgfault": true,
+	"node_vmstat_pgmajfault": true, "node_vmstat_pgpgin": true,
+	"node_vmstat_pgpgout": true, "node_vmstat_pswpin": true, "node_vmstat_pswpout": true, "node_vmstat_oom_kill": true,
+}
+
+// mapPrometheus maps node_exporter series onto SystemMetrics
+func mapPrometheus(h *hostSnapshot, prevCPU map[string]cpuTimes) (domain.SystemMetrics, map[string]cpuTimes) {
+	var m domain.SystemMetrics
+	m.System.Hostname = h.source
+
+	// CPU
+	times := make(map[string]cpuTimes)
+	h.each("node_cpu_seconds_total", func(s domain.Series) {
+		t := times[s.Labels["cpu"]]
+		t.total += s.Value
+		if mode := s.Labels["mode"]; mode == "idle" || mode == "iowait" {
+			t.idle += s.Value
+		}
+		times[s.Labels["cpu"]] = t
+	})
+	m.CPU.UsagePercent, m.CPU.PerCore = cpuUsage(times, prevCPU)
+	m.CPU.Threads = len(times)
+	m.CPU.Cores = len(times)
+
+	// Memory
+	mem := &m.Memory
+	mem.Total = uint64(h.value("node_memory_MemTotal_bytes"))
+	mem.Available = uint64(h.value("node_memory_MemAvailable_bytes"))
+	mem.Free = uint64(h.value("node_memory_MemFree_bytes"))
+	mem.Cached = uint64(h.value("node_memory_Cached_bytes"))
+	mem.Buffers = uint64(h.value("node_memory_Buffers_bytes"))
+	if mem.Total >= mem.Available {
+		mem.Used = mem.Total - mem.Available
+	}
+	mem.UsedPercent = percent(float64(mem.Used), float64(mem.Total))
+	mem.Swap.Total = uint64(h.value("node_memory_SwapTotal_bytes"))
+	mem.Swap.Free = uint64(h.value("node_memory_SwapFree_bytes"))
+	if mem.Swap.Total >= mem.Swap.Free {
+		mem.Swap.Used = mem.Swap.Total - mem.Swap.Free
+	}
+	mem.Swap.UsedPercent = percent(float64(mem.Swap.Used), float64(mem.Swap.Total))
+
+	if h.has("node_memory_Dirty_bytes") {
+		m.MemoryDetail = &domain.MemoryDetailMetrics{
+			Dirty:       uint64(h.value("node_memory_Dirty_bytes")),
+			Writeback:   uint64(h.value("node_memory_Writeback_bytes")),
+			Slab:        uint64(h.value("node_memory_Slab_bytes")),
+			Shmem:       uint64(h.value("node_memory_Shmem_bytes")),
+			CommittedAS: uint64(h.value("node_memory_Committed_AS_bytes")),
+			CommitLimit: uint64(h.value("node_memory_CommitLimit_bytes")),
+		}
+	}
+
+	// Disks
+	h.each("node_filesystem_size_bytes", func(s domain.Series) {
+		match := []string{"mountpoint", s.Labels["mountpoint"], "device", s.Labels["device"]}
+		d := domain.DiskMetrics{
+			Device:      s.Labels["device"],
+			MountPoint:  s.Labels["mountpoint"],
+			FsType:      s.Labels["fstype"],
+			Total:       uint64(s.Value),
+			Free:        uint64(h.value("node_filesystem_avail_bytes", match...)),
+			InodesTotal: uint64(h.value("node_filesystem_files", match...)),
+			InodesFree:  uint64(h.value("node_filesystem_files_free", match...)),
+		}
+		if free := uint64(h.value("node_filesystem_free_bytes", match...)); d.Total >= free {
+			d.Used = d.Total - free
+		}
+		d.UsedPercent = percent(float64(d.Used), float64(d.Used+d.Free))
+		if d.InodesTotal >= d.InodesFree {
+			d.InodesUsed = d.InodesTotal - d.InodesFree
+		}
+		m.Disk = append(m.Disk, d)
+	})
+	sort.Slice(m.Disk, func(i, j int) bool { return m.Disk[i].MountPoint < m.Disk[j].MountPoint })
+
+	// Network
+	h.each("node_network_receive_bytes_total", func(s domain.Series) {
+		dev := s.Labels["device"]
+		iface := domain.NetworkInterface{
+			Name:        dev,
+			BytesRecv:   uint64(s.Value),
+			BytesSent:   uint64(h.value("node_network_transmit_bytes_total", "device", dev)),
+			PacketsRecv: uint64(h.value("node_network_receive_packets_total", "device", dev)),
+			PacketsSent: uint64(h.value("node_network_transmit_packets_total", "device", dev)),
+			MTU:         int(h.value("node_network_mtu_bytes", "device", dev)),
+		}
+		m.Network.Interfaces = append(m.Network.Interfaces, iface)
+		m.Network.BytesRecv += iface.BytesRecv
+		m.Network.BytesSent += iface.BytesSent
+		m.Network.PacketsRecv += iface.PacketsRecv
+		m.Network.PacketsSent += iface.PacketsSent
+	})
+	sort.Slice(m.Network.Interfaces, func(i, j int) bool { return m.Network.Interfaces[i].Name < m.Network.Interfaces[j].Name })
+	m.Network.ErrorsIn = uint64(h.sum("node_network_receive_errs_total"))
+	m.Network.ErrorsOut = uint64(h.sum("node_network_transmit_errs_total"))
+	m.Network.DropIn = uint64(h.sum("node_network_receive_drop_total"))
+	m.Network.DropOut = uint64(h.sum("node_network_transmit_drop_total"))
+	m.Network.Connections.Established = int(h.value("node_netstat_Tcp_CurrEstab"))
+
+	// Processes and load
+	m.Process.Running = int(h.value("node_procs_running"))
+	m.Process.Sleeping = int(h.value("node_procs_blocked"))
+	m.Process.Threads = int(h.value("node_processes_threads"))
+	m.Load.Load1 = h.value("node_load1")
+	m.Load.Load5 = h.value("node_load5")
+	m.Load.Load15 = h.value("node_load15")
+
+	// Temperature
+	h.each("node_hwmon_temp_celsius", func(s domain.Series) {
+		m.Temperature.Sensors = append(m.Temperature.Sensors, domain.ThermalSensor{
+			Name:        s.Labels["chip"] + "_" + s.Labels["sensor"],
+			Temperature: s.Value,
+		})
+	})
+
+	// System
+	h.each("node_uname_info", func(s domain.Series) {
+		if s.Labels["nodename"] != "" {
+			m.System.Hostname = s.Labels["nodename"]
+		}
+		m.System.OS = strings.ToLower(s.Labels["sysname"])
+		m.System.KernelVersion = s.Labels["release"]
+		m.System.KernelArch = s.Labels["machine"]
+	})
+	h.each("node_os_info", func(s domain.Series) {
+		m.System.Platform = s.Labels["id"]
+		m.System.PlatformFamily = s.Labels["id_like"]
+		m.System.PlatformVersion = s.Labels["version_id"]
+	})
+	if boot := h.value("node_boot_time_seconds"); boot > 0 {
+		m.System.BootTime = uint64(boot)
+		if now := h.value("node_time_seconds"); now > boot {
+			m.System.Uptime = uint64(now - boot)
+		}
+	}
+
+	// Pressure, totals only since node_exporter does not export the averages
+	if h.has("node_pressure_cpu_waiting_seconds_total") {
+		total := func(name string) uint64 { return uint64(h.value(name) * 1e6) }
+		m.Pressure = &domain.PressureMetrics{
+			CPU: &domain.PressureStat{Some: domain.PressureAvg{Total: total("node_pressure_cpu_waiting_seconds_total")}},
+			Memory: &domain.PressureStat{
+				Some: domain.PressureAvg{Total: total("node_pressure_memory_waiting_seconds_total")},
+				Full: &domain.PressureAvg{Total: total("node_pressure_memory_stalled_seconds_total")},
+			},
+			IO: &domain.PressureStat{
+				Some: domain.PressureAvg{Total: total("node_pressure_io_waiting_seconds_total")},
+				Full: &domain.PressureAvg{Total: total("node_pressure_io_stalled_seconds_total")},
+			},
+		}
+	}
+
+	if h.has("node_vmstat_pgfault") {
+		m.VMStat = &domain.VMStatMetrics{
+			PageFaults:      uint64(h.value("node_vmstat_pgfault")),
+			MajorPageFaults: uint64(h.value("node_vmstat_pgmajfault")),
+			PagesIn:         uint64(h.value("node_vmstat_pgpgin")),
+			PagesOut:        uint64(h.value("node_vmstat_pgpgout")),
+			SwapIn:          uint64(h.value("node_vmstat_pswpin")),
+			SwapOut:         uint64(h.value("node_vmstat_pswpout")),
+			OOMKills:        uint64(h.value("node_vmstat_oom_kill")),
+		}
+	}
+
+	return m, times
+}
+
+// otlpNames are the OpenTelemetry hostmetrics receiver series mapped onto SystemMetrics
+var otlpNames = map[string]bool{
+	"system.cpu.time": true, "system.cpu.utilization": true,
+	"system.cpu.load_average.1m": true, "system.cpu.load_average.5m": true, "system.cpu.load_average.15m": true,
+	"system.cpu.logical.count": true, "system.cpu.physical.count": true, "system.cpu.frequency": true,
+	"system.memory.usage": true, "system.memory.utilization": true, "system.paging.usage": true,
+	"system.paging.faults": true, "system.paging.operations": true,
+	"system.filesystem.usage": true, "system.filesystem.inodes.usage": true, "system.filesystem.utilization": true,
+	"system.network.io": true, "system.network.packets": true, "system.network.errors": true,
+	"system.network.dropped": true, "system.network.connections": true,
+	"system.processes.count": true, "system.uptime": true,
+}
+
+// mapOTLP maps OpenTelemetry hostmetrics receiver series onto SystemMetrics
+func mapOTLP(h *hostSnapshot, prevCPU map[string]cpuTimes) (domain.SystemMetrics, map[string]cpuTimes) {
+	var m domain.SystemMetrics
+	m.System.Hostname = h.source
+
+	// CPU, from cumulative time when available, otherwise from utilization
+	times := make(map[string]cpuTimes)
+	h.each("system.cpu.time", func(s domain.Series) {
+		t := times[s.Labels["cpu"]]
+		t.total += s.Value
+		if state := s.Labels["state"]; state == "idle" || state == "wait" {
+			t.idle += s.Value
+		}
+		times[s.Labels["cpu"]] = t
+	})
+	if len(times) > 0 {
+		m.CPU.UsagePercent, m.CPU.PerCore = cpuUsage(times, prevCPU)
+		m.CPU.Threads = len(times)
+	} else {
+		idle := make(map[string]float64)
+		h.each("system.cpu.utilization", func(s domain.Series) {
+			if state := s.Labels["state"]; state == "idle" || state == "wait" {
+				idle[s.Labels["cpu"]] += s.Value
+			}
+		})
+		cores := make([]string, 0, len(idle))
+		for cpu := range idle {
+			cores = append(cores, cpu)
+		}
+		sort.Strings(cores)
+		var total float64
+		for _, cpu := range cores {
+			usage := (1 - idle[cpu]) * 100
+			m.CPU.PerCore = append(m.CPU.PerCore, usage)
+			total += usage
+		}
+		if len(cores) > 0 {
+			m.CPU.UsagePercent = total / float64(len(cores))
+		}
+		m.CPU.Threads = len(cores)
+	}
+	if n := h.value("system.cpu.logical.count"); n > 0 {
+		m.CPU.Threads = int(n)
+	}
+	m.CPU.Cores = m.CPU.Threads
+	if n := h.value("system.cpu.physical.count"); n > 0 {
+		m.CPU.Cores = int(n)
+	}
+	m.CPU.Frequency = h.value("system.cpu.frequency") / 1e6
+
+	// Memory
+	mem := &m.Memory
+	mem.Used = uint64(h.value("system.memory.usage", "state", "used"))
+	mem.Free = uint64(h.value("system.memory.usage", "state", "free"))
+	mem.Cached = uint64(h.value("system.memory.usage", "state", "cached"))
+	mem.Buffers = uint64(h.value("system.memory.usage", "state", "buffered"))
+	mem.Total = uint64(h.sum("system.memory.usage"))
+	mem.Available = mem.Free + mem.Cached + mem.Buffers
+	mem.UsedPercent = percent(float64(mem.Used), float64(mem.Total))
+	mem.Swap.Used = uint64(h.sum("system.paging.usage", "state", "used"))
+	mem.Swap.Free = uint64(h.sum("system.paging.usage", "state", "free"))
+	mem.Swap.Total = mem.Swap.Used + mem.Swap.Free
+	mem.Swap.UsedPercent = percent(float64(mem.Swap.Used), float64(mem.Swap.Total))
+
+	// Disks
+	disks := make(map[string]*domain.DiskMetrics)
+	h.each("system.filesystem.usage", func(s domain.Series) {
+		mount := s.Labels["mountpoint"]
+		d, ok := disks[mount]
+		if !ok {
+			d = &domain.DiskMetrics{Device: s.Labels["device"], MountPoint: mount, FsType: s.Labels["type"]}
+			disks[mount] = d
+		}
+		switch s.Labels["state"] {
+		case "used":
+			d.Used = uint64(s.Value)
+		case "free":
+			d.Free = uint64(s.Value)
+		}
+		d.Total += uint64(s.Value)
+	})
+	h.each("system.filesystem.inodes.usage", func(s domain.Series) {
+		d, ok := disks[s.Labels["mountpoint"]]
+		if !ok {
+			return
+		}
+		switch s.Labels["state"] {
+		case "used":
+			d.InodesUsed = uint64(s.Value)
+		case "free":
+			d.InodesFree = uint64(s.Value)
+		}
+		d.InodesTotal = d.InodesUsed + d.InodesFree
+	})
+	for _, d := range disks {
+		d.UsedPercent = percent(float64(d.Used), float64(d.Used+d.Free))
+		m.Disk = append(m.Disk, *d)
+	}
+	sort.Slice(m.Disk, func(i, j int) bool { return m.Disk[i].MountPoint < m.Disk[j].MountPoint })
+
+	// Network
+	ifaces := make(map[string]*domain.NetworkInterface)
+	iface := func(name string) *domain.NetworkInterface {
+		if _, ok := ifaces[name]; !ok {
+			ifaces[name] = &domain.NetworkInterface{Name: name}
+		}
+		return ifaces[name]
+	}
+	h.each("system.network.io", func(s domain.Series) {
+		if s.Labels["direction"] == "transmit" {
+			iface(s.Labels["device"]).BytesSent = uint64(s.Value)
+		} else {
+			iface(s.Labels["device"]).BytesRecv = uint64(s.Value)
+		}
+	})
+	h.each("system.network.packets", func(s domain.Series) {
+		if s.Labels["direction"] == "transmit" {
+			iface(s.Labels["device"]).PacketsSent = uint64(s.Value)
+		} else {
+			iface(s.Labels["device"]).PacketsRecv = uint64(s.Value)
+		}
+	})
+	for _, i := range ifaces {
+		m.Network.Interfaces = append(m.Network.Interfaces, *i)
+		m.Network.BytesSent += i.BytesSent
+		m.Network.BytesRecv += i.BytesRecv
+		m.Network.PacketsSent += i.PacketsSent
+		m.Network.PacketsRecv += i.PacketsRecv
+	}
+	sort.Slice(m.Network.Interfaces, func(i, j int) bool { return m.Network.Interfaces[i].Name < m.Network.Interfaces[j].Name })
+	m.Network.ErrorsIn = uint64(h.sum("system.network.errors", "direction", "receive"))
+	m.Network.ErrorsOut = uint64(h.sum("system.network.errors", "direction", "transmit"))
+	m.Network.DropIn = uint64(h.sum("system.network.dropped", "direction", "receive"))
+	m.Network.DropOut = uint64(h.sum("system.network.dropped", "direction", "transmit"))
+	h.each("system.network.connections", func(s domain.Series) {
+		c := &m.Network.Connections
+		c.Total += int(s.Value)
+		switch s.Labels["state"] {
+		case "ESTABLISHED":
+			c.Established += int(s.Value)
+		case "LISTEN":
+			c.Listen += int(s.Value)
+		case "TIME_WAIT":
+			c.TimeWait += int(s.Value)
+		case "CLOSE_WAIT":
+			c.CloseWait += int(s.Value)
+		}
+	})
+
+	// Processes and load
+	h.each("system.processes.count", func(s domain.Series) {
+		p := &m.Process
+		p.Total += int(s.Value)
+		switch s.Labels["status"] {
+		case "running":
+			p.Running = int(s.Value)
+		case "sleeping", "blocked":
+			p.Sleeping += int(s.Value)
+		case "stopped":
+			p.Stopped = int(s.Value)
+		case "zombies":
+			p.Zombie = int(s.Value)
+		}
+	})
+	m.Load.Load1 = h.value("system.cpu.load_average.1m")
+	m.Load.Load5 = h.value("system.cpu.load_average.5m")
+	m.Load.Load15 = h.value("system.cpu.load_average.15m")
+	m.System.Uptime = uint64(h.value("system.uptime"))
+
+	if h.has("system.paging.faults") {
+		m.VMStat = &domain.VMStatMetrics{
+			PageFaults:      uint64(h.sum("system.paging.faults")),
+			MajorPageFaults: uint64(h.value("system.paging.faults", "type", "major")),
+			SwapIn:          uint64(h.value("system.paging.operations", "direction", "page_in", "type", "major")),
+			SwapOut:         uint64(h.value("system.paging.operations", "direction", "page_out", "type", "major")),
+		}
+	}
+
+	return m, times
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// hostMapper maps the merged series of a host onto SystemMetrics
+type hostMapper func(h *hostSnapshot, prevCPU map[string]cpuTimes) (domain.SystemMetrics, map[string]cpuTimes)
+
+// virtualHost is the in-memory state of one virtual agent
+type virtualHost struct {
+	agent     *domain.Agent
+	snapshot  *hostSnapshot
+	cpu       map[string]cpuTimes
+	lastSaved time.Time
+	checkedAt time.Time
+}
+
+// IngestService turns series pushed by Prometheus remote-write or OTLP into metrics of
+// virtual agents. Series are merged per host, since one host's samples may be split
+// across several requests, and a SystemMetrics sample is saved at most once per interval.
+// Application metrics from StatsD and Influx line protocol are stored as custom metrics only,
+// on the agent whose ID or hostname matches the source, or on a virtual agent otherwise.
+type IngestService struct {
+	agentRepo  domain.AgentRepository
+	customRepo domain.CustomMetricRepository
+	processor  *MetricsProcessor
+	interval   time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*virtualHost
+}
+
+func NewIngestService(agentRepo domain.AgentRepository, customRepo domain.CustomMetricRepository, processor *MetricsProcessor, interval time.Duration) *IngestService {
+	return &IngestService{
+		agentRepo:  agentRepo,
+		customRepo: customRepo,
+		processor:  processor,
+		interval:   interval,
+		hosts:      make(map[string]*virtualHost),
+	}
+}
+
+// GroupByLabel splits series into batches by the value of a label, e.g. "instance"
+func GroupByLabel(series []domain.Series, label string) []domain.SeriesBatch {
+	index := make(map[string]int)
+	var batches []domain.SeriesBatch
+	for _, s := range series {
+		source := s.Labels[label]
+		i, ok := index[source]
+		if !ok {
+			i = len(batches)
+			index[source] = i
+			batches = append(batches, domain.SeriesBatch{Source: source})
+		}
+		batches[i].Series = append(batches[i].Series, s)
+	}
+	return batches
+}
+
+// Ingest stores the batches for agents of the given type. Host metrics are mapped for
+// domain.AgentTypePrometheus and domain.AgentTypeOTLP; every series of
+// domain.AgentTypeStatsD and domain.AgentTypeInflux is kept as a custom metric.
+func (s *IngestService) Ingest(ctx context.Context, agentType string, batches []domain.SeriesBatch) error {
+	var mapper hostMapper
+	var known map[string]bool
+	switch agentType {
+	case domain.AgentTypePrometheus:
+		mapper, known = mapPrometheus, prometheusNames
+	case domain.AgentTypeOTLP:
+		mapper, known = mapOTLP, otlpNames
+	case domain.AgentTypeStatsD, domain.AgentTypeInflux:
+	default:
+		return fmt.Errorf("unsupported agent type %q", agentType)
+	}
+
+	for _, batch := range batches {
+		if batch.Source == "" {
+			batch.Source = "unknown"
+		}
+		if err := s.ingestBatch(ctx, agentType, batch, mapper, known); err != nil {
+			return fmt.Errorf("source %s: %w", batch.Source, err)
+		}
+	}
+	return nil
+}
+
+func (s *IngestService) ingestBatch(ctx context.Context, agentType string, batch domain.SeriesBatch, mapper hostMapper, known map[string]bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host, err := s.host(ctx, agentType, batch.Source)
+	if err != nil {
+		return err
+	}
+
+	var points []domain.CustomMetricPoint
+	for _, series := range batch.Series {
+		if known[series.Name] {
+			host.snapshot.series[seriesKey(series)] = series
+			continue
+		}
+
+		recordedAt := series.Timestamp
+		if recordedAt.IsZero() {
+			recordedAt = time.Now()
+		}
+		points = append(points, domain.CustomMetricPoint{
+			AgentID:    host.agent.ID,
+			Source:     agentType,
+			Name:       series.Name,
+			Value:      series.Value,
+			Unit:       series.Unit,
+			Labels:     series.Labels,
+			RecordedAt: recordedAt,
+		})
+	}
+
+	if err := s.customRepo.SavePoints(ctx, points); err != nil {
+		return fmt.Errorf("failed to save custom metrics: %w", err)
+	}
+
+	// Polled agents and agents fed by host metrics keep their own status
+	if host.agent.Type != agentType && !(isAppMetrics(agentType) && isAppMetrics(host.agent.Type)) {
+		return nil
+	}
+
+	now := time.Now()
+	if err := s.agentRepo.UpdateStatus(ctx, host.agent.ID, "online", now); err != nil {
+		return fmt.Errorf("failed to update agent status: %w", err)
+	}
+
+	if mapper == nil || len(host.snapshot.series) == 0 || now.Sub(host.lastSaved) < s.interval {
+		return nil
+	}
+
+	systemMetrics, cpu := mapper(host.snapshot, host.cpu)
+	systemMetrics.Timestamp = now
+	metrics := &domain.AgentMetrics{
+		AgentID:    host.agent.ID,
+		AgentName:  host.agent.Name,
+		Metrics:    systemMetrics,
+		ReceivedAt: now,
+	}
+	if err := s.agentRepo.SaveMetrics(ctx, metrics); err != nil && !errors.Is(err, domain.ErrDuplicate) {
+		return fmt.Errorf("failed to save metrics: %w", err)
+	}
+	host.cpu = cpu
+	host.lastSaved = now
+
+	if err := s.processor.Process(ctx, metrics); err != nil {
+		log.Printf("⚠️  Agent %s (%s): Failed to process metrics - %v", host.agent.Name, host.agent.ID, err)
+	}
+	return nil
+}
+
+// host returns the state of the agent for a source, registering a virtual agent on first sight
+func (s *IngestService) host(ctx context.Context, agentType, source string) (*virtualHost, error) {
+	key := agentType + "/" + source
+	if host, ok := s.hosts[key]; ok {
+		if time.Since(host.checkedAt) < s.interval {
+			return host, nil
+		}
+		// Re-check once per interval in case the agent was deleted through the API
+		agent, err := s.agentRepo.GetByID(ctx, host.agent.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up agent: %w", err)
+		}
+		if agent != nil {
+			host.checkedAt = time.Now()
+			return host, nil
+		}
+		delete(s.hosts, key)
+	}
+
+	var agent *domain.Agent
+	var err error
+	if isAppMetrics(agentType) {
+		agent, err = s.existingAgent(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up agent: %w", err)
+		}
+	}
+	if agent == nil {
+		agent, err = s.agentRepo.GetByHost(ctx, agentType, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up agent: %w", err)
+		}
+	}
+
+	if agent == nil {
+		now := time.Now()
+		agent = &domain.Agent{
+			ID:          uuid.New().String(),
+			Name:        source,
+			Type:        agentType,
+			Host:        source,
+			Hostname:    source,
+			Status:      "online",
+			LastSeen:    now,
+			Tags:        []string{agentType},
+			Description: "Virtual agent fed by " + agentType + " ingest",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := s.agentRepo.Register(ctx, agent); err != nil {
+			return nil, fmt.Errorf("failed to register virtual agent: %w", err)
+		}
+		log.Printf("✓ Registered virtual %s agent %s (%s)", agentType, agent.Name, agent.ID)
+	}
+
+	host := &virtualHost{
+		agent:     agent,
+		snapshot:  &hostSnapshot{source: source, series: make(map[string]domain.Series)},
+		checkedAt: time.Now(),
+	}
+	s.hosts[key] = host
+	return host, nil
+}
+
+// existingAgent finds a registered agent whose ID or hostname equals source,
+// preferring polled agents over virtual ones
+func (s *IngestService) existingAgent(ctx context.Context, source string) (*domain.Agent, error) {
+	agent, err := s.agentRepo.GetByID(ctx, source)
+	if err != nil || agent != nil {
+		return agent, err
+	}
+
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var virtual *domain.Agent
+	for i := range agents {
+		if agents[i].Hostname != source {
+			continue
+		}
+		if !agents[i].IsVirtual() {
+			return &agents[i], nil
+		}
+		if virtual == nil {
+			virtual = &agents[i]
+		}
+	}
+	return virtual, nil
+}
+
+// isAppMetrics reports whether an agent type carries application metrics only
+func isAppMetrics(agentType string) bool {
+	return agentType == domain.AgentTypeStatsD || agentType == domain.AgentTypeInflux
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+const (
+	// logPruneInterval is how often lines past the retention limits are deleted
+	logPruneInterval = 5 * time.Minute
+	// logTailBuffer is the lines queued for a live tail before lines are dropped
+	logTailBuffer = 256
+)
+
+// LogService stores the log lines agents ship, within the retention limits, and hands new
+// lines to live tails
+type LogService struct {
+	repo        domain.LogRepository
+	agentRepo   domain.AgentRepository
+	retention   time.Duration
+	maxPerAgent int
+
+	mu    sync.Mutex
+	tails map[*LogTail]struct{}
+
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// LogTail receives the new lines of an agent matching a filter. Lines arriving while Lines
+// is full are dropped and counted.
+type LogTail struct {
+	agentID string
+	filter  domain.LogFilter
+	lines   chan domain.LogLine
+	dropped int // guarded by LogService.mu
+}
+
+func NewLogService(repo domain.LogRepository, agentRepo domain.AgentRepository, retention time.Duration, maxPerAgent int) *LogService {
+	return &LogService{
+		repo:        repo,
+		agentRepo:   agentRepo,
+		retention:   retention,
+		maxPerAgent: maxPerAgent,
+		tails:       make(map[*LogTail]struct{}),
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Start begins deleting the lines past the retention limits
+func (s *LogService) Start() {
+	log.Printf("📜 Log retention started (retention: %s, max lines per agent: %d)", s.retention, s.maxPerAgent)
+	s.wg.Add(1)
+	go s.pruneLoop()
+}
+
+// Stop gracefully stops the pruning loop
+func (s *LogService) Stop() {
+	log.Println("⏸️  Stopping log retention...")
+	close(s.stopChan)
+	s.wg.Wait()
+	log.Println("✓ Log retention stopped")
+}
+
+// Ingest stores a batch of lines shipped by an agent and hands the new ones to its live
+// tails. Lines already stored, shipped again after a lost response, are skipped.
+func (s *LogService) Ingest(ctx context.Context, agentID string, lines []domain.LogLine) (int, error) {
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return 0, err
+	}
+	if agent == nil {
+		return 0, domain.ErrNotFound
+	}
+
+	saved, err := s.repo.SaveLines(ctx, agentID, lines)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(agentID, saved)
+	return len(saved), nil
+}
+
+// Query returns the stored lines of an agent matching q, newest first
+func (s *LogService) Query(ctx context.Context, q domain.LogQuery) ([]domain.LogLine, error) {
+	agent, err := s.agentRepo.GetByID(ctx, q.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, domain.ErrNotFound
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && q.Until.Before(q.Since) {
+		return nil, invalidInput("until must not be before since")
+	}
+	return s.repo.Query(ctx, q)
+}
+
+// Tail subscribes to the new lines of an agent matching filter; Untail must be called when
+// the tail is no longer read
+func (s *LogService) Tail(ctx context.Context, agentID string, filter domain.LogFilter) (*LogTail, error) {
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	tail := &LogTail{
+		agentID: agentID,
+		filter:  filter,
+		lines:   make(chan domain.LogLine, logTailBuffer),
+	}
+	s.mu.Lock()
+	s.tails[tail] = struct{}{}
+	s.mu.Unlock()
+	return tail, nil
+}
+
+// Untail ends a live tail
+func (s *LogService) Untail(tail *LogTail) {
+	s.mu.Lock()
+	delete(s.tails, tail)
+	s.mu.Unlock()
+}
+
+// Lines returns the new lines of the tail
+func (t *LogTail) Lines() <-chan domain.LogLine {
+	return t.lines
+}
+
+// Dropped returns the lines dropped since the last call because the tail was read too slowly
+func (s *LogService) Dropped(tail *LogTail) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := tail.dropped
+	tail.dropped = 0
+	return dropped
+}
+
+func (s *LogService) publish(agentID string, lines []domain.LogLine) {
+	if len(lines) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tail := range s.tails {
+		if tail.agentID != agentID {
+			continue
+		}
+		for i := range lines {
+			if !tail.filter.Match(&lines[i]) {
+				continue
+			}
+			select {
+			case tail.lines <- lines[i]:
+			default:
+				tail.dropped++
+			}
+		}
+	}
+}
+
+func (s *LogService) pruneLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(logPruneInterval)
+	defer ticker.Stop()
+
+	s.prune()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.prune()
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *LogService) prune() {
+	if err := s.repo.Prune(context.Background(), time.Now().Add(-s.retention), s.maxPerAgent); err != nil {
+		log.Printf("⚠️  Failed to prune logs: %v", err)
+	}
+}
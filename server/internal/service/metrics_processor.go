@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// MetricsProcessor runs the follow-up work for every metrics sample saved for an agent,
+// whether it was pulled by the poller or pushed by the agent
+type MetricsProcessor struct {
+	services *ServiceTracker
+	custom   domain.CustomMetricRepository
+	ping     domain.PingRepository
+}
+
+func NewMetricsProcessor(services *ServiceTracker, custom domain.CustomMetricRepository, ping domain.PingRepository) *MetricsProcessor {
+	return &MetricsProcessor{
+		services: services,
+		custom:   custom,
+		ping:     ping,
+	}
+}
+
+// Process records service state changes and stores plugin and ping results. Processing a
+// sample again, such as one an agent replays, stores nothing twice.
+func (p *MetricsProcessor) Process(ctx context.Context, metrics *domain.AgentMetrics) error {
+	if err := p.services.Track(ctx, metrics.AgentID, metrics.Metrics.Services); err != nil {
+		return fmt.Errorf("failed to track services: %w", err)
+	}
+
+	if err := p.saveCustom(ctx, metrics); err != nil {
+		return fmt.Errorf("failed to save custom metrics: %w", err)
+	}
+
+	if len(metrics.Metrics.Ping) > 0 {
+		if err := p.ping.SaveResults(ctx, metrics.AgentID, metrics.Metrics.Ping); err != nil {
+			return fmt.Errorf("failed to save ping results: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (p *MetricsProcessor) saveCustom(ctx context.Context, metrics *domain.AgentMetrics) error {
+	checks := metrics.Metrics.Custom
+	if len(checks) == 0 {
+		return nil
+	}
+
+	if err := p.custom.SaveChecks(ctx, metrics.AgentID, checks); err != nil {
+		return err
+	}
+
+	var points []domain.CustomMetricPoint
+	for _, check := range checks {
+		for _, m := range check.Metrics {
+			points = append(points, domain.CustomMetricPoint{
+				AgentID:    metrics.AgentID,
+				Source:     check.Plugin,
+				Name:       m.Name,
+				Value:      m.Value,
+				Unit:       m.Unit,
+				Labels:     m.Labels,
+				RecordedAt: check.CheckedAt,
+			})
+		}
+	}
+
+	return p.custom.SavePoints(ctx, points)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+// registerAgent registers an agent for the rows that reference it
+func registerAgent(t *testing.T, db *sql.DB, id string) {
+	t.Helper()
+	agent := &domain.Agent{ID: id, Name: id, Host: "127.0.0.1", Status: "online", LastSeen: time.Now()}
+	if err := sqlite.NewAgentRepository(db).Register(context.Background(), agent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestProcessor(db *sql.DB) *MetricsProcessor {
+	return NewMetricsProcessor(
+		NewServiceTracker(sqlite.NewServiceRepository(db)),
+		sqlite.NewCustomMetricRepository(db),
+		sqlite.NewPingRepository(db),
+	)
+}
+
+func TestProcessRepeatedPluginResults(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+	processor := newTestProcessor(db)
+
+	checkedAt := time.Date(2026, 10, 19, 8, 30, 0, 0, jakarta)
+	sample := func(sampledAt time.Time, checkedAt time.Time, value float64) *domain.AgentMetrics {
+		return &domain.AgentMetrics{
+			AgentID:    "agent-1",
+			ReceivedAt: sampledAt,
+			Metrics: domain.SystemMetrics{
+				Timestamp: sampledAt,
+				Custom: []domain.CustomCheck{{
+					Plugin:    "check_backup_age",
+					Status:    domain.CheckStatusOK,
+					CheckedAt: checkedAt,
+					Metrics: []domain.CustomMetric{
+						{Name: "backup_age", Value: value, Unit: "s", Labels: map[string]string{"db": "main", "host": "a"}},
+						{Name: "backup_size", Value: value * 2},
+					},
+				}},
+			},
+		}
+	}
+
+	// The plugin ran once, but its result is reported with every sample until it runs again
+	for _, sampledAt := range []time.Time{checkedAt.Add(5 * time.Second), checkedAt.Add(20 * time.Second), checkedAt.Add(35 * time.Second)} {
+		if err := processor.Process(ctx, sample(sampledAt, checkedAt, 120)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The same sample processed again, as when an agent replays it
+	if err := processor.Process(ctx, sample(checkedAt.Add(35*time.Second), checkedAt, 120)); err != nil {
+		t.Fatal(err)
+	}
+	if err := processor.Process(ctx, sample(checkedAt.Add(65*time.Second), checkedAt.Add(time.Minute), 180)); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := sqlite.NewCustomMetricRepository(db).QueryPoints(ctx, domain.CustomMetricQuery{AgentID: "agent-1", Name: "backup_age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("stored %d backup_age points, want one per plugin run: %+v", len(points), points)
+	}
+	if points[0].Value != 180 || points[1].Value != 120 || !points[1].RecordedAt.Equal(checkedAt) {
+		t.Errorf("points = %+v, want 180 then 120 at %s", points, checkedAt)
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// MQTTOptions configures the sensor subscriber. With EmbeddedAddr set a broker is
+// started inside the server and subscribed to directly; otherwise Broker is dialled.
+type MQTTOptions struct {
+	Broker       string // e.g. tcp://mosquitto:1883
+	EmbeddedAddr string // e.g. :1883
+	ClientID     string
+	Username     string
+	Password     string
+	Topics       []string
+	QoS          byte
+	APIKeys      []string // passwords accepted by the embedded broker, which requires one
+}
+
+// MQTTSubscriber stores sensor readings published on MQTT topics.
+// A payload is either a JSON submission (the POST /env-metrics body) or a plain
+// number, in which case the last topic segment is the sensor ID.
+type MQTTSubscriber struct {
+	opts   MQTTOptions
+	ingest *EnvIngestService
+
+	client paho.Client
+	broker *mochi.Server
+}
+
+func NewMQTTSubscriber(opts MQTTOptions, ingest *EnvIngestService) *MQTTSubscriber {
+	return &MQTTSubscriber{
+		opts:   opts,
+		ingest: ingest,
+	}
+}
+
+// Start connects to the broker, or starts the embedded one, and subscribes to the topics
+func (s *MQTTSubscriber) Start() error {
+	if s.opts.EmbeddedAddr != "" {
+		return s.startEmbedded()
+	}
+	return s.startClient()
+}
+
+// Stop disconnects from or shuts down the broker
+func (s *MQTTSubscriber) Stop() {
+	log.Println("⏸️  Stopping MQTT subscriber...")
+	if s.client != nil {
+		s.client.Disconnect(1000)
+	}
+	if s.broker != nil {
+		s.broker.Close()
+	}
+	log.Println("✓ MQTT subscriber stopped")
+}
+
+func (s *MQTTSubscriber) startClient() error {
+	clientOpts := paho.NewClientOptions().
+		AddBroker(s.opts.Broker).
+		SetClientID(s.opts.ClientID).
+		SetUsername(s.opts.Username).
+		SetPassword(s.opts.Password).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(5 * time.Second).
+		SetOnConnectHandler(func(client paho.Client) {
+			// Subscriptions are renewed on every (re)connect
+			for _, topic := range s.opts.Topics {
+				token := client.Subscribe(topic, s.opts.QoS, func(_ paho.Client, msg paho.Message) {
+					s.handleMessage(msg.Topic(), msg.Payload())
+				})
+				if token.Wait() && token.Error() != nil {
+					log.Printf("❌ MQTT: Failed to subscribe to %s - %v", topic, token.Error())
+				}
+			}
+			log.Printf("📡 MQTT subscriber connected to %s (topics: %s)", s.opts.Broker, strings.Join(s.opts.Topics, ", "))
+		}).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("⚠️  MQTT: Connection lost - %v", err)
+		})
+
+	s.client = paho.NewClient(clientOpts)
+	// With connect retry enabled the token only fails on invalid options
+	if token := s.client.Connect(); token.WaitTimeout(10*time.Second) && token.Error() != nil {
+		return token.Error()
+	}
+	return nil
+}
+
+func (s *MQTTSubscriber) startEmbedded() error {
+	// Like POST /env-metrics, the broker accepts no readings without an API key
+	if len(s.opts.APIKeys) == 0 {
+		return errors.New("the embedded MQTT broker requires at least one env metrics API key")
+	}
+
+	s.broker = mochi.New(&mochi.Options{InlineClient: true})
+	if err := s.broker.AddHook(&keyAuthHook{keys: s.opts.APIKeys}, nil); err != nil {
+		return err
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: s.opts.EmbeddedAddr})
+	if err := s.broker.AddListener(tcp); err != nil {
+		return err
+	}
+	if err := s.broker.Serve(); err != nil {
+		return err
+	}
+
+	for i, topic := range s.opts.Topics {
+		err := s.broker.Subscribe(topic, i+1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+			s.handleMessage(pk.TopicName, pk.Payload)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", topic, err)
+		}
+	}
+
+	log.Printf("📡 Embedded MQTT broker started on %s (topics: %s)", tcp.Address(), strings.Join(s.opts.Topics, ", "))
+	return nil
+}
+
+func (s *MQTTSubscriber) handleMessage(topic string, payload []byte) {
+	submissions, err := decodeMQTTPayload(topic, payload)
+	if err != nil {
+		log.Printf("⚠️  MQTT: Invalid payload on %s - %v", topic, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if _, err := s.ingest.Submit(ctx, submissions); err != nil {
+		log.Printf("⚠️  MQTT: Failed to store readings from %s - %v", topic, err)
+	}
+}
+
+// decodeMQTTPayload turns a JSON submission or a bare number into submissions
+func decodeMQTTPayload(topic string, payload []byte) ([]domain.EnvSubmission, error) {
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return nil, errors.New("empty payload")
+	}
+
+	if payload[0] == '{' || payload[0] == '[' {
+		submissions, _, err := DecodeEnvSubmissions(payload)
+		return submissions, err
+	}
+
+	value, err := strconv.ParseFloat(string(payload), 64)
+	if err != nil {
+		return nil, fmt.Errorf("expected JSON or a number: %w", err)
+	}
+	sensorID := topic[strings.LastIndex(topic, "/")+1:]
+	return []domain.EnvSubmission{{
+		Readings: []domain.SensorReading{{SensorID: sensorID, Value: value}},
+	}}, nil
+}
+
+// keyAuthHook lets clients connect to the embedded broker with an API key as password
+type keyAuthHook struct {
+	mochi.HookBase
+	keys []string
+}
+
+func (h *keyAuthHook) ID() string {
+	return "api-key-auth"
+}
+
+func (h *keyAuthHook) Provides(b byte) bool {
+	return b == mochi.OnConnectAuthenticate || b == mochi.OnACLCheck
+}
+
+func (h *keyAuthHook) OnConnectAuthenticate(cl *mochi.Client, pk packets.Packet) bool {
+	for _, key := range h.keys {
+		if subtle.ConstantTimeCompare(pk.Connect.Password, []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *keyAuthHook) OnACLCheck(cl *mochi.Client, topic string, write bool) bool {
+	return true
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+func TestMQTTSubscriberEmbedded(t *testing.T) {
+	db := openTestDB(t)
+	envRepo := sqlite.NewEnvMetricsRepository(db)
+	ingest := NewEnvIngestService(envRepo, sqlite.NewIdempotencyRepository(db))
+
+	subscriber := NewMQTTSubscriber(MQTTOptions{
+		EmbeddedAddr: "127.0.0.1:0",
+		ClientID:     "monitoring-server",
+		Topics:       []string{"sensors/#"},
+		QoS:          1,
+		APIKeys:      []string{"sensor-key"},
+	}, ingest)
+	if err := subscriber.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Stop()
+
+	listener, ok := subscriber.broker.Listeners.Get("tcp")
+	if !ok {
+		t.Fatal("embedded broker has no tcp listener")
+	}
+	broker := "tcp://" + listener.Address()
+
+	connect := func(password string) (paho.Client, error) {
+		client := paho.NewClient(paho.NewClientOptions().
+			AddBroker(broker).
+			SetClientID("esp32-" + password).
+			SetUsername("esp32").
+			SetPassword(password).
+			SetConnectRetry(false))
+		token := client.Connect()
+		if !token.WaitTimeout(5 * time.Second) {
+			t.Fatal("timed out connecting to the embedded broker")
+		}
+		return client, token.Error()
+	}
+
+	if client, err := connect("wrong-key"); err == nil {
+		client.Disconnect(0)
+		t.Fatal("the embedded broker accepted a client without a valid API key")
+	}
+
+	client, err := connect("sensor-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(0)
+
+	publish := func(topic, payload string) {
+		t.Helper()
+		if token := client.Publish(topic, 1, false, payload); !token.WaitTimeout(5*time.Second) || token.Error() != nil {
+			t.Fatalf("publish to %s: %v", topic, token.Error())
+		}
+	}
+
+	// A bare number is a reading of the sensor named by the last topic segment
+	publish("sensors/first_temperature", "21.5")
+	// A bulk JSON submission, then one of its submissions sent again
+	publish("sensors/esp32-1", `[
+		{"idempotency_key": "boot-1-seq-1", "readings": [{"sensor_id": "first_temperature", "value": 22}]},
+		{"idempotency_key": "boot-1-seq-2", "first_humidity": 55, "second_humidity": 60}
+	]`)
+	publish("sensors/esp32-1", `{"idempotency_key": "boot-1-seq-1", "readings": [{"sensor_id": "first_temperature", "value": 99}]}`)
+	// Stored last, so that the earlier messages were all handled once it is
+	publish("sensors/second_temperature", "19")
+
+	readings := func(sensorID string) []domain.SensorReading {
+		t.Helper()
+		readings, err := envRepo.GetReadings(context.Background(), domain.ReadingQuery{SensorIDs: []string{sensorID}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return readings
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(readings("second_temperature")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the readings to be stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	want := map[string][]float64{
+		"first_temperature":  {21.5, 22},
+		"first_humidity":     {55},
+		"second_humidity":    {60},
+		"second_temperature": {19},
+	}
+	for sensorID, values := range want {
+		got := readings(sensorID)
+		if len(got) != len(values) {
+			t.Errorf("%s: got %d readings %+v, want %v", sensorID, len(got), got, values)
+			continue
+		}
+		for i, reading := range got {
+			if reading.Value != values[i] {
+				t.Errorf("%s: reading %d = %g, want %g", sensorID, i, reading.Value, values[i])
+			}
+		}
+	}
+}
+
+func TestMQTTSubscriberEmbeddedWithoutKeys(t *testing.T) {
+	subscriber := NewMQTTSubscriber(MQTTOptions{
+		EmbeddedAddr: "127.0.0.1:0",
+		ClientID:     "monitoring-server",
+		Topics:       []string{"sensors/#"},
+	}, nil)
+	if err := subscriber.Start(); err == nil {
+		subscriber.Stop()
+		t.Fatal("the embedded broker started without API keys")
+	}
+
+	hook := &keyAuthHook{}
+	for _, password := range []string{"", "anything"} {
+		var pk packets.Packet
+		pk.Connect.Password = []byte(password)
+		if hook.OnConnectAuthenticate(nil, pk) {
+			t.Errorf("a client with password %q was accepted without API keys", password)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// Notifier posts notifications to webhook routes
+type Notifier struct {
+	client *http.Client
+}
+
+func NewNotifier(timeout time.Duration) *Notifier {
+	return &Notifier{
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Send posts a notification to each route accepting its status, in the background.
+// Failures are logged and not retried.
+func (n *Notifier) Send(routes []domain.NotificationRoute, notification domain.Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("❌ Failed to encode notification: %v", err)
+		return
+	}
+
+	for _, route := range routes {
+		if route.Accepts(notification.Status) {
+			go n.post(route, body)
+		}
+	}
+}
+
+func (n *Notifier) post(route domain.NotificationRoute, body []byte) {
+	resp, err := n.client.Post(route.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("⚠️  Notification route %q: Failed to send - %v", route.Name, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		log.Printf("⚠️  Notification route %q: Webhook returned status %d", route.Name, resp.StatusCode)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/probe"
+)
+
+// ErrTracerouteFailed is returned when the agent could not trace the route
+var ErrTracerouteFailed = errors.New("traceroute failed")
+
+// PingService builds the latency matrix from the ping results agents report and runs
+// traceroutes on agents
+type PingService struct {
+	repo      domain.PingRepository
+	agentRepo domain.AgentRepository
+	sessions  *AgentSessions
+}
+
+func NewPingService(repo domain.PingRepository, agentRepo domain.AgentRepository, sessions *AgentSessions) *PingService {
+	return &PingService{
+		repo:      repo,
+		agentRepo: agentRepo,
+		sessions:  sessions,
+	}
+}
+
+// Matrix returns the latest result of every agent and target pair checked within maxAge
+func (s *PingService) Matrix(ctx context.Context, maxAge time.Duration) (*domain.PingMatrix, error) {
+	latest, err := s.repo.GetLatest(ctx, time.Now().Add(-maxAge))
+	if err != nil {
+		return nil, err
+	}
+	agents, err := s.agentRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Targets pointing at an agent are recognised by its hostname or addresses
+	byID := make(map[string]*domain.Agent, len(agents))
+	byHost := make(map[string]string)
+	for i := range agents {
+		agent := &agents[i]
+		byID[agent.ID] = agent
+		for _, host := range []string{agent.Hostname, agent.IPAddress, agentHostname(agent.Host)} {
+			if host != "" {
+				byHost[host] = agent.ID
+			}
+		}
+	}
+
+	matrix := &domain.PingMatrix{
+		Agents:      []domain.PingMatrixAgent{},
+		Targets:     []string{},
+		Cells:       make(map[string]map[string]domain.PingMatrixCell),
+		GeneratedAt: time.Now(),
+	}
+	targets := make(map[string]bool)
+	for _, result := range latest {
+		agent := byID[result.AgentID]
+		if agent == nil {
+			continue
+		}
+		row := matrix.Cells[agent.ID]
+		if row == nil {
+			row = make(map[string]domain.PingMatrixCell)
+			matrix.Cells[agent.ID] = row
+			matrix.Agents = append(matrix.Agents, domain.PingMatrixAgent{ID: agent.ID, Name: agent.Name, Status: agent.Status})
+		}
+
+		cell := domain.PingMatrixCell{PingResult: result.PingResult, TargetAgentID: byHost[result.Host]}
+		if cell.TargetAgentID == "" && result.Address != "" {
+			cell.TargetAgentID = byHost[result.Address]
+		}
+		row[result.Target] = cell
+
+		if !targets[result.Target] {
+			targets[result.Target] = true
+			matrix.Targets = append(matrix.Targets, result.Target)
+		}
+	}
+
+	sort.Slice(matrix.Agents, func(i, j int) bool {
+		return matrix.Agents[i].Name < matrix.Agents[j].Name
+	})
+	sort.Strings(matrix.Targets)
+	return matrix, nil
+}
+
+// agentHostname returns the host of an agent's "host:port" address
+func agentHostname(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// Traceroute asks an agent connected over gRPC to trace the route to a host. It returns
+// ErrNotFound for an unknown agent, ErrAgentNotConnected without a command channel and
+// ErrTracerouteFailed when the agent could not trace it.
+func (s *PingService) Traceroute(ctx context.Context, agentID string, req domain.TracerouteRequest) (*domain.TracerouteResult, error) {
+	agent, err := s.agentRepo.GetByID(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	// Every hop may wait its full timeout before the route ends
+	maxHops, timeout := req.MaxHops, time.Duration(req.TimeoutMs)*time.Millisecond
+	if maxHops <= 0 {
+		maxHops = probe.DefaultMaxHops
+	}
+	if timeout <= 0 {
+		timeout = probe.DefaultTracerouteTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(maxHops)*timeout+10*time.Second)
+	defer cancel()
+
+	args := map[string]string{"target": req.Target}
+	if req.MaxHops > 0 {
+		args["max_hops"] = strconv.Itoa(req.MaxHops)
+	}
+	if req.Probes > 0 {
+		args["probes"] = strconv.Itoa(req.Probes)
+	}
+	if req.TimeoutMs > 0 {
+		args["timeout_ms"] = strconv.Itoa(req.TimeoutMs)
+	}
+
+	reply, err := s.sessions.Send(ctx, agentID, domain.AgentCommand{Name: domain.AgentCommandTraceroute, Args: args})
+	if err != nil {
+		return nil, err
+	}
+	if !reply.Success {
+		return nil, fmt.Errorf("%w: %s", ErrTracerouteFailed, reply.Error)
+	}
+
+	var result domain.TracerouteResult
+	if err := json.Unmarshal([]byte(reply.Output), &result); err != nil {
+		return nil, fmt.Errorf("%w: invalid result: %v", ErrTracerouteFailed, err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+)
+
+// ServiceTracker stores the latest systemd unit states reported by agents
+// and records an event whenever a unit's active state changes
+type ServiceTracker struct {
+	repo domain.ServiceRepository
+}
+
+func NewServiceTracker(repo domain.ServiceRepository) *ServiceTracker {
+	return &ServiceTracker{
+		repo: repo,
+	}
+}
+
+// Track compares reported unit states against the stored ones
+func (t *ServiceTracker) Track(ctx context.Context, agentID string, services []domain.ServiceStatus) error {
+	if len(services) == 0 {
+		return nil
+	}
+
+	states, err := t.repo.GetStates(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]string, len(states))
+	for _, state := range states {
+		previous[state.Unit] = state.ActiveState
+	}
+
+	now := time.Now()
+	for _, status := range services {
+		if from, ok := previous[status.Unit]; !ok || from != status.ActiveState {
+			event := &domain.ServiceEvent{
+				AgentID:    agentID,
+				Unit:       status.Unit,
+				FromState:  from,
+				ToState:    status.ActiveState,
+				SubState:   status.SubState,
+				OccurredAt: now,
+			}
+			if err := t.repo.RecordEvent(ctx, event); err != nil {
+				return err
+			}
+			if status.ActiveState == domain.ServiceStateFailed {
+				log.Printf("🔥 Agent %s: unit %s failed (%s)", agentID, status.Unit, status.SubState)
+			}
+		}
+
+		if err := t.repo.SaveState(ctx, &domain.ServiceState{
+			AgentID:       agentID,
+			ServiceStatus: status,
+			UpdatedAt:     now,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
+)
+
+func TestServiceTrackerTransitions(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	registerAgent(t, db, "agent-1")
+	repo := sqlite.NewServiceRepository(db)
+	tracker := NewServiceTracker(repo)
+
+	report := func(states ...string) {
+		t.Helper()
+		services := []domain.ServiceStatus{
+			{Unit: "nginx.service", ActiveState: states[0], SubState: states[1]},
+			{Unit: "postgresql.service", ActiveState: "active", SubState: "running"},
+		}
+		if err := tracker.Track(ctx, "agent-1", services); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report("active", "running")
+	report("active", "running")
+	report("failed", "failed")
+	report("failed", "failed")
+	report("activating", "auto-restart")
+	report("active", "running")
+
+	// Nothing is recorded for an agent reporting no units
+	if err := tracker.Track(ctx, "agent-1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := repo.GetEvents(ctx, "agent-1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for i := len(events) - 1; i >= 0; i-- {
+		got = append(got, events[i].Unit+": "+events[i].FromState+" -> "+events[i].ToState+" ("+events[i].SubState+")")
+	}
+	want := []string{
+		"nginx.service:  -> active (running)",
+		"postgresql.service:  -> active (running)",
+		"nginx.service: active -> failed (failed)",
+		"nginx.service: failed -> activating (auto-restart)",
+		"nginx.service: activating -> active (running)",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events =\n%q\nwant\n%q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	states, err := repo.GetStates(ctx, "agent-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if state.ActiveState != "active" {
+			t.Errorf("%s is %s, want its latest state active", state.Unit, state.ActiveState)
+		}
+	}
+}
+
+func TestAgentAlertFailedUnits(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	agentRepo := sqlite.NewAgentRepository(db)
+	alertRepo := sqlite.NewAgentAlertRepository(db)
+	groupRepo := sqlite.NewGroupRepository(db)
+	url, received := webhook(t)
+
+	agent := &domain.Agent{ID: "agent-1", Name: "db-1", Host: "127.0.0.1", Status: "online", Tags: []string{"db"}, LastSeen: time.Now()}
+	if err := agentRepo.Register(ctx, agent); err != nil {
+		t.Fatal(err)
+	}
+	group := &domain.Group{
+		Name:          "databases",
+		Kind:          domain.GroupKindRole,
+		Selector:      &domain.GroupSelector{Tags: []string{"db"}},
+		AlertRules:    []domain.AgentAlertRule{{Name: "unit-failed", Metric: domain.AgentAlertMetricFailedUnits, Condition: domain.AlertConditionAbove, Threshold: 0}},
+		Notifications: []domain.NotificationRoute{{Name: "ops", URL: url}},
+	}
+	if err := groupRepo.Create(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	groups := NewGroupService(groupRepo, agentRepo, alertRepo, sqlite.NewAgentConfigRepository(db), nil)
+	alerts := NewAgentAlertService(groups, agentRepo, alertRepo, NewNotifier(time.Second), time.Minute)
+
+	at := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+	sample := func(states ...string) {
+		t.Helper()
+		var services []domain.ServiceStatus
+		for i, state := range states {
+			services = append(services, domain.ServiceStatus{Unit: []string{"postgresql.service", "pgbouncer.service"}[i], ActiveState: state})
+		}
+		at = at.Add(15 * time.Second)
+		metrics := &domain.AgentMetrics{AgentID: agent.ID, AgentName: agent.Name, Metrics: domain.SystemMetrics{Timestamp: at, Services: services}, ReceivedAt: at}
+		if err := agentRepo.SaveMetrics(ctx, metrics); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firing := func() []domain.AgentAlert {
+		t.Helper()
+		firing, err := alertRepo.GetAlerts(ctx, domain.AgentAlertQuery{AgentID: agent.ID, Status: domain.AlertStatusFiring})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return firing
+	}
+
+	sample("active", "active")
+	alerts.Evaluate(ctx)
+	if alerts := firing(); len(alerts) != 0 {
+		t.Fatalf("%d alerts firing with every unit active, want none", len(alerts))
+	}
+
+	sample("failed", "failed")
+	alerts.Evaluate(ctx)
+	if alerts := firing(); len(alerts) != 1 || alerts[0].Value != 2 {
+		t.Fatalf("firing alerts = %+v, want one with 2 failed units", alerts)
+	}
+	expectNotification(t, received, domain.NotificationKindAgentAlert, domain.AlertStatusFiring)
+
+	sample("active", "active")
+	alerts.Evaluate(ctx)
+	if alerts := firing(); len(alerts) != 0 {
+		t.Fatalf("%d alerts firing once the units recovered, want none", len(alerts))
+	}
+	expectNotification(t, received, domain.NotificationKindAgentAlert, domain.AlertStatusResolved)
+
+	// A sample without units leaves the rule unevaluated
+	sample()
+	alerts.Evaluate(ctx)
+	if alerts := firing(); len(alerts) != 0 {
+		t.Fatalf("%d alerts firing for a sample without units, want none", len(alerts))
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/statsd"
+)
+
+// StatsDServer listens for StatsD metrics on UDP and TCP and stores the
+// per-flush aggregates as custom metrics through the ingest service.
+// Metrics are attributed to the agent named by their agent_id or host tag,
+// falling back to the sender's IP address.
+type StatsDServer struct {
+	addr     string
+	interval time.Duration
+	ingest   *IngestService
+
+	mu         sync.Mutex
+	aggregator *statsd.Aggregator
+
+	udp      net.PacketConn
+	tcp      net.Listener
+	stopChan chan bool
+	wg       sync.WaitGroup
+}
+
+func NewStatsDServer(addr string, interval time.Duration, percentiles []float64, ingest *IngestService) *StatsDServer {
+	return &StatsDServer{
+		addr:       addr,
+		interval:   interval,
+		ingest:     ingest,
+		aggregator: statsd.NewAggregator(percentiles),
+		stopChan:   make(chan bool),
+	}
+}
+
+// Start opens the UDP and TCP listeners and begins the flush loop
+func (s *StatsDServer) Start() error {
+	udp, err := net.ListenPacket("udp", s.addr)
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	s.udp, s.tcp = udp, tcp
+
+	log.Printf("📈 StatsD listener started on %s (udp/tcp, flush: %s)", s.addr, s.interval)
+	s.wg.Add(3)
+	go s.serveUDP()
+	go s.serveTCP()
+	go s.flushLoop()
+	return nil
+}
+
+// Stop closes the listeners and flushes the pending metrics
+func (s *StatsDServer) Stop() {
+	log.Println("⏸️  Stopping StatsD listener...")
+	close(s.stopChan)
+	s.udp.Close()
+	s.tcp.Close()
+	s.wg.Wait()
+	log.Println("✓ StatsD listener stopped")
+}
+
+func (s *StatsDServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("⚠️  StatsD: UDP read failed - %v", err)
+			continue
+		}
+		s.handleLines(hostOf(addr), strings.Split(string(buf[:n]), "\n"))
+	}
+}
+
+func (s *StatsDServer) serveTCP() {
+	defer s.wg.Done()
+
+	var conns sync.WaitGroup
+	defer conns.Wait()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("⚠️  StatsD: TCP accept failed - %v", err)
+			continue
+		}
+
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+
+			// Unblock the reader on shutdown
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				select {
+				case <-s.stopChan:
+					conn.Close()
+				case <-done:
+				}
+			}()
+
+			source := hostOf(conn.RemoteAddr())
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				s.handleLines(source, []string{scanner.Text()})
+			}
+		}()
+	}
+}
+
+func (s *StatsDServer) handleLines(sender string, lines []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		m, err := statsd.Parse(line)
+		if err != nil {
+			log.Printf("⚠️  StatsD: %v", err)
+			continue
+		}
+
+		source := sender
+		if v := m.Tags["host"]; v != "" {
+			source = v
+		}
+		if v := m.Tags["agent_id"]; v != "" {
+			source = v
+		}
+		s.aggregator.Add(source, m)
+	}
+}
+
+func (s *StatsDServer) flushLoop() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stopChan:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *StatsDServer) flush() {
+	s.mu.Lock()
+	batches := s.aggregator.Flush(time.Now())
+	s.mu.Unlock()
+
+	if len(batches) == 0 {
+		return
+	}
+	if err := s.ingest.Ingest(context.Background(), domain.AgentTypeStatsD, batches); err != nil {
+		log.Printf("❌ StatsD: Failed to store metrics - %v", err)
+	}
+}
+
+// hostOf returns the IP of a network address
+func hostOf(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -1,34 +1,57 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v5"
 	"github.com/rafia9005/realtime-monitoring-server/internal/config"
+	agentgrpc "github.com/rafia9005/realtime-monitoring-server/internal/delivery/grpc"
 	"github.com/rafia9005/realtime-monitoring-server/internal/delivery/http"
 	"github.com/rafia9005/realtime-monitoring-server/internal/delivery/http/handler"
+	"github.com/rafia9005/realtime-monitoring-server/internal/delivery/http/middleware"
 	"github.com/rafia9005/realtime-monitoring-server/internal/domain"
+	"github.com/rafia9005/realtime-monitoring-server/internal/pkg/agentpb"
 	"github.com/rafia9005/realtime-monitoring-server/internal/repository/sqlite"
 	supabaseRepo "github.com/rafia9005/realtime-monitoring-server/internal/repository/supabase"
 	"github.com/rafia9005/realtime-monitoring-server/internal/service"
+	"google.golang.org/grpc"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
+	// "config check" validates the configuration and exits
+	args := os.Args[1:]
+	check := len(args) >= 2 && args[0] == "config" && args[1] == "check"
+	if check {
+		args = args[2:]
+	}
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML config file, reloaded on SIGHUP (default: "+config.DefaultFile+" if it exists); environment variables override it")
+	flags.Parse(args)
+
 	// Load .env file
-	if err := godotenv.Load(); err != nil {
+	if err := godotenv.Load(); err != nil && !check {
 		log.Println("Warning: .env file not found, using system environment variables")
 	}
 
+	if check {
+		os.Exit(checkConfig(*configFile))
+	}
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.Load(*configFile)
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Fatalf("Failed to load config:\n%v", err)
 	}
 	defer cfg.DB.Close()
 
@@ -40,37 +63,203 @@ func main() {
 
 	// Initialize repositories
 	var envMetricsRepo domain.EnvMetricsRepository
+	localEnvRepo := sqlite.NewEnvMetricsRepository(cfg.DB)
 
-	// Use Supabase for env_metrics if configured, otherwise use SQLite (for backward compatibility)
+	// Use Supabase for env_metrics if configured, mirroring readings recorded locally before;
+	// otherwise keep them in SQLite
+	var envSyncService *service.EnvSyncService
 	if cfg.SupabaseClient != nil {
 		log.Println("Using Supabase for env_metrics")
 		envMetricsRepo = supabaseRepo.NewEnvMetricsRepository(cfg.SupabaseClient)
+		envSyncService = service.NewEnvSyncService(localEnvRepo, sqlite.NewEnvSyncRepository(cfg.DB), envMetricsRepo, time.Minute)
+		envSyncService.Start()
 	} else {
-		log.Println("Warning: Supabase not configured, env_metrics will not be available")
-		// You could use a null repository or SQLite fallback here
-		envMetricsRepo = nil
+		log.Println("Supabase not configured, using SQLite for env_metrics")
+		envMetricsRepo = localEnvRepo
 	}
 
-	agentRepo := sqlite.NewAgentRepository(cfg.DB)
+	// The latest sample of every agent is kept in memory for the fleet summary
+	agentRepo := service.NewFleetCache(sqlite.NewAgentRepository(cfg.DB))
+	serviceRepo := sqlite.NewServiceRepository(cfg.DB)
+	customMetricRepo := sqlite.NewCustomMetricRepository(cfg.DB)
+	pingRepo := sqlite.NewPingRepository(cfg.DB)
+
+	// Process service states, plugin and ping results of every saved sample
+	serviceTracker := service.NewServiceTracker(serviceRepo)
+	metricsProcessor := service.NewMetricsProcessor(serviceTracker, customMetricRepo, pingRepo)
+
+	// Virtual agents fed by remote-write and OTLP save a sample at most every 15 seconds;
+	// StatsD and Influx application metrics are stored as custom metrics
+	ingestService := service.NewIngestService(agentRepo, customMetricRepo, metricsProcessor, 15*time.Second)
 
-	// Initialize agent poller (polls agents every 30 seconds)
-	poller := service.NewAgentPoller(agentRepo, 30*time.Second)
+	// Agents connected over gRPC; the poller skips those streaming their metrics
+	agentSessions := service.NewAgentSessions()
+
+	// Initialize agent poller (polls agents that do not push their metrics)
+	poller := service.NewAgentPoller(agentRepo, metricsProcessor, agentSessions, cfg.App.Agents.PollInterval, cfg.App.Agents.PullTimeout)
 	poller.Start()
 
+	// Optional StatsD listener for application metrics
+	var statsdServer *service.StatsDServer
+	if cfg.App.StatsD.Addr != "" {
+		statsdServer = service.NewStatsDServer(cfg.App.StatsD.Addr, cfg.App.StatsD.FlushInterval, cfg.App.StatsD.Percentiles, ingestService)
+		if err := statsdServer.Start(); err != nil {
+			log.Fatalf("Failed to start StatsD listener: %v", err)
+		}
+	}
+
+	// Sensor readings submitted over HTTP or MQTT, their history and threshold alerts
+	envAlertRepo := sqlite.NewEnvAlertRepository(cfg.DB)
+	envIngestService := service.NewEnvIngestService(envMetricsRepo, sqlite.NewIdempotencyRepository(cfg.DB))
+	envHistoryService := service.NewEnvHistoryService(envMetricsRepo)
+	envAlertService := service.NewEnvAlertService(envMetricsRepo, envAlertRepo, 30*time.Second)
+	envAlertService.Start()
+	if len(cfg.App.EnvMetrics.APIKeys) == 0 {
+		log.Println("Warning: ENV_METRICS_API_KEYS not set, POST /api/v1/env-metrics will reject all requests")
+	}
+
+	// Optional MQTT subscriber, connecting to an external broker or running an embedded one
+	var mqttSubscriber *service.MQTTSubscriber
+	if cfg.App.MQTT.Broker != "" || cfg.App.MQTT.EmbeddedAddr != "" {
+		mqttSubscriber = service.NewMQTTSubscriber(service.MQTTOptions{
+			Broker:       cfg.App.MQTT.Broker,
+			EmbeddedAddr: cfg.App.MQTT.EmbeddedAddr,
+			ClientID:     cfg.App.MQTT.ClientID,
+			Username:     cfg.App.MQTT.Username,
+			Password:     cfg.App.MQTT.Password,
+			Topics:       cfg.App.MQTT.Topics,
+			QoS:          cfg.App.MQTT.QoS,
+			APIKeys:      cfg.App.EnvMetrics.APIKeys,
+		}, envIngestService)
+		if err := mqttSubscriber.Start(); err != nil {
+			log.Fatalf("Failed to start MQTT subscriber: %v", err)
+		}
+	}
+
+	// Agent groups, the alerts their rules raise and the webhooks they are sent to
+	agentConfigRepo := sqlite.NewAgentConfigRepository(cfg.DB)
+	groupRepo := sqlite.NewGroupRepository(cfg.DB)
+	agentAlertRepo := sqlite.NewAgentAlertRepository(cfg.DB)
+	groupService := service.NewGroupService(groupRepo, agentRepo, agentAlertRepo, agentConfigRepo, agentSessions)
+	agentAlertService := service.NewAgentAlertService(groupService, agentRepo, agentAlertRepo, service.NewNotifier(10*time.Second), 30*time.Second)
+	agentAlertService.Start()
+
+	// Config documents stored for agents, groups and tags, merged into what each agent fetches
+	agentConfigService := service.NewAgentConfigService(agentConfigRepo, agentRepo, groupService, agentSessions)
+	agentService := service.NewAgentService(agentRepo, sqlite.NewAgentAuditRepository(cfg.DB), agentConfigService)
+
+	// Optional discovery of agents from network scans, target files and DNS SRV records
+	discoveryRepo := sqlite.NewDiscoveryRepository(cfg.DB)
+	discoveryService := service.NewDiscoveryService(service.DiscoveryOptions{
+		Interval:    cfg.App.Discovery.Interval,
+		Timeout:     cfg.App.Discovery.Timeout,
+		Concurrency: cfg.App.Discovery.Concurrency,
+		Approval:    cfg.App.Discovery.Approval,
+		DefaultTags: cfg.App.Discovery.DefaultTags,
+		CIDRs:       cfg.App.Discovery.Scan.CIDRs,
+		Ports:       cfg.App.Discovery.Scan.Ports,
+		Files:       cfg.App.Discovery.Files,
+		FileRefresh: cfg.App.Discovery.FileRefresh,
+		SRV:         cfg.App.Discovery.SRV,
+	}, agentRepo, discoveryRepo, agentService)
+	if discoveryService.Enabled() {
+		discoveryService.Start()
+	}
+
+	// Synthetic checks run from the server and from agents, with their uptime and alerts
+	checkRepo := sqlite.NewCheckRepository(cfg.DB)
+	checkScheduler := service.NewCheckScheduler(checkRepo, agentSessions, service.NewNotifier(10*time.Second))
+	if err := checkScheduler.Start(); err != nil {
+		log.Fatalf("Failed to start check scheduler: %v", err)
+	}
+	checkService := service.NewCheckService(checkRepo, agentRepo, checkScheduler)
+
+	// Latency matrix of the agents' ping results and traceroutes run on agents
+	pingService := service.NewPingService(pingRepo, agentRepo, agentSessions)
+
+	// Log lines shipped by agents, kept within the retention limits, with live tails
+	logService := service.NewLogService(sqlite.NewLogRepository(cfg.DB), agentRepo, cfg.App.Logs.Retention, cfg.App.Logs.MaxLinesPerAgent)
+	logService.Start()
+
+	// Optional gRPC agent service, alongside the HTTP agent API
+	var grpcServer *grpc.Server
+	grpcAdvertiseAddr := ""
+	if cfg.App.GRPC.Addr != "" {
+		listener, err := net.Listen("tcp", cfg.App.GRPC.Addr)
+		if err != nil {
+			log.Fatalf("Failed to start gRPC listener: %v", err)
+		}
+		grpcServer = grpc.NewServer(grpc.ForceServerCodec(agentpb.Codec{}))
+		agentpb.RegisterAgentServiceServer(grpcServer, agentgrpc.NewAgentServer(agentRepo, agentService, metricsProcessor, agentSessions))
+		grpcAdvertiseAddr = cfg.App.GRPC.AdvertiseAddr
+
+		go func() {
+			log.Printf("🔌 gRPC agent service listening on %s", listener.Addr())
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Printf("❌ gRPC server error: %v", err)
+			}
+		}()
+	}
+
 	// Setup graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	// Initialize handlers
-	systemMetricsHandler := handler.NewSystemMetricsHandler(envMetricsRepo)
-	terminalHandler := handler.NewTerminalHandler()
-	agentHandler := handler.NewAgentHandler(agentRepo)
+	handlers := &http.Handlers{
+		SystemMetrics: handler.NewSystemMetricsHandler(envMetricsRepo),
+		Terminal:      handler.NewTerminalHandler(cfg.App.Terminal.IdleTimeout, cfg.App.Terminal.CleanupInterval),
+		Agent:         handler.NewAgentHandler(agentRepo, agentService, metricsProcessor, agentSessions, grpcAdvertiseAddr, cfg.App.Agents.RegisterTimeout),
+		Service:       handler.NewServiceHandler(serviceRepo),
+		CustomMetric:  handler.NewCustomMetricHandler(customMetricRepo),
+		Prometheus:    handler.NewPrometheusHandler(agentRepo),
+		Ingest:        handler.NewIngestHandler(ingestService),
+		Sensor:        handler.NewSensorHandler(envMetricsRepo, agentRepo),
+		EnvMetrics:    handler.NewEnvMetricsHandler(envIngestService, envHistoryService),
+		EnvAlert:      handler.NewEnvAlertHandler(envAlertRepo, envMetricsRepo),
+		AgentConfig:   handler.NewAgentConfigHandler(agentConfigRepo, agentConfigService),
+		Group:         handler.NewGroupHandler(groupService, groupRepo, agentAlertRepo, agentRepo),
+		Fleet:         handler.NewFleetHandler(agentRepo),
+		Discovery:     handler.NewDiscoveryHandler(discoveryService, discoveryRepo),
+		Check:         handler.NewCheckHandler(checkService, checkRepo),
+		Ping:          handler.NewPingHandler(pingService, pingRepo),
+		Log:           handler.NewLogHandler(logService),
+	}
+
+	corsOrigins := middleware.NewCORSOrigins(cfg.App.CORS.AllowOrigins)
 
 	// Initialize Echo
 	e := echo.New()
 
 	// Setup routes
-	http.SetupRouter(e, systemMetricsHandler, terminalHandler, agentHandler)
+	http.SetupRouter(e, handlers, cfg.App.EnvMetrics.APIKeys, corsOrigins)
+
+	// Reload the config on SIGHUP, applying the agents, cors and terminal sections live
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		current := cfg.App
+		for range hangup {
+			next, err := config.LoadApp(*configFile)
+			if err != nil {
+				log.Printf("⚠️  Failed to reload config, keeping the current one:\n%v", err)
+				continue
+			}
+
+			poller.SetSchedule(next.Agents.PollInterval, next.Agents.PullTimeout)
+			handlers.Agent.SetRegisterTimeout(next.Agents.RegisterTimeout)
+			corsOrigins.Set(next.CORS.AllowOrigins)
+			handlers.Terminal.SetTimeouts(next.Terminal.IdleTimeout, next.Terminal.CleanupInterval)
+
+			if changed := current.RestartRequired(next); len(changed) > 0 {
+				log.Printf("🔁 Config reloaded; changes to %s take effect after a restart", strings.Join(changed, ", "))
+			} else {
+				log.Println("🔁 Config reloaded")
+			}
+			// Keep comparing against what is running, so restart-only changes are reported until restarted
+			current.Agents, current.CORS, current.Terminal = next.Agents, next.CORS, next.Terminal
+		}
+	}()
 
 	// Start server in a goroutine
 	go func() {
@@ -87,8 +276,65 @@ func main() {
 
 	// Stop the poller
 	poller.Stop()
+	if grpcServer != nil {
+		// Agent streams stay open indefinitely, so give in-flight calls a few seconds only
+		log.Println("⏸️  Stopping gRPC server...")
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			grpcServer.Stop()
+		}
+		log.Println("✓ gRPC server stopped")
+	}
+	if statsdServer != nil {
+		statsdServer.Stop()
+	}
+	if mqttSubscriber != nil {
+		mqttSubscriber.Stop()
+	}
+	envAlertService.Stop()
+	agentAlertService.Stop()
+	if discoveryService.Enabled() {
+		discoveryService.Stop()
+	}
+	checkScheduler.Stop()
+	logService.Stop()
+	if envSyncService != nil {
+		envSyncService.Stop()
+	}
 
 	// Close database
 	cfg.DB.Close()
 	log.Println("✓ Server stopped")
 }
+
+// checkConfig validates the configuration and prints the effective values, secrets masked.
+// It returns the process exit code.
+func checkConfig(path string) int {
+	app, err := config.LoadApp(path)
+	if err != nil {
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n")
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", strings.ReplaceAll(err.Error(), "\n", "\n    "))
+		}
+		return 1
+	}
+
+	out, err := yaml.Marshal(app.Redacted())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+	fmt.Print(string(out))
+	fmt.Println("Configuration is valid")
+	return 0
+}